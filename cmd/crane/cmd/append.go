@@ -43,7 +43,11 @@ provided tarballs.
 
 If the base image is a Windows base image (i.e., its config.OS is "windows"),
 the contents of the tarballs will be modified to be suitable for a Windows
-container image.`,
+container image.
+
+With --set-base-image-annotations, a Docker schema 2 image is converted to
+OCI, since Docker manifests can't hold annotations. This changes the media
+types in the manifest, and so the digest, of the result.`,
 		Args: cobra.NoArgs,
 		RunE: func(_ *cobra.Command, args []string) error {
 			var base v1.Image
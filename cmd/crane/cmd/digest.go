@@ -42,6 +42,15 @@ func NewCmdDigest(options *[]crane.Option) *cobra.Command {
 				return errors.New("cannot specify --full-ref with --tarball")
 			}
 
+			if tarball == "" {
+				// Resolve a truncated digest once, up front.
+				ref, err := crane.ResolveReference(args[0], *options...)
+				if err != nil {
+					return fmt.Errorf("parsing reference %q: %w", args[0], err)
+				}
+				args[0] = ref.String()
+			}
+
 			digest, err := getDigest(tarball, args, options)
 			if err != nil {
 				return err
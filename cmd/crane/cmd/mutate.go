@@ -17,12 +17,9 @@ package cmd
 import (
 	"errors"
 	"fmt"
-	"strings"
 
 	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/google/go-containerregistry/pkg/name"
-	v1 "github.com/google/go-containerregistry/pkg/v1"
-	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/spf13/cobra"
 )
 
@@ -70,58 +67,39 @@ func NewCmdMutate(options *[]crane.Option) *cobra.Command {
 					return fmt.Errorf("appending %v: %w", newLayers, err)
 				}
 			}
-			cfg, err := img.ConfigFile()
-			if err != nil {
-				return err
-			}
-			cfg = cfg.DeepCopy()
-
-			// Set labels.
-			if cfg.Config.Labels == nil {
-				cfg.Config.Labels = map[string]string{}
-			}
-
 			if err := validateKeyVals(labels); err != nil {
 				return err
 			}
-
-			for k, v := range labels {
-				cfg.Config.Labels[k] = v
-			}
-
 			if err := validateKeyVals(annotations); err != nil {
 				return err
 			}
 
-			// set envvars if specified
-			if err := setEnvVars(cfg, envVars); err != nil {
-				return err
+			var mutations []crane.MutateOption
+			for k, v := range labels {
+				mutations = append(mutations, crane.WithLabel(k, v))
+			}
+			for k, v := range envVars {
+				mutations = append(mutations, crane.WithEnv(k, v))
 			}
-
-			// Set entrypoint.
 			if len(entrypoint) > 0 {
-				cfg.Config.Entrypoint = entrypoint
-				cfg.Config.Cmd = nil // This matches Docker's behavior.
+				mutations = append(mutations, crane.WithEntrypoint(entrypoint...))
 			}
-
-			// Set cmd.
 			if len(cmd) > 0 {
-				cfg.Config.Cmd = cmd
+				mutations = append(mutations, crane.WithCmd(cmd...))
 			}
-
-			// Set user.
 			if len(user) > 0 {
-				cfg.Config.User = user
+				mutations = append(mutations, crane.WithUser(user))
+			}
+			for k, v := range annotations {
+				mutations = append(mutations, crane.WithAnnotation(k, v))
 			}
 
 			// Mutate and write image.
-			img, err = mutate.Config(img, cfg.Config)
+			img, err = crane.MutateImage(img, mutations...)
 			if err != nil {
-				return fmt.Errorf("mutating config: %w", err)
+				return err
 			}
 
-			img = mutate.Annotations(img, annotations).(v1.Image)
-
 			// If the new ref isn't provided, write over the original image.
 			// If that ref was provided by digest (e.g., output from
 			// another crane command), then strip that and push the
@@ -155,7 +133,7 @@ func NewCmdMutate(options *[]crane.Option) *cobra.Command {
 			return nil
 		},
 	}
-	mutateCmd.Flags().StringToStringVarP(&annotations, "annotation", "a", nil, "New annotations to add")
+	mutateCmd.Flags().StringToStringVarP(&annotations, "annotation", "a", nil, "New annotations to add. Annotating a Docker schema 2 image converts it to OCI, which changes its digest")
 	mutateCmd.Flags().StringToStringVarP(&labels, "label", "l", nil, "New labels to add")
 	mutateCmd.Flags().StringToStringVarP(&envVars, "env", "e", nil, "New envvar to add")
 	mutateCmd.Flags().StringSliceVar(&entrypoint, "entrypoint", nil, "New entrypoint to set")
@@ -177,31 +155,3 @@ func validateKeyVals(kvPairs map[string]string) error {
 	}
 	return nil
 }
-
-// setEnvVars override envvars in a config
-func setEnvVars(cfg *v1.ConfigFile, envVars map[string]string) error {
-	newEnv := make([]string, 0, len(cfg.Config.Env))
-	for _, old := range cfg.Config.Env {
-		split := strings.SplitN(old, "=", 2)
-		if len(split) != 2 {
-			return fmt.Errorf("invalid key value pair in config: %s", old)
-		}
-		// keep order so override if specified again
-		oldKey := split[0]
-		if v, ok := envVars[oldKey]; ok {
-			newEnv = append(newEnv, fmt.Sprintf("%s=%s", oldKey, v))
-			delete(envVars, oldKey)
-		} else {
-			newEnv = append(newEnv, old)
-		}
-	}
-	isWindows := cfg.OS == "windows"
-	for k, v := range envVars {
-		if isWindows {
-			k = strings.ToUpper(k)
-		}
-		newEnv = append(newEnv, fmt.Sprintf("%s=%s", k, v))
-	}
-	cfg.Config.Env = newEnv
-	return nil
-}
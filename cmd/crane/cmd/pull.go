@@ -44,10 +44,14 @@ func NewCmdPull(options *[]crane.Option) *cobra.Command {
 			srcList, path := args[:len(args)-1], args[len(args)-1]
 			o := crane.GetOptions(*options...)
 			for _, src := range srcList {
-				ref, err := name.ParseReference(src, o.Name...)
+				ref, err := crane.ResolveReference(src, *options...)
 				if err != nil {
 					return fmt.Errorf("parsing reference %q: %w", src, err)
 				}
+				if d, ok := ref.(name.Digest); ok {
+					// Key by the full digest if src was truncated.
+					src = d.String()
+				}
 
 				rmt, err := remote.Get(ref, o.Remote...)
 				if err != nil {
@@ -15,16 +15,12 @@
 package cmd
 
 import (
-	"errors"
 	"fmt"
 	"log"
 
 	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/google/go-containerregistry/pkg/logs"
 	"github.com/google/go-containerregistry/pkg/name"
-	v1 "github.com/google/go-containerregistry/pkg/v1"
-	"github.com/google/go-containerregistry/pkg/v1/mutate"
-	specsv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/spf13/cobra"
 )
 
@@ -35,7 +31,12 @@ func NewCmdRebase(options *[]crane.Option) *cobra.Command {
 	rebaseCmd := &cobra.Command{
 		Use:   "rebase",
 		Short: "Rebase an image onto a new base image",
-		Args:  cobra.MinimumNArgs(1),
+		Long: `Rebase an image onto a new base image.
+
+The rebased image is annotated with the new base image's name and digest. A
+Docker schema 2 image is converted to OCI to hold those annotations, which
+changes the media types in its manifest, and so its digest.`,
+		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if orig == "" {
 				orig = args[0]
@@ -75,30 +76,7 @@ func NewCmdRebase(options *[]crane.Option) *cobra.Command {
 			if err != nil {
 				return err
 			}
-			origMf, err := origImg.Manifest()
-			if err != nil {
-				return err
-			}
-			anns := origMf.Annotations
-			if newBase == "" && anns != nil {
-				newBase = anns[specsv1.AnnotationBaseImageName]
-			}
-			if newBase == "" {
-				return errors.New("could not determine new base image from annotations")
-			}
-			newBaseRef, err := name.ParseReference(newBase)
-			if err != nil {
-				return err
-			}
-			if oldBase == "" && anns != nil {
-				oldBaseDigest := anns[specsv1.AnnotationBaseImageDigest]
-				oldBase = newBaseRef.Context().Digest(oldBaseDigest).String()
-			}
-			if oldBase == "" {
-				return errors.New("could not determine old base image by digest from annotations")
-			}
-
-			rebasedImg, err := rebaseImage(origImg, oldBase, newBase, *options...)
+			rebasedImg, err := crane.RebaseImage(origImg, oldBase, newBase, *options...)
 			if err != nil {
 				return fmt.Errorf("rebasing image: %w", err)
 			}
@@ -134,77 +112,3 @@ func NewCmdRebase(options *[]crane.Option) *cobra.Command {
 	rebaseCmd.Flags().StringVarP(&rebased, "tag", "t", "", "Tag to apply to rebased image")
 	return rebaseCmd
 }
-
-// rebaseImage parses the references and uses them to perform a rebase on the
-// original image.
-//
-// If oldBase or newBase are "", rebaseImage attempts to derive them using
-// annotations in the original image. If those annotations are not found,
-// rebaseImage returns an error.
-//
-// If rebasing is successful, base image annotations are set on the resulting
-// image to facilitate implicit rebasing next time.
-func rebaseImage(orig v1.Image, oldBase, newBase string, opt ...crane.Option) (v1.Image, error) {
-	m, err := orig.Manifest()
-	if err != nil {
-		return nil, err
-	}
-	if newBase == "" && m.Annotations != nil {
-		newBase = m.Annotations[specsv1.AnnotationBaseImageName]
-		if newBase != "" {
-			logs.Debug.Printf("Detected new base from %q annotation: %s", specsv1.AnnotationBaseImageName, newBase)
-		}
-	}
-	if newBase == "" {
-		return nil, fmt.Errorf("either new base or %q annotation is required", specsv1.AnnotationBaseImageName)
-	}
-	newBaseImg, err := crane.Pull(newBase, opt...)
-	if err != nil {
-		return nil, err
-	}
-
-	if oldBase == "" && m.Annotations != nil {
-		oldBase = m.Annotations[specsv1.AnnotationBaseImageDigest]
-		if oldBase != "" {
-			newBaseRef, err := name.ParseReference(newBase)
-			if err != nil {
-				return nil, err
-			}
-
-			oldBase = newBaseRef.Context().Digest(oldBase).String()
-			logs.Debug.Printf("Detected old base from %q annotation: %s", specsv1.AnnotationBaseImageDigest, oldBase)
-		}
-	}
-	if oldBase == "" {
-		return nil, fmt.Errorf("either old base or %q annotation is required", specsv1.AnnotationBaseImageDigest)
-	}
-
-	oldBaseImg, err := crane.Pull(oldBase, opt...)
-	if err != nil {
-		return nil, err
-	}
-
-	// NB: if newBase is an index, we need to grab the index's digest to
-	// annotate the resulting image, even though we pull the
-	// platform-specific image to rebase.
-	// crane.Digest will pull a platform-specific image, so use crane.Head
-	// here instead.
-	newBaseDesc, err := crane.Head(newBase, opt...)
-	if err != nil {
-		return nil, err
-	}
-	newBaseDigest := newBaseDesc.Digest.String()
-
-	rebased, err := mutate.Rebase(orig, oldBaseImg, newBaseImg)
-	if err != nil {
-		return nil, err
-	}
-
-	// Update base image annotations for the new image manifest.
-	logs.Debug.Printf("Setting annotation %q: %q", specsv1.AnnotationBaseImageDigest, newBaseDigest)
-	logs.Debug.Printf("Setting annotation %q: %q", specsv1.AnnotationBaseImageName, newBase)
-	return mutate.Annotations(rebased, map[string]string{
-		specsv1.AnnotationBaseImageDigest: newBaseDigest,
-		specsv1.AnnotationBaseImageName:   newBase,
-	}).(v1.Image), nil
-}
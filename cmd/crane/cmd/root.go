@@ -41,7 +41,9 @@ var Root = New(use, short, []crane.Option{})
 func New(use, short string, options []crane.Option) *cobra.Command {
 	verbose := false
 	insecure := false
+	insecureAuth := false
 	ndlayers := false
+	resolvePrefix := false
 	platform := &platformValue{}
 
 	root := &cobra.Command{
@@ -59,9 +61,15 @@ func New(use, short string, options []crane.Option) *cobra.Command {
 			if insecure {
 				options = append(options, crane.Insecure)
 			}
+			if insecureAuth {
+				options = append(options, crane.WithAllowInsecureAuth())
+			}
 			if ndlayers {
 				options = append(options, crane.WithNondistributable())
 			}
+			if resolvePrefix {
+				options = append(options, crane.WithResolvePrefix())
+			}
 			if Version != "" {
 				binary := "crane"
 				if len(os.Args[0]) != 0 {
@@ -122,7 +130,9 @@ func New(use, short string, options []crane.Option) *cobra.Command {
 
 	root.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable debug logs")
 	root.PersistentFlags().BoolVar(&insecure, "insecure", false, "Allow image references to be fetched without TLS")
+	root.PersistentFlags().BoolVar(&insecureAuth, "allow-insecure-auth", false, "Allow credentials to be sent without TLS to registries used with --insecure")
 	root.PersistentFlags().BoolVar(&ndlayers, "allow-nondistributable-artifacts", false, "Allow pushing non-distributable (foreign) layers")
+	root.PersistentFlags().BoolVar(&resolvePrefix, "resolve-prefix", false, "Allow truncated digests (e.g. repo@sha256:1a2b3c), resolved by checking each of the repository's tags")
 	root.PersistentFlags().Var(platform, "platform", "Specifies the platform in the form os/arch[/variant][:osversion] (e.g. linux/amd64).")
 
 	return root
@@ -17,11 +17,8 @@ package compression
 
 import (
 	"bufio"
-	"bytes"
 	"io"
 
-	"github.com/google/go-containerregistry/internal/gzip"
-	"github.com/google/go-containerregistry/internal/zstd"
 	"github.com/google/go-containerregistry/pkg/compression"
 )
 
@@ -51,20 +48,8 @@ func GetCompression(opener Opener) (compression.Compression, error) {
 // PeekReader can be used as a replacement for the consumed input io.Reader.
 func PeekCompression(r io.Reader) (compression.Compression, PeekReader, error) {
 	pr := intoPeekReader(r)
-
-	if isGZip, _, err := checkHeader(pr, gzip.MagicHeader); err != nil {
-		return compression.None, pr, err
-	} else if isGZip {
-		return compression.GZip, pr, nil
-	}
-
-	if isZStd, _, err := checkHeader(pr, zstd.MagicHeader); err != nil {
-		return compression.None, pr, err
-	} else if isZStd {
-		return compression.ZStd, pr, nil
-	}
-
-	return compression.None, pr, nil
+	cp, _, err := compression.DetectCompression(pr)
+	return cp, pr, err
 }
 
 // PeekReader is an io.Reader that also implements Peek a la bufio.Reader.
@@ -82,16 +67,3 @@ func intoPeekReader(r io.Reader) PeekReader {
 
 	return bufio.NewReader(r)
 }
-
-// CheckHeader checks whether the first bytes from a PeekReader match an expected header
-func checkHeader(pr PeekReader, expectedHeader []byte) (bool, PeekReader, error) {
-	header, err := pr.Peek(len(expectedHeader))
-	if err != nil {
-		// https://github.com/google/go-containerregistry/issues/367
-		if err == io.EOF {
-			return false, pr, nil
-		}
-		return false, pr, err
-	}
-	return bytes.Equal(header, expectedHeader), pr, nil
-}
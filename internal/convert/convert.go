@@ -0,0 +1,82 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package convert maps the media types of an image manifest between Docker
+// schema 2 and OCI.
+package convert
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// ociLayerMediaTypes maps Docker layer media types to their OCI equivalents.
+var ociLayerMediaTypes = map[types.MediaType]types.MediaType{
+	types.DockerLayer:             types.OCILayer,
+	types.DockerUncompressedLayer: types.OCIUncompressedLayer,
+	types.DockerForeignLayer:      types.OCIRestrictedLayer,
+}
+
+// dockerLayerMediaTypes maps OCI layer media types to their Docker equivalents.
+var dockerLayerMediaTypes = map[types.MediaType]types.MediaType{
+	types.OCILayer:             types.DockerLayer,
+	types.OCIUncompressedLayer: types.DockerUncompressedLayer,
+	types.OCIRestrictedLayer:   types.DockerForeignLayer,
+}
+
+// IsConversion reports whether changing an image's manifest media type from
+// one to the other converts it between Docker schema 2 and OCI.
+func IsConversion(from, to types.MediaType) bool {
+	return (from == types.DockerManifestSchema2 && to == types.OCIManifestSchema1) ||
+		(from == types.OCIManifestSchema1 && to == types.DockerManifestSchema2)
+}
+
+// MediaTypes rewrites the media types of manifest, its config and its
+// layers to their equivalents for the manifest media type mt, which must be
+// Docker schema 2 or OCI. It returns the mapping applied to layer media types.
+func MediaTypes(manifest *v1.Manifest, mt types.MediaType) (map[types.MediaType]types.MediaType, error) {
+	var (
+		layerTypes         map[types.MediaType]types.MediaType
+		fromCfg, toCfg     types.MediaType
+		foreignLayerPrefix string
+	)
+	switch mt {
+	case types.OCIManifestSchema1:
+		layerTypes = ociLayerMediaTypes
+		fromCfg, toCfg = types.DockerConfigJSON, types.OCIConfigJSON
+		foreignLayerPrefix = "application/vnd.docker."
+	case types.DockerManifestSchema2:
+		layerTypes = dockerLayerMediaTypes
+		fromCfg, toCfg = types.OCIConfigJSON, types.DockerConfigJSON
+		foreignLayerPrefix = "application/vnd.oci."
+	default:
+		return nil, fmt.Errorf("unsupported media type %q: must be %q or %q", mt, types.OCIManifestSchema1, types.DockerManifestSchema2)
+	}
+
+	manifest.MediaType = mt
+	if manifest.Config.MediaType == fromCfg {
+		manifest.Config.MediaType = toCfg
+	}
+	for j, desc := range manifest.Layers {
+		if to, ok := layerTypes[desc.MediaType]; ok {
+			manifest.Layers[j].MediaType = to
+		} else if strings.HasPrefix(string(desc.MediaType), foreignLayerPrefix) {
+			return nil, fmt.Errorf("layer %s has media type %q, which has no equivalent in %q", desc.Digest, desc.MediaType, mt)
+		}
+	}
+	return layerTypes, nil
+}
@@ -58,6 +58,13 @@ type Predicate func(error) (retry bool)
 // exponential backoff. If the predicate is never satisfied, it will return the
 // last error returned by f.
 func Retry(f func() error, p Predicate, backoff wait.Backoff) (err error) {
+	return RetryWithContext(context.Background(), f, p, backoff)
+}
+
+// RetryWithContext is like Retry, but stops retrying as soon as ctx is done.
+// If that happens before f runs or while waiting to run it again, it returns
+// the context's error; otherwise it returns the last error returned by f.
+func RetryWithContext(ctx context.Context, f func() error, p Predicate, backoff wait.Backoff) (err error) {
 	if f == nil {
 		return fmt.Errorf("nil f passed to retry")
 	}
@@ -65,15 +72,24 @@ func Retry(f func() error, p Predicate, backoff wait.Backoff) (err error) {
 		return fmt.Errorf("nil p passed to retry")
 	}
 
+	ran := false
 	condition := func() (bool, error) {
-		err = f()
+		err, ran = f(), true
+		if ctx.Err() != nil {
+			// There's no point retrying once the context is done.
+			return true, err
+		}
 		if p(err) {
 			return false, nil
 		}
 		return true, err
 	}
 
-	wait.ExponentialBackoff(backoff, condition)
+	werr := wait.ExponentialBackoffWithContext(ctx, backoff, condition)
+	if !ran || (werr != nil && werr == ctx.Err()) {
+		// The context was done between attempts, so f's last error is stale.
+		return ctx.Err()
+	}
 	return
 }
 
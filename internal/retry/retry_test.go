@@ -16,10 +16,12 @@ package retry
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"testing"
+	"time"
 )
 
 type temp struct{}
@@ -98,3 +100,59 @@ func TestNil(t *testing.T) {
 		t.Errorf("got nil when passing in nil p")
 	}
 }
+
+func TestRetryWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	count := 0
+	f := func() error {
+		count++
+		cancel()
+		return fmt.Errorf("attempt %d", count)
+	}
+
+	// The backoff would take forever if we didn't stop when ctx is done.
+	backoff := Backoff{Duration: time.Hour, Steps: 5}
+	done := make(chan error)
+	go func() {
+		done <- RetryWithContext(ctx, f, IsNotNil, backoff)
+	}()
+	select {
+	case err := <-done:
+		if err == nil || err.Error() != "attempt 1" {
+			t.Errorf("RetryWithContext; got %v, want attempt 1", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RetryWithContext didn't stop after cancel")
+	}
+	if count != 1 {
+		t.Errorf("count; got %d, want 1", count)
+	}
+
+	// If the context is done while waiting to retry, its error is returned
+	// rather than f's.
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	retryable := func() error {
+		time.AfterFunc(10*time.Millisecond, cancel2)
+		return errors.New("retryable")
+	}
+	go func() {
+		done <- RetryWithContext(ctx2, retryable, IsNotNil, backoff)
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("RetryWithContext(canceled while waiting); got %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RetryWithContext didn't stop after cancel")
+	}
+
+	// If the context is already done, f is never called.
+	if err := RetryWithContext(ctx, f, IsNotNil, backoff); !errors.Is(err, context.Canceled) {
+		t.Errorf("RetryWithContext(canceled); got %v, want %v", err, context.Canceled)
+	}
+	if count != 1 {
+		t.Errorf("count; got %d, want 1", count)
+	}
+}
@@ -24,6 +24,11 @@ import (
 // Authenticator is used to authenticate Docker transports.
 type Authenticator interface {
 	// Authorization returns the value to use in an http transport's Authorization header.
+	//
+	// Transports may call Authorization again whenever a registry rejects
+	// their credentials, e.g. when a token expires during a long push, so
+	// implementations backed by short-lived credentials should return fresh
+	// ones on each call rather than caching the first result.
 	Authorization() (*AuthConfig, error)
 }
 
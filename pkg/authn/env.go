@@ -0,0 +1,68 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authn
+
+import (
+	"os"
+	"strings"
+)
+
+// envPrefix is the prefix of environment variables read by the keychain
+// returned by NewEnvKeychain.
+const envPrefix = "GGCR_REGISTRY_"
+
+// NewEnvKeychain returns a Keychain that resolves credentials from environment
+// variables named after the target registry:
+//
+//	GGCR_REGISTRY_<host>_USERNAME
+//	GGCR_REGISTRY_<host>_PASSWORD
+//
+// where <host> is the registry's RegistryStr() as normalized by EnvKeyForRegistry,
+// e.g. GGCR_REGISTRY_LOCALHOST_5000_USERNAME for localhost:5000. Note that
+// Docker Hub is resolved as index.docker.io.
+//
+// If neither variable is set for the target registry, Anonymous is returned,
+// so this composes with NewMultiKeychain.
+func NewEnvKeychain() Keychain { return envKeychain{} }
+
+type envKeychain struct{}
+
+// Resolve implements Keychain.
+func (envKeychain) Resolve(target Resource) (Authenticator, error) {
+	key := envPrefix + EnvKeyForRegistry(target.RegistryStr())
+	u, uok := os.LookupEnv(key + "_USERNAME")
+	p, pok := os.LookupEnv(key + "_PASSWORD")
+	if !uok && !pok {
+		return Anonymous, nil
+	}
+	return FromConfig(AuthConfig{Username: u, Password: p}), nil
+}
+
+// EnvKeyForRegistry returns the normalized form of registry used in the
+// environment variable names read by NewEnvKeychain. Letters are uppercased
+// and every character other than a letter or digit becomes an underscore,
+// so "my-registry.example.com:5000" becomes "MY_REGISTRY_EXAMPLE_COM_5000".
+func EnvKeyForRegistry(registry string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, registry)
+}
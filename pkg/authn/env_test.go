@@ -0,0 +1,101 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authn
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestEnvKeyForRegistry(t *testing.T) {
+	for _, tc := range []struct {
+		registry string
+		want     string
+	}{{
+		registry: "gcr.io",
+		want:     "GCR_IO",
+	}, {
+		registry: "localhost:5000",
+		want:     "LOCALHOST_5000",
+	}, {
+		registry: "my-registry.example.com:5000",
+		want:     "MY_REGISTRY_EXAMPLE_COM_5000",
+	}, {
+		registry: "index.docker.io",
+		want:     "INDEX_DOCKER_IO",
+	}} {
+		if got := EnvKeyForRegistry(tc.registry); got != tc.want {
+			t.Errorf("EnvKeyForRegistry(%q); got %q, want %q", tc.registry, got, tc.want)
+		}
+	}
+}
+
+func TestEnvKeychain(t *testing.T) {
+	t.Setenv("GGCR_REGISTRY_LOCALHOST_5000_USERNAME", "foo")
+	t.Setenv("GGCR_REGISTRY_LOCALHOST_5000_PASSWORD", "bar")
+	t.Setenv("GGCR_REGISTRY_INDEX_DOCKER_IO_USERNAME", "hub")
+	t.Setenv("GGCR_REGISTRY_INDEX_DOCKER_IO_PASSWORD", "secret")
+
+	for _, tc := range []struct {
+		reg  string
+		want Authenticator
+	}{{
+		reg:  "localhost:5000",
+		want: &Basic{Username: "foo", Password: "bar"},
+	}, {
+		reg:  name.DefaultRegistry,
+		want: &Basic{Username: "hub", Password: "secret"},
+	}, {
+		reg:  "gcr.io",
+		want: Anonymous,
+	}} {
+		reg, err := name.NewRegistry(tc.reg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := NewEnvKeychain().Resolve(reg)
+		if err != nil {
+			t.Fatalf("Resolve(%s) = %v", tc.reg, err)
+		}
+		if got != tc.want {
+			gotCfg, err := got.Authorization()
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantCfg, err := tc.want.Authorization()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *gotCfg != *wantCfg {
+				t.Errorf("Resolve(%s); got %+v, want %+v", tc.reg, gotCfg, wantCfg)
+			}
+		}
+	}
+
+	// The env keychain should compose with other keychains.
+	reg, err := name.NewRegistry("gcr.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixed := &Basic{Username: "fixed", Password: "secret"}
+	got, err := NewMultiKeychain(NewEnvKeychain(), fixedKeychain{reg: fixed}).Resolve(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != fixed {
+		t.Errorf("NewMultiKeychain(NewEnvKeychain(), ...).Resolve(); got %v, want %v", got, fixed)
+	}
+}
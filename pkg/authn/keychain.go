@@ -15,8 +15,12 @@
 package authn
 
 import (
+	"context"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/docker/cli/cli/config"
@@ -44,6 +48,26 @@ type Keychain interface {
 	Resolve(Resource) (Authenticator, error)
 }
 
+// ContextKeychain is an optional extension of Keychain for implementations
+// that perform network calls while resolving credentials (e.g. to a cloud
+// metadata server) and should honor cancellation and deadlines.
+type ContextKeychain interface {
+	Keychain
+
+	// ResolveContext looks up the most appropriate credential for the
+	// specified target, aborting if ctx is done.
+	ResolveContext(context.Context, Resource) (Authenticator, error)
+}
+
+// Resolve looks up a credential for target using kc, calling ResolveContext
+// if kc implements ContextKeychain and falling back to Resolve otherwise.
+func Resolve(ctx context.Context, kc Keychain, target Resource) (Authenticator, error) {
+	if ckc, ok := kc.(ContextKeychain); ok {
+		return ckc.ResolveContext(ctx, target)
+	}
+	return kc.Resolve(target)
+}
+
 // defaultKeychain implements Keychain with the semantics of the standard Docker
 // credential keychain.
 type defaultKeychain struct {
@@ -107,6 +131,8 @@ func (dk *defaultKeychain) Resolve(target Resource) (Authenticator, error) {
 		}
 	}
 
+	normalizeCredHelpers(cf)
+
 	// See:
 	// https://github.com/google/ko/issues/90
 	// https://github.com/moby/moby/blob/fc01c2b481097a6057bec3cd1ab2d7b4488c50c4/registry/config.go#L397-L404
@@ -144,6 +170,66 @@ func (dk *defaultKeychain) Resolve(target Resource) (Authenticator, error) {
 	}), nil
 }
 
+// normalizeCredHelpers adds a bare hostname key for every credHelpers entry
+// that is spelled as a URL (e.g. "https://gcr.io/" or
+// "https://index.docker.io/v1/"), since the Docker config package only looks
+// helpers up by exact key. Entries that are already spelled as a bare
+// hostname take precedence, then https over http URLs, then the first key in
+// sorted order.
+func normalizeCredHelpers(cf *configfile.ConfigFile) {
+	keys := make([]string, 0, len(cf.CredentialHelpers))
+	for key := range cf.CredentialHelpers {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if ri, rj := schemeRank(keys[i]), schemeRank(keys[j]); ri != rj {
+			return ri < rj
+		}
+		return keys[i] < keys[j]
+	})
+
+	normalized := map[string]string{}
+	for _, key := range keys {
+		host := credHelperHost(key)
+		if host == key || host == "" {
+			continue
+		}
+		if _, ok := cf.CredentialHelpers[host]; ok {
+			continue
+		}
+		if _, ok := normalized[host]; !ok {
+			normalized[host] = cf.CredentialHelpers[key]
+		}
+	}
+	for host, helper := range normalized {
+		cf.CredentialHelpers[host] = helper
+	}
+}
+
+// credHelperHost returns the host of a credHelpers key, which may be a bare
+// hostname or a URL, or "" if it can't be parsed.
+func credHelperHost(key string) string {
+	if !strings.Contains(key, "://") {
+		key = "//" + key
+	}
+	u, err := url.Parse(key)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+func schemeRank(key string) int {
+	switch {
+	case strings.HasPrefix(key, "https://"):
+		return 0
+	case strings.HasPrefix(key, "http://"):
+		return 1
+	default:
+		return 2
+	}
+}
+
 // fileExists returns true if the given path exists and is not a directory.
 func fileExists(path string) bool {
 	fi, err := os.Stat(path)
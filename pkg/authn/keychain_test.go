@@ -23,8 +23,10 @@ import (
 	"path"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"testing"
 
+	"github.com/docker/cli/cli/config/configfile"
 	"github.com/google/go-containerregistry/pkg/name"
 )
 
@@ -390,3 +392,139 @@ func TestConfigFileIsADir(t *testing.T) {
 		t.Errorf("expected Anonymous, got %v", auth)
 	}
 }
+
+func TestCredHelpers(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("credential helper script requires a POSIX shell")
+	}
+	bin := t.TempDir()
+	script := `#!/bin/sh
+read server
+case "$server" in
+helper.io) echo '{"ServerURL":"helper.io","Username":"foo","Secret":"bar"}' ;;
+token.io) echo '{"ServerURL":"token.io","Username":"<token>","Secret":"idtoken"}' ;;
+broken.io) echo "helper exploded"; exit 1 ;;
+*) echo "credentials not found in native keychain"; exit 1 ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(bin, "docker-credential-test"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	reg := func(s string) Resource {
+		r, err := name.NewRegistry(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	repo, err := name.NewRepository("helper.io/my-repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		desc    string
+		key     string
+		target  Resource
+		cfg     *AuthConfig
+		wantErr bool
+	}{{
+		desc:   "bare hostname",
+		key:    "helper.io",
+		target: reg("helper.io"),
+		cfg:    &AuthConfig{Username: "foo", Password: "bar"},
+	}, {
+		desc:   "scheme and trailing slash",
+		key:    "https://helper.io/",
+		target: reg("helper.io"),
+		cfg:    &AuthConfig{Username: "foo", Password: "bar"},
+	}, {
+		desc:   "path suffix",
+		key:    "https://helper.io/v1/",
+		target: reg("helper.io"),
+		cfg:    &AuthConfig{Username: "foo", Password: "bar"},
+	}, {
+		desc:   "http scheme, repository target",
+		key:    "http://helper.io",
+		target: repo,
+		cfg:    &AuthConfig{Username: "foo", Password: "bar"},
+	}, {
+		desc:   "identity token",
+		key:    "https://token.io",
+		target: reg("token.io"),
+		cfg:    &AuthConfig{IdentityToken: "idtoken"},
+	}, {
+		desc:   "credentials not found",
+		key:    "https://missing.io/",
+		target: reg("missing.io"),
+		cfg:    &AuthConfig{},
+	}, {
+		desc:    "helper error",
+		key:     "https://broken.io/",
+		target:  reg("broken.io"),
+		wantErr: true,
+	}} {
+		t.Run(test.desc, func(t *testing.T) {
+			setupConfigFile(t, fmt.Sprintf(`{"credHelpers": {%q: "test"}}`, test.key))
+
+			auth, err := DefaultKeychain.Resolve(test.target)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("wanted err, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve() = %v", err)
+			}
+			cfg, err := auth.Authorization()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cfg, test.cfg) {
+				t.Errorf("got %+v, want %+v", cfg, test.cfg)
+			}
+		})
+	}
+}
+
+func TestNormalizeCredHelpers(t *testing.T) {
+	for _, test := range []struct {
+		desc    string
+		helpers map[string]string
+		want    string
+	}{{
+		desc:    "bare hostname wins",
+		helpers: map[string]string{"helper.io": "bare", "https://helper.io/": "https", "http://helper.io": "http"},
+		want:    "bare",
+	}, {
+		desc:    "https wins over http",
+		helpers: map[string]string{"http://helper.io/": "http", "https://helper.io": "https"},
+		want:    "https",
+	}, {
+		desc:    "first key in sorted order",
+		helpers: map[string]string{"https://helper.io/v2/": "v2", "https://helper.io/": "root", "https://helper.io/v1/": "v1"},
+		want:    "root",
+	}, {
+		desc:    "path suffix",
+		helpers: map[string]string{"https://helper.io/v1/": "v1"},
+		want:    "v1",
+	}} {
+		t.Run(test.desc, func(t *testing.T) {
+			// Map iteration order is random, so try a few times.
+			for i := 0; i < 10; i++ {
+				helpers := map[string]string{}
+				for k, v := range test.helpers {
+					helpers[k] = v
+				}
+				cf := &configfile.ConfigFile{CredentialHelpers: helpers}
+				normalizeCredHelpers(cf)
+				if got := cf.CredentialHelpers["helper.io"]; got != test.want {
+					t.Fatalf("CredentialHelpers[helper.io] = %q, want %q", got, test.want)
+				}
+			}
+		})
+	}
+}
@@ -14,12 +14,14 @@
 
 package authn
 
+import "context"
+
 type multiKeychain struct {
 	keychains []Keychain
 }
 
-// Assert that our multi-keychain implements Keychain.
-var _ (Keychain) = (*multiKeychain)(nil)
+// Assert that our multi-keychain implements ContextKeychain.
+var _ (ContextKeychain) = (*multiKeychain)(nil)
 
 // NewMultiKeychain composes a list of keychains into one new keychain.
 func NewMultiKeychain(kcs ...Keychain) Keychain {
@@ -28,8 +30,14 @@ func NewMultiKeychain(kcs ...Keychain) Keychain {
 
 // Resolve implements Keychain.
 func (mk *multiKeychain) Resolve(target Resource) (Authenticator, error) {
+	return mk.ResolveContext(context.Background(), target)
+}
+
+// ResolveContext implements ContextKeychain, passing ctx to any of the
+// composed keychains that implement ContextKeychain.
+func (mk *multiKeychain) ResolveContext(ctx context.Context, target Resource) (Authenticator, error) {
 	for _, kc := range mk.keychains {
-		auth, err := kc.Resolve(target)
+		auth, err := Resolve(ctx, kc, target)
 		if err != nil {
 			return nil, err
 		}
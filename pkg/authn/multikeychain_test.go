@@ -15,6 +15,7 @@
 package authn
 
 import (
+	"context"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/name"
@@ -96,3 +97,53 @@ func (fk fixedKeychain) Resolve(target Resource) (Authenticator, error) {
 	}
 	return Anonymous, nil
 }
+
+type ctxKey struct{}
+
+// contextKeychain records the value of ctxKey seen by ResolveContext.
+type contextKeychain struct {
+	got *string
+}
+
+func (ck contextKeychain) Resolve(target Resource) (Authenticator, error) {
+	return ck.ResolveContext(context.Background(), target)
+}
+
+func (ck contextKeychain) ResolveContext(ctx context.Context, _ Resource) (Authenticator, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	*ck.got, _ = ctx.Value(ctxKey{}).(string)
+	return Anonymous, nil
+}
+
+func TestMultiKeychainContext(t *testing.T) {
+	reg, _ := name.NewRegistry("one.gcr.io", name.StrictValidation)
+	one := &Basic{Username: "one", Password: "secret"}
+
+	var got string
+	kc := NewMultiKeychain(contextKeychain{&got}, fixedKeychain{reg: one})
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "hello")
+	auth, err := Resolve(ctx, kc, reg)
+	if err != nil {
+		t.Fatalf("Resolve() = %v", err)
+	}
+	if auth != one {
+		t.Errorf("Resolve() = %v, wanted %v", auth, one)
+	}
+	if got != "hello" {
+		t.Errorf("ResolveContext saw %q, wanted %q", got, "hello")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Resolve(ctx, kc, reg); err == nil {
+		t.Error("Resolve() with cancelled context: expected error")
+	}
+
+	// Plain Keychains still work through Resolve.
+	if auth, err := Resolve(ctx, fixedKeychain{reg: one}, reg); err != nil || auth != one {
+		t.Errorf("Resolve(fixedKeychain) = %v, %v; wanted %v", auth, err, one)
+	}
+}
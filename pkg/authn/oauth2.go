@@ -0,0 +1,130 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authn
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// expiryMargin is how long before an access token expires that it's
+	// refreshed, so it doesn't expire mid-request.
+	expiryMargin = 30 * time.Second
+
+	// defaultExpiresIn is the lifetime assumed for tokens when the endpoint
+	// omits expires_in, per the Docker token authentication spec.
+	defaultExpiresIn = 60
+
+	// refreshTimeout bounds each request to the token endpoint, which is
+	// made while holding the lock that other callers wait on.
+	refreshTimeout = 30 * time.Second
+)
+
+// oauth2Authenticator implements Authenticator by exchanging an OAuth2
+// refresh token (a Docker "identity token") for short-lived access tokens.
+type oauth2Authenticator struct {
+	tokenURL string
+	clientID string
+	client   *http.Client
+
+	// mu protects the fields below. It's held while refreshing so that
+	// concurrent callers don't each spend the refresh token, which the
+	// endpoint may rotate.
+	mu           sync.Mutex
+	refreshToken string
+	accessToken  string
+	expiry       time.Time
+}
+
+// NewOAuth2Authenticator returns an Authenticator that exchanges refreshToken
+// at tokenURL for an access token on behalf of clientID.
+//
+// The access token is cached until shortly before it expires, as given by the
+// token endpoint's expires_in (or 60 seconds without it), and then refreshed
+// on the next call to Authorization. Transports call Authorization again when
+// a registry rejects a token, so long operations survive the token expiring.
+// A token that's rejected before then, e.g. because it was revoked, is still
+// returned until it nears expiry. If the endpoint rotates the refresh token,
+// the new one is used from then on.
+func NewOAuth2Authenticator(tokenURL, refreshToken, clientID string) Authenticator {
+	return &oauth2Authenticator{
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		client:       &http.Client{Timeout: refreshTimeout},
+		refreshToken: refreshToken,
+	}
+}
+
+// Authorization implements Authenticator.
+func (a *oauth2Authenticator) Authorization() (*AuthConfig, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.accessToken == "" || !time.Now().Add(expiryMargin).Before(a.expiry) {
+		if err := a.refresh(); err != nil {
+			return nil, err
+		}
+	}
+	return &AuthConfig{RegistryToken: a.accessToken}, nil
+}
+
+// refresh fetches a new access token. The caller must hold a.mu.
+func (a *oauth2Authenticator) refresh() error {
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", a.refreshToken)
+	if a.clientID != "" {
+		form.Set("client_id", a.clientID)
+	}
+	resp, err := a.client.PostForm(a.tokenURL, form)
+	if err != nil {
+		return fmt.Errorf("refreshing oauth2 token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// Include a little of the body, which usually explains the error.
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("refreshing oauth2 token: unexpected status %s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+
+	var response struct {
+		AccessToken  string `json:"access_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("decoding oauth2 token response: %w", err)
+	}
+	if response.AccessToken == "" {
+		return fmt.Errorf("refreshing oauth2 token: no access_token in response")
+	}
+
+	if response.ExpiresIn <= 0 {
+		response.ExpiresIn = defaultExpiresIn
+	}
+	a.accessToken = response.AccessToken
+	a.expiry = time.Now().Add(time.Duration(response.ExpiresIn) * time.Second)
+	if response.RefreshToken != "" {
+		a.refreshToken = response.RefreshToken
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authn
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOAuth2Authenticator(t *testing.T) {
+	var refreshes int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if got, want := r.PostForm.Get("grant_type"), "refresh_token"; got != want {
+			t.Errorf("grant_type: got %q, want %q", got, want)
+		}
+		if got, want := r.PostForm.Get("client_id"), "crane"; got != want {
+			t.Errorf("client_id: got %q, want %q", got, want)
+		}
+		// Each refresh token can only be used once, and is rotated.
+		if got, want := r.PostForm.Get("refresh_token"), fmt.Sprintf("refresh-%d", refreshes); got != want {
+			http.Error(w, "bad refresh_token", http.StatusBadRequest)
+			return
+		}
+		refreshes++
+		fmt.Fprintf(w, `{"access_token":"access-%d","expires_in":3600,"refresh_token":"refresh-%d"}`, refreshes, refreshes)
+	}))
+	defer s.Close()
+
+	auth := NewOAuth2Authenticator(s.URL, "refresh-0", "crane")
+	for i := 0; i < 3; i++ {
+		cfg, err := auth.Authorization()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := cfg.RegistryToken, "access-1"; got != want {
+			t.Errorf("RegistryToken: got %q, want %q", got, want)
+		}
+	}
+	if refreshes != 1 {
+		t.Errorf("refreshes: got %d, want 1", refreshes)
+	}
+
+	// Close to expiry, the token is refreshed with the rotated refresh token.
+	a := auth.(*oauth2Authenticator)
+	a.expiry = time.Now().Add(expiryMargin / 2)
+	cfg, err := auth.Authorization()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cfg.RegistryToken, "access-2"; got != want {
+		t.Errorf("RegistryToken after expiry: got %q, want %q", got, want)
+	}
+
+	if _, err := NewOAuth2Authenticator(s.URL, "stale", "crane").Authorization(); err == nil {
+		t.Error("Authorization() with bad refresh token: expected error")
+	}
+}
+
+func TestOAuth2AuthenticatorTimeout(t *testing.T) {
+	done := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer s.Close()
+	defer close(done)
+
+	auth := NewOAuth2Authenticator(s.URL, "refresh-0", "crane")
+	a := auth.(*oauth2Authenticator)
+	if a.client.Timeout != refreshTimeout {
+		t.Errorf("client.Timeout: got %v, want %v", a.client.Timeout, refreshTimeout)
+	}
+
+	// A hung token endpoint fails the refresh, rather than blocking callers.
+	a.client.Timeout = 10 * time.Millisecond
+	if _, err := auth.Authorization(); err == nil {
+		t.Error("Authorization() with hung endpoint: expected error")
+	}
+}
@@ -15,6 +15,16 @@
 // Package compression abstracts over gzip and zstd.
 package compression
 
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/google/go-containerregistry/internal/gzip"
+	"github.com/google/go-containerregistry/internal/zstd"
+)
+
 // Compression is an enumeration of the supported compression algorithms
 type Compression string
 
@@ -24,3 +34,39 @@ const (
 	GZip Compression = "gzip"
 	ZStd Compression = "zstd"
 )
+
+// peeker is implemented by e.g. *bufio.Reader.
+type peeker interface {
+	io.Reader
+	Peek(n int) ([]byte, error)
+}
+
+// DetectCompression sniffs the first bytes of r for the gzip or zstd magic
+// number and reports which Compression it uses, or None if neither matches.
+//
+// The returned io.Reader yields the full contents of r, including the bytes
+// that were inspected, and should be used in place of r. If r implements
+// Peek a la bufio.Reader, it is returned as-is.
+func DetectCompression(r io.Reader) (Compression, io.Reader, error) {
+	pr, ok := r.(peeker)
+	if !ok {
+		pr = bufio.NewReader(r)
+	}
+
+	n := len(gzip.MagicHeader)
+	if len(zstd.MagicHeader) > n {
+		n = len(zstd.MagicHeader)
+	}
+	header, err := pr.Peek(n)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return None, pr, err
+	}
+
+	switch {
+	case bytes.HasPrefix(header, gzip.MagicHeader):
+		return GZip, pr, nil
+	case bytes.HasPrefix(header, zstd.MagicHeader):
+		return ZStd, pr, nil
+	}
+	return None, pr, nil
+}
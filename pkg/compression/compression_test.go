@@ -0,0 +1,78 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestDetectCompression(t *testing.T) {
+	content := []byte("This is the input string.")
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	if _, err := gw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var zs bytes.Buffer
+	zw, err := zstd.NewWriter(&zs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := zw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		desc  string
+		input []byte
+		want  Compression
+	}{
+		{"gzip", gz.Bytes(), GZip},
+		{"zstd", zs.Bytes(), ZStd},
+		{"uncompressed", content, None},
+		{"short", []byte{0x1f}, None},
+		{"empty", nil, None},
+	} {
+		t.Run(c.desc, func(t *testing.T) {
+			got, r, err := DetectCompression(bytes.NewReader(c.input))
+			if err != nil {
+				t.Fatalf("DetectCompression() = %v", err)
+			}
+			if got != c.want {
+				t.Errorf("DetectCompression() = %q, want %q", got, c.want)
+			}
+			b, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, c.input) {
+				t.Errorf("returned reader lost bytes: got %d, want %d", len(b), len(c.input))
+			}
+		})
+	}
+}
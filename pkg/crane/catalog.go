@@ -22,6 +22,9 @@ import (
 )
 
 // Catalog returns the repositories in a registry's catalog.
+//
+// If the registry doesn't support listing repositories, the returned error
+// matches remote.ErrCatalogNotSupported.
 func Catalog(src string, opt ...Option) (res []string, err error) {
 	o := makeOptions(opt...)
 	reg, err := name.NewRegistry(src, o.Name...)
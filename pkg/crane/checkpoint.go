@@ -0,0 +1,281 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/logs"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"golang.org/x/sync/errgroup"
+)
+
+// checkpointFile is the serialized form of a checkpoint.
+type checkpointFile struct {
+	Destination string    `json:"destination"`
+	Manifests   []v1.Hash `json:"manifests,omitempty"`
+	Blobs       []v1.Hash `json:"blobs,omitempty"`
+}
+
+// checkpoint tracks the manifests and blobs that have been written to a
+// destination repository, persisting them to a file as they are added.
+// It is safe for concurrent use.
+type checkpoint struct {
+	path string
+	dst  name.Repository
+
+	mu        sync.Mutex
+	manifests map[v1.Hash]struct{}
+	blobs     map[v1.Hash]struct{}
+}
+
+func loadCheckpoint(path string, dst name.Repository) (*checkpoint, error) {
+	cp := &checkpoint{
+		path:      path,
+		dst:       dst,
+		manifests: map[v1.Hash]struct{}{},
+		blobs:     map[v1.Hash]struct{}{},
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var f checkpointFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("parsing checkpoint %s: %w", path, err)
+	}
+	if f.Destination != dst.String() {
+		logs.Warn.Printf("Ignoring checkpoint %s for %s, copying to %s", path, f.Destination, dst)
+		return cp, nil
+	}
+	for _, h := range f.Manifests {
+		cp.manifests[h] = struct{}{}
+	}
+	for _, h := range f.Blobs {
+		cp.blobs[h] = struct{}{}
+	}
+	logs.Progress.Printf("Resuming from checkpoint %s: %d manifests, %d blobs", path, len(cp.manifests), len(cp.blobs))
+	return cp, nil
+}
+
+func (cp *checkpoint) hasManifest(h v1.Hash) bool {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	_, ok := cp.manifests[h]
+	return ok
+}
+
+func (cp *checkpoint) hasBlob(h v1.Hash) bool {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	_, ok := cp.blobs[h]
+	return ok
+}
+
+// record adds the given manifest and blobs to the checkpoint and saves it.
+func (cp *checkpoint) record(manifest v1.Hash, blobs ...v1.Hash) error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.manifests[manifest] = struct{}{}
+	for _, h := range blobs {
+		cp.blobs[h] = struct{}{}
+	}
+	return cp.save()
+}
+
+// save writes the checkpoint to a temporary file and renames it into place,
+// so that a failure while saving doesn't corrupt an existing checkpoint.
+func (cp *checkpoint) save() error {
+	f := checkpointFile{
+		Destination: cp.dst.String(),
+		Manifests:   sortedHashes(cp.manifests),
+		Blobs:       sortedHashes(cp.blobs),
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(cp.path), filepath.Base(cp.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), cp.path)
+}
+
+func sortedHashes(set map[v1.Hash]struct{}) []v1.Hash {
+	hs := make([]v1.Hash, 0, len(set))
+	for h := range set {
+		hs = append(hs, h)
+	}
+	sort.Slice(hs, func(i, j int) bool { return hs[i].String() < hs[j].String() })
+	return hs
+}
+
+func copyIndexWithCheckpoint(idx v1.ImageIndex, src name.Repository, dstRef name.Reference, o Options) error {
+	cp, err := loadCheckpoint(o.checkpoint, dstRef.Context())
+	if err != nil {
+		return err
+	}
+	if o.progress != nil {
+		// Children are written concurrently, but o.progress needn't be safe
+		// for concurrent use.
+		var mu sync.Mutex
+		progress := o.progress
+		o.progress = func(update v1.Update) {
+			mu.Lock()
+			defer mu.Unlock()
+			progress(update)
+		}
+	}
+	if err := cp.writeIndex(idx, src, dstRef, o); err != nil {
+		return err
+	}
+	if err := os.Remove(o.checkpoint); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// writeIndex writes the children of idx that aren't in the checkpoint, up to
+// o.jobs at a time, recording each one as it completes, then writes idx
+// itself. Children that aren't images or indexes are copied from src as is.
+func (cp *checkpoint) writeIndex(idx v1.ImageIndex, src name.Repository, ref name.Reference, o Options) error {
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return err
+	}
+	g, ctx := errgroup.WithContext(o.ctx)
+	g.SetLimit(o.jobs)
+	for _, desc := range im.Manifests {
+		desc := desc
+		if cp.hasManifest(desc.Digest) {
+			logs.Progress.Print("checkpointed manifest: ", desc.Digest)
+			continue
+		}
+		g.Go(func() error {
+			// Don't start another child once one has failed.
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return cp.writeChild(idx, desc, src, ref.Context().Digest(desc.Digest.String()), o)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	if err := remote.Put(ref, idx, o.Remote...); err != nil {
+		return err
+	}
+	h, err := idx.Digest()
+	if err != nil {
+		return err
+	}
+	return cp.record(h)
+}
+
+// writeChild writes the child of idx described by desc to ref.
+func (cp *checkpoint) writeChild(idx v1.ImageIndex, desc v1.Descriptor, src name.Repository, ref name.Reference, o Options) error {
+	switch desc.MediaType {
+	case types.OCIImageIndex, types.DockerManifestList:
+		ii, err := idx.ImageIndex(desc.Digest)
+		if err != nil {
+			return err
+		}
+		return cp.writeIndex(ii, src, ref, o)
+	case types.OCIManifestSchema1, types.DockerManifestSchema2:
+		img, err := idx.Image(desc.Digest)
+		if err != nil {
+			return err
+		}
+		return cp.writeImage(img, ref, o)
+	default:
+		d, err := remote.Get(src.Digest(desc.Digest.String()), o.Remote...)
+		if err != nil {
+			return err
+		}
+		if err := remote.Put(ref, d, o.Remote...); err != nil {
+			return err
+		}
+		return cp.record(desc.Digest)
+	}
+}
+
+// writeImage writes img to ref, skipping layers that are in the checkpoint,
+// and records the image and its blobs when it completes.
+func (cp *checkpoint) writeImage(img v1.Image, ref name.Reference, o Options) error {
+	layers, err := img.Layers()
+	if err != nil {
+		return err
+	}
+	var pending []v1.Layer
+	blobs := []v1.Hash{}
+	for _, l := range layers {
+		h, err := l.Digest()
+		if err != nil {
+			return err
+		}
+		blobs = append(blobs, h)
+		if !cp.hasBlob(h) {
+			pending = append(pending, l)
+		}
+	}
+	if err := withProgress(o, func(opts []remote.Option) error {
+		return remote.Write(ref, &checkpointedImage{Image: img, layers: pending}, opts...)
+	}); err != nil {
+		return err
+	}
+
+	cfg, err := img.ConfigName()
+	if err != nil {
+		return err
+	}
+	h, err := img.Digest()
+	if err != nil {
+		return err
+	}
+	return cp.record(h, append(blobs, cfg)...)
+}
+
+// checkpointedImage is an image whose Layers only returns the layers that
+// still need to be written, so that remote.Write doesn't check for the
+// others. Its manifest still references all of them.
+type checkpointedImage struct {
+	v1.Image
+	layers []v1.Layer
+}
+
+func (i *checkpointedImage) Layers() ([]v1.Layer, error) {
+	return i.layers, nil
+}
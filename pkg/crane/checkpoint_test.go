@@ -0,0 +1,211 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestCopyWithCheckpoint(t *testing.T) {
+	idx, err := random.Index(1024, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	failing := "/v2/test/dst/manifests/" + im.Manifests[1].Digest.String()
+
+	reg := registry.New()
+	var (
+		mu   sync.Mutex
+		fail = true
+		seen []string
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v2/test/dst/") {
+			mu.Lock()
+			seen = append(seen, r.Method+" "+r.URL.Path)
+			failNow := fail && r.Method == http.MethodPut && r.URL.Path == failing
+			if failNow {
+				fail = false
+			}
+			mu.Unlock()
+			if failNow {
+				http.Error(w, "nope", http.StatusForbidden)
+				return
+			}
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := path.Join(u.Host, "test/src")
+	dst := path.Join(u.Host, "test/dst")
+	srcRef, err := name.ParseReference(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(srcRef, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	cp := filepath.Join(t.TempDir(), "checkpoint.json")
+	if err := crane.Copy(src, dst, crane.WithCheckpoint(cp)); err == nil {
+		t.Fatal("Copy() should have failed")
+	}
+	if _, err := os.Stat(cp); err != nil {
+		t.Fatalf("checkpoint not written: %v", err)
+	}
+
+	mu.Lock()
+	seen = nil
+	mu.Unlock()
+	if err := crane.Copy(src, dst, crane.WithCheckpoint(cp)); err != nil {
+		t.Fatalf("Copy() = %v", err)
+	}
+
+	// The first child was recorded in the checkpoint, so nothing about it
+	// should have been checked or written again.
+	first := im.Manifests[0].Digest.String()
+	img, err := idx.Image(im.Manifests[0].Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	skipped := []string{first}
+	for _, l := range layers {
+		d, err := l.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		skipped = append(skipped, d.String())
+	}
+	for _, req := range seen {
+		for _, h := range skipped {
+			if strings.HasSuffix(req, h) {
+				t.Errorf("unexpected request for checkpointed %s: %s", h, req)
+			}
+		}
+	}
+
+	if _, err := os.Stat(cp); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("checkpoint should be removed after a successful copy: %v", err)
+	}
+
+	want, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := crane.Digest(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want.String() {
+		t.Errorf("Digest(%s) = %s, want %s", dst, got, want)
+	}
+}
+
+type artifact struct {
+	raw []byte
+}
+
+func (a artifact) RawManifest() ([]byte, error) {
+	return a.raw, nil
+}
+
+func (a artifact) MediaType() (types.MediaType, error) {
+	return "application/vnd.example.artifact+json", nil
+}
+
+func (a artifact) Digest() (v1.Hash, error) {
+	h, _, err := v1.SHA256(bytes.NewReader(a.raw))
+	return h, err
+}
+
+func (a artifact) Size() (int64, error) {
+	return int64(len(a.raw)), nil
+}
+
+func TestCopyWithCheckpointUnknownChild(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := path.Join(u.Host, "test/src")
+	dst := path.Join(u.Host, "test/dst")
+	srcRef, err := name.ParseReference(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := artifact{raw: []byte(`{"schemaVersion":2,"mediaType":"application/vnd.example.artifact+json"}`)}
+	h, err := a.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Put(srcRef.Context().Digest(h.String()), a); err != nil {
+		t.Fatal(err)
+	}
+	base, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := mutate.AppendManifests(base, mutate.IndexAddendum{Add: a})
+	if err := remote.WriteIndex(srcRef, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	cp := filepath.Join(t.TempDir(), "checkpoint.json")
+	if err := crane.Copy(src, dst, crane.WithCheckpoint(cp), crane.WithJobs(2)); err != nil {
+		t.Fatalf("Copy() = %v", err)
+	}
+	got, err := crane.Manifest(dst + "@" + h.String())
+	if err != nil {
+		t.Fatalf("Manifest() = %v", err)
+	}
+	if !bytes.Equal(got, a.raw) {
+		t.Errorf("Manifest() = %s, want %s", got, a.raw)
+	}
+}
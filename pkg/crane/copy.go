@@ -15,11 +15,15 @@
 package crane
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/google/go-containerregistry/internal/legacy"
 	"github.com/google/go-containerregistry/pkg/logs"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/cache"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
@@ -27,7 +31,7 @@ import (
 // Copy copies a remote image or index from src to dst.
 func Copy(src, dst string, opt ...Option) error {
 	o := makeOptions(opt...)
-	srcRef, err := name.ParseReference(src, o.Name...)
+	srcRef, err := resolveReference(src, o)
 	if err != nil {
 		return fmt.Errorf("parsing reference %q: %w", src, err)
 	}
@@ -51,8 +55,26 @@ func Copy(src, dst string, opt ...Option) error {
 			if err := copyImage(desc, dstRef, o); err != nil {
 				return fmt.Errorf("failed to copy image: %w", err)
 			}
+			break
+		}
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return err
+		}
+		if o.platformFilter != nil {
+			if idx, err = filterPlatforms(idx, o); err != nil {
+				return err
+			}
+		}
+		if o.cache != nil {
+			idx = cache.ImageIndex(idx, o.cache)
+		}
+		if o.checkpoint != "" {
+			if err := copyIndexWithCheckpoint(idx, srcRef.Context(), dstRef, o); err != nil {
+				return fmt.Errorf("failed to copy index: %w", err)
+			}
 		} else {
-			if err := copyIndex(desc, dstRef, o); err != nil {
+			if err := copyIndex(idx, dstRef, o); err != nil {
 				return fmt.Errorf("failed to copy index: %w", err)
 			}
 		}
@@ -76,13 +98,112 @@ func copyImage(desc *remote.Descriptor, dstRef name.Reference, o Options) error
 	if err != nil {
 		return err
 	}
-	return remote.Write(dstRef, img, o.Remote...)
+	if o.cache != nil {
+		img = cache.Image(img, o.cache)
+	}
+	return withProgress(o, func(opts []remote.Option) error {
+		return remote.Write(dstRef, img, opts...)
+	})
 }
 
-func copyIndex(desc *remote.Descriptor, dstRef name.Reference, o Options) error {
-	idx, err := desc.ImageIndex()
+func copyIndex(idx v1.ImageIndex, dstRef name.Reference, o Options) error {
+	return withProgress(o, func(opts []remote.Option) error {
+		return remote.WriteIndex(dstRef, idx, opts...)
+	})
+}
+
+// referenceDigestAnnotation is set on attestation manifests in an index to
+// the digest of the image manifest they're attached to.
+const referenceDigestAnnotation = "vnd.docker.reference.digest"
+
+// filterPlatforms removes the children of idx that o.platformFilter rejects.
+// Children without a platform are kept only if o.keepUnknown is set and,
+// for attestations, the manifest they're attached to is kept too.
+func filterPlatforms(idx v1.ImageIndex, o Options) (v1.ImageIndex, error) {
+	im, err := idx.IndexManifest()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	unknown := func(desc v1.Descriptor) bool {
+		p := desc.Platform
+		return p == nil || (p.OS == "unknown" && p.Architecture == "unknown")
+	}
+	kept := map[string]bool{}
+	for _, desc := range im.Manifests {
+		if !unknown(desc) && o.platformFilter(*desc.Platform) {
+			kept[desc.Digest.String()] = true
+		}
+	}
+	if len(kept) == 0 {
+		return nil, errors.New("no children of the index match the platform filter")
+	}
+	drop := func(desc v1.Descriptor) bool {
+		if !unknown(desc) {
+			return !kept[desc.Digest.String()]
+		}
+		if !o.keepUnknown {
+			return true
+		}
+		if ref, ok := desc.Annotations[referenceDigestAnnotation]; ok {
+			return !kept[ref]
+		}
+		return false
+	}
+	return mutate.RemoveManifests(idx, drop), nil
+}
+
+// withProgress calls write with o.Remote, relaying remote progress updates to
+// o.progress if it is set.
+func withProgress(o Options, write func([]remote.Option) error) error {
+	if o.progress == nil {
+		return write(o.Remote)
+	}
+
+	var last v1.Update
+	relay := func(update v1.Update) {
+		if update.Error == nil {
+			last = update
+		}
+		o.progress(update)
+	}
+
+	// remote closes updates when it finishes writing, except for some early
+	// errors, so we also stop relaying once write returns. No updates are
+	// sent after that, so we only need to drain what is buffered.
+	updates := make(chan v1.Update, 100)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case update, ok := <-updates:
+				if !ok {
+					return
+				}
+				relay(update)
+			case <-stop:
+				for {
+					select {
+					case update, ok := <-updates:
+						if !ok {
+							return
+						}
+						relay(update)
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	opts := append(o.Remote[:len(o.Remote):len(o.Remote)], remote.WithProgress(updates))
+	err := write(opts)
+	close(stop)
+	<-done
+	if err == nil {
+		o.progress(v1.Update{Total: last.Total, Complete: last.Total})
 	}
-	return remote.WriteIndex(dstRef, idx, o.Remote...)
+	return err
 }
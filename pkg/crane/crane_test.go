@@ -17,27 +17,34 @@ package crane_test
 import (
 	"archive/tar"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
 	"path"
 	"strings"
+	"sync"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-containerregistry/internal/compare"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/registry"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/cache"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
 )
 
 // TODO(jonjohnsonjr): Test crane.Copy failures.
@@ -338,6 +345,21 @@ func TestWithPlatform(t *testing.T) {
 		t.Error("crane.Manifest(fake platform): got nil want err")
 	} else if !strings.Contains(err.Error(), arch) {
 		t.Errorf("crane.Manifest(fake platform): expected %q in error, got: %v", arch, err)
+	} else if want := "available platforms: linux/amd64, linux/arm"; !strings.Contains(err.Error(), want) {
+		t.Errorf("crane.Manifest(fake platform): expected %q in error, got: %v", want, err)
+	}
+
+	// Config resolves the platform too.
+	wantConfig, err := imgs[1].Add.(v1.Image).RawConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotConfig, err := crane.Config(src, crane.WithPlatform(imgs[1].Platform))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(gotConfig) != string(wantConfig) {
+		t.Errorf("Config(%q) = %s, want %s", src, gotConfig, wantConfig)
 	}
 }
 
@@ -412,6 +434,76 @@ func TestCraneSaveOCI(t *testing.T) {
 	}
 }
 
+func TestCranePullToLayout(t *testing.T) {
+	// Set up a fake registry.
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idxRef := fmt.Sprintf("%s/test/index", u.Host)
+	ref, err := name.ParseReference(idxRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(ref, idx); err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imgRef := fmt.Sprintf("%s/test/image", u.Host)
+	if err := crane.Push(img, imgRef); err != nil {
+		t.Fatal(err)
+	}
+
+	tmp := path.Join(t.TempDir(), "layout")
+	if err := crane.PullToLayout(idxRef, tmp); err != nil {
+		t.Fatalf("PullToLayout(%s): %v", idxRef, err)
+	}
+	if err := crane.PullToLayout(imgRef, tmp); err != nil {
+		t.Fatalf("PullToLayout(%s): %v", imgRef, err)
+	}
+
+	p, err := layout.FromPath(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ii, err := p.ImageIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := ii.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(m.Manifests), 2; got != want {
+		t.Fatalf("len(Manifests); got %d, want %d", got, want)
+	}
+
+	gotIdx, err := ii.ImageIndex(m.Manifests[0].Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := compare.Indexes(gotIdx, idx); err != nil {
+		t.Errorf("compare.Indexes: %v", err)
+	}
+	gotImg, err := ii.Image(m.Manifests[1].Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := compare.Images(gotImg, img); err != nil {
+		t.Errorf("compare.Images: %v", err)
+	}
+}
+
 func TestCraneFilesystem(t *testing.T) {
 	t.Parallel()
 	tmp, err := os.CreateTemp("", "")
@@ -572,3 +664,682 @@ func TestBadInputs(t *testing.T) {
 		}
 	}
 }
+
+func TestCopyWithProgress(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := fmt.Sprintf("%s/test/progress", u.Host)
+	dst := fmt.Sprintf("%s/test/progress/copy", u.Host)
+
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crane.Push(img, src); err != nil {
+		t.Fatal(err)
+	}
+
+	var updates []v1.Update
+	if err := crane.Copy(src, dst, crane.WithProgress(func(update v1.Update) {
+		updates = append(updates, update)
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(updates) < 2 {
+		t.Fatalf("got %d updates, want at least 2", len(updates))
+	}
+	final := updates[len(updates)-1]
+	if final.Total == 0 || final.Complete != final.Total {
+		t.Errorf("final update = %+v, want Complete == Total > 0", final)
+	}
+	for _, update := range updates {
+		if update.Error != nil {
+			t.Errorf("unexpected error update: %v", update.Error)
+		}
+	}
+}
+
+func TestCraneUserAgent(t *testing.T) {
+	reg := registry.New()
+	var uas []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uas = append(uas, r.Header.Get("User-Agent"))
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := path.Join(u.Host, "test/ua")
+	if err := crane.Push(img, ref, crane.WithUserAgent("tool/1.0")); err != nil {
+		t.Fatal(err)
+	}
+	if len(uas) == 0 {
+		t.Fatal("no requests")
+	}
+	for _, ua := range uas {
+		if want := "tool/1.0 go-containerregistry"; !strings.HasPrefix(ua, want) {
+			t.Errorf("User-Agent; got %q, want prefix %q", ua, want)
+		}
+	}
+}
+
+func TestCranePageSize(t *testing.T) {
+	reg := registry.New()
+	var pages []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/tags/list") || r.URL.Path == "/v2/_catalog" {
+			pages = append(pages, r.URL.Query().Get("n"))
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := path.Join(u.Host, "test/pages")
+	for _, tag := range []string{"a", "b", "c"} {
+		if err := crane.Push(img, repo+":"+tag); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tags, err := crane.ListTags(repo, crane.WithPageSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"a", "b", "c"}, tags); diff != "" {
+		t.Errorf("ListTags() (-want +got): %s", diff)
+	}
+	if _, err := crane.Catalog(u.Host, crane.WithPageSize(2)); err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) == 0 {
+		t.Fatal("no list requests")
+	}
+	for _, n := range pages {
+		if n != "2" {
+			t.Errorf("list request n = %q, want %q", n, "2")
+		}
+	}
+}
+
+func TestMultiPush(t *testing.T) {
+	reg := registry.New()
+	var mounts []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The in-memory registry shares blobs across repositories; pretend
+		// it doesn't, so test/b and test/c have to mount (or upload)
+		// everything.
+		if r.Method == http.MethodHead && (strings.HasPrefix(r.URL.Path, "/v2/test/b/blobs/") || strings.HasPrefix(r.URL.Path, "/v2/test/c/blobs/")) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodPost && r.URL.Query().Get("mount") != "" {
+			mounts = append(mounts, r.URL.Query().Get("mount")+"@"+r.URL.Query().Get("from"))
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := random.Layer(1024, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := mutate.AppendLayers(base, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := path.Join(u.Host, "test/a")
+	b := path.Join(u.Host, "test/b")
+	c := path.Join(u.Host, "test/c")
+	if err := crane.MultiPush(map[string]v1.Image{
+		a + ":base":  base,
+		b + ":image": img,
+		c + ":base":  base,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// test/b mounts the layers of base, and test/c its config too.
+	ls, err := base.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(mounts), 2*len(ls)+1; got != want {
+		t.Fatalf("mount attempts; got %d (%v), want %d", got, mounts, want)
+	}
+	for _, m := range mounts {
+		if !strings.HasSuffix(m, "@test/a") {
+			t.Errorf("mount %q; want from test/a", m)
+		}
+	}
+
+	for ref, want := range map[string]v1.Image{a + ":base": base, b + ":image": img, c + ":base": base} {
+		pulled, err := crane.Pull(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := compare.Images(pulled, want); err != nil {
+			t.Errorf("%s: %v", ref, err)
+		}
+	}
+}
+
+// cachedLayersImage returns the same slice from every call to Layers.
+type cachedLayersImage struct {
+	v1.Image
+	layers []v1.Layer
+}
+
+func (i *cachedLayersImage) Layers() ([]v1.Layer, error) {
+	return i.layers, nil
+}
+
+func TestMultiPushKeepsLayers(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ls, err := base.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := &cachedLayersImage{Image: base, layers: ls}
+	if err := crane.MultiPush(map[string]v1.Image{
+		path.Join(u.Host, "test/a") + ":base": img,
+		path.Join(u.Host, "test/b") + ":base": img,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range img.layers {
+		if _, ok := l.(*remote.MountableLayer); ok {
+			t.Errorf("layer of the pushed image was replaced with %T", l)
+		}
+	}
+}
+
+func TestRetag(t *testing.T) {
+	var layerGets, uploads int
+	var layerDigests map[string]bool
+	reg := registry.New()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && layerDigests[path.Base(r.URL.Path)] {
+			layerGets++
+		}
+		if r.Method == http.MethodPatch {
+			uploads++
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	layerDigests = map[string]bool{}
+	for _, l := range layers {
+		h, err := l.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		layerDigests[h.String()] = true
+	}
+	want, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := fmt.Sprintf("%s/test/src@%s", u.Host, want)
+	if err := crane.Push(img, src); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dst := range []string{
+		fmt.Sprintf("%s/test/src:same-repo", u.Host),
+		fmt.Sprintf("%s/test/release:v1", u.Host),
+	} {
+		layerGets, uploads = 0, 0
+		if err := crane.Retag(src, dst); err != nil {
+			t.Fatalf("Retag(%q): %v", dst, err)
+		}
+		got, err := crane.Digest(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want.String() {
+			t.Errorf("Digest(%q): got %s, want %s", dst, got, want)
+		}
+		if layerGets != 0 {
+			t.Errorf("Retag(%q) downloaded %d layers", dst, layerGets)
+		}
+		if uploads != 0 {
+			t.Errorf("Retag(%q) uploaded %d blobs", dst, uploads)
+		}
+	}
+
+	if err := crane.Retag(src, "example.com/test/release:v1"); err == nil {
+		t.Error("Retag across registries: expected error")
+	}
+}
+
+func TestCopyWithPlatformFilter(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var adds []mutate.IndexAddendum
+	digests := map[string]string{}
+	for _, p := range []v1.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64"},
+		{OS: "linux", Architecture: "s390x"},
+		{OS: "unknown", Architecture: "unknown"},
+	} {
+		p := p
+		img := platformImage(t, p.OS, p.Architecture)
+		d, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		digests[p.Architecture] = d.String()
+		adds = append(adds, mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: &p},
+		})
+	}
+	// Attestations for the amd64 and s390x images; only the first survives.
+	for _, arch := range []string{"amd64", "s390x"} {
+		adds = append(adds, mutate.IndexAddendum{
+			Add: platformImage(t, "unknown", "unknown"),
+			Descriptor: v1.Descriptor{
+				Platform:    &v1.Platform{OS: "unknown", Architecture: "unknown"},
+				Annotations: map[string]string{"vnd.docker.reference.digest": digests[arch]},
+			},
+		})
+	}
+	idx := mutate.AppendManifests(empty.Index, adds...)
+	src := fmt.Sprintf("%s/test/filter/src", u.Host)
+	if err := crane.PushIndex(idx, src); err != nil {
+		t.Fatal(err)
+	}
+
+	keep := func(p v1.Platform) bool {
+		return p.Architecture == "amd64" || p.Architecture == "arm64"
+	}
+	for _, tc := range []struct {
+		opts []crane.Option
+		want []string
+	}{{
+		opts: []crane.Option{crane.WithPlatformFilter(keep)},
+		want: []string{"linux/amd64", "linux/arm64"},
+	}, {
+		opts: []crane.Option{crane.WithPlatformFilter(keep), crane.WithUnknownPlatforms()},
+		want: []string{"linux/amd64", "linux/arm64", "unknown/unknown", "unknown/unknown -> " + digests["amd64"]},
+	}} {
+		dst := fmt.Sprintf("%s/test/filter/dst:%d", u.Host, len(tc.want))
+		if err := crane.Copy(src, dst, tc.opts...); err != nil {
+			t.Fatal(err)
+		}
+		ref, err := name.ParseReference(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		copied, err := remote.Index(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		im, err := copied.IndexManifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, desc := range im.Manifests {
+			p := desc.Platform.String()
+			if ref, ok := desc.Annotations["vnd.docker.reference.digest"]; ok {
+				p += " -> " + ref
+			}
+			got = append(got, p)
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("platforms: (-want +got) %s", diff)
+		}
+	}
+
+	none := func(v1.Platform) bool { return false }
+	if err := crane.Copy(src, fmt.Sprintf("%s/test/filter/none", u.Host), crane.WithPlatformFilter(none)); err == nil {
+		t.Error("Copy with a filter matching nothing: expected error")
+	}
+}
+
+func TestCopyWithSharedCache(t *testing.T) {
+	var mu sync.Mutex
+	layerGets := map[string]int{}
+	reg := registry.New()
+	src := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/blobs/") {
+			mu.Lock()
+			layerGets[path.Base(r.URL.Path)]++
+			mu.Unlock()
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer src.Close()
+	su, err := url.Parse(src.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcRef := fmt.Sprintf("%s/test/src", su.Host)
+	if err := crane.Push(img, srcRef); err != nil {
+		t.Fatal(err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	layerGets = map[string]int{}
+
+	c := cache.NewFilesystemCache(t.TempDir())
+	for i := 0; i < 2; i++ {
+		// Each destination is a separate registry, so blobs can't be mounted.
+		dst := httptest.NewServer(registry.New())
+		defer dst.Close()
+		du, err := url.Parse(dst.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dstRef := fmt.Sprintf("%s/test/dst", du.Host)
+		if err := crane.Copy(srcRef, dstRef, crane.WithSharedCache(c)); err != nil {
+			t.Fatal(err)
+		}
+		copied, err := crane.Pull(dstRef)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := validate.Image(copied); err != nil {
+			t.Error(err)
+		}
+	}
+
+	for _, l := range layers {
+		h, err := l.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := layerGets[h.String()]; got != 1 {
+			t.Errorf("layer %s fetched %d times, want 1", h, got)
+		}
+	}
+}
+
+func TestCopyToStore(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := random.Index(1024, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := fmt.Sprintf("%s/test/src:latest", u.Host)
+	ref, err := name.ParseReference(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(ref, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	blobs := registry.NewInMemoryBlobHandler().(registry.BlobPutHandler)
+	manifests := registry.NewInMemoryManifestHandler()
+	if err := crane.CopyToStore(src, blobs, manifests, "test/dst", "copied"); err != nil {
+		t.Fatalf("CopyToStore() = %v", err)
+	}
+
+	// A registry backed by the same stores serves the copy.
+	store := httptest.NewServer(registry.New(
+		registry.WithBlobHandler(blobs.(registry.BlobHandler)),
+		registry.WithManifestHandler(manifests),
+	))
+	defer store.Close()
+	su, err := url.Parse(store.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := name.ParseReference(su.Host + "/test/dst:copied")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := remote.Index(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Index(got); err != nil {
+		t.Errorf("validate.Index() = %v", err)
+	}
+	want, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := got.Digest(); err != nil {
+		t.Fatal(err)
+	} else if d != want {
+		t.Errorf("Digest() = %s, want %s", d, want)
+	}
+}
+
+func TestPullShortDigest(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := fmt.Sprintf("%s/test/crane:latest", u.Host)
+	if err := crane.Push(img, src); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	short := fmt.Sprintf("%s/test/crane@%s", u.Host, digest.String()[:len("sha256:")+12])
+
+	// Truncated digests are only resolved if asked to.
+	if _, err := crane.Pull(short); err == nil {
+		t.Errorf("Pull(%q) without WithResolvePrefix: expected error", short)
+	}
+
+	ref, err := crane.ResolveReference(short, crane.WithResolvePrefix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("%s/test/crane@%s", u.Host, digest); ref.String() != want {
+		t.Errorf("ResolveReference(%q) = %s, want %s", short, ref, want)
+	}
+
+	pulled, err := crane.Pull(short, crane.WithResolvePrefix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := pulled.Digest(); err != nil {
+		t.Fatal(err)
+	} else if got != digest {
+		t.Errorf("Pull(%q) digest = %s, want %s", short, got, digest)
+	}
+
+	if d, err := crane.Digest(short, crane.WithResolvePrefix()); err != nil {
+		t.Error(err)
+	} else if d != digest.String() {
+		t.Errorf("Digest(%q) = %s, want %s", short, d, digest)
+	}
+}
+
+func TestAllowInsecureAuth(t *testing.T) {
+	reg := registry.New()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "foo" || pass != "bar" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Resolve a non-loopback name to the test server.
+	tr := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, u.Host)
+		},
+	}
+	src := "registry.example.com:" + u.Port() + "/test/insecure"
+	opts := []crane.Option{
+		crane.Insecure,
+		crane.WithAuth(&authn.Basic{Username: "foo", Password: "bar"}),
+		crane.WithTransport(tr),
+	}
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crane.Push(img, src, opts...); err == nil || !strings.Contains(err.Error(), "plain HTTP") {
+		t.Errorf("Push() without WithAllowInsecureAuth = %v, want plain HTTP error", err)
+	}
+
+	opts = append(opts, crane.WithAllowInsecureAuth())
+	if err := crane.Push(img, src, opts...); err != nil {
+		t.Fatalf("Push() with WithAllowInsecureAuth = %v", err)
+	}
+	got, err := crane.Pull(src, opts...)
+	if err != nil {
+		t.Fatalf("Pull() with WithAllowInsecureAuth = %v", err)
+	}
+	if err := validate.Image(got); err != nil {
+		t.Errorf("validate.Image: %v", err)
+	}
+}
+
+func TestCopyWithSharedCacheMounts(t *testing.T) {
+	reg := registry.New()
+	var mu sync.Mutex
+	var posts []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if r.Method == http.MethodPost {
+			posts = append(posts, r.URL.RequestURI())
+		}
+		mu.Unlock()
+		// The registry shares blobs between repositories; have test/dst
+		// mount them rather than find them.
+		if r.Method == http.MethodHead && strings.HasPrefix(r.URL.Path, "/v2/test/dst/blobs/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := fmt.Sprintf("%s/test/src", u.Host)
+	if err := crane.Push(img, src); err != nil {
+		t.Fatal(err)
+	}
+	posts = nil
+
+	// Within a registry, layers are mounted rather than read through the cache.
+	dst := fmt.Sprintf("%s/test/dst", u.Host)
+	if err := crane.Copy(src, dst, crane.WithSharedCache(cache.NewFilesystemCache(t.TempDir()))); err != nil {
+		t.Fatal(err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range layers {
+		h, err := l.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		mounted := false
+		for _, p := range posts {
+			if strings.Contains(p, "mount="+url.QueryEscape(h.String())) {
+				mounted = true
+			}
+		}
+		if !mounted {
+			t.Errorf("layer %s was not mounted; POSTs: %v", h, posts)
+		}
+	}
+}
@@ -17,14 +17,15 @@ package crane
 import (
 	"fmt"
 
-	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 )
 
-// Delete deletes the remote reference at src.
+// Delete deletes the remote reference at src. See remote.Delete for how tags
+// are deleted on registries that only delete by digest, and for the error
+// returned when deletion is disabled.
 func Delete(src string, opt ...Option) error {
 	o := makeOptions(opt...)
-	ref, err := name.ParseReference(src, o.Name...)
+	ref, err := resolveReference(src, o)
 	if err != nil {
 		return fmt.Errorf("parsing reference %q: %w", src, err)
 	}
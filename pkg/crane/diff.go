@@ -0,0 +1,131 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+)
+
+// Changes describes the file-level differences between two images, as
+// returned by Diff. Each list is sorted by path.
+type Changes struct {
+	Added    []Change
+	Modified []Change
+	Deleted  []Change
+}
+
+// Change describes a single path that differs between two images.
+type Change struct {
+	Path string
+
+	// OldSize is the size of the path in the first image, zero if added.
+	OldSize int64
+	// NewSize is the size of the path in the second image, zero if deleted.
+	NewSize int64
+}
+
+// Diff compares the flattened filesystems of the remote images refA and refB.
+//
+// Regular files are considered modified if their contents differ; other
+// entries (e.g. symlinks) if their type or link target differs. Changes to
+// only file metadata, such as mode or ownership, are not reported.
+func Diff(refA, refB string, opt ...Option) (Changes, error) {
+	a, err := Pull(refA, opt...)
+	if err != nil {
+		return Changes{}, err
+	}
+	b, err := Pull(refB, opt...)
+	if err != nil {
+		return Changes{}, err
+	}
+	return DiffImages(a, b)
+}
+
+// DiffImages is like Diff, but compares two v1.Images.
+func DiffImages(a, b v1.Image) (Changes, error) {
+	before, err := fileManifest(a)
+	if err != nil {
+		return Changes{}, fmt.Errorf("reading first image: %w", err)
+	}
+	after, err := fileManifest(b)
+	if err != nil {
+		return Changes{}, fmt.Errorf("reading second image: %w", err)
+	}
+
+	var c Changes
+	for p, old := range before {
+		cur, ok := after[p]
+		if !ok {
+			c.Deleted = append(c.Deleted, Change{Path: p, OldSize: old.size})
+		} else if old != cur {
+			c.Modified = append(c.Modified, Change{Path: p, OldSize: old.size, NewSize: cur.size})
+		}
+	}
+	for p, cur := range after {
+		if _, ok := before[p]; !ok {
+			c.Added = append(c.Added, Change{Path: p, NewSize: cur.size})
+		}
+	}
+	for _, cs := range [][]Change{c.Added, c.Modified, c.Deleted} {
+		sort.Slice(cs, func(i, j int) bool { return cs[i].Path < cs[j].Path })
+	}
+	return c, nil
+}
+
+// fileEntry is what Diff compares for each path.
+type fileEntry struct {
+	typeflag byte
+	linkname string
+	size     int64
+	digest   v1.Hash
+}
+
+// fileManifest returns the entries of the flattened filesystem of img by path.
+func fileManifest(img v1.Image) (map[string]fileEntry, error) {
+	rc := mutate.Extract(img)
+	defer rc.Close()
+
+	files := map[string]fileEntry{}
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		e := fileEntry{
+			typeflag: hdr.Typeflag,
+			linkname: hdr.Linkname,
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			e.size = hdr.Size
+			e.digest, _, err = v1.SHA256(tr)
+			if err != nil {
+				return nil, err
+			}
+		}
+		files[hdr.Name] = e
+	}
+	return files, nil
+}
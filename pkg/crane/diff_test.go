@@ -0,0 +1,94 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+)
+
+func TestDiff(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := crane.Layer(map[string][]byte{
+		"a": []byte("1"),
+		"b": []byte("22"),
+		"c": []byte("333"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	top, err := crane.Layer(map[string][]byte{
+		// Same size, different contents.
+		"a":     []byte("9"),
+		".wh.b": nil,
+		"d":     []byte("4444"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := mutate.AppendLayers(empty.Image, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := mutate.AppendLayers(before, top)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refs := map[string]v1.Image{"before": before, "after": after}
+	for tag, img := range refs {
+		if err := crane.Push(img, fmt.Sprintf("%s/test/diff:%s", u.Host, tag)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := crane.Diff(u.Host+"/test/diff:before", u.Host+"/test/diff:after")
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	want := crane.Changes{
+		Added:    []crane.Change{{Path: "d", NewSize: 4}},
+		Modified: []crane.Change{{Path: "a", OldSize: 1, NewSize: 1}},
+		Deleted:  []crane.Change{{Path: "b", OldSize: 2}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Diff (-want +got): %s", diff)
+	}
+
+	// Identical images have no changes.
+	got, err = crane.DiffImages(after, after)
+	if err != nil {
+		t.Fatalf("DiffImages: %v", err)
+	}
+	if diff := cmp.Diff(crane.Changes{}, got); diff != "" {
+		t.Errorf("DiffImages (-want +got): %s", diff)
+	}
+}
@@ -14,7 +14,15 @@
 
 package crane
 
-import "github.com/google/go-containerregistry/pkg/logs"
+import (
+	"fmt"
+	"os"
+
+	"github.com/google/go-containerregistry/pkg/logs"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
 
 // Digest returns the sha256 hash of the remote image at ref.
 func Digest(ref string, opt ...Option) (string, error) {
@@ -50,3 +58,99 @@ func Digest(ref string, opt ...Option) (string, error) {
 	}
 	return desc.Digest.String(), nil
 }
+
+// DigestFile returns the sha256 hash of the image at path, without any
+// network access. path may be a tarball, as written by `docker save` or Save,
+// or an OCI image layout directory.
+//
+// A layout with a single manifest yields that manifest's digest, whether it
+// is an image or an index. For layouts with several manifests, or to select
+// an image from a multi-platform index, pass WithPlatform. Tarballs contain a
+// single image, so WithPlatform is ignored for them.
+func DigestFile(path string, opt ...Option) (string, error) {
+	o := makeOptions(opt...)
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !fi.IsDir() {
+		img, err := tarball.ImageFromPath(path, nil)
+		if err != nil {
+			return "", fmt.Errorf("loading image from %q: %w", path, err)
+		}
+		digest, err := img.Digest()
+		if err != nil {
+			return "", err
+		}
+		return digest.String(), nil
+	}
+
+	p, err := layout.FromPath(path)
+	if err != nil {
+		return "", err
+	}
+	idx, err := p.ImageIndex()
+	if err != nil {
+		return "", err
+	}
+	if o.Platform == nil {
+		im, err := idx.IndexManifest()
+		if err != nil {
+			return "", err
+		}
+		if len(im.Manifests) != 1 {
+			return "", fmt.Errorf("layout %q contains %d manifests; specify a platform", path, len(im.Manifests))
+		}
+		return im.Manifests[0].Digest.String(), nil
+	}
+
+	desc, err := childForPlatform(idx, *o.Platform)
+	if err != nil {
+		return "", err
+	}
+	if desc == nil {
+		return "", fmt.Errorf("no image for platform %s in layout %q", o.Platform, path)
+	}
+	return desc.Digest.String(), nil
+}
+
+// childForPlatform returns the descriptor of the first image in idx, or any
+// of its nested indexes, that satisfies platform. Images without a platform
+// in their descriptor are matched on the platform in their config file.
+func childForPlatform(idx v1.ImageIndex, platform v1.Platform) (*v1.Descriptor, error) {
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	for _, desc := range im.Manifests {
+		desc := desc
+		switch {
+		case desc.MediaType.IsIndex():
+			child, err := idx.ImageIndex(desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+			found, err := childForPlatform(child, platform)
+			if err != nil || found != nil {
+				return found, err
+			}
+		case desc.MediaType.IsImage():
+			p := desc.Platform
+			if p == nil {
+				img, err := idx.Image(desc.Digest)
+				if err != nil {
+					return nil, err
+				}
+				cf, err := img.ConfigFile()
+				if err != nil {
+					return nil, err
+				}
+				p = cf.Platform()
+			}
+			if p != nil && p.Satisfies(platform) {
+				return &desc, nil
+			}
+		}
+	}
+	return nil, nil
+}
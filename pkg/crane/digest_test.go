@@ -19,8 +19,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"path/filepath"
 	"testing"
 
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
@@ -59,3 +65,96 @@ func TestDigest_MissingDigest(t *testing.T) {
 		t.Errorf("Digest: expected GET to be called")
 	}
 }
+
+func TestDigestFile(t *testing.T) {
+	amd64, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arm64, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := arm64.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf = cf.DeepCopy()
+	cf.OS, cf.Architecture = "linux", "arm64"
+	if arm64, err = mutate.ConfigFile(arm64, cf); err != nil {
+		t.Fatal(err)
+	}
+	digest := func(img v1.Image) string {
+		t.Helper()
+		d, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d.String()
+	}
+
+	tmp := t.TempDir()
+
+	tarPath := filepath.Join(tmp, "image.tar")
+	if err := Save(amd64, "example.com/image:tag", tarPath); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := DigestFile(tarPath); err != nil {
+		t.Errorf("DigestFile(tarball) = %v", err)
+	} else if want := digest(amd64); got != want {
+		t.Errorf("DigestFile(tarball) = %s, want %s", got, want)
+	}
+
+	// The multi-platform index is nested, as written by PullToLayout.
+	idx := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{
+		Add: amd64,
+		Descriptor: v1.Descriptor{
+			Platform: &v1.Platform{OS: "linux", Architecture: "amd64"},
+		},
+	}, mutate.IndexAddendum{
+		// No platform on the descriptor; it's read from the config.
+		Add: arm64,
+	})
+	idxDigest, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	layoutPath := filepath.Join(tmp, "layout")
+	p, err := layout.Write(layoutPath, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AppendIndex(idx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		desc     string
+		platform *v1.Platform
+		want     string
+	}{
+		{"no platform", nil, idxDigest.String()},
+		{"descriptor platform", &v1.Platform{OS: "linux", Architecture: "amd64"}, digest(amd64)},
+		{"config platform", &v1.Platform{OS: "linux", Architecture: "arm64"}, digest(arm64)},
+	} {
+		got, err := DigestFile(layoutPath, WithPlatform(c.platform))
+		if err != nil {
+			t.Errorf("DigestFile(layout, %s) = %v", c.desc, err)
+		} else if got != c.want {
+			t.Errorf("DigestFile(layout, %s) = %s, want %s", c.desc, got, c.want)
+		}
+	}
+
+	if _, err := DigestFile(layoutPath, WithPlatform(&v1.Platform{OS: "windows", Architecture: "amd64"})); err == nil {
+		t.Error("DigestFile(layout) with missing platform: expected error")
+	}
+	if err := p.AppendImage(amd64); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DigestFile(layoutPath); err == nil {
+		t.Error("DigestFile(layout) with several manifests and no platform: expected error")
+	}
+	if _, err := DigestFile(filepath.Join(tmp, "missing")); err == nil {
+		t.Error("DigestFile(missing): expected error")
+	}
+}
@@ -19,17 +19,31 @@ import (
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
-// Export writes the filesystem contents (as a tarball) of img to w.
-// If img has a single layer, just write the (uncompressed) contents to w so
-// that this "just works" for images that just wrap a single blob.
+// Export writes the filesystem contents (as a tarball) of img to w, similar to
+// `docker export`: all layers are flattened into a single tar, with whiteouts
+// resolved according to overlayfs semantics (see mutate.Extract). This differs
+// from tarball.Write, which preserves the layers.
+//
+// If img has a single layer that isn't a filesystem layer, just write the
+// (uncompressed) contents to w so that this "just works" for images that just
+// wrap a single blob.
 func Export(img v1.Image, w io.Writer) error {
 	layers, err := img.Layers()
 	if err != nil {
 		return err
 	}
 	if len(layers) == 1 {
+		mt, err := layers[0].MediaType()
+		if err != nil {
+			return err
+		}
+		if isFilesystemLayer(mt) {
+			return extract(img, w)
+		}
+
 		// If it's a single layer, we don't have to flatten the filesystem.
 		// An added perk of skipping mutate.Extract here is that this works
 		// for non-tarball layers.
@@ -41,7 +55,24 @@ func Export(img v1.Image, w io.Writer) error {
 		_, err = io.Copy(w, rc)
 		return err
 	}
+	return extract(img, w)
+}
+
+func extract(img v1.Image, w io.Writer) error {
 	fs := mutate.Extract(img)
-	_, err = io.Copy(w, fs)
+	defer fs.Close()
+	_, err := io.Copy(w, fs)
 	return err
 }
+
+// isFilesystemLayer returns true if mt is the media type of a layer
+// containing a tar of filesystem changes, which may include whiteouts.
+func isFilesystemLayer(mt types.MediaType) bool {
+	switch mt {
+	case types.DockerLayer, types.DockerUncompressedLayer, types.DockerForeignLayer,
+		types.OCILayer, types.OCILayerZStd, types.OCIUncompressedLayer,
+		types.OCIRestrictedLayer, types.OCIRestrictedLayerZStd, types.OCIUncompressedRestrictedLayer:
+		return true
+	}
+	return false
+}
@@ -15,12 +15,20 @@
 package crane
 
 import (
+	"archive/tar"
 	"bytes"
+	"errors"
+	"io"
+	"sort"
+	"strings"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
@@ -39,3 +47,78 @@ func TestExport(t *testing.T) {
 		t.Errorf("got: %s\nwant: %s", got, want)
 	}
 }
+
+// tarLayer returns a layer containing the given paths. Paths ending in "/" are
+// directories, everything else is a file.
+func tarLayer(t *testing.T, paths ...string) v1.Layer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, p := range paths {
+		hdr := &tar.Header{Name: p, Mode: 0644, Typeflag: tar.TypeReg}
+		if strings.HasSuffix(p, "/") {
+			hdr.Typeflag = tar.TypeDir
+			hdr.Mode = 0755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	l, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func exportedNames(t *testing.T, img v1.Image) []string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Export(img, &buf); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestExportWhiteouts(t *testing.T) {
+	img, err := mutate.AppendLayers(empty.Image,
+		tarLayer(t, "a/", "a/keep", "a/gone", "b/", "b/old", "c/", "c/x"),
+		tarLayer(t, "a/.wh.gone", "b/.wh..wh..opq", "b/new", ".wh.c"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a", "a/keep", "b", "b/new"}
+	if d := cmp.Diff(want, exportedNames(t, img)); d != "" {
+		t.Errorf("Export() (-want +got): %s", d)
+	}
+}
+
+func TestExportSingleLayerWhiteouts(t *testing.T) {
+	img, err := mutate.AppendLayers(empty.Image, tarLayer(t, "a/", "a/.wh.gone", "a/keep"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a", "a/keep"}
+	if d := cmp.Diff(want, exportedNames(t, img)); d != "" {
+		t.Errorf("Export() (-want +got): %s", d)
+	}
+}
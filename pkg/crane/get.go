@@ -16,15 +16,46 @@ package crane
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 )
 
+// ResolveReference parses r like name.ParseReference. With WithResolvePrefix,
+// its digest may also be truncated, as in "example.com/repo@sha256:1a2b3c",
+// the way people tend to paste them. That's resolved against the tags of its
+// repository by remote.ResolveDigestPrefix, which is slow for repositories
+// with many tags.
+//
+// The functions in this package that fetch a reference from a registry, such
+// as Pull, Copy, Digest and Delete, resolve it the same way. References used
+// only as names, such as the keys passed to MultiSave or destinations, must
+// be complete.
+func ResolveReference(r string, opt ...Option) (name.Reference, error) {
+	return resolveReference(r, makeOptions(opt...))
+}
+
+func resolveReference(r string, o Options) (name.Reference, error) {
+	ref, err := name.ParseReference(r, o.Name...)
+	if err == nil || !o.resolvePrefix {
+		return ref, err
+	}
+	repo, prefix, ok := strings.Cut(r, "@")
+	if !ok {
+		return nil, err
+	}
+	rp, rerr := name.NewRepository(repo, o.Name...)
+	if rerr != nil {
+		return nil, err
+	}
+	return remote.ResolveDigestPrefix(rp, prefix, o.Remote...)
+}
+
 func getImage(r string, opt ...Option) (v1.Image, name.Reference, error) {
 	o := makeOptions(opt...)
-	ref, err := name.ParseReference(r, o.Name...)
+	ref, err := resolveReference(r, o)
 	if err != nil {
 		return nil, nil, fmt.Errorf("parsing reference %q: %w", r, err)
 	}
@@ -37,7 +68,7 @@ func getImage(r string, opt ...Option) (v1.Image, name.Reference, error) {
 
 func getManifest(r string, opt ...Option) (*remote.Descriptor, error) {
 	o := makeOptions(opt...)
-	ref, err := name.ParseReference(r, o.Name...)
+	ref, err := resolveReference(r, o)
 	if err != nil {
 		return nil, fmt.Errorf("parsing reference %q: %w", r, err)
 	}
@@ -48,7 +79,7 @@ func getManifest(r string, opt ...Option) (*remote.Descriptor, error) {
 // based on the registry's response.
 func Head(r string, opt ...Option) (*v1.Descriptor, error) {
 	o := makeOptions(opt...)
-	ref, err := name.ParseReference(r, o.Name...)
+	ref, err := resolveReference(r, o)
 	if err != nil {
 		return nil, err
 	}
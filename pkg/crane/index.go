@@ -0,0 +1,182 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane
+
+import (
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Index pulls each of refs and assembles them into a single OCI image index.
+//
+// A reference to an image contributes that image, with its platform inferred
+// from its config file. A reference to an index contributes each of its
+// images, using the platform from the index's descriptor if present and the
+// image's config file otherwise.
+//
+// It is an error for two images to have the same platform, unless
+// WithDeduplicate is passed, in which case the last one wins. Images without
+// a platform, or with the "unknown/unknown" platform (e.g. attestations),
+// are kept, except for attestations of an image that was replaced.
+//
+// The result can be pushed with PushIndex.
+func Index(refs []string, opt ...Option) (v1.ImageIndex, error) {
+	o := makeOptions(opt...)
+
+	var (
+		adds     []mutate.IndexAddendum
+		byPlat   = map[string]int{}
+		from     = map[string]string{}
+		replaced = map[string]bool{}
+	)
+	for _, r := range refs {
+		ref, err := resolveReference(r, o)
+		if err != nil {
+			return nil, fmt.Errorf("parsing reference %q: %w", r, err)
+		}
+		desc, err := remote.Get(ref, o.Remote...)
+		if err != nil {
+			return nil, fmt.Errorf("fetching %q: %w", r, err)
+		}
+
+		var children []mutate.IndexAddendum
+		if desc.MediaType.IsIndex() {
+			idx, err := desc.ImageIndex()
+			if err != nil {
+				return nil, err
+			}
+			children, err = indexChildren(idx)
+			if err != nil {
+				return nil, fmt.Errorf("reading index %q: %w", r, err)
+			}
+		} else {
+			img, err := desc.Image()
+			if err != nil {
+				return nil, err
+			}
+			child, err := imageAddendum(img)
+			if err != nil {
+				return nil, fmt.Errorf("reading image %q: %w", r, err)
+			}
+			children = []mutate.IndexAddendum{child}
+		}
+
+		for _, child := range children {
+			if p := child.Descriptor.Platform; p == nil || (p.OS == "unknown" && p.Architecture == "unknown") {
+				adds = append(adds, child)
+				continue
+			}
+			key := child.Descriptor.Platform.String()
+			if i, ok := byPlat[key]; ok {
+				if !o.deduplicate {
+					return nil, fmt.Errorf("duplicate platform %q in %q and %q", key, from[key], r)
+				}
+				replaced[adds[i].Descriptor.Digest.String()] = true
+				adds[i] = child
+			} else {
+				byPlat[key] = len(adds)
+				adds = append(adds, child)
+			}
+			from[key] = r
+		}
+	}
+
+	return mutate.AppendManifests(empty.Index, dropDanglingAttestations(adds, replaced)...), nil
+}
+
+// dropDanglingAttestations removes the attestations in adds that are attached
+// to one of the replaced images, unless that image is in adds after all.
+func dropDanglingAttestations(adds []mutate.IndexAddendum, replaced map[string]bool) []mutate.IndexAddendum {
+	if len(replaced) == 0 {
+		return adds
+	}
+	kept := map[string]bool{}
+	for _, add := range adds {
+		kept[add.Descriptor.Digest.String()] = true
+	}
+	out := adds[:0]
+	for _, add := range adds {
+		if ref, ok := add.Descriptor.Annotations[referenceDigestAnnotation]; ok && replaced[ref] && !kept[ref] {
+			continue
+		}
+		out = append(out, add)
+	}
+	return out
+}
+
+// indexChildren returns an addendum for every image in idx, recursing into
+// any nested indexes.
+func indexChildren(idx v1.ImageIndex) ([]mutate.IndexAddendum, error) {
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	var adds []mutate.IndexAddendum
+	for _, desc := range im.Manifests {
+		switch {
+		case desc.MediaType.IsIndex():
+			child, err := idx.ImageIndex(desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+			grandchildren, err := indexChildren(child)
+			if err != nil {
+				return nil, err
+			}
+			adds = append(adds, grandchildren...)
+		case desc.MediaType.IsImage():
+			img, err := idx.Image(desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+			add, err := imageAddendum(img)
+			if err != nil {
+				return nil, fmt.Errorf("reading image %s: %w", desc.Digest, err)
+			}
+			adds = append(adds, add)
+		}
+	}
+	return adds, nil
+}
+
+// imageAddendum returns an addendum for img with its descriptor (see
+// partial.ImageDescriptor), which for an image from an index is the index's
+// descriptor for it.
+func imageAddendum(img v1.Image) (mutate.IndexAddendum, error) {
+	desc, err := partial.ImageDescriptor(img)
+	if err != nil {
+		return mutate.IndexAddendum{}, err
+	}
+	return mutate.IndexAddendum{
+		Add:        img,
+		Descriptor: *desc,
+	}, nil
+}
+
+// PushIndex pushes the v1.ImageIndex idx to a registry as dst.
+func PushIndex(idx v1.ImageIndex, dst string, opt ...Option) error {
+	o := makeOptions(opt...)
+	ref, err := name.ParseReference(dst, o.Name...)
+	if err != nil {
+		return fmt.Errorf("parsing reference %q: %w", dst, err)
+	}
+	return remote.WriteIndex(ref, idx, o.Remote...)
+}
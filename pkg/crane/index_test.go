@@ -0,0 +1,257 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func platformImage(t *testing.T, goos, arch string) v1.Image {
+	t.Helper()
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf = cf.DeepCopy()
+	cf.OS, cf.Architecture = goos, arch
+	img, err = mutate.ConfigFile(img, cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestIndex(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	amd64 := platformImage(t, "linux", "amd64")
+	arm64 := platformImage(t, "linux", "arm64")
+	windows := platformImage(t, "windows", "amd64")
+	otherAMD64 := platformImage(t, "linux", "amd64")
+
+	// An index whose descriptor platform takes precedence over the config.
+	winIdx := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{
+		Add: windows,
+		Descriptor: v1.Descriptor{
+			Platform: &v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.1234"},
+		},
+	})
+
+	repo := fmt.Sprintf("%s/test/index", u.Host)
+	for tag, img := range map[string]v1.Image{"amd64": amd64, "arm64": arm64, "other": otherAMD64} {
+		if err := crane.Push(img, repo+":"+tag); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := crane.PushIndex(winIdx, repo+":windows"); err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := crane.Index([]string{repo + ":amd64", repo + ":arm64", repo + ":windows"})
+	if err != nil {
+		t.Fatalf("Index() = %v", err)
+	}
+	if err := validate.Index(idx); err != nil {
+		t.Errorf("validate.Index() = %v", err)
+	}
+	if err := crane.PushIndex(idx, repo+":multi"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]v1.Image{
+		"linux/amd64":                   amd64,
+		"linux/arm64":                   arm64,
+		"windows/amd64:10.0.17763.1234": windows,
+	}
+	desc, err := crane.Head(repo + ":multi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !desc.MediaType.IsIndex() {
+		t.Errorf("pushed media type = %s, wanted an index", desc.MediaType)
+	}
+	pushed, err := crane.Pull(repo+":multi", crane.WithPlatform(&v1.Platform{OS: "linux", Architecture: "arm64"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSameDigest(t, pushed, arm64)
+
+	im, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]v1.Hash{}
+	for _, desc := range im.Manifests {
+		got[desc.Platform.String()] = desc.Digest
+	}
+	wantDigests := map[string]v1.Hash{}
+	for p, img := range want {
+		d, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantDigests[p] = d
+	}
+	if diff := cmp.Diff(wantDigests, got); diff != "" {
+		t.Errorf("Index() manifests (-want +got): %s", diff)
+	}
+
+	if _, err := crane.Index([]string{repo + ":amd64", repo + ":other"}); err == nil {
+		t.Error("Index() with duplicate platforms: expected error")
+	}
+
+	idx, err = crane.Index([]string{repo + ":amd64", repo + ":other"}, crane.WithDeduplicate())
+	if err != nil {
+		t.Fatalf("Index(WithDeduplicate) = %v", err)
+	}
+	im, err = idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(im.Manifests) != 1 {
+		t.Fatalf("Index(WithDeduplicate) has %d manifests, wanted 1", len(im.Manifests))
+	}
+	if d, err := otherAMD64.Digest(); err != nil {
+		t.Fatal(err)
+	} else if im.Manifests[0].Digest != d {
+		t.Errorf("Index(WithDeduplicate) kept %s, wanted the last image %s", im.Manifests[0].Digest, d)
+	}
+}
+
+func TestIndexKeepsAttestations(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := fmt.Sprintf("%s/test/index", u.Host)
+
+	// Single-platform indexes, each with an attestation for its image. The
+	// last replaces the first when deduplicating.
+	var (
+		refs    []string
+		digests []v1.Hash
+	)
+	for i, arch := range []string{"amd64", "arm64", "amd64"} {
+		img := platformImage(t, "linux", arch)
+		att, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		idx := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{
+			Add: img,
+		}, mutate.IndexAddendum{
+			Add: att,
+			Descriptor: v1.Descriptor{
+				Platform: &v1.Platform{OS: "unknown", Architecture: "unknown"},
+				Annotations: map[string]string{
+					"vnd.docker.reference.digest": d.String(),
+					"vnd.docker.reference.type":   "attestation-manifest",
+				},
+			},
+		})
+		ref := fmt.Sprintf("%s:%s-%d", repo, arch, i)
+		if err := crane.PushIndex(idx, ref); err != nil {
+			t.Fatal(err)
+		}
+		refs = append(refs, ref)
+		digests = append(digests, d)
+	}
+
+	idx, err := crane.Index(refs[:2])
+	if err != nil {
+		t.Fatalf("Index() = %v", err)
+	}
+	if err := validate.Index(idx); err != nil {
+		t.Errorf("validate.Index() = %v", err)
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(im.Manifests) != 4 {
+		t.Fatalf("Index() has %d manifests, wanted 4", len(im.Manifests))
+	}
+	for _, desc := range im.Manifests {
+		if desc.Platform.OS == "unknown" && desc.Annotations["vnd.docker.reference.type"] != "attestation-manifest" {
+			t.Errorf("attestation %s lost its annotations: %v", desc.Digest, desc.Annotations)
+		}
+	}
+
+	// The attestation of the replaced image goes with it.
+	idx, err = crane.Index(refs, crane.WithDeduplicate())
+	if err != nil {
+		t.Fatalf("Index(WithDeduplicate) = %v", err)
+	}
+	if err := validate.Index(idx); err != nil {
+		t.Errorf("validate.Index() = %v", err)
+	}
+	im, err = idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(im.Manifests) != 4 {
+		t.Fatalf("Index(WithDeduplicate) has %d manifests, wanted 4", len(im.Manifests))
+	}
+	for _, desc := range im.Manifests {
+		if desc.Digest == digests[0] {
+			t.Errorf("replaced image %s was kept", desc.Digest)
+		}
+		if desc.Annotations["vnd.docker.reference.digest"] == digests[0].String() {
+			t.Errorf("attestation %s of replaced image %s was kept", desc.Digest, digests[0])
+		}
+	}
+}
+
+func assertSameDigest(t *testing.T, got, want v1.Image) {
+	t.Helper()
+	gd, err := got.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := want.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gd != wd {
+		t.Errorf("digest = %s, wanted %s", gd, wd)
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+)
+
+// MutateOption is a functional option for Mutate and MutateImage.
+type MutateOption func(*mutation)
+
+type mutation struct {
+	options []Option
+
+	labels      map[string]string
+	dropLabels  []string
+	env         [][2]string
+	entrypoint  []string
+	setEntry    bool
+	cmd         []string
+	setCmd      bool
+	user        *string
+	workdir     *string
+	annotations map[string]string
+}
+
+// WithPullOptions passes opt to Pull when Mutate fetches the image.
+func WithPullOptions(opt ...Option) MutateOption {
+	return func(m *mutation) {
+		m.options = append(m.options, opt...)
+	}
+}
+
+// WithLabel sets the label key to value.
+func WithLabel(key, value string) MutateOption {
+	return func(m *mutation) {
+		if m.labels == nil {
+			m.labels = map[string]string{}
+		}
+		m.labels[key] = value
+	}
+}
+
+// WithoutLabel deletes the label key, if it's set.
+func WithoutLabel(key string) MutateOption {
+	return func(m *mutation) {
+		m.dropLabels = append(m.dropLabels, key)
+	}
+}
+
+// WithEnv sets the environment variable key to value, replacing it in place
+// if it's already set and appending it otherwise. On Windows images, key is
+// upper-cased when appended.
+func WithEnv(key, value string) MutateOption {
+	return func(m *mutation) {
+		m.env = append(m.env, [2]string{key, value})
+	}
+}
+
+// WithEntrypoint sets the entrypoint, and like Docker clears the cmd.
+func WithEntrypoint(entrypoint ...string) MutateOption {
+	return func(m *mutation) {
+		m.entrypoint = entrypoint
+		m.setEntry = true
+	}
+}
+
+// WithCmd sets the cmd.
+func WithCmd(cmd ...string) MutateOption {
+	return func(m *mutation) {
+		m.cmd = cmd
+		m.setCmd = true
+	}
+}
+
+// WithUser sets the user.
+func WithUser(user string) MutateOption {
+	return func(m *mutation) {
+		m.user = &user
+	}
+}
+
+// WithWorkdir sets the working directory.
+func WithWorkdir(dir string) MutateOption {
+	return func(m *mutation) {
+		m.workdir = &dir
+	}
+}
+
+// WithAnnotation sets the manifest annotation key to value. Docker images are
+// converted to OCI, as described by mutate.Annotations.
+func WithAnnotation(key, value string) MutateOption {
+	return func(m *mutation) {
+		if m.annotations == nil {
+			m.annotations = map[string]string{}
+		}
+		m.annotations[key] = value
+	}
+}
+
+// Mutate pulls the image at ref and returns a copy with the edits described
+// by opts applied; see MutateImage. The image in the registry is untouched
+// until the result is pushed.
+func Mutate(ref string, opts ...MutateOption) (v1.Image, error) {
+	var m mutation
+	for _, o := range opts {
+		o(&m)
+	}
+	img, err := Pull(ref, m.options...)
+	if err != nil {
+		return nil, fmt.Errorf("pulling %s: %w", ref, err)
+	}
+	return m.apply(img)
+}
+
+// MutateImage returns a copy of img with the edits described by opts applied.
+//
+// Regardless of the order they're given in, edits are applied in this order:
+// labels are set, then deleted; environment variables are set; the
+// entrypoint, cmd, user and working directory are set; and finally the
+// manifest is annotated.
+func MutateImage(img v1.Image, opts ...MutateOption) (v1.Image, error) {
+	var m mutation
+	for _, o := range opts {
+		o(&m)
+	}
+	return m.apply(img)
+}
+
+func (m *mutation) apply(img v1.Image) (v1.Image, error) {
+	cf, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	cf = cf.DeepCopy()
+	cfg := &cf.Config
+
+	if len(m.labels) != 0 && cfg.Labels == nil {
+		cfg.Labels = map[string]string{}
+	}
+	for k, v := range m.labels {
+		cfg.Labels[k] = v
+	}
+	for _, k := range m.dropLabels {
+		delete(cfg.Labels, k)
+	}
+
+	if err := setEnv(cf, m.env); err != nil {
+		return nil, err
+	}
+
+	if m.setEntry {
+		cfg.Entrypoint = m.entrypoint
+		cfg.Cmd = nil // This matches Docker's behavior.
+	}
+	if m.setCmd {
+		cfg.Cmd = m.cmd
+	}
+	if m.user != nil {
+		cfg.User = *m.user
+	}
+	if m.workdir != nil {
+		cfg.WorkingDir = *m.workdir
+	}
+
+	img, err = mutate.Config(img, *cfg)
+	if err != nil {
+		return nil, fmt.Errorf("mutating config: %w", err)
+	}
+	if len(m.annotations) != 0 {
+		img = mutate.Annotations(img, m.annotations).(v1.Image)
+	}
+	return img, nil
+}
+
+// setEnv sets each key=value of env in cf, replacing existing variables in
+// place and appending new ones.
+func setEnv(cf *v1.ConfigFile, env [][2]string) error {
+	if len(env) == 0 {
+		return nil
+	}
+	idx := map[string]int{}
+	newEnv := make([]string, 0, len(cf.Config.Env)+len(env))
+	for _, old := range cf.Config.Env {
+		split := strings.SplitN(old, "=", 2)
+		if len(split) != 2 {
+			return fmt.Errorf("invalid key value pair in config: %s", old)
+		}
+		idx[split[0]] = len(newEnv)
+		newEnv = append(newEnv, old)
+	}
+	isWindows := cf.OS == "windows"
+	for _, kv := range env {
+		k, v := kv[0], kv[1]
+		if i, ok := idx[k]; ok {
+			newEnv[i] = fmt.Sprintf("%s=%s", k, v)
+			continue
+		}
+		if isWindows {
+			k = strings.ToUpper(k)
+		}
+		idx[k] = len(newEnv)
+		newEnv = append(newEnv, fmt.Sprintf("%s=%s", k, v))
+	}
+	cf.Config.Env = newEnv
+	return nil
+}
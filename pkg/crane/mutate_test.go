@@ -0,0 +1,146 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestMutate(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := fmt.Sprintf("%s/test/mutate", u.Host)
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf = cf.DeepCopy()
+	cf.Config.Env = []string{"PATH=/bin", "HOME=/root"}
+	cf.Config.Labels = map[string]string{"keep": "me", "drop": "me"}
+	cf.Config.Cmd = []string{"sh"}
+	img, err = mutate.ConfigFile(img, cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crane.Push(img, ref); err != nil {
+		t.Fatal(err)
+	}
+	before, err := crane.Digest(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := crane.Mutate(ref,
+		crane.WithAnnotation("org.example", "annotated"),
+		crane.WithLabel("new", "label"),
+		crane.WithoutLabel("drop"),
+		crane.WithEnv("HOME", "/home/user"),
+		crane.WithEnv("FOO", "bar"),
+		crane.WithCmd("--help"),
+		crane.WithEntrypoint("/app"),
+		crane.WithUser("nobody"),
+		crane.WithWorkdir("/work"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Image(got); err != nil {
+		t.Fatal(err)
+	}
+
+	gotCfg, err := got.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := v1.Config{
+		Env:        []string{"PATH=/bin", "HOME=/home/user", "FOO=bar"},
+		Labels:     map[string]string{"keep": "me", "new": "label"},
+		Entrypoint: []string{"/app"},
+		// The entrypoint clears the cmd before the cmd is set, whatever
+		// order the options were given in.
+		Cmd:        []string{"--help"},
+		User:       "nobody",
+		WorkingDir: "/work",
+	}
+	if diff := cmp.Diff(want, gotCfg.Config); diff != "" {
+		t.Errorf("Config: (-want +got) %s", diff)
+	}
+
+	m, err := got.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Annotations["org.example"], "annotated"; got != want {
+		t.Errorf("annotation: got %q, want %q", got, want)
+	}
+
+	// Nothing is written until the result is pushed.
+	after, err := crane.Digest(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before != after {
+		t.Errorf("Mutate changed %s: %s != %s", ref, before, after)
+	}
+	orig, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := orig.Config.Labels["drop"]; !ok {
+		t.Errorf("Mutate modified the original config: %v", orig.Config.Labels)
+	}
+}
+
+func TestMutateImageEntrypointClearsCmd(t *testing.T) {
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err = crane.MutateImage(img, crane.WithCmd("sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err = crane.MutateImage(img, crane.WithEntrypoint("/app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cf.Config.Cmd != nil {
+		t.Errorf("Cmd: got %v, want nil", cf.Config.Cmd)
+	}
+}
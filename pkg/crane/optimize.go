@@ -34,7 +34,7 @@ import (
 func Optimize(src, dst string, prioritize []string, opt ...Option) error {
 	pset := newStringSet(prioritize)
 	o := makeOptions(opt...)
-	srcRef, err := name.ParseReference(src, o.Name...)
+	srcRef, err := resolveReference(src, o)
 	if err != nil {
 		return fmt.Errorf("parsing reference %q: %w", src, err)
 	}
@@ -21,6 +21,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/cache"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 )
 
@@ -30,6 +31,16 @@ type Options struct {
 	Remote   []remote.Option
 	Platform *v1.Platform
 	Keychain authn.Keychain
+
+	progress       func(v1.Update)
+	checkpoint     string
+	deduplicate    bool
+	platformFilter func(v1.Platform) bool
+	keepUnknown    bool
+	cache          cache.Cache
+	ctx            context.Context
+	resolvePrefix  bool
+	jobs           int
 }
 
 // GetOptions exposes the underlying []remote.Option, []name.Option, and
@@ -46,6 +57,8 @@ func makeOptions(opts ...Option) Options {
 			remote.WithAuthFromKeychain(authn.DefaultKeychain),
 		},
 		Keychain: authn.DefaultKeychain,
+		ctx:      context.Background(),
+		jobs:     defaultJobs,
 	}
 	for _, o := range opts {
 		o(&opt)
@@ -69,6 +82,15 @@ func Insecure(o *Options) {
 	o.Name = append(o.Name, name.Insecure)
 }
 
+// WithAllowInsecureAuth is an Option that allows credentials to be sent over
+// plain HTTP to registries that are used without TLS, e.g. with Insecure.
+// See remote.WithAllowInsecureAuth.
+func WithAllowInsecureAuth() Option {
+	return func(o *Options) {
+		o.Remote = append(o.Remote, remote.WithAllowInsecureAuth())
+	}
+}
+
 // WithPlatform is an Option to specify the platform.
 func WithPlatform(platform *v1.Platform) Option {
 	return func(o *Options) {
@@ -104,13 +126,39 @@ func WithAuth(auth authn.Authenticator) Option {
 }
 
 // WithUserAgent adds the given string to the User-Agent header for any HTTP
-// requests.
+// requests. See remote.WithUserAgent.
 func WithUserAgent(ua string) Option {
 	return func(o *Options) {
 		o.Remote = append(o.Remote, remote.WithUserAgent(ua))
 	}
 }
 
+// WithPageSize sets the number of results requested per page when listing
+// tags or catalog repositories. See remote.WithPageSize.
+func WithPageSize(size int) Option {
+	return func(o *Options) {
+		o.Remote = append(o.Remote, remote.WithPageSize(size))
+	}
+}
+
+// defaultJobs matches the default parallelism of remote.
+const defaultJobs = 4
+
+// WithJobs sets the parallelism of remote operations, e.g. the number of
+// concurrent blob uploads. See remote.WithJobs. Copy with WithCheckpoint also
+// writes up to jobs children of an index at once.
+//
+// If jobs is not positive, the default value of 4 is used.
+func WithJobs(jobs int) Option {
+	return func(o *Options) {
+		if jobs <= 0 {
+			jobs = defaultJobs
+		}
+		o.jobs = jobs
+		o.Remote = append(o.Remote, remote.WithJobs(jobs))
+	}
+}
+
 // WithNondistributable is an option that allows pushing non-distributable
 // layers.
 func WithNondistributable() Option {
@@ -123,5 +171,86 @@ func WithNondistributable() Option {
 func WithContext(ctx context.Context) Option {
 	return func(o *Options) {
 		o.Remote = append(o.Remote, remote.WithContext(ctx))
+		o.ctx = ctx
+	}
+}
+
+// WithProgress sets a callback that receives progress updates as an image or
+// index is written by Copy. The callback is invoked periodically with the
+// bytes completed and total across all blobs, and once more on completion.
+func WithProgress(f func(v1.Update)) Option {
+	return func(o *Options) {
+		o.progress = f
+	}
+}
+
+// WithCheckpoint makes Copy of an index record its progress in a JSON file at
+// path: the digests of every child manifest and blob it has finished writing.
+// If Copy fails, calling it again with the same checkpoint skips the children
+// and blobs that were already written, instead of starting from scratch.
+// Blobs of partially written children are still checked for existence in the
+// destination before they are uploaded again.
+//
+// The checkpoint is discarded if it was recorded for a different destination
+// repository, and removed once the copy completes.
+func WithCheckpoint(path string) Option {
+	return func(o *Options) {
+		o.checkpoint = path
+	}
+}
+
+// WithDeduplicate makes Index keep the last of several images with the same
+// platform, instead of returning an error. Attestations of the images that
+// are replaced are dropped.
+func WithDeduplicate() Option {
+	return func(o *Options) {
+		o.deduplicate = true
+	}
+}
+
+// WithPlatformFilter makes Copy of an index copy only the children whose
+// platform satisfies keep, and write a new index of just those children to
+// the destination. That index has a different digest than the source.
+//
+// Children without a platform, or with the "unknown/unknown" platform that
+// e.g. attestations use, are dropped unless WithUnknownPlatforms is also
+// given. It is an error if no children are left. WithPlatform takes
+// precedence over this.
+func WithPlatformFilter(keep func(v1.Platform) bool) Option {
+	return func(o *Options) {
+		o.platformFilter = keep
+	}
+}
+
+// WithSharedCache makes Copy read layers through c, so that layers already
+// pulled by an earlier or concurrent Copy with the same c are served from it
+// rather than fetched from the source again. c may be shared by Copy calls
+// running in parallel; the caches in the cache package are safe for that.
+// A layer that two calls start pulling at the same time is fetched by both.
+// Layers are still mounted rather than read when the source and destination
+// are on the same registry.
+func WithSharedCache(c cache.Cache) Option {
+	return func(o *Options) {
+		o.cache = c
+	}
+}
+
+// WithUnknownPlatforms makes WithPlatformFilter keep children without a
+// platform, or with the "unknown/unknown" platform, rather than drop them.
+// Attestations are still dropped along with the image they're attached to,
+// which is named by their "vnd.docker.reference.digest" annotation.
+func WithUnknownPlatforms() Option {
+	return func(o *Options) {
+		o.keepUnknown = true
+	}
+}
+
+// WithResolvePrefix makes the functions that fetch a reference from a
+// registry, such as Pull, Copy and Digest, accept a truncated digest like
+// "registry.example.com/repo@sha256:1a2b3c". See remote.WithResolvePrefix.
+func WithResolvePrefix() Option {
+	return func(o *Options) {
+		o.Remote = append(o.Remote, remote.WithResolvePrefix())
+		o.resolvePrefix = true
 	}
 }
@@ -35,7 +35,7 @@ const iWasADigestTag = "i-was-a-digest"
 // Pull returns a v1.Image of the remote image src.
 func Pull(src string, opt ...Option) (v1.Image, error) {
 	o := makeOptions(opt...)
-	ref, err := name.ParseReference(src, o.Name...)
+	ref, err := resolveReference(src, o)
 	if err != nil {
 		return nil, fmt.Errorf("parsing reference %q: %w", src, err)
 	}
@@ -78,6 +78,42 @@ func MultiSave(imgMap map[string]v1.Image, path string, opt ...Option) error {
 	return tarball.MultiWriteToFile(path, tagToImage)
 }
 
+// PullToLayout pulls the remote image or index src into an OCI Image Layout at
+// path, creating the layout if it doesn't exist. If src refers to an index and
+// WithPlatform hasn't been set, the entire index is preserved; otherwise the
+// matching image is appended. Existing entries in the layout are kept.
+func PullToLayout(src, path string, opt ...Option) error {
+	o := makeOptions(opt...)
+	ref, err := resolveReference(src, o)
+	if err != nil {
+		return fmt.Errorf("parsing reference %q: %w", src, err)
+	}
+
+	desc, err := remote.Get(ref, o.Remote...)
+	if err != nil {
+		return err
+	}
+
+	p, err := openLayout(path)
+	if err != nil {
+		return err
+	}
+
+	if desc.MediaType.IsIndex() && o.Platform == nil {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return err
+		}
+		return p.AppendIndex(idx)
+	}
+
+	img, err := desc.Image()
+	if err != nil {
+		return err
+	}
+	return p.AppendImage(img)
+}
+
 // PullLayer returns the given layer from a registry.
 func PullLayer(ref string, opt ...Option) (v1.Layer, error) {
 	o := makeOptions(opt...)
@@ -126,12 +162,9 @@ func SaveOCI(img v1.Image, path string) error {
 // MultiSaveOCI writes collection of v1.Image img as an OCI Image Layout at path. If a layout
 // already exists at that path, it will add the image to the index.
 func MultiSaveOCI(imgMap map[string]v1.Image, path string) error {
-	p, err := layout.FromPath(path)
+	p, err := openLayout(path)
 	if err != nil {
-		p, err = layout.Write(path, empty.Index)
-		if err != nil {
-			return err
-		}
+		return err
 	}
 	for _, img := range imgMap {
 		if err = p.AppendImage(img); err != nil {
@@ -140,3 +173,13 @@ func MultiSaveOCI(imgMap map[string]v1.Image, path string) error {
 	}
 	return nil
 }
+
+// openLayout returns the OCI Image Layout at path, creating an empty one if it
+// doesn't exist.
+func openLayout(path string) (layout.Path, error) {
+	p, err := layout.FromPath(path)
+	if err != nil {
+		return layout.Write(path, empty.Index)
+	}
+	return p, nil
+}
@@ -16,9 +16,11 @@ package crane
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
 )
@@ -53,6 +55,130 @@ func Push(img v1.Image, dst string, opt ...Option) error {
 	return remote.Write(tag, img, o.Remote...)
 }
 
+// MultiPush pushes each v1.Image in imgs to the registry, keyed by destination.
+//
+// Images are pushed together per repository with remote.MultiWrite, so each
+// shared blob is uploaded at most once per repository. Blobs already pushed to
+// another repository on the same registry are mounted from there instead of
+// being uploaded again; if the registry doesn't support cross-repository
+// mounts, they are uploaded as usual.
+func MultiPush(imgs map[string]v1.Image, opt ...Option) error {
+	o := makeOptions(opt...)
+
+	repos := map[name.Repository]map[name.Reference]remote.Taggable{}
+	for dst, img := range imgs {
+		ref, err := name.ParseReference(dst, o.Name...)
+		if err != nil {
+			return fmt.Errorf("parsing reference %q: %w", dst, err)
+		}
+		if repos[ref.Context()] == nil {
+			repos[ref.Context()] = map[name.Reference]remote.Taggable{}
+		}
+		repos[ref.Context()][ref] = img
+	}
+
+	// Push in a stable order, so it's predictable which repository a blob
+	// gets mounted from.
+	order := make([]name.Repository, 0, len(repos))
+	for repo := range repos {
+		order = append(order, repo)
+	}
+	sort.Slice(order, func(i, j int) bool { return order[i].String() < order[j].String() })
+
+	// Where each blob has been pushed so far, per registry.
+	pushed := map[name.Registry]map[v1.Hash]name.Reference{}
+	for _, repo := range order {
+		refs := repos[repo]
+		sources := pushed[repo.Registry]
+		if sources == nil {
+			sources = map[v1.Hash]name.Reference{}
+			pushed[repo.Registry] = sources
+		}
+
+		m := make(map[name.Reference]remote.Taggable, len(refs))
+		for ref, t := range refs {
+			m[ref] = &mountingImage{Image: t.(v1.Image), sources: sources}
+		}
+		if err := remote.MultiWrite(m, o.Remote...); err != nil {
+			return err
+		}
+
+		for ref, t := range refs {
+			img := t.(v1.Image)
+			ls, err := img.Layers()
+			if err != nil {
+				return err
+			}
+			blobs := make([]v1.Hash, 0, len(ls)+1)
+			for _, l := range ls {
+				h, err := l.Digest()
+				if err != nil {
+					return err
+				}
+				blobs = append(blobs, h)
+			}
+			cfg, err := img.ConfigName()
+			if err != nil {
+				return err
+			}
+			for _, h := range append(blobs, cfg) {
+				if _, ok := sources[h]; !ok {
+					sources[h] = ref
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// mountingImage wraps the layers and config blob of an image so that those in
+// sources get mounted from there rather than uploaded.
+type mountingImage struct {
+	v1.Image
+	sources map[v1.Hash]name.Reference
+}
+
+func (i *mountingImage) Layers() ([]v1.Layer, error) {
+	ls, err := i.Image.Layers()
+	if err != nil {
+		return nil, err
+	}
+	// Don't modify ls in place, since it may belong to the image.
+	mls := make([]v1.Layer, 0, len(ls))
+	for _, l := range ls {
+		mls = append(mls, i.mountable(l))
+	}
+	return mls, nil
+}
+
+// ConfigLayer implements partial.withConfigLayer.
+func (i *mountingImage) ConfigLayer() (v1.Layer, error) {
+	cl, err := partial.ConfigLayer(i.Image)
+	if err != nil {
+		return nil, err
+	}
+	return i.mountable(cl), nil
+}
+
+func (i *mountingImage) LayerByDigest(h v1.Hash) (v1.Layer, error) {
+	l, err := i.Image.LayerByDigest(h)
+	if err != nil {
+		return nil, err
+	}
+	return i.mountable(l), nil
+}
+
+func (i *mountingImage) mountable(l v1.Layer) v1.Layer {
+	h, err := l.Digest()
+	if err != nil {
+		return l
+	}
+	if ref, ok := i.sources[h]; ok {
+		return &remote.MountableLayer{Layer: l, Reference: ref}
+	}
+	return l
+}
+
 // Upload pushes the v1.Layer to a given repo.
 func Upload(layer v1.Layer, repo string, opt ...Option) error {
 	o := makeOptions(opt...)
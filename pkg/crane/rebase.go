@@ -0,0 +1,127 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane
+
+import (
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/logs"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	specsv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Rebase pulls the remote image orig and replaces the layers of oldBase in it
+// with the layers of newBase. See mutate.Rebase, which returns an error if
+// oldBase isn't actually a prefix of orig.
+//
+// If newBase or oldBase are "", they are derived from orig's base image
+// annotations (or, failing that, config labels):
+// "org.opencontainers.image.base.name" names the new base, and
+// "org.opencontainers.image.base.digest" is the digest of the old base in the
+// new base's repository.
+//
+// The rebased image is annotated with the new base's name and digest, so that
+// it can be rebased again the same way. A Docker schema 2 image is converted
+// to OCI to hold them; see mutate.Annotations.
+func Rebase(orig, oldBase, newBase string, opt ...Option) (v1.Image, error) {
+	img, err := Pull(orig, opt...)
+	if err != nil {
+		return nil, err
+	}
+	return RebaseImage(img, oldBase, newBase, opt...)
+}
+
+// RebaseImage is like Rebase, but for an orig image that was already pulled.
+func RebaseImage(orig v1.Image, oldBase, newBase string, opt ...Option) (v1.Image, error) {
+	o := makeOptions(opt...)
+
+	if newBase == "" {
+		hint, err := baseImageHint(orig, specsv1.AnnotationBaseImageName)
+		if err != nil {
+			return nil, err
+		}
+		if hint == "" {
+			return nil, fmt.Errorf("either new base or %q annotation is required", specsv1.AnnotationBaseImageName)
+		}
+		newBase = hint
+		logs.Debug.Printf("Detected new base from %q annotation: %s", specsv1.AnnotationBaseImageName, newBase)
+	}
+	newBaseImg, err := Pull(newBase, opt...)
+	if err != nil {
+		return nil, err
+	}
+
+	if oldBase == "" {
+		oldBaseDigest, err := baseImageHint(orig, specsv1.AnnotationBaseImageDigest)
+		if err != nil {
+			return nil, err
+		}
+		if oldBaseDigest == "" {
+			return nil, fmt.Errorf("either old base or %q annotation is required", specsv1.AnnotationBaseImageDigest)
+		}
+		newBaseRef, err := name.ParseReference(newBase, o.Name...)
+		if err != nil {
+			return nil, err
+		}
+		oldBase = newBaseRef.Context().Digest(oldBaseDigest).String()
+		logs.Debug.Printf("Detected old base from %q annotation: %s", specsv1.AnnotationBaseImageDigest, oldBase)
+	}
+	oldBaseImg, err := Pull(oldBase, opt...)
+	if err != nil {
+		return nil, err
+	}
+
+	// NB: if newBase is an index, we need to grab the index's digest to
+	// annotate the resulting image, even though we pull the
+	// platform-specific image to rebase.
+	// Digest will pull a platform-specific image, so use Head here instead.
+	newBaseDesc, err := Head(newBase, opt...)
+	if err != nil {
+		return nil, err
+	}
+	newBaseDigest := newBaseDesc.Digest.String()
+
+	rebased, err := mutate.Rebase(orig, oldBaseImg, newBaseImg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Update base image annotations for the new image manifest.
+	logs.Debug.Printf("Setting annotation %q: %q", specsv1.AnnotationBaseImageDigest, newBaseDigest)
+	logs.Debug.Printf("Setting annotation %q: %q", specsv1.AnnotationBaseImageName, newBase)
+	return mutate.Annotations(rebased, map[string]string{
+		specsv1.AnnotationBaseImageDigest: newBaseDigest,
+		specsv1.AnnotationBaseImageName:   newBase,
+	}).(v1.Image), nil
+}
+
+// baseImageHint returns the value of key in img's manifest annotations, falling
+// back to its config labels, which is where image builders often put it.
+func baseImageHint(img v1.Image, key string) (string, error) {
+	m, err := img.Manifest()
+	if err != nil {
+		return "", err
+	}
+	if v := m.Annotations[key]; v != "" {
+		return v, nil
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		return "", err
+	}
+	return cf.Config.Labels[key], nil
+}
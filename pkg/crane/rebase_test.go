@@ -0,0 +1,164 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	specsv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestRebase(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldBase, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newBase, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	top, err := random.Layer(1024, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldBaseDigest, err := oldBase.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := fmt.Sprintf("%s/test/base", u.Host)
+	if err := crane.Push(oldBase, base+"@"+oldBaseDigest.String()); err != nil {
+		t.Fatal(err)
+	}
+	if err := crane.Push(newBase, base+":latest"); err != nil {
+		t.Fatal(err)
+	}
+
+	withTop, err := mutate.AppendLayers(oldBase, top)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := withTop.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf = cf.DeepCopy()
+	cf.Config.Labels = map[string]string{
+		specsv1.AnnotationBaseImageName:   base + ":latest",
+		specsv1.AnnotationBaseImageDigest: oldBaseDigest.String(),
+	}
+	labeled, err := mutate.ConfigFile(withTop, cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		desc string
+		img  v1.Image
+	}{{
+		desc: "labels",
+		img:  labeled,
+	}, {
+		desc: "annotations",
+		img: mutate.Annotations(withTop, map[string]string{
+			specsv1.AnnotationBaseImageName:   base + ":latest",
+			specsv1.AnnotationBaseImageDigest: oldBaseDigest.String(),
+		}).(v1.Image),
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			orig := fmt.Sprintf("%s/test/app:%s", u.Host, tc.desc)
+			if err := crane.Push(tc.img, orig); err != nil {
+				t.Fatal(err)
+			}
+
+			rebased, err := crane.Rebase(orig, "", "")
+			if err != nil {
+				t.Fatalf("Rebase: %v", err)
+			}
+
+			layers, err := rebased.Layers()
+			if err != nil {
+				t.Fatal(err)
+			}
+			newLayers, err := newBase.Layers()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := len(layers), len(newLayers)+1; got != want {
+				t.Fatalf("len(layers) = %d, want %d", got, want)
+			}
+			for i, l := range append(newLayers, top) {
+				got, err := layers[i].Digest()
+				if err != nil {
+					t.Fatal(err)
+				}
+				want, err := l.Digest()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != want {
+					t.Errorf("layers[%d] = %s, want %s", i, got, want)
+				}
+			}
+
+			m, err := rebased.Manifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			newBaseDigest, err := newBase.Digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := m.Annotations[specsv1.AnnotationBaseImageName], base+":latest"; got != want {
+				t.Errorf("base name annotation = %q, want %q", got, want)
+			}
+			if got, want := m.Annotations[specsv1.AnnotationBaseImageDigest], newBaseDigest.String(); got != want {
+				t.Errorf("base digest annotation = %q, want %q", got, want)
+			}
+		})
+	}
+
+	t.Run("not a prefix", func(t *testing.T) {
+		orig := fmt.Sprintf("%s/test/app:annotations", u.Host)
+		if _, err := crane.Rebase(orig, base+":latest", base+":latest"); err == nil {
+			t.Error("Rebase() with an old base that isn't a prefix: expected error")
+		}
+	})
+
+	t.Run("no hints", func(t *testing.T) {
+		orig := fmt.Sprintf("%s/test/plain", u.Host)
+		if err := crane.Push(withTop, orig); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := crane.Rebase(orig, "", ""); err == nil {
+			t.Error("Rebase() without a new base or annotations: expected error")
+		}
+	})
+}
@@ -0,0 +1,192 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/google/go-containerregistry/pkg/logs"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// CopyToStore copies a remote image or index from src into storage backends
+// rather than to a registry: its blobs are put into blobs, and its manifests
+// into manifests under their digests in repo, with the top-level manifest
+// also stored under tag unless it's "".
+//
+// These are the interfaces that back pkg/registry, so a registry created with
+// registry.WithBlobHandler and registry.WithManifestHandler for the same
+// backends serves the copy. If blobs implements registry.BlobStatHandler,
+// blobs that already exist aren't put again.
+func CopyToStore(src string, blobs registry.BlobPutHandler, manifests registry.ManifestHandler, repo, tag string, opt ...Option) error {
+	o := makeOptions(opt...)
+	ref, err := resolveReference(src, o)
+	if err != nil {
+		return fmt.Errorf("parsing reference %q: %w", src, err)
+	}
+
+	logs.Progress.Printf("Copying from %v to %s", ref, repo)
+	desc, err := remote.Get(ref, o.Remote...)
+	if err != nil {
+		return fmt.Errorf("fetching %q: %w", src, err)
+	}
+
+	var tags []string
+	if tag != "" {
+		tags = append(tags, tag)
+	}
+	s := &storeWriter{ctx: o.ctx, blobs: blobs, manifests: manifests, repo: repo}
+	if desc.MediaType.IsIndex() && o.Platform == nil {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return err
+		}
+		return s.writeIndex(idx, tags...)
+	}
+	img, err := desc.Image()
+	if err != nil {
+		return err
+	}
+	return s.writeImage(img, tags...)
+}
+
+// storable is an image or index whose manifest can be stored.
+type storable interface {
+	RawManifest() ([]byte, error)
+	MediaType() (types.MediaType, error)
+}
+
+// storeWriter writes images and indexes into the storage backends of a
+// registry.
+type storeWriter struct {
+	ctx       context.Context
+	blobs     registry.BlobPutHandler
+	manifests registry.ManifestHandler
+	repo      string
+}
+
+// writeIndex writes idx and its children, storing idx's manifest under tags.
+func (s *storeWriter) writeIndex(idx v1.ImageIndex, tags ...string) error {
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return err
+	}
+	for _, desc := range im.Manifests {
+		switch {
+		case desc.MediaType.IsIndex():
+			child, err := idx.ImageIndex(desc.Digest)
+			if err != nil {
+				return err
+			}
+			if err := s.writeIndex(child); err != nil {
+				return err
+			}
+		case desc.MediaType.IsImage():
+			img, err := idx.Image(desc.Digest)
+			if err != nil {
+				return err
+			}
+			if err := s.writeImage(img); err != nil {
+				return err
+			}
+		default:
+			logs.Warn.Printf("Skipping %s with unsupported media type %q", desc.Digest, desc.MediaType)
+		}
+	}
+	return s.putManifest(idx, tags...)
+}
+
+// writeImage writes img and its blobs, storing its manifest under tags.
+func (s *storeWriter) writeImage(img v1.Image, tags ...string) error {
+	layers, err := img.Layers()
+	if err != nil {
+		return err
+	}
+	for _, l := range layers {
+		mt, err := l.MediaType()
+		if err != nil {
+			return err
+		}
+		if !mt.IsDistributable() {
+			continue
+		}
+		h, err := l.Digest()
+		if err != nil {
+			return err
+		}
+		if err := s.putBlob(h, l.Compressed); err != nil {
+			return err
+		}
+	}
+
+	cfg, err := img.RawConfigFile()
+	if err != nil {
+		return err
+	}
+	h, err := img.ConfigName()
+	if err != nil {
+		return err
+	}
+	if err := s.putBlob(h, func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(cfg)), nil
+	}); err != nil {
+		return err
+	}
+	return s.putManifest(img, tags...)
+}
+
+// putBlob puts the blob h, opening it only if it doesn't already exist.
+func (s *storeWriter) putBlob(h v1.Hash, open func() (io.ReadCloser, error)) error {
+	if bsh, ok := s.blobs.(registry.BlobStatHandler); ok {
+		if _, err := bsh.Stat(s.ctx, s.repo, h); err == nil {
+			logs.Progress.Printf("existing blob: %v", h)
+			return nil
+		}
+	}
+	rc, err := open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return s.blobs.Put(s.ctx, s.repo, h, rc)
+}
+
+// putManifest stores m's manifest under its digest and any tags.
+func (s *storeWriter) putManifest(m storable, tags ...string) error {
+	b, err := m.RawManifest()
+	if err != nil {
+		return err
+	}
+	mt, err := m.MediaType()
+	if err != nil {
+		return err
+	}
+	h, _, err := v1.SHA256(bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	for _, target := range append([]string{h.String()}, tags...) {
+		if err := s.manifests.Put(s.ctx, s.repo, target, b, string(mt)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
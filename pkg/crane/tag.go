@@ -24,7 +24,7 @@ import (
 // Tag adds tag to the remote img.
 func Tag(img, tag string, opt ...Option) error {
 	o := makeOptions(opt...)
-	ref, err := name.ParseReference(img, o.Name...)
+	ref, err := resolveReference(img, o)
 	if err != nil {
 		return fmt.Errorf("parsing reference %q: %w", img, err)
 	}
@@ -37,3 +37,48 @@ func Tag(img, tag string, opt ...Option) error {
 
 	return remote.Tag(dst, desc, o.Remote...)
 }
+
+// Retag points dst, a tag on the same registry as src, at the manifest src
+// refers to. If dst is in another repository, any blobs missing there are
+// mounted from src's repository, so layers are never downloaded; only the
+// manifests (and image configs) are read.
+//
+// Use Copy to copy between registries.
+func Retag(src, dst string, opt ...Option) error {
+	o := makeOptions(opt...)
+	srcRef, err := resolveReference(src, o)
+	if err != nil {
+		return fmt.Errorf("parsing reference %q: %w", src, err)
+	}
+	dstTag, err := name.NewTag(dst, o.Name...)
+	if err != nil {
+		return fmt.Errorf("parsing tag %q: %w", dst, err)
+	}
+	if srcRef.Context().RegistryStr() != dstTag.Context().RegistryStr() {
+		return fmt.Errorf("cannot retag %q as %q: registries differ, use Copy", src, dst)
+	}
+
+	desc, err := remote.Get(srcRef, o.Remote...)
+	if err != nil {
+		return fmt.Errorf("fetching %q: %w", src, err)
+	}
+
+	// Within a repository, all that's needed is the manifest.
+	if srcRef.Context().String() == dstTag.Context().String() {
+		return remote.Tag(dstTag, desc, o.Remote...)
+	}
+
+	// Writing the remote image or index mounts its blobs from the source.
+	if desc.MediaType.IsIndex() {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return err
+		}
+		return remote.WriteIndex(dstTag, idx, o.Remote...)
+	}
+	img, err := desc.Image()
+	if err != nil {
+		return err
+	}
+	return remote.Write(dstTag, img, o.Remote...)
+}
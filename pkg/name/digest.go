@@ -58,6 +58,11 @@ func (d Digest) String() string {
 	return d.original
 }
 
+// WithTag returns a Tag in the same repository as the digest.
+func (d Digest) WithTag(tag string) Tag {
+	return d.Repository.Tag(tag)
+}
+
 // NewDigest returns a new Digest representing the given name.
 func NewDigest(name string, opts ...Option) (Digest, error) {
 	// Split on "@"
@@ -150,3 +150,22 @@ func TestDigestScopes(t *testing.T) {
 		t.Errorf("scope was incorrect for %v. Wanted: `%s` Got: `%s`", digest, expectedScope, actualScope)
 	}
 }
+
+func TestDigestWithTag(t *testing.T) {
+	d, err := NewDigest("registry.example.com/foo/bar@"+validDigest, Insecure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := d.WithTag("v1")
+
+	expectedName := "registry.example.com/foo/bar:v1"
+	if actualName := tag.Name(); actualName != expectedName {
+		t.Errorf("Name() was incorrect for %v. Wanted: `%s` Got: `%s`", tag, expectedName, actualName)
+	}
+	if tag.Context() != d.Context() {
+		t.Errorf("Context(); got %v, want %v", tag.Context(), d.Context())
+	}
+	if got, want := tag.Scheme(), "http"; got != want {
+		t.Errorf("Scheme(); got %q, want %q", got, want)
+	}
+}
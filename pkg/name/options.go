@@ -60,6 +60,9 @@ func WeakValidation(opts *options) {
 }
 
 // Insecure is an Option that allows image references to be fetched without TLS.
+//
+// Credentials aren't sent to such a registry over plain HTTP, unless it's on
+// a loopback address or remote.WithAllowInsecureAuth is used.
 func Insecure(opts *options) {
 	opts.insecure = true
 }
@@ -69,6 +72,9 @@ type OptionFn func() Option
 
 // WithDefaultRegistry sets the default registry that will be used if one is not
 // provided.
+//
+// The implicit "library/" namespace is only inserted for Docker Hub, so with a
+// different default registry "ubuntu" resolves to "<registry>/ubuntu".
 func WithDefaultRegistry(r string) Option {
 	return func(opts *options) {
 		opts.defaultRegistry = r
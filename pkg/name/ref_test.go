@@ -47,6 +47,30 @@ func TestParseReferenceDefaulting(t *testing.T) {
 	}
 }
 
+func TestParseReferenceDefaultRegistryNamespace(t *testing.T) {
+	for _, tc := range []struct {
+		registry string
+		want     string
+	}{{
+		registry: "myregistry.internal",
+		want:     "ubuntu",
+	}, {
+		registry: DefaultRegistry,
+		want:     "library/ubuntu",
+	}, {
+		registry: defaultRegistryAlias,
+		want:     "library/ubuntu",
+	}} {
+		ref, err := ParseReference("ubuntu", WithDefaultRegistry(tc.registry))
+		if err != nil {
+			t.Fatalf("ParseReference(ubuntu); %v", err)
+		}
+		if got := ref.Context().RepositoryStr(); got != tc.want {
+			t.Errorf("WithDefaultRegistry(%q) RepositoryStr(); got %q, want %q", tc.registry, got, tc.want)
+		}
+	}
+}
+
 func TestParseReference(t *testing.T) {
 	for _, name := range goodWeakValidationDigestNames {
 		ref, err := ParseReference(name, WeakValidation)
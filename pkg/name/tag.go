@@ -16,6 +16,8 @@ package name
 
 import (
 	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 )
 
 const (
@@ -59,6 +61,12 @@ func (t Tag) String() string {
 	return t.original
 }
 
+// WithDigest returns a Digest for h in the same repository as the tag, e.g.
+// to pin the tag to what it currently points at.
+func (t Tag) WithDigest(h v1.Hash) Digest {
+	return t.Repository.Digest(h.String())
+}
+
 // Scope returns the scope required to perform the given action on the tag.
 func (t Tag) Scope(action string) string {
 	return t.Repository.Scope(action)
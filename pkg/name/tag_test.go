@@ -18,6 +18,8 @@ import (
 	"path"
 	"strings"
 	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 )
 
 var goodStrictValidationTagNames = []string{
@@ -160,3 +162,29 @@ func TestOverrideDefault(t *testing.T) {
 		t.Errorf("Name() was incorrect for %v. Wanted: `%s` Got: `%s`", tag, expectedName, actualName)
 	}
 }
+
+func TestTagWithDigest(t *testing.T) {
+	tag, err := NewTag("registry.example.com/foo/bar:latest", Insecure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := v1.Hash{
+		Algorithm: "sha256",
+		Hex:       "deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef",
+	}
+	d := tag.WithDigest(h)
+
+	expectedName := "registry.example.com/foo/bar@" + h.String()
+	if actualName := d.Name(); actualName != expectedName {
+		t.Errorf("Name() was incorrect for %v. Wanted: `%s` Got: `%s`", d, expectedName, actualName)
+	}
+	if d.Context() != tag.Context() {
+		t.Errorf("Context(); got %v, want %v", d.Context(), tag.Context())
+	}
+	if got, want := d.Scheme(), "http"; got != want {
+		t.Errorf("Scheme(); got %q, want %q", got, want)
+	}
+	if _, err := NewDigest(d.String()); err != nil {
+		t.Errorf("NewDigest(%q) = %v", d.String(), err)
+	}
+}
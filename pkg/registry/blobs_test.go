@@ -0,0 +1,102 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+// recordingHandler is a blob storage backend that records which repositories
+// blobs were written by.
+type recordingHandler struct {
+	registry.BlobHandler
+
+	mu   sync.Mutex
+	puts map[v1.Hash]string
+}
+
+func (r *recordingHandler) Stat(ctx context.Context, repo string, h v1.Hash) (int64, error) {
+	return r.BlobHandler.(registry.BlobStatHandler).Stat(ctx, repo, h)
+}
+
+func (r *recordingHandler) Put(ctx context.Context, repo string, h v1.Hash, rc io.ReadCloser) error {
+	r.mu.Lock()
+	r.puts[h] = repo
+	r.mu.Unlock()
+	return r.BlobHandler.(registry.BlobPutHandler).Put(ctx, repo, h, rc)
+}
+
+func TestWithBlobHandler(t *testing.T) {
+	bh := &recordingHandler{
+		BlobHandler: registry.NewInMemoryBlobHandler(),
+		puts:        map[v1.Hash]string{},
+	}
+	s := httptest.NewServer(registry.New(registry.WithBlobHandler(bh)))
+	defer s.Close()
+
+	ref, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/foo:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("remote.Write: %v", err)
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := img.ConfigName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []v1.Hash{cfg}
+	for _, l := range layers {
+		d, err := l.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, d)
+	}
+	for _, h := range want {
+		if got := bh.puts[h]; got != "foo" {
+			t.Errorf("Put(%s) repo = %q, want %q", h, got, "foo")
+		}
+	}
+
+	got, err := remote.Image(ref)
+	if err != nil {
+		t.Fatalf("remote.Image: %v", err)
+	}
+	if err := validate.Image(got); err != nil {
+		t.Errorf("validate.Image: %v", err)
+	}
+}
@@ -16,13 +16,16 @@ package registry
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"sort"
 	"strconv"
 	"strings"
@@ -41,16 +44,146 @@ type listTags struct {
 	Tags []string `json:"tags"`
 }
 
+// ManifestHandler represents a manifest storage backend. Manifests are
+// stored under both their tags and their digests, as targets.
+type ManifestHandler interface {
+	// Get returns the contents and media type of the manifest in repo with
+	// the tag or digest target, or ErrNotFound if there's no such manifest,
+	// or ErrRepositoryNotFound if there's no such repository.
+	Get(ctx context.Context, repo, target string) ([]byte, string, error)
+
+	// Put stores the manifest contents and media type in repo under the tag
+	// or digest target, creating the repository if it doesn't exist.
+	Put(ctx context.Context, repo, target string, b []byte, mediaType string) error
+
+	// Delete removes target from repo, or returns ErrNotFound if there's no
+	// such manifest, or ErrRepositoryNotFound if there's no such repository.
+	Delete(ctx context.Context, repo, target string) error
+
+	// Tags returns the tags in repo in any order, or ErrRepositoryNotFound
+	// if there's no such repository.
+	Tags(ctx context.Context, repo string) ([]string, error)
+
+	// Repositories returns the names of all repositories in any order.
+	Repositories(ctx context.Context) ([]string, error)
+}
+
+// ErrRepositoryNotFound represents an error locating a repository.
+var ErrRepositoryNotFound = errors.New("repository not found")
+
+// NewInMemoryManifestHandler returns a ManifestHandler that stores manifests
+// in memory. This is what New uses by default.
+func NewInMemoryManifestHandler() ManifestHandler {
+	return &memManifestHandler{m: map[string]map[string]manifest{}}
+}
+
 type manifest struct {
 	contentType string
 	blob        []byte
 }
 
-type manifests struct {
+type memManifestHandler struct {
 	// maps repo -> manifest tag/digest -> manifest
-	manifests map[string]map[string]manifest
-	lock      sync.Mutex
-	log       *log.Logger
+	m    map[string]map[string]manifest
+	lock sync.Mutex
+}
+
+func (m *memManifestHandler) Get(_ context.Context, repo, target string) ([]byte, string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	c, ok := m.m[repo]
+	if !ok {
+		return nil, "", ErrRepositoryNotFound
+	}
+	mf, ok := c[target]
+	if !ok {
+		return nil, "", ErrNotFound
+	}
+	return mf.blob, mf.contentType, nil
+}
+
+func (m *memManifestHandler) Put(_ context.Context, repo, target string, b []byte, mediaType string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.m[repo]; !ok {
+		m.m[repo] = map[string]manifest{}
+	}
+	m.m[repo][target] = manifest{blob: b, contentType: mediaType}
+	return nil
+}
+
+func (m *memManifestHandler) Delete(_ context.Context, repo, target string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	c, ok := m.m[repo]
+	if !ok {
+		return ErrRepositoryNotFound
+	}
+	if _, ok := c[target]; !ok {
+		return ErrNotFound
+	}
+	delete(c, target)
+	return nil
+}
+
+func (m *memManifestHandler) Tags(_ context.Context, repo string) ([]string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	c, ok := m.m[repo]
+	if !ok {
+		return nil, ErrRepositoryNotFound
+	}
+	var tags []string
+	for tag := range c {
+		if !strings.Contains(tag, "sha256:") {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+func (m *memManifestHandler) Repositories(context.Context) ([]string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	repos := make([]string, 0, len(m.m))
+	for repo := range m.m {
+		repos = append(repos, repo)
+	}
+	return repos, nil
+}
+
+type manifests struct {
+	manifestHandler ManifestHandler
+	log             *log.Logger
+}
+
+var regErrNameUnknown = &regError{
+	Status:  http.StatusNotFound,
+	Code:    "NAME_UNKNOWN",
+	Message: "Unknown name",
+}
+
+var regErrManifestUnknown = &regError{
+	Status:  http.StatusNotFound,
+	Code:    "MANIFEST_UNKNOWN",
+	Message: "Unknown manifest",
+}
+
+// manifestError maps errors from a ManifestHandler to registry errors.
+func manifestError(err error) *regError {
+	switch {
+	case errors.Is(err, ErrRepositoryNotFound):
+		return regErrNameUnknown
+	case errors.Is(err, ErrNotFound):
+		return regErrManifestUnknown
+	default:
+		return regErrInternal(err)
+	}
 }
 
 func isManifest(req *http.Request) bool {
@@ -90,82 +223,35 @@ func (m *manifests) handle(resp http.ResponseWriter, req *http.Request) *regErro
 	repo := strings.Join(elem[1:len(elem)-2], "/")
 
 	switch req.Method {
-	case http.MethodGet:
-		m.lock.Lock()
-		defer m.lock.Unlock()
-
-		c, ok := m.manifests[repo]
-		if !ok {
-			return &regError{
-				Status:  http.StatusNotFound,
-				Code:    "NAME_UNKNOWN",
-				Message: "Unknown name",
-			}
+	case http.MethodGet, http.MethodHead:
+		b, contentType, err := m.manifestHandler.Get(req.Context(), repo, target)
+		if err != nil {
+			return manifestError(err)
 		}
-		m, ok := c[target]
-		if !ok {
-			return &regError{
-				Status:  http.StatusNotFound,
-				Code:    "MANIFEST_UNKNOWN",
-				Message: "Unknown manifest",
-			}
-		}
-		rd := sha256.Sum256(m.blob)
+		rd := sha256.Sum256(b)
 		d := "sha256:" + hex.EncodeToString(rd[:])
 		resp.Header().Set("Docker-Content-Digest", d)
-		resp.Header().Set("Content-Type", m.contentType)
-		resp.Header().Set("Content-Length", fmt.Sprint(len(m.blob)))
+		resp.Header().Set("Content-Type", contentType)
+		resp.Header().Set("Content-Length", fmt.Sprint(len(b)))
 		resp.WriteHeader(http.StatusOK)
-		io.Copy(resp, bytes.NewReader(m.blob))
-		return nil
-
-	case http.MethodHead:
-		m.lock.Lock()
-		defer m.lock.Unlock()
-		if _, ok := m.manifests[repo]; !ok {
-			return &regError{
-				Status:  http.StatusNotFound,
-				Code:    "NAME_UNKNOWN",
-				Message: "Unknown name",
-			}
+		if req.Method == http.MethodGet {
+			io.Copy(resp, bytes.NewReader(b))
 		}
-		m, ok := m.manifests[repo][target]
-		if !ok {
-			return &regError{
-				Status:  http.StatusNotFound,
-				Code:    "MANIFEST_UNKNOWN",
-				Message: "Unknown manifest",
-			}
-		}
-		rd := sha256.Sum256(m.blob)
-		d := "sha256:" + hex.EncodeToString(rd[:])
-		resp.Header().Set("Docker-Content-Digest", d)
-		resp.Header().Set("Content-Type", m.contentType)
-		resp.Header().Set("Content-Length", fmt.Sprint(len(m.blob)))
-		resp.WriteHeader(http.StatusOK)
 		return nil
 
 	case http.MethodPut:
-		m.lock.Lock()
-		defer m.lock.Unlock()
-		if _, ok := m.manifests[repo]; !ok {
-			m.manifests[repo] = map[string]manifest{}
-		}
 		b := &bytes.Buffer{}
 		io.Copy(b, req.Body)
 		rd := sha256.Sum256(b.Bytes())
 		digest := "sha256:" + hex.EncodeToString(rd[:])
-		mf := manifest{
-			blob:        b.Bytes(),
-			contentType: req.Header.Get("Content-Type"),
-		}
+		contentType := req.Header.Get("Content-Type")
 
 		// If the manifest is a manifest list, check that the manifest
 		// list's constituent manifests are already uploaded.
 		// This isn't strictly required by the registry API, but some
 		// registries require this.
-		if types.MediaType(mf.contentType).IsIndex() {
-			im, err := v1.ParseIndexManifest(b)
+		if types.MediaType(contentType).IsIndex() {
+			im, err := v1.ParseIndexManifest(bytes.NewReader(b.Bytes()))
 			if err != nil {
 				return &regError{
 					Status:  http.StatusBadRequest,
@@ -178,12 +264,14 @@ func (m *manifests) handle(resp http.ResponseWriter, req *http.Request) *regErro
 					continue
 				}
 				if desc.MediaType.IsIndex() || desc.MediaType.IsImage() {
-					if _, found := m.manifests[repo][desc.Digest.String()]; !found {
+					if _, _, err := m.manifestHandler.Get(req.Context(), repo, desc.Digest.String()); errors.Is(err, ErrNotFound) || errors.Is(err, ErrRepositoryNotFound) {
 						return &regError{
 							Status:  http.StatusNotFound,
 							Code:    "MANIFEST_UNKNOWN",
 							Message: fmt.Sprintf("Sub-manifest %q not found", desc.Digest),
 						}
+					} else if err != nil {
+						return regErrInternal(err)
 					}
 				} else {
 					// TODO: Probably want to do an existence check for blobs.
@@ -194,33 +282,22 @@ func (m *manifests) handle(resp http.ResponseWriter, req *http.Request) *regErro
 
 		// Allow future references by target (tag) and immutable digest.
 		// See https://docs.docker.com/engine/reference/commandline/pull/#pull-an-image-by-digest-immutable-identifier.
-		m.manifests[repo][target] = mf
-		m.manifests[repo][digest] = mf
+		if err := m.manifestHandler.Put(req.Context(), repo, target, b.Bytes(), contentType); err != nil {
+			return regErrInternal(err)
+		}
+		if target != digest {
+			if err := m.manifestHandler.Put(req.Context(), repo, digest, b.Bytes(), contentType); err != nil {
+				return regErrInternal(err)
+			}
+		}
 		resp.Header().Set("Docker-Content-Digest", digest)
 		resp.WriteHeader(http.StatusCreated)
 		return nil
 
 	case http.MethodDelete:
-		m.lock.Lock()
-		defer m.lock.Unlock()
-		if _, ok := m.manifests[repo]; !ok {
-			return &regError{
-				Status:  http.StatusNotFound,
-				Code:    "NAME_UNKNOWN",
-				Message: "Unknown name",
-			}
-		}
-
-		_, ok := m.manifests[repo][target]
-		if !ok {
-			return &regError{
-				Status:  http.StatusNotFound,
-				Code:    "MANIFEST_UNKNOWN",
-				Message: "Unknown manifest",
-			}
+		if err := m.manifestHandler.Delete(req.Context(), repo, target); err != nil {
+			return manifestError(err)
 		}
-
-		delete(m.manifests[repo], target)
 		resp.WriteHeader(http.StatusAccepted)
 		return nil
 
@@ -239,23 +316,9 @@ func (m *manifests) handleTags(resp http.ResponseWriter, req *http.Request) *reg
 	repo := strings.Join(elem[1:len(elem)-2], "/")
 
 	if req.Method == "GET" {
-		m.lock.Lock()
-		defer m.lock.Unlock()
-
-		c, ok := m.manifests[repo]
-		if !ok {
-			return &regError{
-				Status:  http.StatusNotFound,
-				Code:    "NAME_UNKNOWN",
-				Message: "Unknown name",
-			}
-		}
-
-		var tags []string
-		for tag := range c {
-			if !strings.Contains(tag, "sha256:") {
-				tags = append(tags, tag)
-			}
+		tags, err := m.manifestHandler.Tags(req.Context(), repo)
+		if err != nil {
+			return manifestError(err)
 		}
 		sort.Strings(tags)
 
@@ -280,6 +343,14 @@ func (m *manifests) handleTags(resp http.ResponseWriter, req *http.Request) *reg
 				}
 			} else if n < len(tags) {
 				tags = tags[:n]
+				if n > 0 {
+					// Point the client at the next page.
+					next := url.URL{
+						Path:     req.URL.Path,
+						RawQuery: url.Values{"n": {ns}, "last": {tags[n-1]}}.Encode(),
+					}
+					resp.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.String()))
+				}
 			}
 		}
 
@@ -311,13 +382,15 @@ func (m *manifests) handleCatalog(resp http.ResponseWriter, req *http.Request) *
 	}
 
 	if req.Method == "GET" {
-		m.lock.Lock()
-		defer m.lock.Unlock()
+		all, err := m.manifestHandler.Repositories(req.Context())
+		if err != nil {
+			return regErrInternal(err)
+		}
 
 		var repos []string
 		countRepos := 0
 		// TODO: implement pagination
-		for key := range m.manifests {
+		for _, key := range all {
 			if countRepos >= n {
 				break
 			}
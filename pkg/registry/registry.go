@@ -77,13 +77,13 @@ func New(opts ...Option) http.Handler {
 	r := &registry{
 		log: log.New(os.Stderr, "", log.LstdFlags),
 		blobs: blobs{
-			blobHandler: &memHandler{m: map[string][]byte{}},
+			blobHandler: NewInMemoryBlobHandler(),
 			uploads:     map[string][]byte{},
 			log:         log.New(os.Stderr, "", log.LstdFlags),
 		},
 		manifests: manifests{
-			manifests: map[string]map[string]manifest{},
-			log:       log.New(os.Stderr, "", log.LstdFlags),
+			manifestHandler: NewInMemoryManifestHandler(),
+			log:             log.New(os.Stderr, "", log.LstdFlags),
 		},
 	}
 	for _, o := range opts {
@@ -104,3 +104,24 @@ func Logger(l *log.Logger) Option {
 		r.blobs.log = l
 	}
 }
+
+// WithBlobHandler overrides the default in-memory blob storage backend, e.g.
+// to serve blobs from object storage.
+//
+// bh must implement BlobHandler to serve blob contents, and may implement
+// BlobStatHandler, BlobPutHandler and BlobDeleteHandler to support HEAD
+// requests, pushes and deletes, respectively. Manifests are stored separately;
+// see WithManifestHandler.
+func WithBlobHandler(bh BlobHandler) Option {
+	return func(r *registry) {
+		r.blobs.blobHandler = bh
+	}
+}
+
+// WithManifestHandler overrides the default in-memory manifest storage
+// backend, e.g. to store manifests alongside blobs in object storage.
+func WithManifestHandler(mh ManifestHandler) Option {
+	return func(r *registry) {
+		r.manifests.manifestHandler = mh
+	}
+}
@@ -415,6 +415,7 @@ func TestCalls(t *testing.T) {
 			Method:      "GET",
 			URL:         "/v2/foo/tags/list?n=1",
 			Code:        http.StatusOK,
+			Header:      map[string]string{"Link": `</v2/foo/tags/list?last=latest&n=1>; rel="next"`},
 			Want:        `{"name":"foo","tags":["latest"]}`,
 		},
 		{
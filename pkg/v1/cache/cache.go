@@ -126,6 +126,11 @@ func (l *lazyLayer) Uncompressed() (io.ReadCloser, error) {
 	return rl.Uncompressed()
 }
 
+// Unwrap returns the underlying layer, so that writers can still mount it.
+func (l *lazyLayer) Unwrap() v1.Layer {
+	return l.inner
+}
+
 func (l *lazyLayer) Size() (int64, error)                { return l.inner.Size() }
 func (l *lazyLayer) DiffID() (v1.Hash, error)            { return l.inner.DiffID() }
 func (l *lazyLayer) Digest() (v1.Hash, error)            { return l.inner.Digest() }
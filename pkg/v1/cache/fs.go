@@ -18,6 +18,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -56,13 +57,82 @@ type layer struct {
 	v1.Layer
 	path           string
 	digest, diffID v1.Hash
+
+	// track, if set, is called before a blob for h is written to the cache
+	// directory. The returned func is called once the file is closed.
+	track func(h v1.Hash) func() error
 }
 
-func (l *layer) create(h v1.Hash) (io.WriteCloser, error) {
+// create returns a file that the blob for h is written to. The file is only
+// moved into place by commit, so concurrent writers of the same blob, and
+// readers, never see a partially written file.
+func (l *layer) create(h v1.Hash) (*cacheFile, error) {
 	if err := os.MkdirAll(l.path, 0700); err != nil {
 		return nil, err
 	}
-	return os.Create(cachepath(l.path, h))
+	done := func() error { return nil }
+	if l.track != nil {
+		done = l.track(h)
+	}
+	p := cachepath(l.path, h)
+	f, err := createTemp(p)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	return &cacheFile{File: f, path: p, done: done}, nil
+}
+
+// createTemp creates a new file next to p to write p's contents to. Unlike
+// os.CreateTemp, which creates files readable only by their owner, it uses
+// the same permissions as os.Create, so cache directories can be shared.
+func createTemp(p string) (*os.File, error) {
+	for i := 0; ; i++ {
+		f, err := os.OpenFile(fmt.Sprintf("%s-%d%s", p, rand.Uint32(), tmpSuffix), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if os.IsExist(err) && i < 100 {
+			continue
+		}
+		return f, err
+	}
+}
+
+// tmpSuffix marks blobs that are still being written.
+const tmpSuffix = ".tmp"
+
+type cacheFile struct {
+	*os.File
+	path string
+	done func() error
+}
+
+// commit closes the file and, if complete is true, renames it to its final
+// path. Otherwise, the file is removed.
+func (f *cacheFile) commit(complete bool) error {
+	err := f.File.Close()
+	if err == nil && complete {
+		if err = os.Rename(f.Name(), f.path); err != nil {
+			// Another writer may have won the race for the same blob,
+			// e.g. on Windows, where an open file can't be replaced.
+			if _, serr := os.Stat(f.path); serr == nil {
+				err = nil
+				complete = false
+			}
+		}
+	}
+	if err != nil || !complete {
+		if rerr := os.Remove(f.Name()); err == nil && !os.IsNotExist(rerr) {
+			err = rerr
+		}
+	}
+	if derr := f.done(); err == nil {
+		err = derr
+	}
+	return err
+}
+
+// Unwrap returns the cached layer, so that writers can still mount it.
+func (l *layer) Unwrap() v1.Layer {
+	return l.Layer
 }
 
 func (l *layer) Compressed() (io.ReadCloser, error) {
@@ -72,12 +142,10 @@ func (l *layer) Compressed() (io.ReadCloser, error) {
 	}
 	rc, err := l.Layer.Compressed()
 	if err != nil {
+		f.commit(false)
 		return nil, err
 	}
-	return &readcloser{
-		t:      io.TeeReader(rc, f),
-		closes: []func() error{rc.Close, f.Close},
-	}, nil
+	return newReadCloser(rc, f), nil
 }
 
 func (l *layer) Uncompressed() (io.ReadCloser, error) {
@@ -87,21 +155,32 @@ func (l *layer) Uncompressed() (io.ReadCloser, error) {
 	}
 	rc, err := l.Layer.Uncompressed()
 	if err != nil {
+		f.commit(false)
 		return nil, err
 	}
-	return &readcloser{
-		t:      io.TeeReader(rc, f),
-		closes: []func() error{rc.Close, f.Close},
-	}, nil
+	return newReadCloser(rc, f), nil
+}
+
+// newReadCloser tees rc into f, and commits f on Close if rc was read to
+// the end.
+func newReadCloser(rc io.ReadCloser, f *cacheFile) *readcloser {
+	r := &readcloser{t: io.TeeReader(rc, f)}
+	r.closes = []func() error{rc.Close, func() error { return f.commit(r.eof) }}
+	return r
 }
 
 type readcloser struct {
 	t      io.Reader
 	closes []func() error
+	eof    bool
 }
 
 func (rc *readcloser) Read(b []byte) (int, error) {
-	return rc.t.Read(b)
+	n, err := rc.t.Read(b)
+	if errors.Is(err, io.EOF) {
+		rc.eof = true
+	}
+	return n, err
 }
 
 func (rc *readcloser) Close() error {
@@ -18,12 +18,14 @@ import (
 	"errors"
 	"io"
 	"os"
+	"path/filepath"
 	"testing"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/google/go-containerregistry/pkg/v1/types"
+	"golang.org/x/sync/errgroup"
 )
 
 func TestFilesystemCache(t *testing.T) {
@@ -211,3 +213,147 @@ func TestErrUnexpectedEOF(t *testing.T) {
 		t.Errorf("os.Stat(%q): %v", p, err)
 	}
 }
+
+func TestConcurrentPut(t *testing.T) {
+	dir := t.TempDir()
+	c := NewFilesystemCache(dir)
+
+	l, err := random.Layer(1<<20, types.DockerLayer)
+	if err != nil {
+		t.Fatalf("random.Layer: %v", err)
+	}
+	h, err := l.Digest()
+	if err != nil {
+		t.Fatalf("layer.Digest(): %v", err)
+	}
+
+	var g errgroup.Group
+	for i := 0; i < 8; i++ {
+		g.Go(func() error {
+			cl, err := c.Put(l)
+			if err != nil {
+				return err
+			}
+			rc, err := cl.Compressed()
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(io.Discard, rc); err != nil {
+				rc.Close()
+				return err
+			}
+			return rc.Close()
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	cl, err := c.Get(h)
+	if err != nil {
+		t.Fatalf("Get(%q): %v", h, err)
+	}
+	rc, err := cl.Compressed()
+	if err != nil {
+		t.Fatalf("Compressed(): %v", err)
+	}
+	defer rc.Close()
+	got, _, err := v1.SHA256(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != h {
+		t.Errorf("cached blob digest: got %s, want %s", got, h)
+	}
+
+	// No temporary files should be left behind.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d files in cache, want 1", len(entries))
+	}
+}
+
+func TestPartialReadNotCached(t *testing.T) {
+	dir := t.TempDir()
+	c := NewFilesystemCache(dir)
+
+	l, err := random.Layer(1<<20, types.DockerLayer)
+	if err != nil {
+		t.Fatalf("random.Layer: %v", err)
+	}
+	h, err := l.Digest()
+	if err != nil {
+		t.Fatalf("layer.Digest(): %v", err)
+	}
+	cl, err := c.Put(l)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	rc, err := cl.Compressed()
+	if err != nil {
+		t.Fatalf("Compressed(): %v", err)
+	}
+	if _, err := rc.Read(make([]byte, 10)); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if _, err := c.Get(h); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(%q): got %v, want %v", h, err, ErrNotFound)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("got %d files in cache, want 0", len(entries))
+	}
+}
+
+func TestFilesystemCachePermissions(t *testing.T) {
+	dir := t.TempDir()
+	img, err := random.Image(10, 1)
+	if err != nil {
+		t.Fatalf("random.Image: %v", err)
+	}
+	ls, err := Image(img, NewFilesystemCache(dir)).Layers()
+	if err != nil {
+		t.Fatalf("Layers: %v", err)
+	}
+	rc, err := ls[0].Compressed()
+	if err != nil {
+		t.Fatalf("Compressed: %v", err)
+	}
+	if _, err := io.Copy(io.Discard, rc); err != nil {
+		t.Fatalf("Error reading contents: %v", err)
+	}
+	rc.Close()
+	h, err := ls[0].Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Cached blobs get the same permissions as os.Create gives files, so
+	// that a cache directory can be shared.
+	f, err := os.Create(filepath.Join(t.TempDir(), "want"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	want, err := os.Stat(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.Stat(cachepath(dir, h))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Mode().Perm() != want.Mode().Perm() {
+		t.Errorf("cached blob has mode %v, want %v", got.Mode().Perm(), want.Mode().Perm())
+	}
+}
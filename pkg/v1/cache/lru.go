@@ -0,0 +1,158 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+type lrucache struct {
+	fscache
+	maxBytes int64
+
+	mu sync.Mutex
+	// writing counts the in-progress writes per cache file, so those files
+	// are never evicted out from under a writer.
+	writing map[string]int
+}
+
+// NewFilesystemCacheWithLimit returns a Cache implementation backed by files
+// that keeps the total size of the files in path under maxBytes.
+//
+// Whenever a blob finishes being written, the least-recently-used blobs are
+// removed until the cache fits within the budget again. Get counts as a use.
+// A layer returned by Get may still fail to read if it is evicted before it
+// is consumed; callers should treat that like a cache miss.
+func NewFilesystemCacheWithLimit(path string, maxBytes int64) Cache {
+	return &lrucache{
+		fscache:  fscache{path},
+		maxBytes: maxBytes,
+		writing:  map[string]int{},
+	}
+}
+
+func (c *lrucache) Put(l v1.Layer) (v1.Layer, error) {
+	cl, err := c.fscache.Put(l)
+	if err != nil {
+		return nil, err
+	}
+	cl.(*layer).track = c.track
+	return cl, nil
+}
+
+func (c *lrucache) track(h v1.Hash) func() error {
+	p := cachepath(c.path, h)
+
+	c.mu.Lock()
+	c.writing[p]++
+	c.mu.Unlock()
+
+	return func() error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if c.writing[p]--; c.writing[p] == 0 {
+			delete(c.writing, p)
+		}
+		if err := touch(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return c.evict()
+	}
+}
+
+func (c *lrucache) Get(h v1.Hash) (v1.Layer, error) {
+	c.mu.Lock()
+	err := touch(cachepath(c.path, h))
+	c.mu.Unlock()
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Opening the layer reads the whole blob, so don't hold c.mu for it. If
+	// the blob is evicted in the meantime, this is a cache miss.
+	return c.fscache.Get(h)
+}
+
+func (c *lrucache) Delete(h v1.Hash) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.fscache.Delete(h)
+}
+
+// evict removes the least-recently-used files until the cache fits within
+// maxBytes. Files that are still being written are skipped. c.mu must be held.
+func (c *lrucache) evict() error {
+	entries, err := os.ReadDir(c.path)
+	if err != nil {
+		return err
+	}
+
+	var (
+		total int64
+		files []os.FileInfo
+	)
+	for _, e := range entries {
+		if e.IsDir() || strings.HasSuffix(e.Name(), tmpSuffix) {
+			continue
+		}
+		fi, err := e.Info()
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		total += fi.Size()
+		files = append(files, fi)
+	}
+	if total <= c.maxBytes {
+		return nil
+	}
+
+	// The modification time doubles as the access time, since atime is
+	// often disabled or coarse on the filesystems CI runs on.
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+	for _, fi := range files {
+		if total <= c.maxBytes {
+			break
+		}
+		p := filepath.Join(c.path, fi.Name())
+		if c.writing[p] > 0 {
+			continue
+		}
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= fi.Size()
+	}
+	return nil
+}
+
+func touch(p string) error {
+	now := time.Now()
+	return os.Chtimes(p, now, now)
+}
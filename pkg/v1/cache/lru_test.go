@@ -0,0 +1,160 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// fill puts l into c and reads it, which writes it to disk.
+func fill(c Cache, l v1.Layer) error {
+	cl, err := c.Put(l)
+	if err != nil {
+		return err
+	}
+	rc, err := cl.Compressed()
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(io.Discard, rc); err != nil {
+		rc.Close()
+		return err
+	}
+	return rc.Close()
+}
+
+func TestFilesystemCacheWithLimit(t *testing.T) {
+	dir := t.TempDir()
+
+	var (
+		ls     []v1.Layer
+		hashes []v1.Hash
+		total  int64
+	)
+	for i := 0; i < 3; i++ {
+		l, err := random.Layer(1024, types.DockerLayer)
+		if err != nil {
+			t.Fatalf("random.Layer: %v", err)
+		}
+		h, err := l.Digest()
+		if err != nil {
+			t.Fatalf("Digest: %v", err)
+		}
+		size, err := l.Size()
+		if err != nil {
+			t.Fatalf("Size: %v", err)
+		}
+		ls = append(ls, l)
+		hashes = append(hashes, h)
+		total += size
+	}
+
+	// Only two of the three layers fit.
+	c := NewFilesystemCacheWithLimit(dir, total-1)
+
+	for _, l := range ls[:2] {
+		if err := fill(c, l); err != nil {
+			t.Fatalf("fill: %v", err)
+		}
+	}
+
+	// Using the first layer makes the second one the least recently used.
+	if _, err := c.Get(hashes[0]); err != nil {
+		t.Fatalf("Get(%s): %v", hashes[0], err)
+	}
+
+	if err := fill(c, ls[2]); err != nil {
+		t.Fatalf("fill: %v", err)
+	}
+
+	if _, err := c.Get(hashes[1]); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(%s); got %v, want %v", hashes[1], err, ErrNotFound)
+	}
+	for _, h := range []v1.Hash{hashes[0], hashes[2]} {
+		if _, err := c.Get(h); err != nil {
+			t.Errorf("Get(%s): %v", h, err)
+		}
+	}
+
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if got, want := len(dirEntries), 2; got != want {
+		t.Errorf("Got %d cached files, want %d", got, want)
+	}
+}
+
+func TestFilesystemCacheWithLimitConcurrent(t *testing.T) {
+	dir := t.TempDir()
+
+	img, err := random.Image(1024, 10)
+	if err != nil {
+		t.Fatalf("random.Image: %v", err)
+	}
+	ls, err := img.Layers()
+	if err != nil {
+		t.Fatalf("Layers: %v", err)
+	}
+
+	// Small enough that layers get evicted while others are being written.
+	c := NewFilesystemCacheWithLimit(dir, 4096)
+
+	var wg sync.WaitGroup
+	for _, l := range ls {
+		l := l
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fill(c, l); err != nil {
+				t.Errorf("fill: %v", err)
+				return
+			}
+			h, err := l.Digest()
+			if err != nil {
+				t.Errorf("Digest: %v", err)
+				return
+			}
+			if _, err := c.Get(h); err != nil && !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get(%s): %v", h, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	var size int64
+	for _, de := range dirEntries {
+		fi, err := de.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		size += fi.Size()
+	}
+	if size > 4096 {
+		t.Errorf("cache size; got %d, want <= %d", size, 4096)
+	}
+}
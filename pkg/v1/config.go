@@ -26,6 +26,11 @@ import (
 //
 // docker_version and os.version are not part of the spec but included
 // for backwards compatibility.
+//
+// Marshaling a ConfigFile with encoding/json, as this module does to compute
+// config digests, is deterministic: fields are written in declaration order
+// and map keys (e.g. Labels, ExposedPorts and Volumes) are sorted, so the same
+// logical config always produces the same bytes and digest.
 type ConfigFile struct {
 	Architecture  string    `json:"architecture"`
 	Author        string    `json:"author,omitempty"`
@@ -40,6 +45,20 @@ type ConfigFile struct {
 	Variant       string    `json:"variant,omitempty"`
 }
 
+// Platform returns the Platform described by the ConfigFile's fields, or nil
+// if none of them are set.
+func (cf *ConfigFile) Platform() *Platform {
+	if cf.OS == "" && cf.Architecture == "" && cf.OSVersion == "" && cf.Variant == "" {
+		return nil
+	}
+	return &Platform{
+		OS:           cf.OS,
+		Architecture: cf.Architecture,
+		OSVersion:    cf.OSVersion,
+		Variant:      cf.Variant,
+	}
+}
+
 // History is one entry of a list recording how this container image was built.
 type History struct {
 	Author     string `json:"author,omitempty"`
@@ -15,6 +15,8 @@
 package v1
 
 import (
+	"encoding/json"
+	"fmt"
 	"strings"
 	"testing"
 
@@ -36,3 +38,44 @@ func TestParseConfig(t *testing.T) {
 		t.Errorf("expected error, got: %v", got)
 	}
 }
+
+func TestConfigFileMarshalIsDeterministic(t *testing.T) {
+	cf := func(order []int) *ConfigFile {
+		c := &ConfigFile{
+			Config: Config{
+				Labels:       map[string]string{},
+				ExposedPorts: map[string]struct{}{},
+				Volumes:      map[string]struct{}{},
+			},
+		}
+		for _, i := range order {
+			c.Config.Labels[fmt.Sprintf("label-%02d", i)] = "value"
+			c.Config.ExposedPorts[fmt.Sprintf("%d/tcp", 8000+i)] = struct{}{}
+			c.Config.Volumes[fmt.Sprintf("/vol/%02d", i)] = struct{}{}
+		}
+		return c
+	}
+
+	var forward, backward []int
+	for i := 0; i < 32; i++ {
+		forward = append(forward, i)
+		backward = append([]int{i}, backward...)
+	}
+
+	want, err := json.Marshal(cf(forward))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(want), `"label-00":"value","label-01":"value"`) {
+		t.Errorf("labels are not sorted: %s", want)
+	}
+	for i := 0; i < 10; i++ {
+		got, err := json.Marshal(cf(backward))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(string(want), string(got)); diff != "" {
+			t.Fatalf("json.Marshal() is not deterministic (-want +got): %s", diff)
+		}
+	}
+}
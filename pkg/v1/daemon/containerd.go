@@ -0,0 +1,232 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+const defaultContainerdNamespace = "default"
+
+// WithContainerd is a functional option to talk to containerd instead of the
+// Docker daemon, for nodes that run containerd without dockerd.
+//
+// Images are exported from and imported into containerd's content store with
+// the ctr binary, which must be on $PATH. The containerd socket is taken from
+// $CONTAINERD_ADDRESS, as ctr does.
+func WithContainerd() Option {
+	return func(o *options) {
+		o.client = &containerdClient{namespace: o.namespace}
+	}
+}
+
+// WithContainerdNamespace sets the containerd namespace that images are
+// resolved in when using WithContainerd.
+//
+// By default, $CONTAINERD_NAMESPACE is used, or "default" if it is unset.
+func WithContainerdNamespace(namespace string) Option {
+	return func(o *options) {
+		o.namespace = namespace
+		if cc, ok := o.client.(*containerdClient); ok {
+			cc.namespace = namespace
+		}
+	}
+}
+
+// containerdClient implements Client by shelling out to ctr.
+type containerdClient struct {
+	namespace string
+}
+
+var _ Client = (*containerdClient)(nil)
+
+func (c *containerdClient) command(ctx context.Context, args ...string) *exec.Cmd {
+	ns := c.namespace
+	if ns == "" {
+		ns = os.Getenv("CONTAINERD_NAMESPACE")
+	}
+	if ns == "" {
+		ns = defaultContainerdNamespace
+	}
+	return exec.CommandContext(ctx, "ctr", append([]string{"--namespace", ns}, args...)...)
+}
+
+// run runs ctr with args, returning its output.
+func (c *containerdClient) run(ctx context.Context, stdin io.Reader, args ...string) ([]byte, error) {
+	cmd := c.command(ctx, args...)
+	cmd.Stdin = stdin
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ctr %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// NegotiateAPIVersion implements Client.
+func (c *containerdClient) NegotiateAPIVersion(context.Context) {}
+
+// ImageSave implements Client by streaming "ctr images export".
+func (c *containerdClient) ImageSave(ctx context.Context, refs []string) (io.ReadCloser, error) {
+	args := []string{"images", "export", "-"}
+	for _, ref := range refs {
+		args = append(args, containerdRef(ref))
+	}
+	cmd := c.command(ctx, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &cmdReader{ReadCloser: stdout, cmd: cmd, stderr: &stderr}, nil
+}
+
+// cmdReader reads the output of a command, reporting its failure on Close.
+type cmdReader struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+}
+
+func (r *cmdReader) Close() error {
+	// Drain the output so the command isn't blocked writing to the pipe.
+	io.Copy(io.Discard, r.ReadCloser)
+	if err := r.cmd.Wait(); err != nil {
+		return fmt.Errorf("%s: %w: %s", strings.Join(r.cmd.Args, " "), err, strings.TrimSpace(r.stderr.String()))
+	}
+	return nil
+}
+
+// ImageLoad implements Client with "ctr images import".
+func (c *containerdClient) ImageLoad(ctx context.Context, input io.Reader, _ bool) (types.ImageLoadResponse, error) {
+	out, err := c.run(ctx, input, "images", "import", "-")
+	if err != nil {
+		return types.ImageLoadResponse{}, err
+	}
+	return types.ImageLoadResponse{
+		Body: io.NopCloser(bytes.NewReader(out)),
+	}, nil
+}
+
+// ImageTag implements Client with "ctr images tag".
+func (c *containerdClient) ImageTag(ctx context.Context, src, dest string) error {
+	_, err := c.run(ctx, nil, "images", "tag", containerdRef(src), containerdRef(dest))
+	return err
+}
+
+// ImageInspectWithRaw implements Client, but only populates the ID, which is
+// the config digest. It is read from the image's manifest in containerd's
+// content store, so nothing is exported.
+func (c *containerdClient) ImageInspectWithRaw(ctx context.Context, ref string) (types.ImageInspect, []byte, error) {
+	cref := containerdRef(ref)
+	out, err := c.run(ctx, nil, "images", "ls", "name=="+cref)
+	if err != nil {
+		return types.ImageInspect{}, nil, err
+	}
+	target, err := imageTarget(out, cref)
+	if err != nil {
+		return types.ImageInspect{}, nil, err
+	}
+	b, err := c.run(ctx, nil, "content", "get", target)
+	if err != nil {
+		return types.ImageInspect{}, nil, err
+	}
+	var m struct {
+		Config    *v1.Descriptor
+		Manifests []v1.Descriptor
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return types.ImageInspect{}, nil, fmt.Errorf("parsing manifest %s: %w", target, err)
+	}
+	if m.Config == nil {
+		// It's an index; use the manifest for this platform, like
+		// "ctr images export" does.
+		child, err := platformManifest(m.Manifests)
+		if err != nil {
+			return types.ImageInspect{}, nil, fmt.Errorf("%s: %w", cref, err)
+		}
+		if b, err = c.run(ctx, nil, "content", "get", child); err != nil {
+			return types.ImageInspect{}, nil, err
+		}
+		if err := json.Unmarshal(b, &m); err != nil {
+			return types.ImageInspect{}, nil, fmt.Errorf("parsing manifest %s: %w", child, err)
+		}
+		if m.Config == nil {
+			return types.ImageInspect{}, nil, fmt.Errorf("manifest %s has no config", child)
+		}
+	}
+	return types.ImageInspect{ID: m.Config.Digest.String()}, nil, nil
+}
+
+// imageTarget finds the target digest of ref in the output of
+// "ctr images ls", whose columns are REF, TYPE, DIGEST, SIZE, and so on.
+func imageTarget(out []byte, ref string) (string, error) {
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && fields[0] == ref {
+			return fields[2], nil
+		}
+	}
+	return "", fmt.Errorf("image %q not found", ref)
+}
+
+// platformManifest returns the digest of the manifest in an index for the
+// platform this is running on.
+func platformManifest(manifests []v1.Descriptor) (string, error) {
+	for _, desc := range manifests {
+		if p := desc.Platform; p != nil && p.OS == runtime.GOOS && p.Architecture == runtime.GOARCH {
+			return desc.Digest.String(), nil
+		}
+	}
+	return "", fmt.Errorf("no manifest for %s/%s", runtime.GOOS, runtime.GOARCH)
+}
+
+// containerdRef converts ref to the fully qualified form that containerd
+// uses, which spells Docker Hub as "docker.io".
+func containerdRef(ref string) string {
+	r, err := name.ParseReference(ref)
+	if err != nil {
+		return ref
+	}
+	reg := r.Context().RegistryStr()
+	if reg == name.DefaultRegistry {
+		reg = "docker.io"
+	}
+	return fmt.Sprintf("%s/%s%s%s", reg, r.Context().RepositoryStr(), delimiter(r), r.Identifier())
+}
+
+func delimiter(r name.Reference) string {
+	if _, ok := r.(name.Digest); ok {
+		return "@"
+	}
+	return ":"
+}
@@ -0,0 +1,226 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/internal/compare"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+// fakeCtr puts a ctr script on $PATH that logs its arguments, exports
+// imagePath and saves imports, returning the log and import paths.
+func fakeCtr(t *testing.T) (string, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ctr is a shell script")
+	}
+	abs, err := filepath.Abs(imagePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log")
+	imported := filepath.Join(dir, "imported.tar")
+	content := fakeContent(t, filepath.Join(dir, "content"))
+	script := `#!/bin/sh
+echo "$@" >> ` + log + `
+case "$3 $4" in
+"images export") cat ` + abs + ` ;;
+"images import") cat > ` + imported + `; echo "unpacking done" ;;
+"images tag") ;;
+"images ls") cat ` + filepath.Join(content, "images") + ` ;;
+"content get") cat ` + content + `/$5 ;;
+*) echo "unexpected args: $@" >&2; exit 1 ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "ctr"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return log, imported
+}
+
+// fakeContent writes the manifest of the image at imagePath, and an index
+// containing it, to dir, along with the "ctr images ls" output listing
+// docker.io/library/test:latest and docker.io/library/multi:latest.
+func fakeContent(t *testing.T, dir string) string {
+	t.Helper()
+	img, err := tarball.ImageFromPath(imagePath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{
+		Add: img,
+		Descriptor: v1.Descriptor{
+			Platform: &v1.Platform{OS: runtime.GOOS, Architecture: runtime.GOARCH},
+		},
+	})
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	var images strings.Builder
+	images.WriteString("REF TYPE DIGEST SIZE PLATFORMS LABELS\n")
+	for ref, m := range map[string]partial.Describable{"test": img, "multi": idx} {
+		b, err := m.(partial.WithRawManifest).RawManifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := m.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		mt, err := m.MediaType()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, d.String()), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&images, "docker.io/library/%s:latest %s %s 1.0 KiB linux/amd64 -\n", ref, mt, d)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "images"), []byte(images.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestContainerdInspect(t *testing.T) {
+	log, _ := fakeCtr(t)
+
+	img, err := tarball.ImageFromPath(imagePath, nil)
+	if err != nil {
+		t.Fatalf("error loading test image: %s", err)
+	}
+	want, err := img.ConfigName()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ref := range []name.Reference{name.MustParseReference("test"), name.MustParseReference("multi")} {
+		got, err := Image(ref, WithContainerd())
+		if err != nil {
+			t.Fatalf("Image(%s): %v", ref, err)
+		}
+		cfg, err := got.ConfigName()
+		if err != nil {
+			t.Fatalf("ConfigName(%s): %v", ref, err)
+		}
+		if cfg != want {
+			t.Errorf("ConfigName(%s); got %s, want %s", ref, cfg, want)
+		}
+	}
+
+	if _, _, err := (&containerdClient{}).ImageInspectWithRaw(context.Background(), "missing"); err == nil {
+		t.Error("ImageInspectWithRaw(missing); expected error")
+	}
+
+	b, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "export") {
+		t.Errorf("ConfigName exported the image: %q", b)
+	}
+}
+
+func TestContainerdImage(t *testing.T) {
+	log, _ := fakeCtr(t)
+
+	want, err := tarball.ImageFromPath(imagePath, nil)
+	if err != nil {
+		t.Fatalf("error loading test image: %s", err)
+	}
+
+	got, err := Image(name.MustParseReference("test"), WithContainerd(), WithContainerdNamespace("k8s.io"))
+	if err != nil {
+		t.Fatalf("Image: %v", err)
+	}
+	if err := compare.Images(want, got); err != nil {
+		t.Errorf("compare.Images: %v", err)
+	}
+	if err := validate.Image(got); err != nil {
+		t.Errorf("validate.Image: %v", err)
+	}
+
+	b, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		// ConfigName inspects the image's metadata instead of exporting it.
+		if strings.Contains(line, " images ls ") || strings.Contains(line, " content get ") {
+			if !strings.HasPrefix(line, "--namespace k8s.io ") {
+				t.Errorf("ctr args; got %q, want namespace k8s.io", line)
+			}
+			continue
+		}
+		if want := "--namespace k8s.io images export - docker.io/library/test:latest"; line != want {
+			t.Errorf("ctr args; got %q, want %q", line, want)
+		}
+	}
+}
+
+func TestContainerdWrite(t *testing.T) {
+	log, imported := fakeCtr(t)
+	t.Setenv("CONTAINERD_NAMESPACE", "env")
+
+	img, err := tarball.ImageFromPath(imagePath, nil)
+	if err != nil {
+		t.Fatalf("error loading test image: %s", err)
+	}
+	tag := name.MustParseReference("gcr.io/test/image:write").(name.Tag)
+
+	resp, err := Write(tag, img, WithContainerd())
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if !strings.Contains(resp, "unpacking done") {
+		t.Errorf("Write response; got %q, want it to contain %q", resp, "unpacking done")
+	}
+	got, err := tarball.ImageFromPath(imported, nil)
+	if err != nil {
+		t.Fatalf("reading imported image: %v", err)
+	}
+	if err := compare.Images(img, got); err != nil {
+		t.Errorf("compare.Images: %v", err)
+	}
+
+	if err := Tag(tag, name.MustParseReference("ubuntu:copy").(name.Tag), WithContainerd()); err != nil {
+		t.Fatalf("Tag: %v", err)
+	}
+
+	b, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--namespace env images import -\n--namespace env images tag gcr.io/test/image:write docker.io/library/ubuntu:copy\n"
+	if got := string(b); got != want {
+		t.Errorf("ctr args; got %q, want %q", got, want)
+	}
+}
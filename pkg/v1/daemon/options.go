@@ -33,6 +33,9 @@ type options struct {
 	ctx      context.Context
 	client   Client
 	buffered bool
+
+	// The containerd namespace to use with WithContainerd.
+	namespace string
 }
 
 var defaultClient = func() (Client, error) {
@@ -41,6 +41,8 @@ type lister struct {
 	client    *http.Client
 	ctx       context.Context
 	userAgent string
+
+	allowInsecureAuth bool
 }
 
 func newLister(repo name.Repository, options ...Option) (*lister, error) {
@@ -77,7 +79,11 @@ func newLister(repo name.Repository, options ...Option) (*lister, error) {
 	}
 
 	scopes := []string{repo.Scope(transport.PullScope)}
-	tr, err := transport.NewWithContext(l.ctx, repo.Registry, l.auth, l.transport, scopes)
+	var topts []transport.Option
+	if l.allowInsecureAuth {
+		topts = append(topts, transport.WithAllowInsecureAuth())
+	}
+	tr, err := transport.NewWithContext(l.ctx, repo.Registry, l.auth, l.transport, scopes, topts...)
 	if err != nil {
 		return nil, err
 	}
@@ -71,3 +71,13 @@ func WithUserAgent(ua string) Option {
 		return nil
 	}
 }
+
+// WithAllowInsecureAuth is a functional option for sending credentials over
+// plain HTTP to registries that don't support TLS. See
+// remote.WithAllowInsecureAuth.
+func WithAllowInsecureAuth() Option {
+	return func(l *lister) error {
+		l.allowInsecureAuth = true
+		return nil
+	}
+}
@@ -16,6 +16,9 @@ package v1
 
 import (
 	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -34,6 +37,11 @@ type Hash struct {
 	Hex string
 }
 
+var (
+	_ encoding.TextMarshaler   = Hash{}
+	_ encoding.TextUnmarshaler = (*Hash)(nil)
+)
+
 // String reverses NewHash returning the string-form of the hash.
 func (h Hash) String() string {
 	return fmt.Sprintf("%s:%s", h.Algorithm, h.Hex)
@@ -48,6 +56,60 @@ func NewHash(s string) (Hash, error) {
 	return h, nil
 }
 
+// TagName returns the form of h used as a tag by the OCI referrers tag
+// schema, with the colon replaced by a dash, e.g. "sha256-deadbeef". To keep
+// the tag valid, the schema truncates the algorithm to 32 characters and the
+// hex to 64, so e.g. a sha512 hash loses half its hex.
+func (h Hash) TagName() string {
+	alg, hex := h.Algorithm, h.Hex
+	if len(alg) > 32 {
+		alg = alg[:32]
+	}
+	if len(hex) > 64 {
+		hex = hex[:64]
+	}
+	return alg + "-" + hex
+}
+
+// NewHashFromTagName reverses Hash.TagName, validating that tag is the tag
+// form of a hash, as NewHash does for its string form. The tag of a hash
+// whose hex TagName truncated, such as a sha512 one, can't be reversed, and
+// is rejected as an invalid hash.
+func NewHashFromTagName(tag string) (Hash, error) {
+	alg, hex, ok := strings.Cut(tag, "-")
+	if !ok {
+		return Hash{}, fmt.Errorf("missing dash separator in hash tag: %q", tag)
+	}
+	return NewHash(alg + ":" + hex)
+}
+
+// Equal reports whether h and other have the same algorithm and digest.
+// The hex portions are compared case-insensitively and in constant time, so
+// it is safe to use when comparing against an untrusted expected digest.
+func (h Hash) Equal(other Hash) bool {
+	if h.Algorithm != other.Algorithm {
+		return false
+	}
+	a, err := hex.DecodeString(h.Hex)
+	if err != nil {
+		return false
+	}
+	b, err := hex.DecodeString(other.Hex)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// NewHashNormalized is like NewHash, but lowercases the hex portion of the
+// input first, for tools that emit uppercase digests.
+func NewHashNormalized(s string) (Hash, error) {
+	if i := strings.LastIndex(s, ":"); i != -1 {
+		s = s[:i+1] + strings.ToLower(s[i+1:])
+	}
+	return NewHash(s)
+}
+
 // MarshalJSON implements json.Marshaler
 func (h Hash) MarshalJSON() ([]byte, error) {
 	return json.Marshal(h.String())
@@ -74,11 +136,14 @@ func (h *Hash) UnmarshalText(text []byte) error {
 	return h.parse(string(text))
 }
 
-// Hasher returns a hash.Hash for the named algorithm (e.g. "sha256")
+// Hasher returns a hash.Hash for the named algorithm (e.g. "sha256").
+// Unsupported algorithms are rejected with the same error NewHash reports.
 func Hasher(name string) (hash.Hash, error) {
 	switch name {
 	case "sha256":
 		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
 	default:
 		return nil, fmt.Errorf("unsupported hash: %q", name)
 	}
@@ -86,12 +151,22 @@ func Hasher(name string) (hash.Hash, error) {
 
 func (h *Hash) parse(unquoted string) error {
 	parts := strings.Split(unquoted, ":")
-	if len(parts) != 2 {
-		return fmt.Errorf("cannot parse hash: %q", unquoted)
+	switch {
+	case len(parts) < 2:
+		return fmt.Errorf("missing colon separator in hash: %q", unquoted)
+	case len(parts) > 2:
+		return fmt.Errorf("too many parts in hash: %q", unquoted)
+	}
+
+	if parts[1] == "" {
+		return fmt.Errorf("empty hex in hash: %q", unquoted)
 	}
 
 	rest := strings.TrimLeft(parts[1], "0123456789abcdef")
 	if len(rest) != 0 {
+		if strings.ContainsRune("ABCDEF", rune(rest[0])) {
+			return fmt.Errorf("found uppercase hex character in hash: %c (hex must be lowercase)", rest[0])
+		}
 		return fmt.Errorf("found non-hex character in hash: %c", rest[0])
 	}
 
@@ -110,14 +185,64 @@ func (h *Hash) parse(unquoted string) error {
 }
 
 // SHA256 computes the Hash of the provided io.Reader's content.
+// It does not close r, so it composes with io.TeeReader when the caller
+// needs to keep consuming the underlying stream.
 func SHA256(r io.Reader) (Hash, int64, error) {
-	hasher := sha256.New()
+	return digest("sha256", r)
+}
+
+// SHA512 computes the sha512 Hash of the provided io.Reader's content.
+// Like SHA256, it does not close r.
+func SHA512(r io.Reader) (Hash, int64, error) {
+	return digest("sha512", r)
+}
+
+// MultiHash computes the Hash of the provided io.Reader's content for each of
+// algos (e.g. "sha256" and "sha512"), reading it only once. The result is
+// keyed by algorithm. Every algorithm is checked with Hasher before anything
+// is read. Like SHA256, it does not close r.
+func MultiHash(r io.Reader, algos ...string) (map[string]Hash, int64, error) {
+	if len(algos) == 0 {
+		return nil, 0, fmt.Errorf("no hash algorithms given")
+	}
+	hashers := make(map[string]hash.Hash, len(algos))
+	writers := make([]io.Writer, 0, len(algos))
+	for _, algorithm := range algos {
+		if _, ok := hashers[algorithm]; ok {
+			continue
+		}
+		hasher, err := Hasher(algorithm)
+		if err != nil {
+			return nil, 0, err
+		}
+		hashers[algorithm] = hasher
+		writers = append(writers, hasher)
+	}
+	n, err := io.Copy(io.MultiWriter(writers...), r)
+	if err != nil {
+		return nil, 0, err
+	}
+	hashes := make(map[string]Hash, len(hashers))
+	for algorithm, hasher := range hashers {
+		hashes[algorithm] = Hash{
+			Algorithm: algorithm,
+			Hex:       hex.EncodeToString(hasher.Sum(make([]byte, 0, hasher.Size()))),
+		}
+	}
+	return hashes, n, nil
+}
+
+func digest(algorithm string, r io.Reader) (Hash, int64, error) {
+	hasher, err := Hasher(algorithm)
+	if err != nil {
+		return Hash{}, 0, err
+	}
 	n, err := io.Copy(hasher, r)
 	if err != nil {
 		return Hash{}, 0, err
 	}
 	return Hash{
-		Algorithm: "sha256",
+		Algorithm: algorithm,
 		Hex:       hex.EncodeToString(hasher.Sum(make([]byte, 0, hasher.Size()))),
 	}, n, nil
 }
@@ -16,6 +16,7 @@ package v1
 
 import (
 	"encoding/json"
+	"io"
 	"strconv"
 	"strings"
 	"testing"
@@ -25,6 +26,7 @@ func TestGoodHashes(t *testing.T) {
 	good := []string{
 		"sha256:deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef",
 		"sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		"sha512:" + strings.Repeat("0123456789abcdef", 8),
 	}
 
 	for _, s := range good {
@@ -57,6 +59,8 @@ func TestBadHashes(t *testing.T) {
 		"0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
 		// Too many parts
 		"md5:sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		// sha512 with a sha256-sized hex
+		"sha512:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
 	}
 
 	for _, s := range bad {
@@ -67,6 +71,135 @@ func TestBadHashes(t *testing.T) {
 	}
 }
 
+func TestHashParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{{
+		input: "deadbeef",
+		want:  `missing colon separator in hash: "deadbeef"`,
+	}, {
+		input: "md5:sha256:deadbeef",
+		want:  `too many parts in hash: "md5:sha256:deadbeef"`,
+	}, {
+		input: "sha256:",
+		want:  `empty hex in hash: "sha256:"`,
+	}, {
+		input: "sha512:",
+		want:  `empty hex in hash: "sha512:"`,
+	}, {
+		input: "md5:",
+		want:  `empty hex in hash: "md5:"`,
+	}, {
+		input: "sha256:" + strings.Repeat("AB", 32),
+		want:  "found uppercase hex character in hash: A (hex must be lowercase)",
+	}} {
+		_, err := NewHash(tc.input)
+		if err == nil {
+			t.Errorf("NewHash(%q); expected error", tc.input)
+			continue
+		}
+		if got := err.Error(); got != tc.want {
+			t.Errorf("NewHash(%q); got %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestNewHashNormalized(t *testing.T) {
+	want := "sha256:" + strings.Repeat("ab", 32)
+	h, err := NewHashNormalized("sha256:" + strings.Repeat("AB", 32))
+	if err != nil {
+		t.Fatal("NewHashNormalized() =", err)
+	}
+	if got := h.String(); got != want {
+		t.Errorf("NewHashNormalized(); got %q, want %q", got, want)
+	}
+
+	// The algorithm is not normalized.
+	if _, err := NewHashNormalized("SHA256:" + strings.Repeat("ab", 32)); err == nil {
+		t.Error("NewHashNormalized(SHA256:...); expected error")
+	}
+}
+
+func TestTagName(t *testing.T) {
+	h, err := NewHash("sha256:deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := h.TagName()
+	if want := "sha256-deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef"; tag != want {
+		t.Errorf("TagName(); got %q, want %q", tag, want)
+	}
+	got, err := NewHashFromTagName(tag)
+	if err != nil {
+		t.Fatalf("NewHashFromTagName(%q) = %v", tag, err)
+	}
+	if got != h {
+		t.Errorf("NewHashFromTagName(%q); got %v, want %v", tag, got, h)
+	}
+
+	// Longer hashes are truncated to keep the tag valid, and can't be reversed.
+	h, err = NewHash("sha512:" + strings.Repeat("0123456789abcdef", 8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag = h.TagName()
+	if want := "sha512-" + strings.Repeat("0123456789abcdef", 4); tag != want {
+		t.Errorf("TagName(); got %q, want %q", tag, want)
+	}
+	if got, err := NewHashFromTagName(tag); err == nil {
+		t.Errorf("NewHashFromTagName(%q) = %v, expected error", tag, got)
+	}
+	long := Hash{Algorithm: strings.Repeat("a", 40), Hex: strings.Repeat("0", 100)}
+	if want := strings.Repeat("a", 32) + "-" + strings.Repeat("0", 64); long.TagName() != want {
+		t.Errorf("TagName(); got %q, want %q", long.TagName(), want)
+	}
+
+	for _, bad := range []string{
+		"latest",
+		"sha256-deadbeef",
+		"sha256:deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef",
+		"md5-deadbeefdeadbeefdeadbeefdeadbeef",
+		"sha256-DEADBEEFDEADBEEFDEADBEEFDEADBEEFDEADBEEFDEADBEEFDEADBEEFDEADBEEF",
+	} {
+		if h, err := NewHashFromTagName(bad); err == nil {
+			t.Errorf("NewHashFromTagName(%q) = %v, expected error", bad, h)
+		}
+	}
+}
+
+func TestHashEqual(t *testing.T) {
+	hex := strings.Repeat("ab", 32)
+	h := Hash{Algorithm: "sha256", Hex: hex}
+
+	for _, tc := range []struct {
+		other Hash
+		want  bool
+	}{{
+		other: Hash{Algorithm: "sha256", Hex: hex},
+		want:  true,
+	}, {
+		other: Hash{Algorithm: "sha256", Hex: strings.ToUpper(hex)},
+		want:  true,
+	}, {
+		other: Hash{Algorithm: "sha256", Hex: strings.Repeat("cd", 32)},
+		want:  false,
+	}, {
+		other: Hash{Algorithm: "sha512", Hex: hex},
+		want:  false,
+	}, {
+		other: Hash{Algorithm: "sha256", Hex: "not hex"},
+		want:  false,
+	}, {
+		other: Hash{},
+		want:  false,
+	}} {
+		if got := h.Equal(tc.other); got != tc.want {
+			t.Errorf("%s.Equal(%s); got %t, want %t", h, tc.other, got, tc.want)
+		}
+	}
+}
+
 func TestSHA256(t *testing.T) {
 	input := "asdf"
 	h, n, err := SHA256(strings.NewReader(input))
@@ -84,6 +217,129 @@ func TestSHA256(t *testing.T) {
 	}
 }
 
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestSHA256DoesNotClose(t *testing.T) {
+	input := "asdf"
+	rc := &closeRecorder{Reader: strings.NewReader(input)}
+	h, n, err := SHA256(rc)
+	if err != nil {
+		t.Fatal("SHA256() =", err)
+	}
+	if rc.closed {
+		t.Error("SHA256() closed the reader")
+	}
+	if got, want := n, int64(len(input)); got != want {
+		t.Errorf("n; got %v, want %v", got, want)
+	}
+	want, _, err := SHA256(strings.NewReader(input))
+	if err != nil {
+		t.Fatal("SHA256() =", err)
+	}
+	if h != want {
+		t.Errorf("SHA256(); got %v, want %v", h, want)
+	}
+}
+
+func TestSHA512(t *testing.T) {
+	input := "asdf"
+	h, n, err := SHA512(strings.NewReader(input))
+	if err != nil {
+		t.Error("SHA512(asdf) =", err)
+	}
+	if got, want := h.Algorithm, "sha512"; got != want {
+		t.Errorf("Algorithm; got %v, want %v", got, want)
+	}
+	if got, want := h.Hex, "401b09eab3c013d4ca54922bb802bec8fd5318192b0a75f201d8b3727429080fb337591abd3e44453b954555b7a0812e1081c39b740293f765eae731f5a65ed1"; got != want {
+		t.Errorf("Hex; got %v, want %v", got, want)
+	}
+	if got, want := n, int64(len(input)); got != want {
+		t.Errorf("n; got %v, want %v", got, want)
+	}
+	if _, err := NewHash(h.String()); err != nil {
+		t.Errorf("NewHash(%q) = %v", h, err)
+	}
+}
+
+func TestMultiHash(t *testing.T) {
+	input := "asdf"
+	r := &countingReader{Reader: strings.NewReader(input)}
+	hashes, n, err := MultiHash(r, "sha256", "sha512", "sha256")
+	if err != nil {
+		t.Fatal("MultiHash(asdf) =", err)
+	}
+	if got, want := n, int64(len(input)); got != want {
+		t.Errorf("n; got %v, want %v", got, want)
+	}
+	if got, want := r.n, len(input); got != want {
+		t.Errorf("read %d bytes, want %d", got, want)
+	}
+	if got, want := len(hashes), 2; got != want {
+		t.Errorf("len(hashes); got %d, want %d", got, want)
+	}
+	for algorithm, single := range map[string]func(io.Reader) (Hash, int64, error){
+		"sha256": SHA256,
+		"sha512": SHA512,
+	} {
+		want, _, err := single(strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hashes[algorithm]; got != want {
+			t.Errorf("%s; got %v, want %v", algorithm, got, want)
+		}
+	}
+
+	r = &countingReader{Reader: strings.NewReader(input)}
+	if _, _, err := MultiHash(r, "sha256", "md5"); err == nil {
+		t.Error("MultiHash(md5); expected error")
+	}
+	if r.n != 0 {
+		t.Errorf("MultiHash(md5) read %d bytes before failing", r.n)
+	}
+	if _, _, err := MultiHash(r); err == nil {
+		t.Error("MultiHash(); expected error")
+	}
+}
+
+type countingReader struct {
+	io.Reader
+	n int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += n
+	return n, err
+}
+
+func TestHasher(t *testing.T) {
+	for algorithm, size := range map[string]int{
+		"sha256": 32,
+		"sha512": 64,
+	} {
+		h, err := Hasher(algorithm)
+		if err != nil {
+			t.Fatalf("Hasher(%q) = %v", algorithm, err)
+		}
+		if got, want := h.Size(), size; got != want {
+			t.Errorf("Hasher(%q).Size(); got %d, want %d", algorithm, got, want)
+		}
+	}
+
+	if _, err := Hasher("md5"); err == nil {
+		t.Error("Hasher(md5); expected error")
+	}
+}
+
 // This tests that you can use Hash as a key in a map (needs to implement both
 // MarshalText and UnmarshalText).
 func TestTextMarshalling(t *testing.T) {
@@ -112,4 +368,11 @@ func TestTextMarshalling(t *testing.T) {
 	if h.String() != g.String() {
 		t.Errorf("mismatched hash: %s != %s", h, g)
 	}
+	if got, want := string(text), h.String(); got != want {
+		t.Errorf("MarshalText(); got %q, want %q", got, want)
+	}
+
+	if err := g.UnmarshalText([]byte(strconv.Quote(h.String()))); err == nil {
+		t.Error("UnmarshalText(quoted); expected error")
+	}
 }
@@ -54,6 +54,7 @@ type Image interface {
 	LayerByDigest(Hash) (Layer, error)
 
 	// LayerByDiffID is an analog to LayerByDigest, looking up by "diff id"
-	// (the uncompressed hash).
+	// (the uncompressed hash), e.g. from ConfigFile().RootFS.DiffIDs.
+	// Implementations return an error if no layer has the given diff id.
 	LayerByDiffID(Hash) (Layer, error)
 }
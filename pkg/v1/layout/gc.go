@@ -0,0 +1,156 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package layout
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// GarbageCollect removes every blob in the Path that is not reachable from
+// the index.json, and returns the hashes of the removed blobs.
+//
+// Reachability is computed by walking the index.json recursively through
+// nested indexes down to each image's manifest, config and layers. Every
+// manifest is parsed to find what it references, whatever its media type, so
+// artifacts keep their config, layers and blobs, and subjects of referrers
+// are kept too. Files in the blobs directory whose names aren't valid hashes
+// are left alone.
+func (l Path) GarbageCollect() ([]v1.Hash, error) {
+	rawIndex, err := os.ReadFile(l.path("index.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	reachable := map[v1.Hash]bool{}
+	if err := l.markIndex(rawIndex, reachable); err != nil {
+		return nil, err
+	}
+
+	algorithms, err := os.ReadDir(l.path("blobs"))
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []v1.Hash
+	for _, algorithm := range algorithms {
+		if !algorithm.IsDir() {
+			continue
+		}
+		blobs, err := os.ReadDir(l.path("blobs", algorithm.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, blob := range blobs {
+			h, err := v1.NewHash(algorithm.Name() + ":" + blob.Name())
+			if err != nil || blob.IsDir() {
+				continue
+			}
+			if reachable[h] {
+				continue
+			}
+			if err := l.RemoveBlob(h); err != nil {
+				return removed, err
+			}
+			removed = append(removed, h)
+		}
+	}
+
+	return removed, nil
+}
+
+// walkable holds the fields of any manifest or index that reference other
+// blobs. Blobs are parsed into it regardless of their declared media type, so
+// that artifacts and manifests with empty or unknown media types still keep
+// everything they reference.
+type walkable struct {
+	Manifests []v1.Descriptor  `json:"manifests,omitempty"`
+	Config    *v1.Descriptor   `json:"config,omitempty"`
+	Layers    []v1.Descriptor  `json:"layers,omitempty"`
+	Blobs     []v1.Descriptor  `json:"blobs,omitempty"`
+	Subject   *v1.Descriptor   `json:"subject,omitempty"`
+	FSLayers  []schema1FSLayer `json:"fsLayers,omitempty"`
+}
+
+type schema1FSLayer struct {
+	BlobSum v1.Hash `json:"blobSum"`
+}
+
+// markIndex adds every blob reachable from the given raw index to reachable.
+func (l Path) markIndex(rawIndex []byte, reachable map[v1.Hash]bool) error {
+	var index v1.IndexManifest
+	if err := json.Unmarshal(rawIndex, &index); err != nil {
+		return err
+	}
+
+	for _, desc := range index.Manifests {
+		if err := l.markManifest(desc, reachable, true); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// markManifest marks desc and, if its blob parses as a manifest or index of
+// any kind, everything it references. Referenced manifests (children of an
+// index, and subjects) are walked in turn. Blobs that don't parse as JSON,
+// like the blobs of an artifact, are kept without looking any further.
+//
+// If required, it's an error for the blob to be missing, which aborts the
+// collection rather than risk removing blobs it would have referenced.
+func (l Path) markManifest(desc v1.Descriptor, reachable map[v1.Hash]bool, required bool) error {
+	if reachable[desc.Digest] {
+		return nil
+	}
+	reachable[desc.Digest] = true
+
+	b, err := l.Bytes(desc.Digest)
+	if os.IsNotExist(err) && !required {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("reading manifest %s: %w", desc.Digest, err)
+	}
+	var w walkable
+	if err := json.Unmarshal(b, &w); err != nil {
+		// Not a manifest.
+		return nil
+	}
+
+	if w.Config != nil {
+		reachable[w.Config.Digest] = true
+	}
+	for _, layer := range append(w.Layers, w.Blobs...) {
+		reachable[layer.Digest] = true
+	}
+	for _, layer := range w.FSLayers {
+		reachable[layer.BlobSum] = true
+	}
+	for _, child := range w.Manifests {
+		if err := l.markManifest(child, reachable, true); err != nil {
+			return err
+		}
+	}
+	if w.Subject != nil {
+		// The subject may have been pulled separately, or not at all.
+		if err := l.markManifest(*w.Subject, reachable, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package layout
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/match"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestGarbageCollect(t *testing.T) {
+	tmp := t.TempDir()
+	l, err := Write(tmp, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An index nested inside an index is kept.
+	inner, err := random.Index(1024, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outer := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: inner})
+	if err := l.AppendIndex(outer); err != nil {
+		t.Fatal(err)
+	}
+
+	// An image that is appended and subsequently removed is collected.
+	orphan, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.AppendImage(orphan); err != nil {
+		t.Fatal(err)
+	}
+	orphanDigest, err := orphan.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.RemoveDescriptors(match.Digests(orphanDigest)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Files that aren't named after hashes are left alone.
+	if err := os.WriteFile(l.path("blobs", "sha256", "not-a-hash"), []byte("hi"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := l.GarbageCollect()
+	if err != nil {
+		t.Fatalf("GarbageCollect() = %v", err)
+	}
+
+	want := map[v1.Hash]bool{orphanDigest: true}
+	cfg, err := orphan.ConfigName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want[cfg] = true
+	layers, err := orphan.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, layer := range layers {
+		d, err := layer.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[d] = true
+	}
+
+	if got, want := len(removed), len(want); got != want {
+		t.Errorf("GarbageCollect() removed %d blobs, want %d: %v", got, want, removed)
+	}
+	for _, h := range removed {
+		if !want[h] {
+			t.Errorf("GarbageCollect() removed reachable blob %s", h)
+		}
+		if _, err := os.Stat(l.blobPath(h)); !os.IsNotExist(err) {
+			t.Errorf("blob %s still exists: %v", h, err)
+		}
+	}
+	if _, err := os.Stat(l.path("blobs", "sha256", "not-a-hash")); err != nil {
+		t.Errorf("GarbageCollect() removed non-blob file: %v", err)
+	}
+
+	idx, err := l.ImageIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Index(idx); err != nil {
+		t.Errorf("validate.Index() = %v", err)
+	}
+
+	// A second pass has nothing left to do.
+	if removed, err := l.GarbageCollect(); err != nil {
+		t.Fatal(err)
+	} else if len(removed) != 0 {
+		t.Errorf("second GarbageCollect() removed %v", removed)
+	}
+}
+
+func TestGarbageCollectArtifacts(t *testing.T) {
+	tmp := t.TempDir()
+	l, err := Write(tmp, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeBlob := func(b []byte, mt types.MediaType) v1.Descriptor {
+		t.Helper()
+		h, size, err := v1.SHA256(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := l.WriteBlob(h, io.NopCloser(bytes.NewReader(b))); err != nil {
+			t.Fatal(err)
+		}
+		return v1.Descriptor{MediaType: mt, Digest: h, Size: size}
+	}
+	writeManifest := func(m interface{}) v1.Descriptor {
+		t.Helper()
+		b, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return writeBlob(b, "")
+	}
+
+	// An artifact appended with an empty, and one with an unknown, media
+	// type keeps its config and layers.
+	var keep []v1.Hash
+	for _, mt := range []types.MediaType{"", "application/vnd.example.artifact.v1+json"} {
+		cfg := writeBlob([]byte(`{"artifact":"`+string(mt)+`"}`), "application/vnd.example.config")
+		layer := writeBlob([]byte("artifact payload "+string(mt)), "application/vnd.example.payload")
+		desc := writeManifest(map[string]interface{}{
+			"schemaVersion": 2,
+			"mediaType":     mt,
+			"config":        cfg,
+			"layers":        []v1.Descriptor{layer},
+		})
+		desc.MediaType = mt
+		if err := l.AppendDescriptor(desc); err != nil {
+			t.Fatal(err)
+		}
+		keep = append(keep, desc.Digest, cfg.Digest, layer.Digest)
+	}
+
+	// The subject of a referrer is kept, along with its config and layers,
+	// even though only the referrer is in the index.json.
+	subject, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.WriteImage(subject); err != nil {
+		t.Fatal(err)
+	}
+	subjectDesc, err := partial.Descriptor(subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := writeBlob([]byte("signature"), "application/vnd.example.signature")
+	referrer := writeManifest(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     types.OCIManifestSchema1,
+		"artifactType":  "application/vnd.example.signature",
+		"config":        v1.Descriptor{MediaType: "application/vnd.oci.empty.v1+json", Digest: sig.Digest, Size: sig.Size},
+		"layers":        []v1.Descriptor{sig},
+		"subject":       subjectDesc,
+	})
+	referrer.MediaType = types.OCIManifestSchema1
+	if err := l.AppendDescriptor(referrer); err != nil {
+		t.Fatal(err)
+	}
+	keep = append(keep, referrer.Digest, sig.Digest, subjectDesc.Digest)
+	cfg, err := subject.ConfigName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keep = append(keep, cfg)
+	layers, err := subject.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, layer := range layers {
+		d, err := layer.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keep = append(keep, d)
+	}
+
+	// Unreferenced blobs are still collected.
+	orphan := writeBlob([]byte("orphan"), "")
+
+	removed, err := l.GarbageCollect()
+	if err != nil {
+		t.Fatalf("GarbageCollect() = %v", err)
+	}
+	if diff := cmp.Diff([]v1.Hash{orphan.Digest}, removed); diff != "" {
+		t.Errorf("GarbageCollect() removed (-want +got): %s", diff)
+	}
+	for _, h := range keep {
+		if _, err := os.Stat(l.blobPath(h)); err != nil {
+			t.Errorf("reachable blob %s was removed: %v", h, err)
+		}
+	}
+}
+
+func TestGarbageCollectMissingManifest(t *testing.T) {
+	tmp := t.TempDir()
+	l, err := Write(tmp, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orphan, err := random.Layer(1024, types.OCILayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.WriteBlob(mustDigest(t, orphan), io.NopCloser(mustCompressed(t, orphan))); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.AppendDescriptor(v1.Descriptor{
+		Digest: v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("a", 64)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Since it can't tell what the missing manifest references, nothing is
+	// removed.
+	if _, err := l.GarbageCollect(); err == nil {
+		t.Error("GarbageCollect() = nil, wanted error for missing manifest")
+	}
+	if _, err := os.Stat(l.blobPath(mustDigest(t, orphan))); err != nil {
+		t.Errorf("blob was removed despite the error: %v", err)
+	}
+}
+
+func mustDigest(t *testing.T, l v1.Layer) v1.Hash {
+	t.Helper()
+	h, err := l.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
@@ -39,6 +39,10 @@ var layoutFile = `{
 
 // AppendImage writes a v1.Image to the Path and updates
 // the index.json to reference it.
+//
+// Use WithAnnotations to annotate the image's descriptor in index.json, e.g.
+// with "org.opencontainers.image.ref.name" so that tools can find the image
+// by name; match.Annotation finds it again.
 func (l Path) AppendImage(img v1.Image, options ...Option) error {
 	if err := l.WriteImage(img); err != nil {
 		return err
@@ -91,12 +95,7 @@ func (l Path) AppendDescriptor(desc v1.Descriptor) error {
 
 	index.Manifests = append(index.Manifests, desc)
 
-	rawIndex, err := json.MarshalIndent(index, "", "   ")
-	if err != nil {
-		return err
-	}
-
-	return l.WriteFile("index.json", rawIndex, os.ModePerm)
+	return l.writeIndexJSON(index)
 }
 
 // ReplaceImage writes a v1.Image to the Path and updates
@@ -148,12 +147,7 @@ func (l Path) replaceDescriptor(append mutate.Appendable, matcher match.Matcher,
 		return err
 	}
 
-	rawIndex, err := json.MarshalIndent(index, "", "   ")
-	if err != nil {
-		return err
-	}
-
-	return l.WriteFile("index.json", rawIndex, os.ModePerm)
+	return l.writeIndexJSON(index)
 }
 
 // RemoveDescriptors removes any descriptors that match the match.Matcher from the index.json of the Path.
@@ -169,6 +163,17 @@ func (l Path) RemoveDescriptors(matcher match.Matcher) error {
 		return err
 	}
 
+	return l.writeIndexJSON(index)
+}
+
+// writeIndexJSON writes index to the index.json of the Path. Descriptors are
+// written as given, annotations included, and the index's media type is set
+// if it's missing, as the image-layout spec recommends.
+func (l Path) writeIndexJSON(index *v1.IndexManifest) error {
+	if index.MediaType == "" {
+		index.MediaType = types.OCIImageIndex
+	}
+
 	rawIndex, err := json.MarshalIndent(index, "", "   ")
 	if err != nil {
 		return err
@@ -196,6 +201,7 @@ func (l Path) WriteBlob(hash v1.Hash, r io.ReadCloser) error {
 }
 
 func (l Path) writeBlob(hash v1.Hash, size int64, rc io.ReadCloser, renamer func() (v1.Hash, error)) error {
+	defer rc.Close()
 	if hash.Hex == "" && renamer == nil {
 		panic("writeBlob called an invalid hash and no renamer")
 	}
@@ -206,32 +212,28 @@ func (l Path) writeBlob(hash v1.Hash, size int64, rc io.ReadCloser, renamer func
 	}
 
 	// Check if blob already exists and is the correct size
-	file := filepath.Join(dir, hash.Hex)
-	if s, err := os.Stat(file); err == nil && !s.IsDir() && (s.Size() == size || size == -1) {
+	if hash.Hex != "" && l.blobExists(hash, size) {
 		return nil
 	}
-
-	// If a renamer func was provided write to a temporary file
-	open := func() (*os.File, error) { return os.Create(file) }
-	if renamer != nil {
-		open = func() (*os.File, error) { return os.CreateTemp(dir, hash.Hex) }
+	if renamer == nil {
+		renamer = func() (v1.Hash, error) { return hash, nil }
 	}
-	w, err := open()
+
+	// Write to a temporary file and rename it into place once it's complete,
+	// so that an interrupted write never leaves a truncated blob behind.
+	w, err := os.CreateTemp(dir, hash.Hex)
 	if err != nil {
 		return err
 	}
-	if renamer != nil {
-		// Delete temp file if an error is encountered before renaming
-		defer func() {
-			if err := os.Remove(w.Name()); err != nil && !errors.Is(err, os.ErrNotExist) {
-				logs.Warn.Printf("error removing temporary file after encountering an error while writing blob: %v", err)
-			}
-		}()
-	}
+	// Delete temp file if an error is encountered before renaming
+	defer func() {
+		if err := os.Remove(w.Name()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logs.Warn.Printf("error removing temporary file after encountering an error while writing blob: %v", err)
+		}
+	}()
 	defer w.Close()
 
-	// Write to file and exit if not renaming
-	if n, err := io.Copy(w, rc); err != nil || renamer == nil {
+	if n, err := io.Copy(w, rc); err != nil {
 		return err
 	} else if size != -1 && n != size {
 		return fmt.Errorf("expected blob size %d, but only wrote %d", size, n)
@@ -257,10 +259,23 @@ func (l Path) writeBlob(hash v1.Hash, size int64, rc io.ReadCloser, renamer func
 		return fmt.Errorf("error getting final digest of layer: %w", err)
 	}
 
+	// Renaming replaces whatever was there before, including a truncated blob
+	// or a symlink, with a regular file.
 	renamePath := l.path("blobs", finalHash.Algorithm, finalHash.Hex)
 	return os.Rename(w.Name(), renamePath)
 }
 
+// blobExists returns whether the blob for hash already exists as a regular
+// file of the given size. If size is -1, any size is accepted.
+func (l Path) blobExists(hash v1.Hash, size int64) bool {
+	// Lstat, so that we never treat a symlink as the blob.
+	fi, err := os.Lstat(l.path("blobs", hash.Algorithm, hash.Hex))
+	if err != nil || !fi.Mode().IsRegular() {
+		return false
+	}
+	return size == -1 || fi.Size() == size
+}
+
 // writeLayer writes the compressed layer to a blob. Unlike WriteBlob it will
 // write to a temporary file (suffixed with .tmp) within the layout until the
 // compressed reader is fully consumed and written to disk. Also unlike
@@ -293,6 +308,11 @@ func (l Path) writeLayer(layer v1.Layer) error {
 		return err
 	}
 
+	// Don't bother opening the layer if we already have it.
+	if d.Hex != "" && s != -1 && l.blobExists(d, s) {
+		return nil
+	}
+
 	r, err := layer.Compressed()
 	if err != nil {
 		return err
@@ -319,7 +339,8 @@ func (l Path) RemoveBlob(hash v1.Hash) error {
 
 // WriteImage writes an image, including its manifest, config and all of its
 // layers, to the blobs directory. If any blob already exists, as determined by
-// the hash filename, does not write it.
+// the hash filename and size, does not write it. Blobs of the wrong size (e.g.
+// from an interrupted write) are rewritten.
 // This function does *not* update the `index.json` file. If you want to write the
 // image and also update the `index.json`, call AppendImage(), which wraps this
 // and also updates the `index.json`.
@@ -350,7 +371,7 @@ func (l Path) WriteImage(img v1.Image) error {
 	if err != nil {
 		return err
 	}
-	if err := l.WriteBlob(cfgName, io.NopCloser(bytes.NewReader(cfgBlob))); err != nil {
+	if err := l.writeBlob(cfgName, int64(len(cfgBlob)), io.NopCloser(bytes.NewReader(cfgBlob)), nil); err != nil {
 		return err
 	}
 
@@ -364,7 +385,7 @@ func (l Path) WriteImage(img v1.Image) error {
 		return err
 	}
 
-	return l.WriteBlob(d, io.NopCloser(bytes.NewReader(manifest)))
+	return l.writeBlob(d, int64(len(manifest)), io.NopCloser(bytes.NewReader(manifest)), nil)
 }
 
 type withLayer interface {
@@ -404,6 +425,9 @@ func (l Path) writeIndexToFile(indexFile string, ii v1.ImageIndex) error {
 		default:
 			// TODO: The layout could reference arbitrary things, which we should
 			// probably just pass through.
+			if l.blobExists(desc.Digest, desc.Size) {
+				continue
+			}
 
 			var blob io.ReadCloser
 			// Workaround for #819.
@@ -419,7 +443,7 @@ func (l Path) writeIndexToFile(indexFile string, ii v1.ImageIndex) error {
 			if err != nil {
 				return err
 			}
-			if err := l.WriteBlob(desc.Digest, blob); err != nil {
+			if err := l.writeBlob(desc.Digest, desc.Size, blob, nil); err != nil {
 				return err
 			}
 		}
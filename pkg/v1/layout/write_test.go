@@ -20,6 +20,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -28,6 +29,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/match"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/stream"
 	"github.com/google/go-containerregistry/pkg/v1/types"
@@ -184,6 +186,73 @@ func TestOptions(t *testing.T) {
 	}
 }
 
+func TestRefNameAnnotationsRoundtrip(t *testing.T) {
+	const refName = "org.opencontainers.image.ref.name"
+	tmp := t.TempDir()
+	l, err := Write(tmp, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imgs := map[string]v1.Image{}
+	for _, tag := range []string{"v1", "v2"} {
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		imgs[tag] = img
+		if err := l.AppendImage(img, WithAnnotations(map[string]string{refName: tag})); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Replace v2, keeping its name.
+	v2, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imgs["v2"] = v2
+	if err := l.ReplaceImage(v2, match.Annotation(refName, "v2"), WithAnnotations(map[string]string{refName: "v2"})); err != nil {
+		t.Fatal(err)
+	}
+
+	ii, err := l.ImageIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	im, err := ii.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := im.MediaType, types.OCIImageIndex; got != want {
+		t.Errorf("index.json mediaType: got %q, want %q", got, want)
+	}
+	if got, want := len(im.Manifests), 2; got != want {
+		t.Fatalf("len(manifests): got %d, want %d", got, want)
+	}
+	for tag, img := range imgs {
+		found, err := partial.FindImages(ii, match.Annotation(refName, tag))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != 1 {
+			t.Errorf("FindImages(%s): got %d images, want 1", tag, len(found))
+			continue
+		}
+		got, err := found[0].Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("FindImages(%s): got %s, want %s", tag, got, want)
+		}
+	}
+}
+
 func TestDeduplicatedWrites(t *testing.T) {
 	lp, err := FromPath(testPath)
 	if err != nil {
@@ -670,3 +739,157 @@ func TestOverwriteWithReplaceImage(t *testing.T) {
 		t.Fatalf("validating image after attempting repair of truncated layer with ReplaceImage; validate.Image() = %v", err)
 	}
 }
+
+// countingLayer counts how many times its contents are opened.
+type countingLayer struct {
+	v1.Layer
+	opens *int
+}
+
+func (c *countingLayer) Compressed() (io.ReadCloser, error) {
+	*c.opens++
+	return c.Layer.Compressed()
+}
+
+func TestAppendIndexSkipsExistingBlobs(t *testing.T) {
+	tmp := t.TempDir()
+	l, err := Write(tmp, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := random.Index(1024, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.AppendIndex(idx); err != nil {
+		t.Fatalf("AppendIndex() = %v", err)
+	}
+
+	// Truncate a config blob, as if a previous write had crashed.
+	m, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := idx.Image(m.Manifests[0].Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfgName, err := img.ConfigName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfgPath := l.path("blobs", cfgName.Algorithm, cfgName.Hex)
+	if err := os.WriteFile(cfgPath, []byte("{"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writing the same images again shouldn't open any of their layers.
+	opens := 0
+	for _, desc := range m.Manifests {
+		img, err := idx.Image(desc.Digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ls, err := img.Layers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var counted []mutate.Addendum
+		for _, layer := range ls {
+			counted = append(counted, mutate.Addendum{Layer: &countingLayer{Layer: layer, opens: &opens}})
+		}
+		base, err := mutate.ConfigFile(empty.Image, &v1.ConfigFile{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cimg, err := mutate.Append(base, counted...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := l.WriteImage(cimg); err != nil {
+			t.Fatalf("WriteImage() = %v", err)
+		}
+	}
+	if opens != 0 {
+		t.Errorf("opened existing layers %d times, want 0", opens)
+	}
+
+	// But the truncated blob gets rewritten.
+	if err := l.AppendIndex(idx); err != nil {
+		t.Fatalf("AppendIndex() = %v", err)
+	}
+	got, err := l.Bytes(cfgName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := img.RawConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("config blob after rewrite; got %q, want %q", got, want)
+	}
+}
+
+func TestWriteImageReplacesSymlinks(t *testing.T) {
+	tmp := t.TempDir()
+	l, err := Write(tmp, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ls, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := ls[0].Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(mustCompressed(t, ls[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Leave a symlink to a copy of the blob where the blob should be.
+	outside := filepath.Join(t.TempDir(), "blob")
+	if err := os.WriteFile(outside, b, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(l.path("blobs", h.Algorithm), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, l.path("blobs", h.Algorithm, h.Hex)); err != nil {
+		t.Skipf("can't create symlinks: %v", err)
+	}
+
+	if err := l.AppendImage(img); err != nil {
+		t.Fatalf("AppendImage() = %v", err)
+	}
+
+	if err := filepath.Walk(tmp, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			t.Errorf("%s is a symlink", path)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustCompressed(t *testing.T, l v1.Layer) io.Reader {
+	t.Helper()
+	rc, err := l.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rc.Close() })
+	return rc
+}
@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"errors"
 
+	"github.com/google/go-containerregistry/internal/convert"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/stream"
@@ -33,10 +34,16 @@ type image struct {
 	configFile      *v1.ConfigFile
 	manifest        *v1.Manifest
 	annotations     map[string]string
+	subject         *v1.Descriptor
 	mediaType       *types.MediaType
 	configMediaType *types.MediaType
 	diffIDMap       map[v1.Hash]v1.Layer
 	digestMap       map[v1.Hash]v1.Layer
+
+	// layerMediaTypes maps the media types of the base image's layers to the
+	// ones declared in this image's manifest, if it was converted between
+	// Docker schema 2 and OCI.
+	layerMediaTypes map[types.MediaType]types.MediaType
 }
 
 var _ v1.Image = (*image)(nil)
@@ -45,7 +52,58 @@ func (i *image) MediaType() (types.MediaType, error) {
 	if i.mediaType != nil {
 		return *i.mediaType, nil
 	}
-	return i.base.MediaType()
+	mt, err := i.base.MediaType()
+	if err != nil {
+		return "", err
+	}
+	if (i.annotations != nil || i.subject != nil) && mt == types.DockerManifestSchema2 {
+		return types.OCIManifestSchema1, nil
+	}
+	return mt, nil
+}
+
+// convertedLayer overrides the media type of a layer in an image that has
+// been converted between Docker schema 2 and OCI.
+type convertedLayer struct {
+	v1.Layer
+	mediaType types.MediaType
+}
+
+// MediaType implements v1.Layer
+func (l *convertedLayer) MediaType() (types.MediaType, error) {
+	return l.mediaType, nil
+}
+
+// Unwrap returns the layer whose media type is overridden, so that writers
+// can still find where its (unchanged) blob can be mounted from.
+func (l *convertedLayer) Unwrap() v1.Layer {
+	return l.Layer
+}
+
+// Descriptor implements partial.withDescriptor
+func (l *convertedLayer) Descriptor() (*v1.Descriptor, error) {
+	desc, err := partial.Descriptor(l.Layer)
+	if err != nil {
+		return nil, err
+	}
+	desc.MediaType = l.mediaType
+	return desc, nil
+}
+
+// convertLayer wraps l in a convertedLayer if this image has been converted
+// between Docker schema 2 and OCI and l has a media type that was mapped.
+func (i *image) convertLayer(l v1.Layer) (v1.Layer, error) {
+	if i.layerMediaTypes == nil {
+		return l, nil
+	}
+	mt, err := l.MediaType()
+	if err != nil {
+		return nil, err
+	}
+	if to, ok := i.layerMediaTypes[mt]; ok {
+		return &convertedLayer{Layer: l, mediaType: to}, nil
+	}
+	return l, nil
 }
 
 func (i *image) compute() error {
@@ -101,6 +159,10 @@ func (i *image) compute() error {
 		// Fields in the addendum override the original descriptor.
 		if len(add.Annotations) != 0 {
 			desc.Annotations = add.Annotations
+		} else if len(desc.Annotations) == 0 {
+			// Some layers (e.g. from tarball) report empty annotations,
+			// which wouldn't survive a round trip through the manifest.
+			desc.Annotations = nil
 		}
 		if len(add.URLs) != 0 {
 			desc.URLs = add.URLs
@@ -135,13 +197,16 @@ func (i *image) compute() error {
 		manifest.Config.Data = rcfg
 	}
 
-	// If the user wants to mutate the media type of the config
-	if i.configMediaType != nil {
-		manifest.Config.MediaType = *i.configMediaType
-	}
-
+	var layerMediaTypes map[types.MediaType]types.MediaType
 	if i.mediaType != nil {
-		manifest.MediaType = *i.mediaType
+		if convert.IsConversion(manifest.MediaType, *i.mediaType) {
+			layerMediaTypes, err = convert.MediaTypes(manifest, *i.mediaType)
+			if err != nil {
+				return err
+			}
+		} else {
+			manifest.MediaType = *i.mediaType
+		}
 	}
 
 	if i.annotations != nil {
@@ -154,8 +219,29 @@ func (i *image) compute() error {
 		}
 	}
 
+	if i.subject != nil {
+		manifest.Subject = i.subject
+	}
+
+	if i.annotations != nil || i.subject != nil {
+		// Docker schema 2 manifests have no annotations or subject, so
+		// convert the image to the equivalent OCI image.
+		if i.mediaType == nil && manifest.MediaType == types.DockerManifestSchema2 {
+			layerMediaTypes, err = convert.MediaTypes(manifest, types.OCIManifestSchema1)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// If the user wants to mutate the media type of the config
+	if i.configMediaType != nil {
+		manifest.Config.MediaType = *i.configMediaType
+	}
+
 	i.configFile = configFile
 	i.manifest = manifest
+	i.layerMediaTypes = layerMediaTypes
 	i.diffIDMap = diffIDMap
 	i.digestMap = digestMap
 	i.computed = true
@@ -260,19 +346,30 @@ func (i *image) LayerByDigest(h v1.Hash) (v1.Layer, error) {
 	} else if h == cn {
 		return partial.ConfigLayer(i)
 	}
-	if layer, ok := i.digestMap[h]; ok {
-		return layer, nil
+	layer, ok := i.digestMap[h]
+	if !ok {
+		var err error
+		if layer, err = i.base.LayerByDigest(h); err != nil {
+			return nil, err
+		}
 	}
-	return i.base.LayerByDigest(h)
+	return i.convertLayer(layer)
 }
 
 // LayerByDiffID is an analog to LayerByDigest, looking up by "diff id"
 // (the uncompressed hash).
 func (i *image) LayerByDiffID(h v1.Hash) (v1.Layer, error) {
-	if layer, ok := i.diffIDMap[h]; ok {
-		return layer, nil
+	if err := i.compute(); err != nil && !errors.Is(err, stream.ErrNotComputed) {
+		return nil, err
+	}
+	layer, ok := i.diffIDMap[h]
+	if !ok {
+		var err error
+		if layer, err = i.base.LayerByDiffID(h); err != nil {
+			return nil, err
+		}
 	}
-	return i.base.LayerByDiffID(h)
+	return i.convertLayer(layer)
 }
 
 func validate(adds []Addendum) error {
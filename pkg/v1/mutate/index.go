@@ -48,11 +48,23 @@ func computeDescriptor(ia IndexAddendum) (*v1.Descriptor, error) {
 		desc.URLs = ia.Descriptor.URLs
 	}
 	if len(ia.Descriptor.Annotations) != 0 {
-		desc.Annotations = ia.Descriptor.Annotations
+		// Merge rather than replace, so that annotations already on the
+		// descriptor survive unless they're overridden.
+		anns := make(map[string]string, len(desc.Annotations)+len(ia.Descriptor.Annotations))
+		for k, v := range desc.Annotations {
+			anns[k] = v
+		}
+		for k, v := range ia.Descriptor.Annotations {
+			anns[k] = v
+		}
+		desc.Annotations = anns
 	}
 	if ia.Descriptor.Data != nil {
 		desc.Data = ia.Descriptor.Data
 	}
+	if ia.Descriptor.ArtifactType != "" {
+		desc.ArtifactType = ia.Descriptor.ArtifactType
+	}
 
 	return desc, nil
 }
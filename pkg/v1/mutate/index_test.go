@@ -23,6 +23,7 @@ import (
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/google/go-containerregistry/pkg/v1/validate"
@@ -139,6 +140,99 @@ func TestAppendIndex(t *testing.T) {
 	}
 }
 
+func TestAppendIndexBadDescriptor(t *testing.T) {
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := img.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{
+		Add: img,
+		Descriptor: v1.Descriptor{
+			Size:     size + 1,
+			Platform: &v1.Platform{OS: "linux", Architecture: "arm64"},
+		},
+	})
+
+	err = validate.Index(idx)
+	if err == nil {
+		t.Fatal("validate.Index() with wrong descriptor size; expected error")
+	}
+	for _, want := range []string{"mismatched size", "linux/arm64"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("validate.Index() = %v, expected it to contain %q", err, want)
+		}
+	}
+}
+
+func TestAppendImage(t *testing.T) {
+	platformImage := func(p v1.Platform) v1.Image {
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cf, err := img.ConfigFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cf.OS = p.OS
+		cf.Architecture = p.Architecture
+		cf.Variant = p.Variant
+		cf.OSVersion = p.OSVersion
+		img, err = mutate.ConfigFile(img, cf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+
+	arm := v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}
+	windows := v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.1339"}
+	override := v1.Platform{OS: "linux", Architecture: "riscv64"}
+
+	var idx v1.ImageIndex = empty.Index
+	for _, tc := range []struct {
+		img  v1.Image
+		opts []partial.DescriptorOption
+	}{{
+		img: platformImage(arm),
+	}, {
+		img:  platformImage(windows),
+		opts: []partial.DescriptorOption{partial.WithAnnotations(map[string]string{"foo": "bar"})},
+	}, {
+		img:  platformImage(arm),
+		opts: []partial.DescriptorOption{partial.WithPlatform(override)},
+	}} {
+		var err error
+		idx, err = mutate.AppendImage(idx, tc.img, tc.opts...)
+		if err != nil {
+			t.Fatalf("AppendImage: %v", err)
+		}
+	}
+
+	if err := validate.Index(idx); err != nil {
+		t.Errorf("validate.Index: %v", err)
+	}
+	m, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []v1.Platform
+	for _, desc := range m.Manifests {
+		got = append(got, *desc.Platform)
+	}
+	if diff := cmp.Diff([]v1.Platform{arm, windows, override}, got); diff != "" {
+		t.Errorf("platforms (-want +got): %s", diff)
+	}
+	if got, want := m.Manifests[1].Annotations["foo"], "bar"; got != want {
+		t.Errorf("annotation; got %q, want %q", got, want)
+	}
+}
+
 func TestIndexImmutability(t *testing.T) {
 	base, err := random.Index(1024, 3, 3)
 	if err != nil {
@@ -180,3 +274,173 @@ func TestIndexImmutability(t *testing.T) {
 		}
 	})
 }
+
+// describedImage is an image that carries its own descriptor, like images
+// read from a remote index do.
+type describedImage struct {
+	v1.Image
+	desc v1.Descriptor
+}
+
+func (d *describedImage) Descriptor() (*v1.Descriptor, error) {
+	return &d.desc, nil
+}
+
+func TestIndexAnnotationsRoundTrip(t *testing.T) {
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := partial.Descriptor(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc.Annotations = map[string]string{
+		"org.opencontainers.image.ref.name": "original",
+		"com.example.kept":                  "yes",
+	}
+
+	add := mutate.IndexAddendum{
+		Add: &describedImage{Image: img, desc: *desc},
+		Descriptor: v1.Descriptor{
+			Annotations: map[string]string{
+				"org.opencontainers.image.ref.name": "override",
+				"com.example.provenance":            "build-123",
+			},
+		},
+	}
+	idx := mutate.AppendManifests(empty.Index, add)
+	idx = mutate.Annotations(idx, map[string]string{
+		"com.example.index": "top",
+		"com.example.other": "level",
+	}).(v1.ImageIndex)
+
+	m, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{
+		"com.example.index": "top",
+		"com.example.other": "level",
+	}; !cmp.Equal(m.Annotations, want) {
+		t.Errorf("index annotations (-want +got): %s", cmp.Diff(want, m.Annotations))
+	}
+	if want := map[string]string{
+		"org.opencontainers.image.ref.name": "override",
+		"com.example.kept":                  "yes",
+		"com.example.provenance":            "build-123",
+	}; !cmp.Equal(m.Manifests[0].Annotations, want) {
+		t.Errorf("child annotations (-want +got): %s", cmp.Diff(want, m.Manifests[0].Annotations))
+	}
+
+	// Re-serializing after a round trip should produce the same bytes.
+	b, err := idx.RawManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := v1.ParseIndexManifest(strings.NewReader(string(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	children := make([]mutate.IndexAddendum, 0, len(parsed.Manifests))
+	for _, d := range parsed.Manifests {
+		children = append(children, mutate.IndexAddendum{
+			Add:        &describedImage{Image: img, desc: d},
+			Descriptor: d,
+		})
+	}
+	again := mutate.AppendManifests(empty.Index, children...)
+	again = mutate.Annotations(again, parsed.Annotations).(v1.ImageIndex)
+	rb, err := again.RawManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != string(rb) {
+		t.Errorf("round trip not byte-stable:\n%s\n%s", b, rb)
+	}
+}
+
+// verbatimImage is an image whose raw manifest isn't what json.Marshal of its
+// Manifest would produce.
+type verbatimImage struct {
+	v1.Image
+	raw []byte
+}
+
+func (i *verbatimImage) RawManifest() ([]byte, error) { return i.raw, nil }
+
+func (i *verbatimImage) Digest() (v1.Hash, error) { return partial.Digest(i) }
+
+func (i *verbatimImage) Size() (int64, error) { return partial.Size(i) }
+
+func TestIndexPreservesChildManifests(t *testing.T) {
+	base, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := img.RawManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Reformat the manifest and add a field that v1.Manifest doesn't know
+	// about, so that re-serializing it would change its digest.
+	raw = []byte(strings.Replace(string(raw), "{", "{\n  \"x-extension\": true,\n  ", 1))
+	child := &verbatimImage{Image: img, raw: raw}
+	want, err := child.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx := mutate.AppendManifests(base, mutate.IndexAddendum{Add: child})
+	// Further mutations shouldn't touch the child either.
+	other, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx = mutate.AppendManifests(idx, mutate.IndexAddendum{Add: other})
+	idx = mutate.Annotations(idx, map[string]string{"foo": "bar"}).(v1.ImageIndex)
+
+	m, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, desc := range m.Manifests {
+		if desc.Digest != want {
+			continue
+		}
+		found = true
+		if desc.Size != int64(len(raw)) {
+			t.Errorf("Size = %d, want %d", desc.Size, len(raw))
+		}
+		got, err := idx.Image(desc.Digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotRaw, err := got.RawManifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := cmp.Diff(string(raw), string(gotRaw)); d != "" {
+			t.Errorf("RawManifest() (-want +got): %s", d)
+		}
+	}
+	if !found {
+		t.Errorf("no descriptor with digest %s in %v", want, m.Manifests)
+	}
+
+	// Children of the base index are untouched too.
+	bm, err := base.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, desc := range bm.Manifests {
+		if d := cmp.Diff(desc, m.Manifests[i]); d != "" {
+			t.Errorf("Manifests[%d] (-want +got): %s", i, d)
+		}
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"github.com/google/go-containerregistry/internal/gzip"
+	"github.com/google/go-containerregistry/pkg/compression"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/match"
@@ -36,6 +37,10 @@ import (
 
 const whiteoutPrefix = ".wh."
 
+// opaqueWhiteout marks its directory as opaque, hiding the contents of lower
+// layers.
+const opaqueWhiteout = whiteoutPrefix + whiteoutPrefix + ".opq"
+
 // Addendum contains layers and history to be appended
 // to a base image
 type Addendum struct {
@@ -44,6 +49,19 @@ type Addendum struct {
 	URLs        []string
 	Annotations map[string]string
 	MediaType   types.MediaType
+
+	// Compression, if set, recompresses Layer with the given algorithm before
+	// appending it. For compression.ZStd, the layer's media type becomes
+	// types.OCILayerZStd. The layer's DiffID is unaffected.
+	Compression compression.Compression
+
+	// CompressionLevel, if set, sets the level used to recompress Layer. If
+	// Compression isn't set, the layer is recompressed with gzip, which
+	// accepts levels from gzip.HuffmanOnly to gzip.BestCompression, including
+	// gzip.NoCompression. The compressed bytes (and so the digest) change;
+	// a gzip media type stays the same, while uncompressed and zstd layers
+	// become types.OCILayer (or types.OCIRestrictedLayer).
+	CompressionLevel *int
 }
 
 // AppendLayers applies layers to a base image.
@@ -65,12 +83,70 @@ func Append(base v1.Image, adds ...Addendum) (v1.Image, error) {
 		return nil, err
 	}
 
+	adds, err := recompress(adds)
+	if err != nil {
+		return nil, err
+	}
+
 	return &image{
 		base: base,
 		adds: adds,
 	}, nil
 }
 
+// recompress returns a copy of adds where any layers with an explicit
+// Compression have been recompressed accordingly.
+func recompress(adds []Addendum) ([]Addendum, error) {
+	out := make([]Addendum, len(adds))
+	for i, add := range adds {
+		out[i] = add
+		if add.Compression == "" && add.CompressionLevel != nil {
+			add.Compression = compression.GZip
+		}
+		if add.Compression == "" || add.Layer == nil {
+			continue
+		}
+
+		mt, err := add.Layer.MediaType()
+		if err != nil {
+			return nil, err
+		}
+		switch add.Compression {
+		case compression.ZStd:
+			// Keep non-distributable layers from being pushed.
+			if mt.IsDistributable() {
+				mt = types.OCILayerZStd
+			} else {
+				mt = types.OCIRestrictedLayerZStd
+			}
+		case compression.GZip:
+			switch mt {
+			case types.OCILayer, types.OCIRestrictedLayer, types.DockerLayer, types.DockerForeignLayer:
+				// Already a gzip media type.
+			case types.OCILayerZStd, types.OCIUncompressedLayer:
+				mt = types.OCILayer
+			case types.OCIRestrictedLayerZStd, types.OCIUncompressedRestrictedLayer:
+				mt = types.OCIRestrictedLayer
+			default:
+				mt = types.DockerLayer
+			}
+		default:
+			return nil, fmt.Errorf("unsupported compression for appended layer: %q", add.Compression)
+		}
+
+		opts := []tarball.LayerOption{tarball.WithCompression(add.Compression), tarball.WithMediaType(mt)}
+		if add.CompressionLevel != nil {
+			opts = append(opts, tarball.WithCompressionLevel(*add.CompressionLevel))
+		}
+		l, err := tarball.LayerFromOpener(add.Layer.Uncompressed, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("recompressing layer with %s: %w", add.Compression, err)
+		}
+		out[i].Layer = l
+	}
+	return out, nil
+}
+
 // Appendable is an interface that represents something that can be appended
 // to an ImageIndex. We need to be able to construct a v1.Descriptor in order
 // to append something, and this is the minimum required information for that.
@@ -88,6 +164,11 @@ type IndexAddendum struct {
 }
 
 // AppendManifests appends a manifest to the ImageIndex.
+//
+// Only the index itself is re-serialized: the descriptors of existing children
+// are kept as they are, and the descriptors of appended children are computed
+// from their RawManifest, which is preserved verbatim, so writing the result
+// (e.g. with remote.WriteIndex) doesn't change any children's digests.
 func AppendManifests(base v1.ImageIndex, adds ...IndexAddendum) v1.ImageIndex {
 	return &index{
 		base: base,
@@ -95,6 +176,21 @@ func AppendManifests(base v1.ImageIndex, adds ...IndexAddendum) v1.ImageIndex {
 	}
 }
 
+// AppendImage returns a new v1.ImageIndex with img appended to base, using a
+// descriptor whose platform is inferred from img's config file (os,
+// architecture, variant and os.version). Use partial.WithPlatform to override
+// it, or other partial.DescriptorOptions to set e.g. annotations.
+func AppendImage(base v1.ImageIndex, img v1.Image, opts ...partial.DescriptorOption) (v1.ImageIndex, error) {
+	desc, err := partial.ImageDescriptor(img, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return AppendManifests(base, IndexAddendum{
+		Add:        img,
+		Descriptor: *desc,
+	}), nil
+}
+
 // RemoveManifests removes any descriptors that match the match.Matcher.
 func RemoveManifests(base v1.ImageIndex, matcher match.Matcher) v1.ImageIndex {
 	return &index{
@@ -110,11 +206,29 @@ func Config(base v1.Image, cfg v1.Config) (v1.Image, error) {
 		return nil, err
 	}
 
-	cf.Config = cfg
+	// base may return a config file it has cached, so don't modify it.
+	cf = cf.DeepCopy()
+	cf.Config = *cfg.DeepCopy()
 
 	return ConfigFile(base, cf)
 }
 
+// CopyConfigFile returns a deep copy of the v1.ConfigFile of img, which can be
+// safely modified and passed to WithConfigFile without affecting img.
+func CopyConfigFile(img v1.Image) (*v1.ConfigFile, error) {
+	cf, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	return cf.DeepCopy(), nil
+}
+
+// WithConfigFile returns an image like base, with its whole config file
+// replaced by a copy of cfg, such as one returned by CopyConfigFile.
+func WithConfigFile(base v1.Image, cfg *v1.ConfigFile) (v1.Image, error) {
+	return ConfigFile(base, cfg)
+}
+
 // Annotatable represents a manifest that can carry annotations.
 type Annotatable interface {
 	partial.WithRawManifest
@@ -135,6 +249,13 @@ type Annotatable interface {
 //	    "foo": "bar",
 //	}).(v1.ImageIndex)
 //
+// Docker schema 2 image manifests have no annotations field, so annotating
+// such an image converts it to the equivalent OCI image: the manifest, config
+// and layer media types are replaced with their OCI counterparts (unless
+// MediaType or ConfigMediaType were used to set them explicitly). Beyond the
+// annotations themselves, this changes the image's digest. The layers' blobs
+// are unchanged, so remote.Write can still mount them from their source.
+//
 // If the input Annotatable is not an Image or ImageIndex, the result will
 // attempt to lazily annotate the raw manifest.
 func Annotations(f Annotatable, anns map[string]string) Annotatable {
@@ -153,6 +274,18 @@ func Annotations(f Annotatable, anns map[string]string) Annotatable {
 	return arbitraryRawManifest{f, anns}
 }
 
+// Subject sets the subject of img's manifest to the given descriptor, which
+// makes img a referrer of the subject in the OCI Referrers API.
+//
+// Docker schema 2 manifests have no subject field, so like Annotations this
+// converts such an image to the equivalent OCI image.
+func Subject(img v1.Image, subject v1.Descriptor) v1.Image {
+	return &image{
+		base:    img,
+		subject: &subject,
+	}
+}
+
 type arbitraryRawManifest struct {
 	a    Annotatable
 	anns map[string]string
@@ -168,7 +301,7 @@ func (a arbitraryRawManifest) RawManifest() ([]byte, error) {
 		return nil, err
 	}
 	if ann, ok := m["annotations"]; ok {
-		if annm, ok := ann.(map[string]string); ok {
+		if annm, ok := ann.(map[string]any); ok {
 			for k, v := range a.anns {
 				annm[k] = v
 			}
@@ -181,7 +314,10 @@ func (a arbitraryRawManifest) RawManifest() ([]byte, error) {
 	return json.Marshal(m)
 }
 
-// ConfigFile mutates the provided v1.Image to have the provided v1.ConfigFile
+// ConfigFile mutates the provided v1.Image to have the provided v1.ConfigFile.
+//
+// The whole config file is replaced. cfg is copied, so later changes to it
+// don't affect the returned image.
 func ConfigFile(base v1.Image, cfg *v1.ConfigFile) (v1.Image, error) {
 	m, err := base.Manifest()
 	if err != nil {
@@ -191,7 +327,7 @@ func ConfigFile(base v1.Image, cfg *v1.ConfigFile) (v1.Image, error) {
 	image := &image{
 		base:       base,
 		manifest:   m.DeepCopy(),
-		configFile: cfg,
+		configFile: cfg.DeepCopy(),
 	}
 
 	return image, nil
@@ -211,7 +347,9 @@ func CreatedAt(base v1.Image, created v1.Time) (v1.Image, error) {
 }
 
 // Extract takes an image and returns an io.ReadCloser containing the image's
-// flattened filesystem.
+// flattened filesystem. Whiteouts are resolved: files deleted by a later
+// layer are omitted, and the ".wh." marker entries themselves are dropped.
+// Use ExtractLayer to see what a single layer changed, including deletions.
 //
 // Callers can read the filesystem contents by passing the reader to
 // tar.NewReader, or io.Copy it directly to some output.
@@ -232,14 +370,47 @@ func Extract(img v1.Image) io.ReadCloser {
 	return pr
 }
 
+// ExtractLayer returns an io.ReadCloser containing the uncompressed tar of
+// layer alone, exactly as the layer records it. Unlike Extract, nothing is
+// resolved, so whiteout entries (".wh." files and ".wh..wh..opq" markers)
+// are kept and callers can see what the layer deletes.
+//
+// Errors, e.g. from decompressing the layer, are returned by Read. If a
+// caller doesn't read the full contents, they should Close it.
+func ExtractLayer(layer v1.Layer) io.ReadCloser {
+	pr, pw := io.Pipe()
+
+	go func() {
+		rc, err := layer.Uncompressed()
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		defer rc.Close()
+		_, err = io.Copy(pw, rc)
+		pw.CloseWithError(err)
+	}()
+
+	return pr
+}
+
 // Adapted from https://github.com/google/containerregistry/blob/da03b395ccdc4e149e34fbb540483efce962dc64/client/v2_2/docker_image_.py#L816
 func extract(img v1.Image, w io.Writer) error {
+	return extractLayers(img.Layers, w)
+}
+
+// extractLayers writes the filesystem that results from applying the layers
+// returned by getLayers in order, with whiteouts resolved, to w as a tarball.
+func extractLayers(getLayers func() ([]v1.Layer, error), w io.Writer) error {
 	tarWriter := tar.NewWriter(w)
 	defer tarWriter.Close()
 
 	fileMap := map[string]bool{}
+	// Directories made opaque by a layer we've already processed. Entries
+	// under these from lower layers are hidden.
+	opaqueDirs := map[string]bool{}
 
-	layers, err := img.Layers()
+	layers, err := getLayers()
 	if err != nil {
 		return fmt.Errorf("retrieving image layers: %w", err)
 	}
@@ -255,6 +426,7 @@ func extract(img v1.Image, w io.Writer) error {
 		}
 		defer layerReader.Close()
 		tarReader := tar.NewReader(layerReader)
+		layerOpaque := map[string]bool{}
 		for {
 			header, err := tarReader.Next()
 			if errors.Is(err, io.EOF) {
@@ -274,6 +446,12 @@ func extract(img v1.Image, w io.Writer) error {
 
 			basename := filepath.Base(header.Name)
 			dirname := filepath.Dir(header.Name)
+			// An opaque whiteout hides everything under its directory from
+			// lower layers, but not entries from this layer.
+			if basename == opaqueWhiteout {
+				layerOpaque[dirname] = true
+				continue
+			}
 			tombstone := strings.HasPrefix(basename, whiteoutPrefix)
 			if tombstone {
 				basename = basename[len(whiteoutPrefix):]
@@ -293,7 +471,7 @@ func extract(img v1.Image, w io.Writer) error {
 			}
 
 			// check for a whited out parent directory
-			if inWhiteoutDir(fileMap, name) {
+			if inWhiteoutDir(fileMap, name) || inOpaqueDir(opaqueDirs, name) {
 				continue
 			}
 
@@ -311,10 +489,25 @@ func extract(img v1.Image, w io.Writer) error {
 				}
 			}
 		}
+		for dir := range layerOpaque {
+			opaqueDirs[dir] = true
+		}
 	}
 	return nil
 }
 
+// inOpaqueDir returns true if any parent directory of file is in opaqueDirs.
+func inOpaqueDir(opaqueDirs map[string]bool, file string) bool {
+	for dir := filepath.Dir(file); ; dir = filepath.Dir(dir) {
+		if opaqueDirs[dir] {
+			return true
+		}
+		if dir == "." || dir == "/" {
+			return false
+		}
+	}
+}
+
 func inWhiteoutDir(fileMap map[string]bool, file string) bool {
 	for {
 		if file == "" {
@@ -340,6 +533,11 @@ func max(a, b int) int {
 }
 
 // Time sets all timestamps in an image to the given timestamp.
+//
+// This includes the config's created time, every history entry's created
+// time, and the ModTime of every entry in each layer. Access and change times
+// are also set to t for entries that record them. Since the layers are
+// rewritten, their digests and the config's diff_ids change accordingly.
 func Time(img v1.Image, t time.Time) (v1.Image, error) {
 	newImage := empty.Image
 
@@ -437,6 +635,14 @@ func layerTime(layer v1.Layer, t time.Time) (v1.Layer, error) {
 		}
 
 		header.ModTime = t
+		// Only rewrite access and change times that are present, since
+		// recording them requires a different tar format.
+		if !header.AccessTime.IsZero() {
+			header.AccessTime = t
+		}
+		if !header.ChangeTime.IsZero() {
+			header.ChangeTime = t
+		}
 		if err := tarWriter.WriteHeader(header); err != nil {
 			return nil, fmt.Errorf("writing tar header: %w", err)
 		}
@@ -476,14 +682,32 @@ func Canonical(img v1.Image) (v1.Image, error) {
 		return nil, err
 	}
 
+	return CanonicalConfig(img)
+}
+
+// CanonicalConfig strips the fields of img's config that vary from build to
+// build of the same content: the created timestamps of the config and its
+// history, container, config.Hostname and docker_version. Fields this package
+// doesn't model, like container_config, are dropped when the config is
+// re-serialized, and map keys are written in sorted order, so identical
+// content produces the same config digest.
+//
+// Unlike Canonical, layers are left untouched.
+func CanonicalConfig(img v1.Image) (v1.Image, error) {
 	cf, err := img.ConfigFile()
 	if err != nil {
 		return nil, err
 	}
 
-	// Get rid of host-dependent random config
 	cfg := cf.DeepCopy()
 
+	// Set all timestamps to 0
+	cfg.Created = v1.Time{}
+	for i := range cfg.History {
+		cfg.History[i].Created = v1.Time{}
+	}
+
+	// Get rid of host-dependent random config
 	cfg.Container = ""
 	cfg.Config.Hostname = ""
 	cfg.DockerVersion = ""
@@ -491,7 +715,80 @@ func Canonical(img v1.Image) (v1.Image, error) {
 	return ConfigFile(img, cfg)
 }
 
+// Flatten squashes all of img's layers into a single layer containing its
+// flattened filesystem (see Extract), with whiteouts resolved.
+//
+// The resulting config keeps everything but layer-specific information: its
+// rootfs.diff_ids has a single entry and its history collapses to one entry.
+// The manifest's media type and annotations are also preserved.
+func Flatten(img v1.Image) (v1.Image, error) {
+	digest, err := img.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("getting digest: %w", err)
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	ocf, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("getting config: %w", err)
+	}
+
+	cf := ocf.DeepCopy()
+	cf.RootFS.DiffIDs = []v1.Hash{}
+	cf.History = []v1.History{}
+
+	base, err := ConfigFile(empty.Image, cf)
+	if err != nil {
+		return nil, fmt.Errorf("mutating config: %w", err)
+	}
+
+	mt, err := img.MediaType()
+	if err != nil {
+		return nil, err
+	}
+	layerType := types.DockerLayer
+	if mt == types.OCIManifestSchema1 {
+		layerType = types.OCILayer
+	}
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return Extract(img), nil
+	}, tarball.WithMediaType(layerType))
+	if err != nil {
+		return nil, fmt.Errorf("flattening layers: %w", err)
+	}
+
+	flat, err := Append(base, Addendum{
+		Layer: layer,
+		History: v1.History{
+			Created:   ocf.Created,
+			CreatedBy: fmt.Sprintf("flatten %s", digest),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("appending layer: %w", err)
+	}
+
+	flat = MediaType(flat, mt)
+	flat = ConfigMediaType(flat, m.Config.MediaType)
+	if len(m.Annotations) != 0 {
+		flat = Annotations(flat, m.Annotations).(v1.Image)
+	}
+	return flat, nil
+}
+
 // MediaType modifies the MediaType() of the given image.
+//
+// If that converts a Docker schema 2 image to OCI (types.OCIManifestSchema1)
+// or vice versa, the config and layer media types in the manifest are mapped
+// to their equivalents too, e.g. types.DockerLayer to types.OCILayer, and the
+// layers returned by the image report the new media types. Layer contents are
+// unchanged. Other media types are left alone, except that it is an error to
+// convert an image with layers that have no equivalent in mt, such as
+// types.OCILayerZStd to Docker. Errors are returned when the image is accessed.
+//
+// Any other mt just overrides the manifest media type.
 func MediaType(img v1.Image, mt types.MediaType) v1.Image {
 	return &image{
 		base:      img,
@@ -17,9 +17,12 @@ package mutate_test
 import (
 	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"errors"
+	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"reflect"
 	"strings"
@@ -28,11 +31,14 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/google/go-containerregistry/pkg/compression"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/match"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/static"
 	"github.com/google/go-containerregistry/pkg/v1/stream"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/google/go-containerregistry/pkg/v1/types"
@@ -83,6 +89,71 @@ func TestExtractOverwrittenFile(t *testing.T) {
 	}
 }
 
+func TestExtractLayerKeepsWhiteouts(t *testing.T) {
+	img, err := tarball.ImageFromPath("testdata/whiteout_image.tar", nil)
+	if err != nil {
+		t.Fatalf("Error loading image: %v", err)
+	}
+	names := func(rc io.ReadCloser) []string {
+		t.Helper()
+		defer rc.Close()
+		var names []string
+		tr := tar.NewReader(rc)
+		for {
+			header, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				return names
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, header.Name)
+		}
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var whiteouts []string
+	for _, l := range layers {
+		for _, name := range names(mutate.ExtractLayer(l)) {
+			if strings.HasPrefix(path.Base(name), ".wh.") {
+				whiteouts = append(whiteouts, name)
+			}
+		}
+	}
+	if len(whiteouts) == 0 {
+		t.Fatal("ExtractLayer: no whiteout entries found in any layer")
+	}
+
+	flattened := names(mutate.Extract(img))
+	for _, wh := range whiteouts {
+		deleted := path.Join(path.Dir(wh), strings.TrimPrefix(path.Base(wh), ".wh."))
+		for _, name := range flattened {
+			if name == wh || path.Clean(name) == deleted {
+				t.Errorf("Extract: found %q, which %q deletes", name, wh)
+			}
+		}
+	}
+}
+
+func TestExtractLayerError(t *testing.T) {
+	rc := mutate.ExtractLayer(invalidLayer{})
+	if _, err := io.Copy(io.Discard, rc); !errors.Is(err, errInvalidImage) {
+		t.Errorf("rc.Read; got %v, want %v", err, errInvalidImage)
+	}
+}
+
+// invalidLayer is a layer which returns an error when Uncompressed() is called.
+type invalidLayer struct {
+	v1.Layer
+}
+
+func (invalidLayer) Uncompressed() (io.ReadCloser, error) {
+	return nil, errInvalidImage
+}
+
 // TestExtractError tests that if there are any errors encountered
 func TestExtractError(t *testing.T) {
 	rc := mutate.Extract(invalidImage{})
@@ -188,6 +259,164 @@ func TestAppendWithAddendum(t *testing.T) {
 	}
 }
 
+func TestAppendWithCompression(t *testing.T) {
+	source := sourceImage(t)
+	layer, err := random.Layer(100, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := layer.DiffID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := mutate.Append(source, mutate.Addendum{
+		Layer:       layer,
+		Compression: compression.ZStd,
+	})
+	if err != nil {
+		t.Fatalf("failed to append: %v", err)
+	}
+
+	m, err := result.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Layers[1].MediaType, types.OCILayerZStd; got != want {
+		t.Errorf("MediaType; got %v, want %v", got, want)
+	}
+
+	cf := getConfigFile(t, result)
+	if got := cf.RootFS.DiffIDs[1]; got != want {
+		t.Errorf("DiffID; got %v, want %v", got, want)
+	}
+
+	layers := getLayers(t, result)
+	rc, err := layers[1].Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(rc, magic); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}) {
+		t.Errorf("Compressed() is not zstd; got magic %x", magic)
+	}
+
+	if _, err := mutate.Append(source, mutate.Addendum{
+		Layer:       layer,
+		Compression: compression.None,
+	}); err == nil {
+		t.Error("Append() with unsupported compression; expected error")
+	}
+}
+
+func TestAppendWithCompressionLevel(t *testing.T) {
+	source := sourceImage(t)
+	layer, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffID, err := layer.DiffID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digests := map[int]v1.Hash{}
+	for _, level := range []int{gzip.NoCompression, gzip.BestSpeed, gzip.BestCompression} {
+		level := level
+		result, err := mutate.Append(source, mutate.Addendum{
+			Layer:            layer,
+			CompressionLevel: &level,
+		})
+		if err != nil {
+			t.Fatalf("failed to append: %v", err)
+		}
+		m, err := result.Manifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := m.Layers[1].MediaType, types.DockerLayer; got != want {
+			t.Errorf("level %d: MediaType; got %v, want %v", level, got, want)
+		}
+		cf := getConfigFile(t, result)
+		if got := cf.RootFS.DiffIDs[1]; got != diffID {
+			t.Errorf("level %d: DiffID; got %v, want %v", level, got, diffID)
+		}
+		if err := validate.Image(result); err != nil {
+			t.Errorf("level %d: validate.Image() = %v", level, err)
+		}
+		digests[level] = m.Layers[1].Digest
+	}
+	if digests[gzip.BestSpeed] == digests[gzip.BestCompression] || digests[gzip.NoCompression] == digests[gzip.BestSpeed] {
+		t.Errorf("expected digests to differ between compression levels: %v", digests)
+	}
+
+	// Recompressing an uncompressed layer makes it a gzip OCI layer.
+	uncompressed, err := random.Layer(1024, types.OCIUncompressedLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	level := gzip.BestSpeed
+	result, err := mutate.Append(source, mutate.Addendum{
+		Layer:            uncompressed,
+		CompressionLevel: &level,
+	})
+	if err != nil {
+		t.Fatalf("failed to append: %v", err)
+	}
+	m, err := result.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Layers[1].MediaType, types.OCILayer; got != want {
+		t.Errorf("uncompressed: MediaType; got %v, want %v", got, want)
+	}
+
+	invalid := gzip.BestCompression + 1
+	if _, err := mutate.Append(source, mutate.Addendum{
+		Layer:            layer,
+		CompressionLevel: &invalid,
+	}); err == nil {
+		t.Error("Append() with invalid compression level; expected error")
+	}
+}
+
+func TestAppendWithCompressionNondistributable(t *testing.T) {
+	for _, tc := range []struct {
+		in          types.MediaType
+		compression compression.Compression
+		want        types.MediaType
+	}{
+		{types.DockerForeignLayer, compression.ZStd, types.OCIRestrictedLayerZStd},
+		{types.OCIRestrictedLayer, compression.ZStd, types.OCIRestrictedLayerZStd},
+		{types.OCIRestrictedLayerZStd, compression.GZip, types.OCIRestrictedLayer},
+	} {
+		t.Run(fmt.Sprintf("%s to %s", tc.in, tc.compression), func(t *testing.T) {
+			layer, err := random.Layer(100, tc.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := mutate.Append(empty.Image, mutate.Addendum{
+				Layer:       layer,
+				Compression: tc.compression,
+			})
+			if err != nil {
+				t.Fatalf("failed to append: %v", err)
+			}
+			m, err := result.Manifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := m.Layers[0].MediaType; got != tc.want {
+				t.Errorf("MediaType; got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
 func TestAppendLayers(t *testing.T) {
 	source := sourceImage(t)
 	layer, err := random.Layer(100, types.DockerLayer)
@@ -266,12 +495,109 @@ func TestMutateConfig(t *testing.T) {
 	}
 }
 
+// cachedConfigImage returns the same config file from every call to
+// ConfigFile, like images that cache it do.
+type cachedConfigImage struct {
+	v1.Image
+	cf *v1.ConfigFile
+}
+
+func (i *cachedConfigImage) ConfigFile() (*v1.ConfigFile, error) {
+	return i.cf, nil
+}
+
+func TestMutateConfigFileCopies(t *testing.T) {
+	source, err := mutate.Config(sourceImage(t), v1.Config{
+		Env:          []string{"A=1"},
+		Cmd:          []string{"run"},
+		Entrypoint:   []string{"/bin/sh"},
+		Labels:       map[string]string{"a": "1"},
+		ExposedPorts: map[string]struct{}{"80/tcp": {}},
+		Volumes:      map[string]struct{}{"/data": {}},
+	})
+	if err != nil {
+		t.Fatalf("Config: %v", err)
+	}
+	orig, err := source.ConfigFile()
+	if err != nil {
+		t.Fatalf("ConfigFile: %v", err)
+	}
+	want := orig.DeepCopy()
+
+	// mutate.Config doesn't modify the config file of a base that returns
+	// the same one every time.
+	shared := &cachedConfigImage{Image: source, cf: orig.DeepCopy()}
+	if _, err := mutate.Config(shared, v1.Config{Env: []string{"OTHER=1"}}); err != nil {
+		t.Fatalf("Config: %v", err)
+	}
+	if diff := cmp.Diff(want, shared.cf); diff != "" {
+		t.Errorf("mutate.Config changed its base (-want +got): %s", diff)
+	}
+
+	cf, err := mutate.CopyConfigFile(source)
+	if err != nil {
+		t.Fatalf("CopyConfigFile: %v", err)
+	}
+	edit := func(cf *v1.ConfigFile) {
+		cf.Config.Env[0] = "A=2"
+		cf.Config.Cmd[0] = "stop"
+		cf.Config.Entrypoint[0] = "/bin/bash"
+		cf.Config.Labels["b"] = "2"
+		cf.Config.ExposedPorts["443/tcp"] = struct{}{}
+		cf.Config.Volumes["/cache"] = struct{}{}
+	}
+	edit(cf)
+
+	got, err := source.ConfigFile()
+	if err != nil {
+		t.Fatalf("ConfigFile: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("editing the copy changed the source (-want +got): %s", diff)
+	}
+
+	result, err := mutate.WithConfigFile(source, cf)
+	if err != nil {
+		t.Fatalf("WithConfigFile: %v", err)
+	}
+	before, err := result.ConfigFile()
+	if err != nil {
+		t.Fatalf("ConfigFile: %v", err)
+	}
+
+	// Editing cf after replacing the config doesn't affect the result.
+	cf.Config.Labels["c"] = "3"
+	cf.Config.Env = append(cf.Config.Env, "B=1")
+	after, err := result.ConfigFile()
+	if err != nil {
+		t.Fatalf("ConfigFile: %v", err)
+	}
+	if diff := cmp.Diff(before, after); diff != "" {
+		t.Errorf("editing the config after mutate.WithConfigFile changed the result (-want +got): %s", diff)
+	}
+	if got, want := after.Config.Labels["b"], "2"; got != want {
+		t.Errorf("Labels[b]; got %q, want %q", got, want)
+	}
+
+	if err := validate.Image(result); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+}
+
 type arbitrary struct {
 }
 
 func (arbitrary) RawManifest() ([]byte, error) {
 	return []byte(`{"hello":"world"}`), nil
 }
+
+type annotatedArbitrary struct {
+}
+
+func (annotatedArbitrary) RawManifest() ([]byte, error) {
+	return []byte(`{"annotations":{"hey":"there"},"hello":"world"}`), nil
+}
+
 func TestAnnotations(t *testing.T) {
 	anns := map[string]string{
 		"foo": "bar",
@@ -284,7 +610,7 @@ func TestAnnotations(t *testing.T) {
 	}{{
 		desc: "image",
 		in:   empty.Image,
-		want: `{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.v2+json","config":{"mediaType":"application/vnd.docker.container.image.v1+json","size":115,"digest":"sha256:5b943e2b943f6c81dbbd4e2eca5121f4fcc39139e3d1219d6d89bd925b77d9fe"},"layers":[],"annotations":{"foo":"bar"}}`,
+		want: `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.oci.image.config.v1+json","size":115,"digest":"sha256:5b943e2b943f6c81dbbd4e2eca5121f4fcc39139e3d1219d6d89bd925b77d9fe"},"layers":[],"annotations":{"foo":"bar"}}`,
 	}, {
 		desc: "index",
 		in:   empty.Index,
@@ -293,6 +619,10 @@ func TestAnnotations(t *testing.T) {
 		desc: "arbitrary",
 		in:   arbitrary{},
 		want: `{"annotations":{"foo":"bar"},"hello":"world"}`,
+	}, {
+		desc: "arbitrary with annotations",
+		in:   annotatedArbitrary{},
+		want: `{"annotations":{"foo":"bar","hey":"there"},"hello":"world"}`,
 	}} {
 		t.Run(c.desc, func(t *testing.T) {
 			got, err := mutate.Annotations(c.in, anns).RawManifest()
@@ -306,6 +636,97 @@ func TestAnnotations(t *testing.T) {
 	}
 }
 
+func TestAnnotationsConvertsDockerToOCI(t *testing.T) {
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	annotated := mutate.Annotations(img, map[string]string{
+		"org.opencontainers.image.source": "https://example.com/repo",
+	}).(v1.Image)
+
+	if err := validate.Image(annotated); err != nil {
+		t.Fatalf("validate.Image: %v", err)
+	}
+
+	mt, err := annotated.MediaType()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt != types.OCIManifestSchema1 {
+		t.Errorf("MediaType() = %s, want %s", mt, types.OCIManifestSchema1)
+	}
+	m, err := annotated.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.MediaType != types.OCIManifestSchema1 {
+		t.Errorf("Manifest().MediaType = %s, want %s", m.MediaType, types.OCIManifestSchema1)
+	}
+	if m.Config.MediaType != types.OCIConfigJSON {
+		t.Errorf("Manifest().Config.MediaType = %s, want %s", m.Config.MediaType, types.OCIConfigJSON)
+	}
+	layers, err := annotated.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, l := range layers {
+		mt, err := l.MediaType()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mt != types.OCILayer {
+			t.Errorf("layers[%d].MediaType() = %s, want %s", i, mt, types.OCILayer)
+		}
+		if m.Layers[i].MediaType != types.OCILayer {
+			t.Errorf("Manifest().Layers[%d].MediaType = %s, want %s", i, m.Layers[i].MediaType, types.OCILayer)
+		}
+	}
+
+	if manifestsAreEqual(t, img, annotated) {
+		t.Error("annotating an image MUST change its manifest digest")
+	}
+}
+
+func TestSubject(t *testing.T) {
+	subject, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd, err := partial.Descriptor(subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	referrer := mutate.Subject(img, *sd)
+	if err := validate.Image(referrer); err != nil {
+		t.Fatalf("validate.Image: %v", err)
+	}
+	m, err := referrer.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(sd, m.Subject); diff != "" {
+		t.Errorf("Manifest().Subject (-want +got): %s", diff)
+	}
+	// random.Image is a Docker image, which has no subject field.
+	if m.MediaType != types.OCIManifestSchema1 {
+		t.Errorf("Manifest().MediaType = %s, want %s", m.MediaType, types.OCIManifestSchema1)
+	}
+	if mt, err := referrer.MediaType(); err != nil {
+		t.Fatal(err)
+	} else if mt != types.OCIManifestSchema1 {
+		t.Errorf("MediaType() = %s, want %s", mt, types.OCIManifestSchema1)
+	}
+	if manifestsAreEqual(t, img, referrer) {
+		t.Error("setting the subject MUST change the manifest digest")
+	}
+}
+
 func TestMutateCreatedAt(t *testing.T) {
 	source := sourceImage(t)
 	want := time.Now().Add(-2 * time.Minute)
@@ -369,6 +790,255 @@ func TestMutateTime(t *testing.T) {
 	}
 }
 
+func TestMutateTimeLayers(t *testing.T) {
+	old := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range []*tar.Header{{
+		Name:       "foo",
+		Typeflag:   tar.TypeReg,
+		Size:       3,
+		Mode:       0644,
+		ModTime:    old,
+		AccessTime: old,
+		ChangeTime: old,
+		Format:     tar.FormatPAX,
+	}, {
+		Name:     "bar",
+		Typeflag: tar.TypeLink,
+		Linkname: "foo",
+		ModTime:  old,
+	}, {
+		Name:     ".wh.baz",
+		Typeflag: tar.TypeReg,
+		ModTime:  old,
+	}} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			if _, err := tw.Write([]byte("foo")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	result, err := mutate.Time(img, want)
+	if err != nil {
+		t.Fatalf("mutate.Time() = %v", err)
+	}
+	if err := validate.Image(result); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+
+	layers := getLayers(t, result)
+	rc, err := layers[0].Uncompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	tr := tar.NewReader(rc)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+		if !hdr.ModTime.Equal(want) {
+			t.Errorf("%s ModTime; got %v, want %v", hdr.Name, hdr.ModTime, want)
+		}
+		if hdr.Name == "foo" {
+			if !hdr.AccessTime.Equal(want) || !hdr.ChangeTime.Equal(want) {
+				t.Errorf("%s AccessTime, ChangeTime; got %v, %v, want %v", hdr.Name, hdr.AccessTime, hdr.ChangeTime, want)
+			}
+		}
+		if hdr.Name == "bar" && (hdr.Typeflag != tar.TypeLink || hdr.Linkname != "foo") {
+			t.Errorf("hardlink was not preserved: %+v", hdr)
+		}
+	}
+	if diff := cmp.Diff([]string{"foo", "bar", ".wh.baz"}, names); diff != "" {
+		t.Errorf("layer entries (-want +got): %s", diff)
+	}
+
+	d, err := layers[0].DiffID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := getConfigFile(t, result).RootFS.DiffIDs[0]; got != d {
+		t.Errorf("DiffIDs[0]; got %v, want %v", got, d)
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	img, err := mutate.AppendLayers(empty.Image,
+		layerWithFiles(t, "dir/", "dir/a", "dir/b", "keep", "gone"),
+		layerWithFiles(t, "dir/", "dir/.wh..wh..opq", "dir/c", ".wh.gone"),
+		layerWithFiles(t, "new"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err = mutate.Config(img, v1.Config{
+		Entrypoint: []string{"/bin/sh"},
+		Env:        []string{"FOO=bar"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flat, err := mutate.Flatten(img)
+	if err != nil {
+		t.Fatalf("mutate.Flatten() = %v", err)
+	}
+	if err := validate.Image(flat); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+
+	layers := getLayers(t, flat)
+	if got, want := len(layers), 1; got != want {
+		t.Fatalf("len(Layers()); got %d, want %d", got, want)
+	}
+
+	cf := getConfigFile(t, flat)
+	if got, want := len(cf.RootFS.DiffIDs), 1; got != want {
+		t.Errorf("len(DiffIDs); got %d, want %d", got, want)
+	}
+	if got, want := len(cf.History), 1; got != want {
+		t.Errorf("len(History); got %d, want %d", got, want)
+	}
+	if diff := cmp.Diff(getConfigFile(t, img).Config, cf.Config); diff != "" {
+		t.Errorf("Config (-want +got): %s", diff)
+	}
+
+	rc, err := layers[0].Uncompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	var got []string
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, hdr.Name)
+	}
+	want := []string{"new", "dir", "dir/c", "keep"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("flattened files (-want +got): %s", diff)
+	}
+}
+
+func TestSquash(t *testing.T) {
+	layers := []v1.Layer{
+		layerWithFiles(t, "dir/", "dir/a", "dir/b", "keep", "gone"),
+		layerWithFiles(t, "dir/", "dir/.wh..wh..opq", "dir/c", ".wh.gone"),
+		layerWithFiles(t, "new"),
+	}
+	squashed, err := mutate.Squash(layers)
+	if err != nil {
+		t.Fatalf("mutate.Squash() = %v", err)
+	}
+	if err := validate.Layer(squashed); err != nil {
+		t.Errorf("validate.Layer() = %v", err)
+	}
+	if mt, err := squashed.MediaType(); err != nil {
+		t.Fatal(err)
+	} else if mt != types.DockerLayer {
+		t.Errorf("MediaType() = %s, want %s", mt, types.DockerLayer)
+	}
+
+	// Uncompressed can be read more than once, and agrees with Flatten.
+	img, err := mutate.AppendLayers(empty.Image, layers...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flat, err := mutate.Flatten(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := getLayers(t, flat)[0]
+	for i := 0; i < 2; i++ {
+		rc, err := squashed.Uncompressed()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _, err := v1.SHA256(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d, err := want.DiffID(); err != nil {
+			t.Fatal(err)
+		} else if got != d {
+			t.Errorf("SHA256(Uncompressed()) = %s, want Flatten's diffid %s", got, d)
+		}
+	}
+
+	if _, err := mutate.Squash(nil); err == nil {
+		t.Error("mutate.Squash(nil): expected error")
+	}
+}
+
+// layerWithFiles returns a layer containing the given files, or directories
+// for names with a trailing slash.
+func layerWithFiles(t *testing.T, names ...string) v1.Layer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range names {
+		hdr := &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644}
+		if strings.HasSuffix(name, "/") {
+			hdr.Typeflag = tar.TypeDir
+			hdr.Mode = 0755
+		} else {
+			hdr.Size = int64(len(name))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			if _, err := tw.Write([]byte(name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	l, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
 func TestMutateMediaType(t *testing.T) {
 	want := types.OCIManifestSchema1
 	wantCfg := types.OCIConfigJSON
@@ -449,6 +1119,82 @@ func TestMutateMediaType(t *testing.T) {
 	}
 }
 
+func TestMediaTypeConversion(t *testing.T) {
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		mt    types.MediaType
+		cfg   types.MediaType
+		layer types.MediaType
+	}{
+		{types.OCIManifestSchema1, types.OCIConfigJSON, types.OCILayer},
+		{types.DockerManifestSchema2, types.DockerConfigJSON, types.DockerLayer},
+	} {
+		img = mutate.MediaType(img, c.mt)
+		if err := validate.Image(img); err != nil {
+			t.Fatalf("validate.Image(%s): %v", c.mt, err)
+		}
+		m, err := img.Manifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.MediaType != c.mt {
+			t.Errorf("Manifest().MediaType = %s, want %s", m.MediaType, c.mt)
+		}
+		if m.Config.MediaType != c.cfg {
+			t.Errorf("Manifest().Config.MediaType = %s, want %s", m.Config.MediaType, c.cfg)
+		}
+		layers, err := img.Layers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, l := range layers {
+			mt, err := l.MediaType()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mt != c.layer {
+				t.Errorf("layers[%d].MediaType() = %s, want %s", i, mt, c.layer)
+			}
+			if m.Layers[i].MediaType != c.layer {
+				t.Errorf("Manifest().Layers[%d].MediaType = %s, want %s", i, m.Layers[i].MediaType, c.layer)
+			}
+		}
+	}
+
+	// Converting back and forth is lossless.
+	if !manifestsAreEqual(t, img, mutate.MediaType(mutate.MediaType(img, types.OCIManifestSchema1), types.DockerManifestSchema2)) {
+		t.Error("round trip through OCI changed the manifest")
+	}
+
+	// Other media types just override the manifest's.
+	const other = types.MediaType("application/vnd.example.manifest.v1+json")
+	m, err := mutate.MediaType(img, other).Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.MediaType != other {
+		t.Errorf("Manifest().MediaType = %s, want %s", m.MediaType, other)
+	}
+	if m.Config.MediaType != types.DockerConfigJSON || m.Layers[0].MediaType != types.DockerLayer {
+		t.Errorf("MediaType(%s) changed config or layer media types: %s, %s", other, m.Config.MediaType, m.Layers[0].MediaType)
+	}
+
+	zstd, err := mutate.Append(mutate.MediaType(empty.Image, types.OCIManifestSchema1), mutate.Addendum{
+		Layer:     static.NewLayer([]byte("data"), types.OCILayerZStd),
+		MediaType: types.OCILayerZStd,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mutate.MediaType(zstd, types.DockerManifestSchema2).Manifest(); err == nil {
+		t.Error("MediaType(DockerManifestSchema2) with zstd layer: expected error")
+	}
+}
+
 func TestAppendStreamableLayer(t *testing.T) {
 	img, err := mutate.AppendLayers(
 		sourceImage(t),
@@ -570,6 +1316,124 @@ func TestCanonical(t *testing.T) {
 	}
 }
 
+func TestCanonicalConfig(t *testing.T) {
+	source := sourceImage(t)
+	img, err := mutate.CanonicalConfig(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sourceCf, err := source.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range cf.History {
+		want := "bazel build ..."
+		got := h.CreatedBy
+		if want != got {
+			t.Errorf("%q != %q", want, got)
+		}
+	}
+	var want, got string
+	want = cf.Architecture
+	got = sourceCf.Architecture
+	if want != got {
+		t.Errorf("%q != %q", want, got)
+	}
+	want = cf.OS
+	got = sourceCf.OS
+	if want != got {
+		t.Errorf("%q != %q", want, got)
+	}
+	want = cf.OSVersion
+	got = sourceCf.OSVersion
+	if want != got {
+		t.Errorf("%q != %q", want, got)
+	}
+	for _, s := range []string{
+		cf.Container,
+		cf.Config.Hostname,
+		cf.DockerVersion,
+	} {
+		if s != "" {
+			t.Errorf("non-zeroed string: %v", s)
+		}
+	}
+
+	if !cf.Created.IsZero() {
+		t.Errorf("Created; got %v, want zero", cf.Created)
+	}
+	for i, h := range cf.History {
+		if !h.Created.IsZero() {
+			t.Errorf("History[%d].Created; got %v, want zero", i, h.Created)
+		}
+	}
+
+	// Layers are untouched.
+	sourceLayers := getLayers(t, source)
+	layers := getLayers(t, img)
+	if len(layers) != len(sourceLayers) {
+		t.Fatalf("len(layers); got %d, want %d", len(layers), len(sourceLayers))
+	}
+	for i := range layers {
+		got, err := layers[i].Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := sourceLayers[i].Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("layer %d digest; got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestCanonicalStable(t *testing.T) {
+	base, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	build := func(created time.Time, hostname, dockerVersion string) v1.Hash {
+		t.Helper()
+		cf, err := base.ConfigFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cf = cf.DeepCopy()
+		cf.Created = v1.Time{Time: created}
+		cf.Container = hostname
+		cf.Config.Hostname = hostname
+		cf.DockerVersion = dockerVersion
+		cf.Config.Labels = map[string]string{"b": "2", "a": "1", "c": "3"}
+		cf.History = []v1.History{{Created: v1.Time{Time: created}, CreatedBy: "build"}}
+		img, err := mutate.ConfigFile(base, cf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err = mutate.CanonicalConfig(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, err := img.ConfigName()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	first := build(time.Now(), "host-a", "20.10.1")
+	second := build(time.Now().Add(time.Hour), "host-b", "24.0.0")
+	if first != second {
+		t.Errorf("config digests differ: %v != %v", first, second)
+	}
+}
+
 func TestRemoveManifests(t *testing.T) {
 	// Load up the registry.
 	count := 3
@@ -34,19 +34,19 @@ func Rebase(orig, oldBase, newBase v1.Image) (v1.Image, error) {
 		return nil, err
 	}
 	if len(oldBaseLayers) > len(origLayers) {
-		return nil, fmt.Errorf("image %q is not based on %q (too few layers)", orig, oldBase)
+		return nil, fmt.Errorf("image %s is not based on %s (too few layers: %d < %d)", imageName(orig), imageName(oldBase), len(origLayers), len(oldBaseLayers))
 	}
 	for i, l := range oldBaseLayers {
 		oldLayerDigest, err := l.Digest()
 		if err != nil {
-			return nil, fmt.Errorf("failed to get digest of layer %d of %q: %w", i, oldBase, err)
+			return nil, fmt.Errorf("failed to get digest of layer %d of %s: %w", i, imageName(oldBase), err)
 		}
 		origLayerDigest, err := origLayers[i].Digest()
 		if err != nil {
-			return nil, fmt.Errorf("failed to get digest of layer %d of %q: %w", i, orig, err)
+			return nil, fmt.Errorf("failed to get digest of layer %d of %s: %w", i, imageName(orig), err)
 		}
 		if oldLayerDigest != origLayerDigest {
-			return nil, fmt.Errorf("image %q is not based on %q (layer %d mismatch)", orig, oldBase, i)
+			return nil, fmt.Errorf("image %s is not based on %s (layer %d mismatch: got %s, want %s)", imageName(orig), imageName(oldBase), i, origLayerDigest, oldLayerDigest)
 		}
 	}
 
@@ -84,6 +84,7 @@ func Rebase(orig, oldBase, newBase v1.Image) (v1.Image, error) {
 	rebasedConfig.Architecture = newConfig.Architecture
 	rebasedConfig.OS = newConfig.OS
 	rebasedConfig.OSVersion = newConfig.OSVersion
+	rebasedConfig.Variant = newConfig.Variant
 
 	// Apply config properties to rebased.
 	rebasedImage, err = ConfigFile(rebasedImage, rebasedConfig)
@@ -111,6 +112,15 @@ func Rebase(orig, oldBase, newBase v1.Image) (v1.Image, error) {
 	return rebasedImage, nil
 }
 
+// imageName identifies img by digest in error messages.
+func imageName(img v1.Image) string {
+	h, err := img.Digest()
+	if err != nil {
+		return "<unknown image>"
+	}
+	return h.String()
+}
+
 // createAddendums makes a list of addendums from a history and layers starting from a specific history and layer
 // indexes.
 func createAddendums(startHistory, startLayer int, history []v1.History, layers []v1.Layer) []Addendum {
@@ -136,7 +146,7 @@ func createAddendums(startHistory, startLayer int, history []v1.History, layers
 	// In the event history was malformed or non-existent, append the remaining layers.
 	for i := layerIndex; i < len(layers); i++ {
 		if i >= startLayer {
-			adds = append(adds, Addendum{Layer: layers[layerIndex]})
+			adds = append(adds, Addendum{Layer: layers[i]})
 		}
 	}
 
@@ -15,12 +15,16 @@
 package mutate_test
 
 import (
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
 func layerDigests(t *testing.T, img v1.Image) []string {
@@ -105,6 +109,7 @@ func TestRebase(t *testing.T) {
 	newBaseConfigFile.Architecture = "arm"
 	newBaseConfigFile.OS = "windows"
 	newBaseConfigFile.OSVersion = "10.0.17763.1339"
+	newBaseConfigFile.Variant = "v7"
 
 	newBase, err = mutate.ConfigFile(newBase, newBaseConfigFile)
 	if err != nil {
@@ -176,4 +181,93 @@ func TestRebase(t *testing.T) {
 	if rebasedConfig.OSVersion != newBaseConfig.OSVersion {
 		t.Errorf("ConfigFile property OSVersion mismatch, got %q, want %q", rebasedConfig.OSVersion, newBaseConfig.OSVersion)
 	}
+	if rebasedConfig.Variant != newBaseConfig.Variant {
+		t.Errorf("ConfigFile property Variant mismatch, got %q, want %q", rebasedConfig.Variant, newBaseConfig.Variant)
+	}
+}
+
+func TestRebaseNotBased(t *testing.T) {
+	oldBase, err := random.Image(100, 3)
+	if err != nil {
+		t.Fatalf("random.Image (oldBase): %v", err)
+	}
+	other, err := random.Image(100, 3)
+	if err != nil {
+		t.Fatalf("random.Image (other): %v", err)
+	}
+	small, err := random.Image(100, 1)
+	if err != nil {
+		t.Fatalf("random.Image (small): %v", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		orig v1.Image
+		want string
+	}{{
+		name: "mismatch",
+		orig: other,
+		want: "layer 0 mismatch",
+	}, {
+		name: "too few layers",
+		orig: small,
+		want: "too few layers: 1 < 3",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := mutate.Rebase(tc.orig, oldBase, empty.Image)
+			if err == nil {
+				t.Fatal("Rebase: expected error")
+			}
+			if !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Rebase error; got %q, want it to contain %q", err, tc.want)
+			}
+			d, derr := tc.orig.Digest()
+			if derr != nil {
+				t.Fatal(derr)
+			}
+			if !strings.Contains(err.Error(), d.String()) {
+				t.Errorf("Rebase error; got %q, want it to name %s", err, d)
+			}
+		})
+	}
+}
+
+func TestRebaseWithoutHistory(t *testing.T) {
+	oldBase, err := random.Image(100, 2)
+	if err != nil {
+		t.Fatalf("random.Image (oldBase): %v", err)
+	}
+	top, err := random.Layer(100, types.DockerLayer)
+	if err != nil {
+		t.Fatalf("random.Layer: %v", err)
+	}
+	orig, err := mutate.AppendLayers(oldBase, top)
+	if err != nil {
+		t.Fatalf("AppendLayers: %v", err)
+	}
+
+	// A base image whose config has no history for its layers.
+	newBase, err := random.Image(100, 3)
+	if err != nil {
+		t.Fatalf("random.Image (newBase): %v", err)
+	}
+	cf, err := newBase.ConfigFile()
+	if err != nil {
+		t.Fatalf("ConfigFile: %v", err)
+	}
+	cf.History = nil
+	newBase, err = mutate.ConfigFile(newBase, cf)
+	if err != nil {
+		t.Fatalf("ConfigFile: %v", err)
+	}
+
+	rebased, err := mutate.Rebase(orig, oldBase, newBase)
+	if err != nil {
+		t.Fatalf("Rebase: %v", err)
+	}
+	origDigests := layerDigests(t, orig)
+	want := append(layerDigests(t, newBase), origDigests[len(origDigests)-1])
+	if diff := cmp.Diff(want, layerDigests(t, rebased)); diff != "" {
+		t.Errorf("rebased layers (-want +got): %s", diff)
+	}
 }
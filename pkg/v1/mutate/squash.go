@@ -0,0 +1,111 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Squash returns a single gzip-compressed layer containing the filesystem
+// that results from applying layers in order, with whiteouts resolved so that
+// later layers override earlier ones (see Extract).
+//
+// Nothing is read from layers until the result is used. Its Uncompressed
+// streams a freshly squashed tarball each time it's called, and Compressed
+// gzips that stream on the fly, so memory use doesn't grow with the size or
+// number of layers. Its Digest, DiffID and Size are computed on first use by
+// streaming the squashed contents.
+func Squash(layers []v1.Layer) (v1.Layer, error) {
+	if len(layers) == 0 {
+		return nil, errors.New("squash: no layers")
+	}
+	return &squashedLayer{layers: layers}, nil
+}
+
+type squashedLayer struct {
+	layers []v1.Layer
+
+	once     sync.Once
+	computed v1.Layer
+	err      error
+}
+
+var _ v1.Layer = (*squashedLayer)(nil)
+
+// Uncompressed implements v1.Layer
+func (l *squashedLayer) Uncompressed() (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(extractLayers(func() ([]v1.Layer, error) {
+			return l.layers, nil
+		}, pw))
+	}()
+	return pr, nil
+}
+
+// MediaType implements v1.Layer
+func (l *squashedLayer) MediaType() (types.MediaType, error) {
+	return types.DockerLayer, nil
+}
+
+// compute hashes the squashed contents, the first time it's called.
+func (l *squashedLayer) compute() (v1.Layer, error) {
+	l.once.Do(func() {
+		l.computed, l.err = tarball.LayerFromOpener(l.Uncompressed, tarball.WithMediaType(types.DockerLayer))
+	})
+	return l.computed, l.err
+}
+
+// Compressed implements v1.Layer
+func (l *squashedLayer) Compressed() (io.ReadCloser, error) {
+	c, err := l.compute()
+	if err != nil {
+		return nil, err
+	}
+	return c.Compressed()
+}
+
+// Digest implements v1.Layer
+func (l *squashedLayer) Digest() (v1.Hash, error) {
+	c, err := l.compute()
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	return c.Digest()
+}
+
+// DiffID implements v1.Layer
+func (l *squashedLayer) DiffID() (v1.Hash, error) {
+	c, err := l.compute()
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	return c.DiffID()
+}
+
+// Size implements v1.Layer
+func (l *squashedLayer) Size() (int64, error) {
+	c, err := l.compute()
+	if err != nil {
+		return -1, err
+	}
+	return c.Size()
+}
@@ -0,0 +1,170 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partial
+
+import (
+	"bytes"
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// emptyJSON is the content of a config with media type types.OCIEmptyJSON.
+var emptyJSON = []byte("{}")
+
+type artifact struct {
+	manifest []byte
+	parsed   *v1.Manifest
+	config   []byte
+	layers   []v1.Layer
+	byDigest map[v1.Hash]v1.Layer
+}
+
+var _ v1.Image = (*artifact)(nil)
+
+// Artifact returns a v1.Image for non-image OCI content, such as an artifact
+// whose config isn't an image config, so that it can be written with e.g.
+// remote.Write. The raw manifest is served as-is, so its digest is preserved.
+//
+// The config is the blob referenced by the manifest's config descriptor; if it
+// is nil and the config's media type is types.OCIEmptyJSON, the empty JSON
+// object "{}" is used. Every layer referenced by the manifest must be given.
+//
+// Since the config may not be an image config, ConfigFile may return an error.
+func Artifact(manifest, config []byte, layers ...v1.Layer) (v1.Image, error) {
+	m, err := v1.ParseManifest(bytes.NewReader(manifest))
+	if err != nil {
+		return nil, fmt.Errorf("parsing manifest: %w", err)
+	}
+
+	if config == nil && m.Config.MediaType == types.OCIEmptyJSON {
+		config = emptyJSON
+	}
+	h, _, err := v1.SHA256(bytes.NewReader(config))
+	if err != nil {
+		return nil, err
+	}
+	if h != m.Config.Digest {
+		return nil, fmt.Errorf("config digest %s does not match manifest config %s", h, m.Config.Digest)
+	}
+
+	byDigest := map[v1.Hash]v1.Layer{}
+	for _, l := range layers {
+		d, err := l.Digest()
+		if err != nil {
+			return nil, err
+		}
+		byDigest[d] = l
+	}
+	ordered := make([]v1.Layer, 0, len(m.Layers))
+	for _, desc := range m.Layers {
+		l, ok := byDigest[desc.Digest]
+		if !ok {
+			return nil, fmt.Errorf("missing layer %s referenced by manifest", desc.Digest)
+		}
+		ordered = append(ordered, l)
+	}
+
+	return &artifact{
+		manifest: manifest,
+		parsed:   m,
+		config:   config,
+		layers:   ordered,
+		byDigest: byDigest,
+	}, nil
+}
+
+// Layers implements v1.Image.
+func (a *artifact) Layers() ([]v1.Layer, error) {
+	return a.layers, nil
+}
+
+// MediaType implements v1.Image.
+func (a *artifact) MediaType() (types.MediaType, error) {
+	if a.parsed.MediaType == "" {
+		// The mediaType field is optional for OCI manifests.
+		return types.OCIManifestSchema1, nil
+	}
+	return a.parsed.MediaType, nil
+}
+
+// Size implements v1.Image.
+func (a *artifact) Size() (int64, error) {
+	return Size(a)
+}
+
+// ConfigName implements v1.Image.
+func (a *artifact) ConfigName() (v1.Hash, error) {
+	return a.parsed.Config.Digest, nil
+}
+
+// ConfigFile implements v1.Image.
+func (a *artifact) ConfigFile() (*v1.ConfigFile, error) {
+	return ConfigFile(a)
+}
+
+// RawConfigFile implements v1.Image.
+func (a *artifact) RawConfigFile() ([]byte, error) {
+	return a.config, nil
+}
+
+// Digest implements v1.Image.
+func (a *artifact) Digest() (v1.Hash, error) {
+	return Digest(a)
+}
+
+// Manifest implements v1.Image.
+func (a *artifact) Manifest() (*v1.Manifest, error) {
+	return a.parsed.DeepCopy(), nil
+}
+
+// RawManifest implements v1.Image.
+func (a *artifact) RawManifest() ([]byte, error) {
+	return a.manifest, nil
+}
+
+// ConfigLayer implements partial.withConfigLayer.
+func (a *artifact) ConfigLayer() (v1.Layer, error) {
+	return &configLayer{
+		hash:    a.parsed.Config.Digest,
+		content: a.config,
+	}, nil
+}
+
+// LayerByDigest implements v1.Image.
+func (a *artifact) LayerByDigest(h v1.Hash) (v1.Layer, error) {
+	if h == a.parsed.Config.Digest {
+		return a.ConfigLayer()
+	}
+	if l, ok := a.byDigest[h]; ok {
+		return l, nil
+	}
+	return nil, fmt.Errorf("unknown blob %v", h)
+}
+
+// LayerByDiffID implements v1.Image.
+func (a *artifact) LayerByDiffID(h v1.Hash) (v1.Layer, error) {
+	for _, l := range a.layers {
+		diffID, err := l.DiffID()
+		if err != nil {
+			return nil, err
+		}
+		if diffID == h {
+			return l, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown diffID %v", h)
+}
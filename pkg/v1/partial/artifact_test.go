@@ -0,0 +1,110 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partial_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestArtifact(t *testing.T) {
+	layer, err := random.Layer(1024, "application/vnd.example.sbom.v1+json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := partial.Descriptor(layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty, _, err := v1.SHA256(bytes.NewReader([]byte("{}")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(v1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIManifestSchema1,
+		ArtifactType:  "application/vnd.example.sbom.v1+json",
+		Config: v1.Descriptor{
+			MediaType: types.OCIEmptyJSON,
+			Size:      2,
+			Digest:    empty,
+		},
+		Layers: []v1.Descriptor{*desc},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := partial.Artifact(b, nil, layer)
+	if err != nil {
+		t.Fatalf("Artifact() = %v", err)
+	}
+
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(u.Host + "/foo/artifact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("remote.Write() = %v", err)
+	}
+
+	got, err := remote.Get(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Manifest, b) {
+		t.Errorf("manifest; got %s, want %s", got.Manifest, b)
+	}
+	pushed, err := got.Image()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pushed.LayerByDigest(desc.Digest); err != nil {
+		t.Errorf("LayerByDigest(%s) = %v", desc.Digest, err)
+	}
+	cfg, err := pushed.RawConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(cfg) != "{}" {
+		t.Errorf("RawConfigFile(); got %q, want %q", cfg, "{}")
+	}
+
+	// Missing layers and mismatched configs are rejected.
+	if _, err := partial.Artifact(b, nil); err == nil {
+		t.Error("Artifact() without layers; expected error")
+	}
+	if _, err := partial.Artifact(b, []byte("[]"), layer); err == nil {
+		t.Error("Artifact() with mismatched config; expected error")
+	}
+}
@@ -15,6 +15,8 @@
 package partial
 
 import (
+	"errors"
+	"fmt"
 	"io"
 
 	"github.com/google/go-containerregistry/internal/and"
@@ -98,6 +100,48 @@ func CompressedToLayer(ul CompressedLayer) (v1.Layer, error) {
 	return &compressedLayerExtender{ul}, nil
 }
 
+// ErrDecompressionDisabled is returned by the Uncompressed and DiffID methods
+// of layers returned by CompressedOnly.
+var ErrDecompressionDisabled = errors.New("decompression is disabled for this layer")
+
+// CompressedOnly returns a v1.Layer that serves l's Compressed, Digest, Size,
+// MediaType and Descriptor, but whose Uncompressed and DiffID return an error
+// wrapping ErrDecompressionDisabled instead of decompressing l.
+//
+// This is useful to make sure that a pipeline which should only pass
+// compressed bytes through, like a copy between registries, never pays to
+// decompress a layer.
+func CompressedOnly(l v1.Layer) v1.Layer {
+	return &compressedOnlyLayer{CompressedLayer: l}
+}
+
+type compressedOnlyLayer struct {
+	CompressedLayer
+}
+
+// Uncompressed implements v1.Layer
+func (l *compressedOnlyLayer) Uncompressed() (io.ReadCloser, error) {
+	return nil, l.disabled("Uncompressed")
+}
+
+// DiffID implements v1.Layer
+func (l *compressedOnlyLayer) DiffID() (v1.Hash, error) {
+	return v1.Hash{}, l.disabled("DiffID")
+}
+
+// Descriptor implements withDescriptor
+func (l *compressedOnlyLayer) Descriptor() (*v1.Descriptor, error) {
+	return Descriptor(l.CompressedLayer)
+}
+
+func (l *compressedOnlyLayer) disabled(method string) error {
+	d, err := l.Digest()
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, ErrDecompressionDisabled)
+	}
+	return fmt.Errorf("%s of layer %s: %w", method, d, ErrDecompressionDisabled)
+}
+
 // CompressedImageCore represents the base minimum interface a natively
 // compressed image must implement for us to produce a v1.Image.
 type CompressedImageCore interface {
@@ -127,9 +171,18 @@ func (i *compressedImageExtender) Digest() (v1.Hash, error) {
 
 // ConfigName implements v1.Image
 func (i *compressedImageExtender) ConfigName() (v1.Hash, error) {
+	// If our nested CompressedImageCore knows its config's digest without
+	// reading the config, delegate to it.
+	if wcn, ok := i.CompressedImageCore.(withConfigName); ok {
+		return wcn.ConfigName()
+	}
 	return ConfigName(i)
 }
 
+type withConfigName interface {
+	ConfigName() (v1.Hash, error)
+}
+
 // Layers implements v1.Image
 func (i *compressedImageExtender) Layers() ([]v1.Layer, error) {
 	hs, err := FSLayers(i)
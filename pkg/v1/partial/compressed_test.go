@@ -15,6 +15,7 @@
 package partial_test
 
 import (
+	"errors"
 	"io"
 	"net/http/httptest"
 	"net/url"
@@ -191,3 +192,92 @@ func TestCompressed(t *testing.T) {
 		t.Fatalf("partial.Descriptor: %v", err)
 	}
 }
+
+func TestCompressedLayerByDiffID(t *testing.T) {
+	rnd, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := partial.CompressedToImage(&compressedImage{rnd})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range layers {
+		diffID, err := want.DiffID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := img.LayerByDiffID(diffID)
+		if err != nil {
+			t.Fatalf("LayerByDiffID(%s) = %v", diffID, err)
+		}
+		if err := compare.Layers(got, want); err != nil {
+			t.Errorf("compare.Layers: %v", err)
+		}
+	}
+
+	// Looking up a compressed digest by diffID should not match anything.
+	digest, err := layers[0].Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := img.LayerByDiffID(digest); err == nil {
+		t.Errorf("LayerByDiffID(%s); expected error", digest)
+	}
+}
+
+func TestCompressedOnly(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rl, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := partial.CompressedOnly(rl)
+
+	if _, err := l.Uncompressed(); !errors.Is(err, partial.ErrDecompressionDisabled) {
+		t.Errorf("Uncompressed() = %v, want %v", err, partial.ErrDecompressionDisabled)
+	}
+	if _, err := l.DiffID(); !errors.Is(err, partial.ErrDecompressionDisabled) {
+		t.Errorf("DiffID() = %v, want %v", err, partial.ErrDecompressionDisabled)
+	}
+
+	want, err := partial.Descriptor(rl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := partial.Descriptor(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Descriptor() (-want +got): %s", diff)
+	}
+
+	// Pushing a layer only needs its compressed bytes.
+	dst, err := name.NewRepository(path.Join(u.Host, "compressed/only"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteLayer(dst, l); err != nil {
+		t.Fatalf("WriteLayer() = %v", err)
+	}
+	pushed, err := remote.Layer(dst.Digest(want.Digest.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := compare.Layers(rl, pushed); err != nil {
+		t.Error(err)
+	}
+}
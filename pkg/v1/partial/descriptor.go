@@ -0,0 +1,68 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partial
+
+import (
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// DescriptorOption customizes the v1.Descriptor returned by ImageDescriptor.
+type DescriptorOption func(*v1.Descriptor)
+
+// WithAnnotations sets the annotations of the descriptor.
+func WithAnnotations(annotations map[string]string) DescriptorOption {
+	return func(desc *v1.Descriptor) {
+		desc.Annotations = annotations
+	}
+}
+
+// WithArtifactType sets the artifactType of the descriptor.
+func WithArtifactType(artifactType string) DescriptorOption {
+	return func(desc *v1.Descriptor) {
+		desc.ArtifactType = artifactType
+	}
+}
+
+// WithPlatform sets the platform of the descriptor, overriding the one read
+// from the image's config file.
+func WithPlatform(platform v1.Platform) DescriptorOption {
+	return func(desc *v1.Descriptor) {
+		desc.Platform = &platform
+	}
+}
+
+// ImageDescriptor returns a v1.Descriptor for img, suitable for adding it to an
+// index. In addition to what Descriptor populates, the platform is read from
+// the image's config file, unless the config doesn't specify one.
+func ImageDescriptor(img v1.Image, opts ...DescriptorOption) (*v1.Descriptor, error) {
+	d, err := Descriptor(img)
+	if err != nil {
+		return nil, err
+	}
+	desc := *d
+
+	if desc.Platform == nil {
+		cf, err := img.ConfigFile()
+		if err != nil {
+			return nil, err
+		}
+		desc.Platform = cf.Platform()
+	}
+
+	for _, opt := range opts {
+		opt(&desc)
+	}
+	return &desc, nil
+}
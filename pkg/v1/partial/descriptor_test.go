@@ -0,0 +1,87 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partial_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+func TestImageDescriptor(t *testing.T) {
+	img, err := random.Image(100, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf.OS = "linux"
+	cf.Architecture = "arm"
+	cf.Variant = "v7"
+	img, err = mutate.ConfigFile(img, cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	anns := map[string]string{"foo": "bar"}
+	got, err := partial.ImageDescriptor(img, partial.WithAnnotations(anns), partial.WithArtifactType("application/vnd.example"))
+	if err != nil {
+		t.Fatalf("ImageDescriptor: %v", err)
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := img.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mt, err := img.MediaType()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &v1.Descriptor{
+		MediaType:    mt,
+		Size:         size,
+		Digest:       digest,
+		Annotations:  anns,
+		ArtifactType: "application/vnd.example",
+		Platform: &v1.Platform{
+			OS:           "linux",
+			Architecture: "arm",
+			Variant:      "v7",
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ImageDescriptor (-want +got): %s", diff)
+	}
+
+	// An image whose config doesn't describe a platform gets none.
+	got, err = partial.ImageDescriptor(empty.Image)
+	if err != nil {
+		t.Fatalf("ImageDescriptor: %v", err)
+	}
+	if got.Platform != nil {
+		t.Errorf("Platform; got %v, want nil", got.Platform)
+	}
+}
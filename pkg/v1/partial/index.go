@@ -15,7 +15,9 @@
 package partial
 
 import (
+	"bytes"
 	"fmt"
+	"sort"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/match"
@@ -83,3 +85,140 @@ func FindIndexes(index v1.ImageIndex, matcher match.Matcher) ([]v1.ImageIndex, e
 	}
 	return matches, nil
 }
+
+// IndexContentHash returns a fingerprint of index and everything reachable from
+// it: the sha256 of the sorted, de-duplicated, newline-separated digests of
+// every manifest, config and layer in the tree, recursing through nested
+// indexes. It changes if any of index's children (or their blobs) change.
+//
+// The index's own digest isn't included, so it's unaffected by e.g. the order
+// of the index's children or annotations on the index; use index.Digest() to
+// identify the index itself.
+func IndexContentHash(index v1.ImageIndex) (v1.Hash, error) {
+	digests := map[string]struct{}{}
+	if err := collectDigests(index, digests); err != nil {
+		return v1.Hash{}, err
+	}
+	sorted := make([]string, 0, len(digests))
+	for d := range digests {
+		sorted = append(sorted, d)
+	}
+	sort.Strings(sorted)
+
+	var buf bytes.Buffer
+	for _, d := range sorted {
+		buf.WriteString(d)
+		buf.WriteByte('\n')
+	}
+	h, _, err := v1.SHA256(&buf)
+	return h, err
+}
+
+// IndexSize returns the total compressed size of index: the size of its
+// manifest, plus the sizes of every manifest, config and layer reachable from
+// it, recursing through nested indexes. Each blob is counted once, however
+// many children share it.
+func IndexSize(index v1.ImageIndex) (int64, error) {
+	total, err := index.Size()
+	if err != nil {
+		return -1, err
+	}
+	manifests, blobs, err := indexChildren(index, map[string]struct{}{})
+	if err != nil {
+		return -1, err
+	}
+	return total + sumSizes(manifests) + sumSizes(blobs), nil
+}
+
+// IndexBlobs returns the descriptors of the configs and layers of every image
+// reachable from index, recursing through nested indexes, with each digest
+// listed once. Like Blobs, the descriptors are taken from the images'
+// manifests. The manifests themselves aren't included.
+func IndexBlobs(index v1.ImageIndex) ([]v1.Descriptor, error) {
+	_, blobs, err := indexChildren(index, map[string]struct{}{})
+	return blobs, err
+}
+
+// indexChildren returns the descriptors of the manifests reachable from
+// index, and of their images' configs and layers, that aren't already in
+// seen, and adds them to seen.
+func indexChildren(index v1.ImageIndex, seen map[string]struct{}) (manifests, blobs []v1.Descriptor, err error) {
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, desc := range indexManifest.Manifests {
+		if _, ok := seen[desc.Digest.String()]; ok {
+			// Already visited.
+			continue
+		}
+		seen[desc.Digest.String()] = struct{}{}
+		manifests = append(manifests, desc)
+
+		switch {
+		case desc.MediaType.IsIndex():
+			idx, err := index.ImageIndex(desc.Digest)
+			if err != nil {
+				return nil, nil, err
+			}
+			m, b, err := indexChildren(idx, seen)
+			if err != nil {
+				return nil, nil, err
+			}
+			manifests = append(manifests, m...)
+			blobs = append(blobs, b...)
+		case desc.MediaType.IsImage():
+			img, err := index.Image(desc.Digest)
+			if err != nil {
+				return nil, nil, err
+			}
+			b, err := imageBlobs(img, seen)
+			if err != nil {
+				return nil, nil, err
+			}
+			blobs = append(blobs, b...)
+		}
+	}
+	return manifests, blobs, nil
+}
+
+// collectDigests adds the digests of everything reachable from index to
+// digests.
+func collectDigests(index v1.ImageIndex, digests map[string]struct{}) error {
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return err
+	}
+	for _, desc := range indexManifest.Manifests {
+		if _, ok := digests[desc.Digest.String()]; ok {
+			// Already visited.
+			continue
+		}
+		digests[desc.Digest.String()] = struct{}{}
+
+		switch {
+		case desc.MediaType.IsIndex():
+			idx, err := index.ImageIndex(desc.Digest)
+			if err != nil {
+				return err
+			}
+			if err := collectDigests(idx, digests); err != nil {
+				return err
+			}
+		case desc.MediaType.IsImage():
+			img, err := index.Image(desc.Digest)
+			if err != nil {
+				return err
+			}
+			m, err := img.Manifest()
+			if err != nil {
+				return err
+			}
+			digests[m.Config.Digest.String()] = struct{}{}
+			for _, l := range m.Layers {
+				digests[l.Digest.String()] = struct{}{}
+			}
+		}
+	}
+	return nil
+}
@@ -17,6 +17,7 @@ package partial_test
 import (
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
@@ -117,3 +118,197 @@ func TestFindIndexes(t *testing.T) {
 		t.Errorf("failed on index, actual %d, expected %d", len(idxes), indexCount)
 	}
 }
+
+func TestIndexContentHash(t *testing.T) {
+	inner, err := random.Index(100, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(100, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := random.Image(100, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: inner},
+		mutate.IndexAddendum{Add: img},
+	)
+	want, err := partial.IndexContentHash(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		desc string
+		idx  v1.ImageIndex
+		same bool
+	}{{
+		desc: "same index",
+		idx:  idx,
+		same: true,
+	}, {
+		desc: "reordered children",
+		idx: mutate.AppendManifests(empty.Index,
+			mutate.IndexAddendum{Add: img},
+			mutate.IndexAddendum{Add: inner},
+		),
+		same: true,
+	}, {
+		desc: "annotated index",
+		idx:  mutate.Annotations(idx, map[string]string{"foo": "bar"}).(v1.ImageIndex),
+		same: true,
+	}, {
+		desc: "different image",
+		idx: mutate.AppendManifests(empty.Index,
+			mutate.IndexAddendum{Add: inner},
+			mutate.IndexAddendum{Add: other},
+		),
+	}, {
+		desc: "different nested index",
+		idx: mutate.AppendManifests(empty.Index,
+			mutate.IndexAddendum{Add: mutate.AppendManifests(inner, mutate.IndexAddendum{Add: other})},
+			mutate.IndexAddendum{Add: img},
+		),
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := partial.IndexContentHash(tc.idx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if same := got == want; same != tc.same {
+				t.Errorf("IndexContentHash() = %s, want same as %s: %t", got, want, tc.same)
+			}
+		})
+	}
+}
+
+func TestImageAndIndexSize(t *testing.T) {
+	shared, err := random.Layer(100, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sharedSize, err := shared.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each image has its own layer, plus shared twice.
+	var imgs []v1.Image
+	var wantImg []int64
+	for i := 0; i < 2; i++ {
+		img, err := random.Image(200, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err = mutate.AppendLayers(img, shared, shared)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := img.Manifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		size, err := img.Size()
+		if err != nil {
+			t.Fatal(err)
+		}
+		imgs = append(imgs, img)
+		wantImg = append(wantImg, size+m.Config.Size+m.Layers[0].Size+sharedSize)
+	}
+
+	for i, img := range imgs {
+		got, err := partial.ImageSize(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != wantImg[i] {
+			t.Errorf("ImageSize(imgs[%d]): got %d, want %d", i, got, wantImg[i])
+		}
+	}
+
+	// The nested index repeats imgs[1], which is only counted once.
+	nested := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: imgs[1]})
+	idx := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: imgs[0]},
+		mutate.IndexAddendum{Add: imgs[1]},
+		mutate.IndexAddendum{Add: nested},
+	)
+	idxSize, err := idx.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nestedSize, err := nested.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := idxSize + nestedSize + wantImg[0] + wantImg[1] - sharedSize
+	got, err := partial.IndexSize(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("IndexSize: got %d, want %d", got, want)
+	}
+}
+
+func TestBlobsAndIndexBlobs(t *testing.T) {
+	shared, err := random.Layer(100, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[v1.Hash]v1.Descriptor{}
+	var imgs []v1.Image
+	for i := 0; i < 2; i++ {
+		img, err := random.Image(200, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err = mutate.AppendLayers(img, shared, shared)
+		if err != nil {
+			t.Fatal(err)
+		}
+		imgs = append(imgs, img)
+
+		m, err := img.Manifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		blobs, err := partial.Blobs(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The config, the image's own layer and the shared layer once.
+		wantImg := []v1.Descriptor{m.Config, m.Layers[0], m.Layers[1]}
+		if diff := cmp.Diff(wantImg, blobs); diff != "" {
+			t.Errorf("Blobs(imgs[%d]): (-want +got) %s", i, diff)
+		}
+		for _, desc := range wantImg {
+			want[desc.Digest] = desc
+		}
+	}
+
+	nested := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: imgs[1]})
+	idx := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: imgs[0]},
+		mutate.IndexAddendum{Add: nested},
+	)
+	blobs, err := partial.IndexBlobs(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[v1.Hash]v1.Descriptor{}
+	for _, desc := range blobs {
+		if _, ok := got[desc.Digest]; ok {
+			t.Errorf("IndexBlobs: %s listed more than once", desc.Digest)
+		}
+		got[desc.Digest] = desc
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("IndexBlobs: (-want +got) %s", diff)
+	}
+}
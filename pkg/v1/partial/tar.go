@@ -0,0 +1,164 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partial
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"sync"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// TOCEntry describes a single entry in a layer's tarball.
+type TOCEntry struct {
+	// Header is the tar header of the entry.
+	Header *tar.Header
+
+	// Offset is where the entry's contents start in the uncompressed layer.
+	Offset int64
+}
+
+// TOC reads through the uncompressed contents of l and returns a table of
+// contents with the offset of each entry, so that individual files can be read
+// later with OpenEntry without reading the whole layer again.
+func TOC(l v1.Layer) ([]TOCEntry, error) {
+	rc, err := l.Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	// archive/tar reads exactly the blocks it needs, so the count of bytes
+	// read after Next is the offset of the entry's contents.
+	cr := &countingReader{r: rc}
+	tr := tar.NewReader(cr)
+	var toc []TOCEntry
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return toc, nil
+		} else if err != nil {
+			return nil, err
+		}
+		toc = append(toc, TOCEntry{
+			Header: hdr,
+			Offset: cr.n,
+		})
+	}
+}
+
+// OpenEntry returns a reader for the contents of e, which must come from the
+// TOC of l.
+func OpenEntry(l v1.Layer, e TOCEntry) (io.Reader, error) {
+	ra, err := ReaderAt(l)
+	if err != nil {
+		return nil, err
+	}
+	return io.NewSectionReader(ra, e.Offset, e.Header.Size), nil
+}
+
+// withReaderAt is implemented by layers whose blobs support random access.
+type withReaderAt interface {
+	// ReaderAt returns an io.ReaderAt for the blob (i.e. Compressed) contents.
+	ReaderAt() (io.ReaderAt, error)
+}
+
+// ReaderAt returns an io.ReaderAt for the uncompressed contents of l.
+//
+// If the layer is an uncompressed tarball and its implementation supports
+// random access (e.g. via range requests), reads go directly to the requested
+// offset. Otherwise, this falls back to reading Uncompressed sequentially,
+// which is only efficient for reads at increasing offsets.
+func ReaderAt(l v1.Layer) (io.ReaderAt, error) {
+	mt, err := l.MediaType()
+	if err != nil {
+		return nil, err
+	}
+	if isUncompressed(mt) {
+		if wra, ok := unwrap(l).(withReaderAt); ok {
+			return wra.ReaderAt()
+		}
+	}
+	return &sequentialReaderAt{open: l.Uncompressed}, nil
+}
+
+func isUncompressed(mt types.MediaType) bool {
+	switch mt {
+	case types.OCIUncompressedLayer, types.OCIUncompressedRestrictedLayer, types.DockerUncompressedLayer:
+		return true
+	}
+	return false
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// sequentialReaderAt implements io.ReaderAt on top of a stream, reopening it
+// whenever a read asks for an offset that has already been passed.
+type sequentialReaderAt struct {
+	open func() (io.ReadCloser, error)
+
+	mu  sync.Mutex
+	rc  io.ReadCloser
+	pos int64
+}
+
+// ReadAt implements io.ReaderAt
+func (s *sequentialReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.rc == nil || off < s.pos {
+		if s.rc != nil {
+			s.rc.Close()
+		}
+		rc, err := s.open()
+		if err != nil {
+			s.rc = nil
+			return 0, err
+		}
+		s.rc, s.pos = rc, 0
+	}
+
+	if off > s.pos {
+		n, err := io.CopyN(io.Discard, s.rc, off-s.pos)
+		s.pos += n
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := io.ReadFull(s.rc, p)
+	s.pos += int64(n)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	if err != nil {
+		s.rc.Close()
+		s.rc = nil
+	}
+	return n, err
+}
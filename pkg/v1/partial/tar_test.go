@@ -0,0 +1,81 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partial_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestTOC(t *testing.T) {
+	files := []struct{ name, contents string }{
+		{"a.txt", "hello"},
+		{"dir/b.txt", string(bytes.Repeat([]byte("b"), 1500))},
+		{"c.txt", ""},
+		{"d.txt", "world"},
+	}
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     f.name,
+			Mode:     0644,
+			Size:     int64(len(f.contents)),
+			Typeflag: tar.TypeReg,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	l := static.NewLayer(buf.Bytes(), types.OCIUncompressedLayer)
+	toc, err := partial.TOC(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(toc), len(files); got != want {
+		t.Fatalf("len(TOC); got %d, want %d", got, want)
+	}
+
+	// Read backwards to make sure we don't depend on sequential access.
+	for i := len(toc) - 1; i >= 0; i-- {
+		e := toc[i]
+		if got, want := e.Header.Name, files[i].name; got != want {
+			t.Errorf("TOC[%d].Name; got %q, want %q", i, got, want)
+		}
+		r, err := partial.OpenEntry(l, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(b), files[i].contents; got != want {
+			t.Errorf("OpenEntry(%s); got %q, want %q", e.Header.Name, got, want)
+		}
+	}
+}
@@ -46,6 +46,18 @@ type uncompressedLayerExtender struct {
 	size          int64
 	hashSizeError error
 	once          sync.Once
+
+	diffID     v1.Hash
+	diffIDErr  error
+	diffIDOnce sync.Once
+}
+
+// DiffID implements v1.Layer
+func (ule *uncompressedLayerExtender) DiffID() (v1.Hash, error) {
+	ule.diffIDOnce.Do(func() {
+		ule.diffID, ule.diffIDErr = ule.UncompressedLayer.DiffID()
+	})
+	return ule.diffID, ule.diffIDErr
 }
 
 // Compressed implements v1.Layer
@@ -110,6 +122,11 @@ type uncompressedImageExtender struct {
 
 	lock     sync.Mutex
 	manifest *v1.Manifest
+
+	// Memoize layers by diffID so that their digests are only computed
+	// once, no matter how many times the manifest or layers are requested.
+	layerLock sync.Mutex
+	layers    map[v1.Hash]v1.Layer
 }
 
 // Assert that our extender type completes the v1.Image interface
@@ -206,11 +223,26 @@ func (i *uncompressedImageExtender) Layers() ([]v1.Layer, error) {
 
 // LayerByDiffID implements v1.Image
 func (i *uncompressedImageExtender) LayerByDiffID(diffID v1.Hash) (v1.Layer, error) {
+	i.layerLock.Lock()
+	defer i.layerLock.Unlock()
+	if l, ok := i.layers[diffID]; ok {
+		return l, nil
+	}
+
 	ul, err := i.UncompressedImageCore.LayerByDiffID(diffID)
 	if err != nil {
 		return nil, err
 	}
-	return UncompressedToLayer(ul)
+	l, err := UncompressedToLayer(ul)
+	if err != nil {
+		return nil, err
+	}
+
+	if i.layers == nil {
+		i.layers = map[v1.Hash]v1.Layer{}
+	}
+	i.layers[diffID] = l
+	return l, nil
 }
 
 // LayerByDigest implements v1.Image
@@ -231,3 +231,62 @@ func TestUncompressed(t *testing.T) {
 		t.Errorf("Exists() = %t != %t", got, want)
 	}
 }
+
+// countingLayer counts how many times its contents are read.
+type countingLayer struct {
+	v1.Layer
+	reads *int
+}
+
+func (l *countingLayer) Uncompressed() (io.ReadCloser, error) {
+	*l.reads++
+	return l.Layer.Uncompressed()
+}
+
+type countingImage struct {
+	uncompressedImage
+	reads int
+}
+
+func (i *countingImage) LayerByDiffID(h v1.Hash) (partial.UncompressedLayer, error) {
+	l, err := i.img.LayerByDiffID(h)
+	if err != nil {
+		return nil, err
+	}
+	return &countingLayer{Layer: l, reads: &i.reads}, nil
+}
+
+func TestUncompressedCachesLayers(t *testing.T) {
+	rnd, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	core := &countingImage{uncompressedImage: uncompressedImage{rnd}}
+	img, err := partial.UncompressedToImage(core)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := img.Manifest(); err != nil {
+			t.Fatal(err)
+		}
+		layers, err := img.Layers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, l := range layers {
+			if _, err := l.Digest(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := l.Size(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if got, want := core.reads, 3; got != want {
+		t.Errorf("Uncompressed() called %d times, want %d", got, want)
+	}
+}
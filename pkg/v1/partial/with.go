@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"time"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/types"
@@ -49,6 +50,30 @@ func ConfigName(i WithRawConfigFile) (v1.Hash, error) {
 	return h, err
 }
 
+// Created returns when the image was created, according to the "created"
+// field of its config file. It returns the zero time, not an error, if the
+// field is missing, null or empty.
+func Created(i WithRawConfigFile) (time.Time, error) {
+	b, err := i.RawConfigFile()
+	if err != nil {
+		return time.Time{}, err
+	}
+	var cfg struct {
+		Created string `json:"created"`
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return time.Time{}, err
+	}
+	if cfg.Created == "" {
+		return time.Time{}, nil
+	}
+	created, err := time.Parse(time.RFC3339Nano, cfg.Created)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing created time: %w", err)
+	}
+	return created, nil
+}
+
 type configLayer struct {
 	hash    v1.Hash
 	content []byte
@@ -190,6 +215,55 @@ func Size(i WithRawManifest) (int64, error) {
 	return int64(len(b)), nil
 }
 
+// ImageSize returns the total compressed size of img: the size of its
+// manifest, plus the sizes of its config and layers as recorded in the
+// manifest. A layer that appears more than once is counted once.
+func ImageSize(img v1.Image) (int64, error) {
+	total, err := img.Size()
+	if err != nil {
+		return -1, err
+	}
+	blobs, err := Blobs(img)
+	if err != nil {
+		return -1, err
+	}
+	return total + sumSizes(blobs), nil
+}
+
+// Blobs returns the descriptors of img's config and layers, as recorded in
+// its manifest, with each digest listed once. Unlike img.Layers(), this
+// includes the config, and the descriptors (sizes, media types and any URLs)
+// are enough to fetch each blob without reading the manifest again.
+func Blobs(img v1.Image) ([]v1.Descriptor, error) {
+	return imageBlobs(img, map[string]struct{}{})
+}
+
+// imageBlobs returns the descriptors of img's config and layers that aren't
+// already in seen, and adds them to seen.
+func imageBlobs(img v1.Image, seen map[string]struct{}) ([]v1.Descriptor, error) {
+	m, err := img.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	var blobs []v1.Descriptor
+	for _, desc := range append([]v1.Descriptor{m.Config}, m.Layers...) {
+		if _, ok := seen[desc.Digest.String()]; ok {
+			continue
+		}
+		seen[desc.Digest.String()] = struct{}{}
+		blobs = append(blobs, desc)
+	}
+	return blobs, nil
+}
+
+func sumSizes(descs []v1.Descriptor) int64 {
+	var total int64
+	for _, desc := range descs {
+		total += desc.Size
+	}
+	return total
+}
+
 // FSLayers is a helper for implementing v1.Image
 func FSLayers(i WithManifest) ([]v1.Hash, error) {
 	m, err := i.Manifest()
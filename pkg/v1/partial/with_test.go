@@ -15,12 +15,19 @@
 package partial_test
 
 import (
+	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
@@ -244,3 +251,66 @@ func TestExists(t *testing.T) {
 		t.Errorf("Exists() = %t != %t", got, want)
 	}
 }
+
+type rawConfig []byte
+
+func (r rawConfig) RawConfigFile() ([]byte, error) {
+	return r, nil
+}
+
+func TestCreated(t *testing.T) {
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2020, 2, 3, 4, 5, 6, 7, time.UTC)
+	img, err = mutate.CreatedAt(img, v1.Time{Time: want})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Write it to a registry to check that it works through remote too.
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(u.Host + "/test/created")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	rmt, err := remote.Image(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		desc string
+		i    partial.WithRawConfigFile
+		want time.Time
+	}{
+		{"mutate", img, want},
+		{"remote", rmt, want},
+		{"missing", rawConfig(`{}`), time.Time{}},
+		{"null", rawConfig(`{"created":null}`), time.Time{}},
+		{"empty", rawConfig(`{"created":""}`), time.Time{}},
+		{"rfc3339", rawConfig(`{"created":"2020-02-03T04:05:06Z"}`), time.Date(2020, 2, 3, 4, 5, 6, 0, time.UTC)},
+	} {
+		got, err := partial.Created(tc.i)
+		if err != nil {
+			t.Errorf("%s: Created() = %v", tc.desc, err)
+			continue
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("%s: Created() = %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+
+	if _, err := partial.Created(rawConfig(`{"created":"yesterday"}`)); err == nil {
+		t.Error("Created() with a bad time: expected error")
+	}
+}
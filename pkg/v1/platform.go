@@ -85,6 +85,89 @@ func (p Platform) Equals(o Platform) bool {
 		stringSliceEqualIgnoreOrder(p.Features, o.Features)
 }
 
+// Satisfies returns true if this platform satisfies the required platform,
+// i.e. if an image for this platform can be used where one for required is
+// asked for:
+//   - OS and architecture must be identical, treating "aarch64" as "arm64"
+//     and "x86_64" as "amd64".
+//   - A required variant must be identical, if provided. An empty variant is
+//     treated as "v8" for arm64 and "v7" for arm.
+//   - A required OS version must be identical, if provided. For Windows, the
+//     required OS version may be a prefix of this platform's, e.g. "10.0.17763"
+//     is satisfied by "10.0.17763.1234".
+//   - The required features and OS features must be subsets of this platform's.
+func (p Platform) Satisfies(required Platform) bool {
+	arch, variant := normalizeArch(p.Architecture, p.Variant)
+	reqArch, reqVariant := normalizeArch(required.Architecture, required.Variant)
+
+	// Required fields that must be identical.
+	if arch != reqArch || p.OS != required.OS {
+		return false
+	}
+
+	// Optional fields that may be empty, but must be identical if provided.
+	if required.Variant != "" && variant != reqVariant {
+		return false
+	}
+	if required.OSVersion != "" && !osVersionSatisfies(p.OS, p.OSVersion, required.OSVersion) {
+		return false
+	}
+
+	// Verify required platform's features are a subset of this platform's features.
+	if !isSubset(p.OSFeatures, required.OSFeatures) {
+		return false
+	}
+	if !isSubset(p.Features, required.Features) {
+		return false
+	}
+
+	return true
+}
+
+// normalizeArch returns the canonical name and variant for arch.
+func normalizeArch(arch, variant string) (string, string) {
+	switch arch {
+	case "aarch64", "arm64":
+		arch = "arm64"
+		if variant == "" || variant == "8" {
+			variant = "v8"
+		}
+	case "x86_64", "x86-64":
+		arch = "amd64"
+	case "arm":
+		if variant == "" {
+			variant = "v7"
+		}
+	}
+	return arch, variant
+}
+
+// osVersionSatisfies returns true if version satisfies the required version.
+func osVersionSatisfies(os, version, required string) bool {
+	if version == required {
+		return true
+	}
+	// On Windows, the OS version is major.minor.build.revision, and any
+	// revision of a build is compatible with it.
+	return os == "windows" && strings.HasPrefix(version, required+".")
+}
+
+// isSubset checks if the required array of strings is a subset of the given lst.
+func isSubset(lst, required []string) bool {
+	set := make(map[string]bool)
+	for _, value := range lst {
+		set[value] = true
+	}
+
+	for _, value := range required {
+		if _, ok := set[value]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
 // stringSliceEqual compares 2 string slices and returns if their contents are identical.
 func stringSliceEqual(a, b []string) bool {
 	if len(a) != len(b) {
@@ -149,3 +149,313 @@ func TestPlatformEquals(t *testing.T) {
 		}
 	}
 }
+
+// TestPlatformSatisfies runs test cases on the Satisfies method which verifies
+// whether the given platform can run on the required platform by checking the
+// compatibility of architecture, OS, OS version, OS features, variant and features.
+func TestPlatformSatisfies(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		// want is the expected return value from Satisfies
+		// when the given platform is 'given' and the required platform is 'required'.
+		given    v1.Platform
+		required v1.Platform
+		want     bool
+	}{{ // The given & required platforms are identical. Satisfies expected to return true.
+		given: v1.Platform{
+			Architecture: "amd64",
+			OS:           "linux",
+			OSVersion:    "10.0.10586",
+			OSFeatures:   []string{"win32k"},
+			Variant:      "armv6l",
+			Features:     []string{"sse4"},
+		},
+		required: v1.Platform{
+			Architecture: "amd64",
+			OS:           "linux",
+			OSVersion:    "10.0.10586",
+			OSFeatures:   []string{"win32k"},
+			Variant:      "armv6l",
+			Features:     []string{"sse4"},
+		},
+		want: true,
+	},
+		{ // OS and Architecture must exactly match. Satisfies expected to return false.
+			given: v1.Platform{
+				Architecture: "arm",
+				OS:           "linux",
+				OSVersion:    "10.0.10586",
+				OSFeatures:   []string{"win64k"},
+				Variant:      "armv6l",
+				Features:     []string{"sse4"},
+			},
+			required: v1.Platform{
+				Architecture: "amd64",
+				OS:           "linux",
+				OSVersion:    "10.0.10586",
+				OSFeatures:   []string{"win32k"},
+				Variant:      "armv6l",
+				Features:     []string{"sse4"},
+			},
+			want: false,
+		},
+		{ // OS version must exactly match
+			given: v1.Platform{
+				Architecture: "amd64",
+				OS:           "linux",
+				OSVersion:    "10.0.10586",
+				OSFeatures:   []string{"win64k"},
+				Variant:      "armv6l",
+				Features:     []string{"sse4"},
+			},
+			required: v1.Platform{
+				Architecture: "amd64",
+				OS:           "linux",
+				OSVersion:    "10.0.10587",
+				OSFeatures:   []string{"win64k"},
+				Variant:      "armv6l",
+				Features:     []string{"sse4"},
+			},
+			want: false,
+		},
+		{ // OS Features must exactly match. Satisfies expected to return false.
+			given: v1.Platform{
+				Architecture: "arm",
+				OS:           "linux",
+				OSVersion:    "10.0.10586",
+				OSFeatures:   []string{"win64k"},
+				Variant:      "armv6l",
+				Features:     []string{"sse4"},
+			},
+			required: v1.Platform{
+				Architecture: "arm",
+				OS:           "linux",
+				OSVersion:    "10.0.10586",
+				OSFeatures:   []string{"win32k"},
+				Variant:      "armv6l",
+				Features:     []string{"sse4"},
+			},
+			want: false,
+		},
+		{ // Variant must exactly match. Satisfies expected to return false.
+			given: v1.Platform{
+				Architecture: "amd64",
+				OS:           "linux",
+				OSVersion:    "10.0.10586",
+				OSFeatures:   []string{"win64k"},
+				Variant:      "armv6l",
+				Features:     []string{"sse4"},
+			},
+			required: v1.Platform{
+				Architecture: "amd64",
+				OS:           "linux",
+				OSVersion:    "10.0.10586",
+				OSFeatures:   []string{"win64k"},
+				Variant:      "armv7l",
+				Features:     []string{"sse4"},
+			},
+			want: false,
+		},
+		{ // OS must exactly match, and is case sensative. Satisfies expected to return false.
+			given: v1.Platform{
+				Architecture: "arm",
+				OS:           "linux",
+				OSVersion:    "10.0.10586",
+				OSFeatures:   []string{"win64k"},
+				Variant:      "armv6l",
+				Features:     []string{"sse4"},
+			},
+			required: v1.Platform{
+				Architecture: "arm",
+				OS:           "LinuX",
+				OSVersion:    "10.0.10586",
+				OSFeatures:   []string{"win64k"},
+				Variant:      "armv6l",
+				Features:     []string{"sse4"},
+			},
+			want: false,
+		},
+		{ // OSVersion and Variant are specified in given but not in required.
+			// Satisfies expected to return true.
+			given: v1.Platform{
+				Architecture: "arm",
+				OS:           "linux",
+				OSVersion:    "10.0.10586",
+				OSFeatures:   []string{"win64k"},
+				Variant:      "armv6l",
+				Features:     []string{"sse4"},
+			},
+			required: v1.Platform{
+				Architecture: "arm",
+				OS:           "linux",
+				OSVersion:    "",
+				OSFeatures:   []string{"win64k"},
+				Variant:      "",
+				Features:     []string{"sse4"},
+			},
+			want: true,
+		},
+		{ // Ensure the optional field OSVersion & Variant match exactly if specified as required.
+			given: v1.Platform{
+				Architecture: "amd64",
+				OS:           "linux",
+				OSVersion:    "",
+				OSFeatures:   []string{},
+				Variant:      "",
+				Features:     []string{},
+			},
+			required: v1.Platform{
+				Architecture: "amd64",
+				OS:           "linux",
+				OSVersion:    "10.0.10586",
+				OSFeatures:   []string{"win32k"},
+				Variant:      "armv6l",
+				Features:     []string{"sse4"},
+			},
+			want: false,
+		},
+		{ // Checking subset validity when required less features than given features.
+			// Satisfies expected to return true.
+			given: v1.Platform{
+				Architecture: "",
+				OS:           "linux",
+				OSVersion:    "10.0.10586",
+				OSFeatures:   []string{"win32k"},
+				Variant:      "armv6l",
+				Features:     []string{"sse4"},
+			},
+			required: v1.Platform{
+				Architecture: "",
+				OS:           "linux",
+				OSVersion:    "",
+				OSFeatures:   []string{},
+				Variant:      "",
+				Features:     []string{},
+			},
+			want: true,
+		},
+		{ // Checking subset validity when required features are subset of given features.
+			// Satisfies expected to return true.
+			given: v1.Platform{
+				Architecture: "arm",
+				OS:           "linux",
+				OSVersion:    "10.0.10586",
+				OSFeatures:   []string{"win64k", "f1", "f2"},
+				Variant:      "",
+				Features:     []string{"sse4", "f1"},
+			},
+			required: v1.Platform{
+				Architecture: "arm",
+				OS:           "linux",
+				OSVersion:    "10.0.10586",
+				OSFeatures:   []string{"win64k"},
+				Variant:      "",
+				Features:     []string{"sse4"},
+			},
+			want: true,
+		},
+		{ // Checking subset validity when some required features is not subset of given features.
+			// Satisfies expected to return false.
+			given: v1.Platform{
+				Architecture: "arm",
+				OS:           "linux",
+				OSVersion:    "10.0.10586",
+				OSFeatures:   []string{"win64k", "f1", "f2"},
+				Variant:      "",
+				Features:     []string{"sse4", "f1"},
+			},
+			required: v1.Platform{
+				Architecture: "arm",
+				OS:           "linux",
+				OSVersion:    "10.0.10586",
+				OSFeatures:   []string{"win64k"},
+				Variant:      "",
+				Features:     []string{"sse4", "f2"},
+			},
+			want: false,
+		},
+		{ // Checking subset validity when OS features not required,
+			// and required features is indeed a subset of given features.
+			// Satisfies expected to return true.
+			given: v1.Platform{
+				Architecture: "arm",
+				OS:           "linux",
+				OSVersion:    "10.0.10586",
+				OSFeatures:   []string{"win64k", "f1", "f2"},
+				Variant:      "armv6l",
+				Features:     []string{"sse4"},
+			},
+			required: v1.Platform{
+				Architecture: "arm",
+				OS:           "linux",
+				OSVersion:    "10.0.10586",
+				OSFeatures:   []string{},
+				Variant:      "armv6l",
+				Features:     []string{"sse4"},
+			},
+			want: true,
+		},
+		{ // An empty arm64 variant is equivalent to v8.
+			given:    v1.Platform{Architecture: "arm64", OS: "linux"},
+			required: v1.Platform{Architecture: "arm64", OS: "linux", Variant: "v8"},
+			want:     true,
+		},
+		{ // An arm64 v8 variant satisfies an arm64 platform with no variant.
+			given:    v1.Platform{Architecture: "arm64", OS: "linux", Variant: "v8"},
+			required: v1.Platform{Architecture: "arm64", OS: "linux"},
+			want:     true,
+		},
+		{ // aarch64 is an alias for arm64.
+			given:    v1.Platform{Architecture: "aarch64", OS: "linux"},
+			required: v1.Platform{Architecture: "arm64", OS: "linux", Variant: "v8"},
+			want:     true,
+		},
+		{ // An empty arm variant is equivalent to v7.
+			given:    v1.Platform{Architecture: "arm", OS: "linux"},
+			required: v1.Platform{Architecture: "arm", OS: "linux", Variant: "v7"},
+			want:     true,
+		},
+		{ // An arm v6 variant does not satisfy v7.
+			given:    v1.Platform{Architecture: "arm", OS: "linux", Variant: "v6"},
+			required: v1.Platform{Architecture: "arm", OS: "linux", Variant: "v7"},
+			want:     false,
+		},
+		{ // Any arm variant satisfies an arm platform with no variant.
+			given:    v1.Platform{Architecture: "arm", OS: "linux", Variant: "v6"},
+			required: v1.Platform{Architecture: "arm", OS: "linux"},
+			want:     true,
+		},
+		{ // arm and arm64 are different architectures.
+			given:    v1.Platform{Architecture: "arm64", OS: "linux", Variant: "v8"},
+			required: v1.Platform{Architecture: "arm", OS: "linux", Variant: "v8"},
+			want:     false,
+		},
+		{ // On Windows, any revision satisfies the required build.
+			given:    v1.Platform{Architecture: "amd64", OS: "windows", OSVersion: "10.0.17763.1234"},
+			required: v1.Platform{Architecture: "amd64", OS: "windows", OSVersion: "10.0.17763"},
+			want:     true,
+		},
+		{ // On Windows, a different build does not satisfy the required build.
+			given:    v1.Platform{Architecture: "amd64", OS: "windows", OSVersion: "10.0.20348.1234"},
+			required: v1.Platform{Architecture: "amd64", OS: "windows", OSVersion: "10.0.17763"},
+			want:     false,
+		},
+		{ // Build numbers are not matched as string prefixes.
+			given:    v1.Platform{Architecture: "amd64", OS: "windows", OSVersion: "10.0.177630"},
+			required: v1.Platform{Architecture: "amd64", OS: "windows", OSVersion: "10.0.17763"},
+			want:     false,
+		},
+		{ // OS version prefixes are only matched on Windows.
+			given:    v1.Platform{Architecture: "amd64", OS: "linux", OSVersion: "10.0.17763.1234"},
+			required: v1.Platform{Architecture: "amd64", OS: "linux", OSVersion: "10.0.17763"},
+			want:     false,
+		},
+	}
+
+	for _, test := range tests {
+		got := test.given.Satisfies(test.required)
+		if got != test.want {
+			t.Errorf("%v.Satisfies(%v); got %v, want %v", test.given, test.required, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+func TestContextCancel(t *testing.T) {
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		// stall reports whether the server should hang on this request.
+		stall func(r *http.Request) bool
+		do    func(ref name.Tag, opts ...Option) error
+	}{{
+		name:  "Write",
+		stall: func(r *http.Request) bool { return r.Method == http.MethodPatch },
+		do: func(ref name.Tag, opts ...Option) error {
+			return Write(ref, img, opts...)
+		},
+	}, {
+		name:  "MultiWrite",
+		stall: func(r *http.Request) bool { return r.Method == http.MethodPatch },
+		do: func(ref name.Tag, opts ...Option) error {
+			return MultiWrite(map[name.Reference]Taggable{ref: img}, opts...)
+		},
+	}, {
+		name:  "List",
+		stall: func(r *http.Request) bool { return strings.HasSuffix(r.URL.Path, "/tags/list") },
+		do: func(ref name.Tag, opts ...Option) error {
+			_, err := List(ref.Context(), opts...)
+			return err
+		},
+	}, {
+		name:  "Referrers",
+		stall: func(r *http.Request) bool { return strings.Contains(r.URL.Path, "/referrers/") },
+		do: func(ref name.Tag, opts ...Option) error {
+			_, err := Referrers(ref.Context().Digest(d.String()), opts...)
+			return err
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := registry.New()
+			stalled := make(chan struct{}, 1)
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !tc.stall(r) {
+					reg.ServeHTTP(w, r)
+					return
+				}
+				// Consume the body so the server notices when the client
+				// goes away.
+				io.Copy(io.Discard, r.Body)
+				select {
+				case stalled <- struct{}{}:
+				default:
+				}
+				select {
+				case <-r.Context().Done():
+				case <-time.After(10 * time.Second):
+				}
+			}))
+			defer s.Close()
+			u, err := url.Parse(s.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ref := mustNewTag(t, u.Host+"/test/cancel:latest")
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() {
+				<-stalled
+				cancel()
+			}()
+
+			// Retry everything with a long backoff, so we'd notice if
+			// cancellation didn't stop the retries.
+			opts := []Option{
+				WithContext(ctx),
+				WithRetryPredicate(func(error) bool { return true }),
+				WithRetryBackoff(Backoff{Duration: time.Hour, Steps: 3}),
+			}
+
+			start := time.Now()
+			err = tc.do(ref, opts...)
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("got %v, want %v", err, context.Canceled)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("took %v to return after cancel", elapsed)
+			}
+		})
+	}
+}
@@ -17,6 +17,7 @@ package remote
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -29,6 +30,46 @@ type catalog struct {
 	Repos []string `json:"repositories"`
 }
 
+// ErrCatalogNotSupported is returned by Catalog and CatalogPage when the
+// registry doesn't implement the /v2/_catalog API. Use errors.Is to check for
+// it; the registry's response is still available via errors.As.
+var ErrCatalogNotSupported = errors.New("registry does not support listing repositories")
+
+type catalogNotSupported struct {
+	err error
+}
+
+func (e *catalogNotSupported) Error() string {
+	return fmt.Sprintf("%v: %v", ErrCatalogNotSupported, e.err)
+}
+
+func (e *catalogNotSupported) Unwrap() error {
+	return e.err
+}
+
+func (e *catalogNotSupported) Is(target error) bool {
+	return target == ErrCatalogNotSupported
+}
+
+// checkCatalogError is like transport.CheckError, but surfaces registries that
+// don't serve /v2/_catalog as ErrCatalogNotSupported.
+func checkCatalogError(resp *http.Response) error {
+	err := transport.CheckError(resp, http.StatusOK)
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		return err
+	}
+	if terr.StatusCode == http.StatusNotFound {
+		return &catalogNotSupported{err}
+	}
+	for _, d := range terr.Errors {
+		if d.Code == transport.UnsupportedErrorCode {
+			return &catalogNotSupported{err}
+		}
+	}
+	return err
+}
+
 // CatalogPage calls /_catalog, returning the list of repositories on the registry.
 func CatalogPage(target name.Registry, last string, n int, options ...Option) ([]string, error) {
 	o, err := makeOptions(target, options...)
@@ -37,7 +78,7 @@ func CatalogPage(target name.Registry, last string, n int, options ...Option) ([
 	}
 
 	scopes := []string{target.Scope(transport.PullScope)}
-	tr, err := transport.NewWithContext(o.context, target, o.auth, o.transport, scopes)
+	tr, err := transport.NewWithContext(o.context, target, o.auth, o.transport, scopes, o.transportOptions()...)
 	if err != nil {
 		return nil, err
 	}
@@ -62,7 +103,7 @@ func CatalogPage(target name.Registry, last string, n int, options ...Option) ([
 	}
 	defer resp.Body.Close()
 
-	if err := transport.CheckError(resp, http.StatusOK); err != nil {
+	if err := checkCatalogError(resp); err != nil {
 		return nil, err
 	}
 
@@ -82,7 +123,7 @@ func Catalog(ctx context.Context, target name.Registry, options ...Option) ([]st
 	}
 
 	scopes := []string{target.Scope(transport.PullScope)}
-	tr, err := transport.NewWithContext(o.context, target, o.auth, o.transport, scopes)
+	tr, err := transport.NewWithContext(o.context, target, o.auth, o.transport, scopes, o.transportOptions()...)
 	if err != nil {
 		return nil, err
 	}
@@ -104,10 +145,7 @@ func Catalog(ctx context.Context, target name.Registry, options ...Option) ([]st
 		ctx = o.context
 	}
 
-	var (
-		parsed   catalog
-		repoList []string
-	)
+	var repoList []string
 
 	// get responses until there is no next page
 	for {
@@ -128,11 +166,14 @@ func Catalog(ctx context.Context, target name.Registry, options ...Option) ([]st
 			return nil, err
 		}
 
-		if err := transport.CheckError(resp, http.StatusOK); err != nil {
+		if err := checkCatalogError(resp); err != nil {
+			resp.Body.Close()
 			return nil, err
 		}
 
+		var parsed catalog
 		if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+			resp.Body.Close()
 			return nil, err
 		}
 		if err := resp.Body.Close(); err != nil {
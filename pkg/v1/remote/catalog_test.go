@@ -25,6 +25,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 )
 
 func TestCatalogPage(t *testing.T) {
@@ -181,3 +182,56 @@ func TestCancelledCatalog(t *testing.T) {
 		t.Errorf("wanted %v got %v", want, got)
 	}
 }
+
+func TestCatalogNotSupported(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		status int
+		body   string
+	}{{
+		name:   "not found",
+		status: http.StatusNotFound,
+		body:   `{"errors":[{"code":"NOT_FOUND","message":"404 page not found"}]}`,
+	}, {
+		name:   "unsupported",
+		status: http.StatusMethodNotAllowed,
+		body:   `{"errors":[{"code":"UNSUPPORTED","message":"the operation is unsupported"}]}`,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v2/":
+					w.WriteHeader(http.StatusOK)
+				case "/v2/_catalog":
+					w.WriteHeader(tc.status)
+					w.Write([]byte(tc.body))
+				default:
+					t.Fatalf("Unexpected path: %v", r.URL.Path)
+				}
+			}))
+			defer server.Close()
+			u, err := url.Parse(server.URL)
+			if err != nil {
+				t.Fatalf("url.Parse(%v) = %v", server.URL, err)
+			}
+
+			reg, err := name.NewRegistry(u.Host)
+			if err != nil {
+				t.Fatalf("name.NewRegistry(%v) = %v", u.Host, err)
+			}
+
+			_, err = Catalog(context.Background(), reg)
+			if !errors.Is(err, ErrCatalogNotSupported) {
+				t.Errorf("Catalog(); got %v, want %v", err, ErrCatalogNotSupported)
+			}
+			var terr *transport.Error
+			if !errors.As(err, &terr) || terr.StatusCode != tc.status {
+				t.Errorf("Catalog(); got %v, want a transport.Error with status %d", err, tc.status)
+			}
+
+			if _, err := CatalogPage(reg, "", 10); !errors.Is(err, ErrCatalogNotSupported) {
+				t.Errorf("CatalogPage(); got %v, want %v", err, ErrCatalogNotSupported)
+			}
+		})
+	}
+}
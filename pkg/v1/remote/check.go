@@ -30,15 +30,18 @@ import (
 // This can be useful to check whether the caller has permission to push an
 // image before doing work to construct the image.
 //
+// The opts are passed to transport.NewWithContext, e.g.
+// transport.WithAllowInsecureAuth to check an insecure registry.
+//
 // TODO(#412): Remove the need for this method.
-func CheckPushPermission(ref name.Reference, kc authn.Keychain, t http.RoundTripper) error {
-	auth, err := kc.Resolve(ref.Context().Registry)
+func CheckPushPermission(ref name.Reference, kc authn.Keychain, t http.RoundTripper, opts ...transport.Option) error {
+	auth, err := authn.Resolve(context.TODO(), kc, ref.Context().Registry)
 	if err != nil {
 		return fmt.Errorf("resolving authorization for %v failed: %w", ref.Context().Registry, err)
 	}
 
 	scopes := []string{ref.Scope(transport.PushScope)}
-	tr, err := transport.NewWithContext(context.TODO(), ref.Context().Registry, auth, t, scopes)
+	tr, err := transport.NewWithContext(context.TODO(), ref.Context().Registry, auth, t, scopes, opts...)
 	if err != nil {
 		return fmt.Errorf("creating push check transport for %v failed: %w", ref.Context().Registry, err)
 	}
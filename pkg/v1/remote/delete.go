@@ -15,6 +15,7 @@
 package remote
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -23,23 +24,73 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 )
 
+// ErrDeleteNotSupported is returned by Delete when the registry responds with
+// 405 Method Not Allowed, which registries use to signal that deletion is
+// disabled by policy. Use errors.Is to check for it; the registry's response
+// is still available via errors.As.
+var ErrDeleteNotSupported = errors.New("registry does not allow deleting manifests")
+
+type deleteNotSupported struct {
+	err error
+}
+
+func (e *deleteNotSupported) Error() string {
+	return fmt.Sprintf("%v: %v", ErrDeleteNotSupported, e.err)
+}
+
+func (e *deleteNotSupported) Unwrap() error {
+	return e.err
+}
+
+func (e *deleteNotSupported) Is(target error) bool {
+	return target == ErrDeleteNotSupported
+}
+
 // Delete removes the specified image reference from the remote registry.
+//
+// Some registries only delete manifests by digest. If deleting a tag is
+// rejected with 400 Bad Request or 405 Method Not Allowed, Delete resolves the
+// tag with a HEAD request and deletes the manifest it points to instead, which
+// also removes every other tag pointing at that manifest. If the registry
+// doesn't allow deletion at all, the error matches ErrDeleteNotSupported.
 func Delete(ref name.Reference, options ...Option) error {
 	o, err := makeOptions(ref.Context(), options...)
 	if err != nil {
 		return err
 	}
 	scopes := []string{ref.Scope(transport.DeleteScope)}
-	tr, err := transport.NewWithContext(o.context, ref.Context().Registry, o.auth, o.transport, scopes)
+	tr, err := transport.NewWithContext(o.context, ref.Context().Registry, o.auth, o.transport, scopes, o.transportOptions()...)
 	if err != nil {
 		return err
 	}
 	c := &http.Client{Transport: tr}
 
+	err = deleteManifest(o, c, ref.Context(), ref.Identifier())
+	if _, ok := ref.(name.Tag); ok && err != nil {
+		var terr *transport.Error
+		if errors.As(err, &terr) && (terr.StatusCode == http.StatusBadRequest || terr.StatusCode == http.StatusMethodNotAllowed) {
+			desc, herr := Head(ref, options...)
+			if herr != nil {
+				return fmt.Errorf("resolving %s to delete it by digest: %w", ref, herr)
+			}
+			err = deleteManifest(o, c, ref.Context(), desc.Digest.String())
+		}
+	}
+
+	var terr *transport.Error
+	if errors.As(err, &terr) && terr.StatusCode == http.StatusMethodNotAllowed {
+		return &deleteNotSupported{err}
+	}
+	return err
+}
+
+// deleteManifest deletes the manifest identified by identifier (a tag or
+// digest) from repo.
+func deleteManifest(o *options, c *http.Client, repo name.Repository, identifier string) error {
 	u := url.URL{
-		Scheme: ref.Context().Registry.Scheme(),
-		Host:   ref.Context().RegistryStr(),
-		Path:   fmt.Sprintf("/v2/%s/manifests/%s", ref.Context().RepositoryStr(), ref.Identifier()),
+		Scheme: repo.Registry.Scheme(),
+		Host:   repo.RegistryStr(),
+		Path:   fmt.Sprintf("/v2/%s/manifests/%s", repo.RepositoryStr(), identifier),
 	}
 
 	req, err := http.NewRequest(http.MethodDelete, u.String(), nil)
@@ -15,13 +15,19 @@
 package remote
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
 func TestDelete(t *testing.T) {
@@ -87,3 +93,83 @@ func TestDeleteBadStatus(t *testing.T) {
 		t.Error("Delete() = nil; wanted error")
 	}
 }
+
+func TestDeleteTagByDigest(t *testing.T) {
+	// Like some registries, this one only deletes manifests by digest.
+	reg := registry.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete && !strings.Contains(r.URL.Path, "/manifests/sha256:") {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"errors":[{"code":"DIGEST_INVALID","message":"provided digest did not match uploaded content"}]}`)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := name.NewTag(u.Host + "/delete/by-digest:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(tag, img); err != nil {
+		t.Fatal(err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Delete(tag); err != nil {
+		t.Fatalf("Delete() = %v", err)
+	}
+	if _, err := Head(tag.Context().Digest(d.String())); err == nil {
+		t.Error("Delete() didn't delete the manifest")
+	}
+}
+
+func TestDeleteNotSupported(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodHead:
+			w.Header().Set("Content-Type", string(types.DockerManifestSchema2))
+			w.Header().Set("Docker-Content-Digest", "sha256:deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef")
+			w.Header().Set("Content-Length", "100")
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			fmt.Fprint(w, `{"errors":[{"code":"UNSUPPORTED","message":"The operation is unsupported."}]}`)
+		}
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{
+		u.Host + "/delete/disabled:latest",
+		u.Host + "/delete/disabled@sha256:deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef",
+	} {
+		ref, err := name.ParseReference(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = Delete(ref)
+		if !errors.Is(err, ErrDeleteNotSupported) {
+			t.Errorf("Delete(%s) = %v, want ErrDeleteNotSupported", ref, err)
+		}
+		var terr *transport.Error
+		if !errors.As(err, &terr) || terr.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("Delete(%s) = %v, want a 405 *transport.Error", ref, err)
+		}
+	}
+}
@@ -17,14 +17,17 @@ package remote
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
 
 	"github.com/google/go-containerregistry/internal/redact"
 	"github.com/google/go-containerregistry/internal/verify"
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/logs"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -90,14 +93,6 @@ func Get(ref name.Reference, options ...Option) (*Descriptor, error) {
 // Note that the server response will not have a body, so any errors encountered
 // should be retried with Get to get more details.
 func Head(ref name.Reference, options ...Option) (*v1.Descriptor, error) {
-	acceptable := []types.MediaType{
-		// Just to look at them.
-		types.DockerManifestSchema1,
-		types.DockerManifestSchema1Signed,
-	}
-	acceptable = append(acceptable, acceptableImageMediaTypes...)
-	acceptable = append(acceptable, acceptableIndexMediaTypes...)
-
 	o, err := makeOptions(ref.Context(), options...)
 	if err != nil {
 		return nil, err
@@ -108,7 +103,51 @@ func Head(ref name.Reference, options ...Option) (*v1.Descriptor, error) {
 		return nil, err
 	}
 
-	return f.headManifest(ref, acceptable)
+	return f.headManifest(ref, headMediaTypes())
+}
+
+// HeadResponse is like Head, but also returns the headers of the registry's
+// response, e.g. to inspect rate limiting headers like RateLimit-Remaining.
+//
+// If the registry responds with an error, the headers are returned along
+// with the error, since that's where e.g. the rate limiting headers of a 429
+// response are found.
+func HeadResponse(ref name.Reference, options ...Option) (*v1.Descriptor, http.Header, error) {
+	o, err := makeOptions(ref.Context(), options...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := makeFetcher(ref, o)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return f.headManifestResponse(ref, headMediaTypes())
+}
+
+// headMediaTypes returns the media types that Head accepts.
+func headMediaTypes() []types.MediaType {
+	acceptable := []types.MediaType{
+		// Just to look at them.
+		types.DockerManifestSchema1,
+		types.DockerManifestSchema1Signed,
+	}
+	acceptable = append(acceptable, acceptableImageMediaTypes...)
+	return append(acceptable, acceptableIndexMediaTypes...)
+}
+
+// Resolve returns the digest reference that tag currently points to, by
+// issuing a HEAD request and reading the Docker-Content-Digest header.
+//
+// Unlike Get, this never falls back to fetching the manifest, so it returns an
+// error if the registry doesn't include the header.
+func Resolve(tag name.Tag, options ...Option) (name.Digest, error) {
+	desc, err := Head(tag, options...)
+	if err != nil {
+		return name.Digest{}, err
+	}
+	return tag.Context().Digest(desc.Digest.String()), nil
 }
 
 // Handle options and fetch the manifest with the acceptable MediaTypes in the
@@ -123,6 +162,9 @@ func get(ref name.Reference, acceptable []types.MediaType, options ...Option) (*
 		return nil, err
 	}
 	b, desc, err := f.fetchManifest(ref, acceptable)
+	if err != nil && len(o.mirrors) != 0 && shouldTryMirror(err) {
+		f, b, desc, err = fetchManifestFromMirrors(ref, acceptable, o, err)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -134,6 +176,72 @@ func get(ref name.Reference, acceptable []types.MediaType, options ...Option) (*
 	}, nil
 }
 
+// shouldTryMirror returns true if err indicates that the primary registry is
+// unavailable, rather than that the reference doesn't exist.
+func shouldTryMirror(err error) bool {
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		for _, code := range retryableStatusCodes {
+			if terr.StatusCode == code {
+				return true
+			}
+		}
+		return false
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr)
+}
+
+// fetchManifestFromMirrors tries each of o.mirrors in order, returning the
+// fetcher for the first mirror that succeeds so that subsequent blob fetches
+// are also served by it. If every mirror fails, the last error is returned.
+func fetchManifestFromMirrors(ref name.Reference, acceptable []types.MediaType, o *options, lastErr error) (*fetcher, []byte, *v1.Descriptor, error) {
+	for _, mirror := range o.mirrors {
+		mref, err := mirrorReference(ref, mirror)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		// Never send the primary registry's credentials to a mirror.
+		mo := *o
+		mo.auth = authn.Anonymous
+		if o.keychain != nil {
+			auth, err := authn.Resolve(o.context, o.keychain, mref.Context())
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			mo.auth = auth
+		}
+		f, err := makeFetcher(mref, &mo)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		b, desc, err := f.fetchManifest(mref, acceptable)
+		if err != nil {
+			logs.Warn.Printf("fetching %s from mirror %s: %v", ref, mirror, err)
+			lastErr = err
+			continue
+		}
+		return f, b, desc, nil
+	}
+	return nil, nil, nil, lastErr
+}
+
+// mirrorReference rewrites the registry of ref to be mirror.
+func mirrorReference(ref name.Reference, mirror string) (name.Reference, error) {
+	repo, err := name.NewRepository(mirror + "/" + ref.Context().RepositoryStr())
+	if err != nil {
+		return nil, fmt.Errorf("invalid mirror %q: %w", mirror, err)
+	}
+	if repo.RegistryStr() != mirror {
+		return nil, fmt.Errorf("invalid mirror %q: not a registry host", mirror)
+	}
+	if d, ok := ref.(name.Digest); ok {
+		return repo.Digest(d.DigestStr()), nil
+	}
+	return repo.Tag(ref.Identifier()), nil
+}
+
 // Image converts the Descriptor into a v1.Image.
 //
 // If the fetched artifact is already an image, it will just return it.
@@ -217,7 +325,7 @@ type fetcher struct {
 }
 
 func makeFetcher(ref name.Reference, o *options) (*fetcher, error) {
-	tr, err := transport.NewWithContext(o.context, ref.Context().Registry, o.auth, o.transport, []string{ref.Scope(transport.PullScope)})
+	tr, err := transport.NewWithContext(o.context, ref.Context().Registry, o.auth, o.transport, []string{ref.Scope(transport.PullScope)}, o.transportOptions()...)
 	if err != nil {
 		return nil, err
 	}
@@ -271,7 +379,8 @@ func (f *fetcher) fetchManifest(ref name.Reference, acceptable []types.MediaType
 
 	mediaType := types.MediaType(resp.Header.Get("Content-Type"))
 	contentDigest, err := v1.NewHash(resp.Header.Get("Docker-Content-Digest"))
-	if err == nil && mediaType == types.DockerManifestSchema1Signed {
+	hasContentDigest := err == nil
+	if hasContentDigest && mediaType == types.DockerManifestSchema1Signed {
 		// If we can parse the digest from the header, and it's a signed schema 1
 		// manifest, let's use that for the digest to appease older registries.
 		digest = contentDigest
@@ -282,14 +391,19 @@ func (f *fetcher) fetchManifest(ref name.Reference, acceptable []types.MediaType
 		if digest.String() != dgst.DigestStr() {
 			return nil, nil, fmt.Errorf("manifest digest: %q does not match requested digest: %q for %q", digest, dgst.DigestStr(), f.Ref)
 		}
+	} else if hasContentDigest && contentDigest.Algorithm == digest.Algorithm && !mediaType.IsSchema1() {
+		// For tags, validate that the "Docker-Content-Digest" header (if any)
+		// matches what is returned by the registry.
+		//
+		// Registries that convert schema 1 manifests on the fly are notorious
+		// for getting this wrong, so don't bother checking those.
+		//
+		// For reference:
+		// https://github.com/GoogleContainerTools/kaniko/issues/298
+		if digest != contentDigest {
+			return nil, nil, fmt.Errorf("manifest digest: %q does not match Docker-Content-Digest: %q for %q", digest, contentDigest, f.Ref)
+		}
 	}
-	// Do nothing for tags; I give up.
-	//
-	// We'd like to validate that the "Docker-Content-Digest" header matches what is returned by the registry,
-	// but so many registries implement this incorrectly that it's not worth checking.
-	//
-	// For reference:
-	// https://github.com/GoogleContainerTools/kaniko/issues/298
 
 	// Return all this info since we have to calculate it anyway.
 	desc := v1.Descriptor{
@@ -302,10 +416,15 @@ func (f *fetcher) fetchManifest(ref name.Reference, acceptable []types.MediaType
 }
 
 func (f *fetcher) headManifest(ref name.Reference, acceptable []types.MediaType) (*v1.Descriptor, error) {
+	desc, _, err := f.headManifestResponse(ref, acceptable)
+	return desc, err
+}
+
+func (f *fetcher) headManifestResponse(ref name.Reference, acceptable []types.MediaType) (*v1.Descriptor, http.Header, error) {
 	u := f.url("manifests", ref.Identifier())
 	req, err := http.NewRequest(http.MethodHead, u.String(), nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	accept := []string{}
 	for _, mt := range acceptable {
@@ -315,38 +434,38 @@ func (f *fetcher) headManifest(ref name.Reference, acceptable []types.MediaType)
 
 	resp, err := f.Client.Do(req.WithContext(f.context))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
 
 	if err := transport.CheckError(resp, http.StatusOK); err != nil {
-		return nil, err
+		return nil, resp.Header, err
 	}
 
 	mth := resp.Header.Get("Content-Type")
 	if mth == "" {
-		return nil, fmt.Errorf("HEAD %s: response did not include Content-Type header", u.String())
+		return nil, resp.Header, fmt.Errorf("HEAD %s: response did not include Content-Type header", u.String())
 	}
 	mediaType := types.MediaType(mth)
 
 	size := resp.ContentLength
 	if size == -1 {
-		return nil, fmt.Errorf("GET %s: response did not include Content-Length header", u.String())
+		return nil, resp.Header, fmt.Errorf("GET %s: response did not include Content-Length header", u.String())
 	}
 
 	dh := resp.Header.Get("Docker-Content-Digest")
 	if dh == "" {
-		return nil, fmt.Errorf("HEAD %s: response did not include Docker-Content-Digest header", u.String())
+		return nil, resp.Header, fmt.Errorf("HEAD %s: response did not include Docker-Content-Digest header", u.String())
 	}
 	digest, err := v1.NewHash(dh)
 	if err != nil {
-		return nil, err
+		return nil, resp.Header, err
 	}
 
 	// Validate the digest matches what we asked for, if pulling by digest.
 	if dgst, ok := ref.(name.Digest); ok {
 		if digest.String() != dgst.DigestStr() {
-			return nil, fmt.Errorf("manifest digest: %q does not match requested digest: %q for %q", digest, dgst.DigestStr(), f.Ref)
+			return nil, resp.Header, fmt.Errorf("manifest digest: %q does not match requested digest: %q for %q", digest, dgst.DigestStr(), f.Ref)
 		}
 	}
 
@@ -355,7 +474,7 @@ func (f *fetcher) headManifest(ref name.Reference, acceptable []types.MediaType)
 		Digest:    digest,
 		Size:      size,
 		MediaType: mediaType,
-	}, nil
+	}, resp.Header, nil
 }
 
 func (f *fetcher) fetchBlob(ctx context.Context, size int64, h v1.Hash) (io.ReadCloser, error) {
@@ -24,10 +24,15 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/registry"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
 )
 
 func TestGetSchema1(t *testing.T) {
@@ -180,6 +185,62 @@ func TestHeadSchema1(t *testing.T) {
 	}
 }
 
+func TestHeadResponse(t *testing.T) {
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := registry.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/manifests/") {
+			w.Header().Set("RateLimit-Remaining", "42;w=21600")
+			if strings.Contains(r.URL.Path, "denied") {
+				w.Header().Set("RateLimit-Remaining", "0;w=21600")
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag := mustNewTag(t, u.Host+"/foo/bar:latest")
+	if err := Write(tag, img); err != nil {
+		t.Fatal(err)
+	}
+	want, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	desc, header, err := HeadResponse(tag)
+	if err != nil {
+		t.Fatalf("HeadResponse(%s) = %v", tag, err)
+	}
+	if desc.Digest != want {
+		t.Errorf("Descriptor.Digest = %s, expected %s", desc.Digest, want)
+	}
+	if got := header.Get("Docker-Content-Digest"); got != want.String() {
+		t.Errorf("Docker-Content-Digest = %q, expected %q", got, want)
+	}
+	if got, want := header.Get("RateLimit-Remaining"), "42;w=21600"; got != want {
+		t.Errorf("RateLimit-Remaining = %q, expected %q", got, want)
+	}
+
+	// Headers are returned with errors, too.
+	denied := mustNewTag(t, u.Host+"/foo/bar:denied")
+	if _, header, err := HeadResponse(denied); err == nil {
+		t.Errorf("HeadResponse(%s) = nil, expected error", denied)
+	} else if got, want := header.Get("RateLimit-Remaining"), "0;w=21600"; got != want {
+		t.Errorf("RateLimit-Remaining = %q, expected %q", got, want)
+	}
+}
+
 // TestHead_MissingHeaders tests that HEAD responses missing necessary headers
 // result in errors.
 func TestHead_MissingHeaders(t *testing.T) {
@@ -219,6 +280,60 @@ func TestHead_MissingHeaders(t *testing.T) {
 	}
 }
 
+func TestResolve(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag := mustNewTag(t, fmt.Sprintf("%s/foo/bar:latest", u.Host))
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(tag, img); err != nil {
+		t.Fatal(err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Resolve(tag)
+	if err != nil {
+		t.Fatalf("Resolve(%s) = %v", tag, err)
+	}
+	if want := tag.Context().Digest(d.String()); got != want {
+		t.Errorf("Resolve(%s); got %v, want %v", tag, got, want)
+	}
+}
+
+func TestResolve_MissingDigest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method != http.MethodHead {
+			t.Errorf("Method; got %v, want %v", r.Method, http.MethodHead)
+		}
+		w.Header().Set("Content-Type", string(types.OCIManifestSchema1))
+		w.Header().Set("Content-Length", "10")
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse(%v) = %v", server.URL, err)
+	}
+
+	tag := mustNewTag(t, fmt.Sprintf("%s/foo/bar:latest", u.Host))
+	if _, err := Resolve(tag); err == nil {
+		t.Errorf("Resolve(%q): expected error, got nil", tag)
+	}
+}
+
 // TestRedactFetchBlob tests that a request to fetchBlob that gets redirected
 // to a URL that contains sensitive information has that information redacted
 // if the subsequent request fails.
@@ -257,3 +372,216 @@ func (errTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	}
 	return nil, fmt.Errorf("error reaching %s", req.URL.String())
 }
+
+func TestGetMirrors(t *testing.T) {
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mirror := httptest.NewServer(registry.New())
+	defer mirror.Close()
+	mu, err := url.Parse(mirror.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(mustNewTag(t, mu.Host+"/foo/bar:latest"), img); err != nil {
+		t.Fatal(err)
+	}
+
+	var unavailable, notFound int
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case "/v2/foo/bar/manifests/latest":
+			unavailable++
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			notFound++
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer broken.Close()
+	bu, err := url.Parse(broken.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag := mustNewTag(t, bu.Host+"/foo/bar:latest")
+
+	if _, err := Image(tag); err == nil {
+		t.Fatal("Image() without mirrors; expected error")
+	}
+
+	got, err := Image(tag, WithMirrors([]string{bu.Host, mu.Host}))
+	if err != nil {
+		t.Fatalf("Image() with mirrors = %v", err)
+	}
+	if err := validate.Image(got); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+	if unavailable == 0 {
+		t.Error("primary registry was never tried")
+	}
+	if notFound != 0 {
+		t.Errorf("blobs were fetched from the primary registry %d times", notFound)
+	}
+
+	// A 404 from the primary should not fall back to mirrors.
+	missing := mustNewTag(t, bu.Host+"/foo/missing:latest")
+	if _, err := Image(missing, WithMirrors([]string{mu.Host})); err == nil {
+		t.Error("Image() of missing tag; expected error")
+	}
+
+	// If all mirrors fail, return the last error.
+	if _, err := Image(tag, WithMirrors([]string{bu.Host})); err == nil {
+		t.Error("Image() with broken mirrors; expected error")
+	}
+
+	if _, err := Image(tag, WithMirrors([]string{"not a host"})); err == nil {
+		t.Error("Image() with invalid mirror; expected error")
+	}
+}
+
+func TestGetMirrorsDoNotGetPrimaryAuth(t *testing.T) {
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := registry.New()
+	var leaked []string
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			leaked = append(leaked, r.Method+" "+r.URL.Path)
+		}
+		if r.URL.Path == "/v2/" {
+			// Challenge for basic auth so that any credentials are sent.
+			w.Header().Set("WWW-Authenticate", `Basic realm="mirror"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer mirror.Close()
+	mu, err := url.Parse(mirror.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(mustNewTag(t, mu.Host+"/foo/bar:latest"), img); err != nil {
+		t.Fatal(err)
+	}
+	leaked = nil
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="primary"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer broken.Close()
+	bu, err := url.Parse(broken.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag := mustNewTag(t, bu.Host+"/foo/bar:latest")
+	auth := &authn.Basic{Username: "primary", Password: "secret"}
+	got, err := Image(tag, WithAuth(auth), WithMirrors([]string{mu.Host}))
+	if err != nil {
+		t.Fatalf("Image() with mirrors = %v", err)
+	}
+	if err := validate.Image(got); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+	if len(leaked) != 0 {
+		t.Errorf("primary credentials were sent to the mirror: %v", leaked)
+	}
+}
+
+func TestGetRetryAfter(t *testing.T) {
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := registry.New()
+	limited := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/manifests/") && limited == 0 {
+			limited++
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag := mustNewTag(t, u.Host+"/foo/bar:latest")
+	if err := Write(tag, img); err != nil {
+		t.Fatal(err)
+	}
+
+	// The Retry-After wait is bounded by the backoff's Cap.
+	start := time.Now()
+	if _, err := Get(tag, WithRetryBackoff(Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3, Cap: 10 * time.Millisecond})); err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Get() took %v; Retry-After was not capped", elapsed)
+	}
+	if limited != 1 {
+		t.Errorf("rate limited %d times, want 1", limited)
+	}
+}
+
+type ctxKey struct{}
+
+// contextKeychain records the ctxKey value it was resolved with.
+type contextKeychain struct {
+	got *string
+}
+
+func (ck contextKeychain) Resolve(authn.Resource) (authn.Authenticator, error) {
+	return nil, errors.New("Resolve called instead of ResolveContext")
+}
+
+func (ck contextKeychain) ResolveContext(ctx context.Context, _ authn.Resource) (authn.Authenticator, error) {
+	*ck.got, _ = ctx.Value(ctxKey{}).(string)
+	return authn.Anonymous, nil
+}
+
+func TestGetContextKeychain(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag := mustNewTag(t, fmt.Sprintf("%s/foo/bar:latest", u.Host))
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(tag, img); err != nil {
+		t.Fatal(err)
+	}
+
+	var got string
+	ctx := context.WithValue(context.Background(), ctxKey{}, "hello")
+	if _, err := Get(tag, WithContext(ctx), WithAuthFromKeychain(contextKeychain{&got})); err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	if got != "hello" {
+		t.Errorf("ResolveContext saw %q, wanted %q", got, "hello")
+	}
+}
@@ -121,6 +121,17 @@ func (r *remoteImage) RawConfigFile() ([]byte, error) {
 	return r.config, nil
 }
 
+// ConfigName implements partial.withConfigName so that we don't have to fetch
+// the config blob just to find out its digest. RawConfigFile verifies that the
+// config matches this digest when it is eventually fetched.
+func (r *remoteImage) ConfigName() (v1.Hash, error) {
+	m, err := partial.Manifest(r)
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	return m.Config.Digest, nil
+}
+
 // Descriptor retains the original descriptor from an index manifest.
 // See partial.Descriptor.
 func (r *remoteImage) Descriptor() (*v1.Descriptor, error) {
@@ -17,9 +17,12 @@ package remote
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -36,6 +39,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/google/go-containerregistry/pkg/v1/validate"
 )
@@ -140,7 +144,18 @@ func TestRawManifestDigests(t *testing.T) {
 		ref:           "latest",
 		responseBody:  mustRawManifest(t, img),
 		contentDigest: bogusDigest,
-		wantErr:       false,
+		wantErr:       true,
+	}, {
+		name:          "right content-digest, wrong body, by tag",
+		ref:           "latest",
+		responseBody:  []byte("not even json"),
+		contentDigest: mustDigest(t, img).String(),
+		wantErr:       true,
+	}, {
+		name:         "no content-digest, by tag",
+		ref:          "latest",
+		responseBody: mustRawManifest(t, img),
+		wantErr:      false,
 	}, {
 		// NB: This succeeds! We don't care what the registry thinks.
 		name:          "right body, wrong content-digest, by digest",
@@ -741,3 +756,313 @@ func TestData(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestWithMaxIdleConnsPerHost(t *testing.T) {
+	cache := &transportCache{}
+	tuned, ok := withMaxIdleConnsPerHost(DefaultTransport, 10, cache).(*http.Transport)
+	if !ok {
+		t.Fatalf("withMaxIdleConnsPerHost(DefaultTransport) is not an *http.Transport")
+	}
+	if tuned == DefaultTransport {
+		t.Error("withMaxIdleConnsPerHost modified DefaultTransport")
+	}
+	if got, want := tuned.MaxIdleConnsPerHost, 10; got != want {
+		t.Errorf("MaxIdleConnsPerHost; got %d, want %d", got, want)
+	}
+	if !tuned.ForceAttemptHTTP2 {
+		t.Error("ForceAttemptHTTP2; got false, want true")
+	}
+	if got := DefaultTransport.(*http.Transport).MaxIdleConnsPerHost; got != 0 {
+		t.Errorf("DefaultTransport.MaxIdleConnsPerHost; got %d, want 0", got)
+	}
+
+	// Repeated calls with the same cache should share a connection pool.
+	if again := withMaxIdleConnsPerHost(DefaultTransport, 10, cache); again != tuned {
+		t.Error("withMaxIdleConnsPerHost returned a different transport for the same inputs")
+	}
+
+	// Reusing an Option reuses its transport, but separate Options don't
+	// share anything, so they can't accumulate.
+	opt := WithMaxIdleConnsPerHost(10)
+	first, err := makeOptions(nil, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := makeOptions(nil, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.maxIdleConnsCache != second.maxIdleConnsCache {
+		t.Error("reusing WithMaxIdleConnsPerHost used a different cache")
+	}
+	separate, err := makeOptions(nil, WithMaxIdleConnsPerHost(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if separate.maxIdleConnsCache == first.maxIdleConnsCache {
+		t.Error("separate WithMaxIdleConnsPerHost options share a cache")
+	}
+	if a, b := first.maxIdleConnsCache.cached, second.maxIdleConnsCache.cached; a == nil || a != b {
+		t.Errorf("reusing WithMaxIdleConnsPerHost; got transports %p and %p, want the same", a, b)
+	}
+
+	// Other transports are left alone.
+	other := &transport.Wrapper{}
+	if got := withMaxIdleConnsPerHost(other, 10, cache); got != other {
+		t.Errorf("withMaxIdleConnsPerHost(%T); got %v, want %v", other, got, other)
+	}
+
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := mustNewTag(t, u.Host+"/foo/bar:latest")
+	if err := Write(tag, img, WithMaxIdleConnsPerHost(10)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Image(tag, WithMaxIdleConnsPerHost(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Image(got); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+}
+
+func TestWithInsecureHosts(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Resolve this host to the test server, which only speaks plain HTTP.
+	host := "registry.example.com:5000"
+	tr := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, u.Host)
+		},
+	}
+	tag := mustNewTag(t, host+"/foo/bar:latest")
+	if got := tag.Context().Registry.Scheme(); got != "https" {
+		t.Fatalf("Scheme(); got %s, want https", got)
+	}
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(tag, img, WithTransport(tr), WithRetryBackoff(fastBackoff)); err == nil {
+		t.Error("Write() without WithInsecureHosts; expected error")
+	}
+
+	for _, hosts := range [][]string{{host}, {"registry.example.com"}} {
+		if err := Write(tag, img, WithTransport(tr), WithInsecureHosts(hosts)); err != nil {
+			t.Fatalf("Write() with WithInsecureHosts(%v) = %v", hosts, err)
+		}
+		got, err := Image(tag, WithTransport(tr), WithInsecureHosts(hosts))
+		if err != nil {
+			t.Fatalf("Image() with WithInsecureHosts(%v) = %v", hosts, err)
+		}
+		if err := validate.Image(got); err != nil {
+			t.Errorf("validate.Image() = %v", err)
+		}
+	}
+}
+
+func TestWithRootCAsAndClientCert(t *testing.T) {
+	s := httptest.NewUnstartedServer(registry.New())
+	s.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert, MinVersion: tls.VersionTLS12}
+	s.StartTLS()
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(s.Certificate())
+	// The server accepts any client certificate, so reuse its own.
+	cert := s.TLS.Certificates[0]
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := mustNewTag(t, u.Host+"/foo/bar:latest")
+
+	if err := Write(tag, img, WithRetryBackoff(fastBackoff)); err == nil {
+		t.Error("Write() without WithRootCAs; expected error")
+	}
+	if err := Write(tag, img, WithRootCAs(pool), WithRetryBackoff(fastBackoff)); err == nil {
+		t.Error("Write() without WithClientCert; expected error")
+	}
+
+	// These compose with WithTransport, whichever order they're given in.
+	tr := DefaultTransport.(*http.Transport).Clone()
+	if err := Write(tag, img, WithRootCAs(pool), WithClientCert(cert), WithTransport(tr)); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	got, err := Image(tag, WithTransport(tr), WithRootCAs(pool), WithClientCert(cert))
+	if err != nil {
+		t.Fatalf("Image() = %v", err)
+	}
+	if err := validate.Image(got); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+	if cfg := tr.TLSClientConfig; cfg != nil && (cfg.RootCAs != nil || len(cfg.Certificates) != 0) {
+		t.Error("WithRootCAs modified the transport passed to WithTransport")
+	}
+
+	// Repeated calls with the same cache should share a connection pool,
+	// even for separately loaded copies of the same certificate.
+	cache := &transportCache{}
+	c1, c2 := cert, cert
+	if a, b := withTLS(tr, pool, &c1, cache), withTLS(tr, pool, &c2, cache); a != b {
+		t.Error("withTLS returned a different transport for the same inputs")
+	}
+
+	// A different certificate replaces the cached transport.
+	other := tls.Certificate{Certificate: [][]byte{[]byte("other")}}
+	if a, b := withTLS(tr, pool, &c1, cache), withTLS(tr, pool, &other, cache); a == b {
+		t.Error("withTLS returned the same transport for a different certificate")
+	}
+}
+
+func TestWithSkipTLSVerifyHosts(t *testing.T) {
+	s := httptest.NewTLSServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := mustNewTag(t, u.Host+"/foo/bar:latest")
+
+	if err := Write(tag, img, WithRetryBackoff(fastBackoff)); err == nil {
+		t.Error("Write() without WithSkipTLSVerifyHosts; expected error")
+	}
+	if err := Write(tag, img, WithSkipTLSVerifyHosts([]string{"registry.example.com"}), WithRetryBackoff(fastBackoff)); err == nil {
+		t.Error("Write() with other WithSkipTLSVerifyHosts; expected error")
+	}
+
+	for _, hosts := range [][]string{{u.Host}, {u.Hostname()}} {
+		if err := Write(tag, img, WithSkipTLSVerifyHosts(hosts)); err != nil {
+			t.Fatalf("Write() with WithSkipTLSVerifyHosts(%v) = %v", hosts, err)
+		}
+		got, err := Image(tag, WithSkipTLSVerifyHosts(hosts))
+		if err != nil {
+			t.Fatalf("Image() with WithSkipTLSVerifyHosts(%v) = %v", hosts, err)
+		}
+		if err := validate.Image(got); err != nil {
+			t.Errorf("validate.Image() = %v", err)
+		}
+	}
+	if cfg := DefaultTransport.(*http.Transport).TLSClientConfig; cfg != nil && cfg.InsecureSkipVerify {
+		t.Error("WithSkipTLSVerifyHosts modified DefaultTransport")
+	}
+
+	// Reusing the Option reuses its unverified transport.
+	cache := &transportCache{}
+	a := newSkipVerifyTransport(DefaultTransport, []string{"example.com"}, cache).(*skipVerifyTransport)
+	b := newSkipVerifyTransport(DefaultTransport, []string{"example.com"}, cache).(*skipVerifyTransport)
+	if a.unverified != b.unverified {
+		t.Error("newSkipVerifyTransport returned a different unverified transport for the same cache")
+	}
+}
+
+func TestLazyConfig(t *testing.T) {
+	idx, err := random.Index(1024, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := idx.Image(m.Manifests[0].Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfgName, err := child.ConfigName()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := registry.New()
+	configGets := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/blobs/"+cfgName.String()) {
+			configGets++
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := mustNewTag(t, fmt.Sprintf("%s/%s:latest", u.Host, "test/lazy"))
+	if err := WriteIndex(ref, idx); err != nil {
+		t.Fatal(err)
+	}
+	configGets = 0
+
+	for _, tc := range []struct {
+		name string
+		img  func() (v1.Image, error)
+	}{{
+		name: "image",
+		img:  func() (v1.Image, error) { return Image(ref.Context().Digest(m.Manifests[0].Digest.String())) },
+	}, {
+		name: "index by platform",
+		img:  func() (v1.Image, error) { return Image(ref) },
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			configGets = 0
+			img, err := tc.img()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := img.Digest(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := img.Manifest(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := img.Layers(); err != nil {
+				t.Fatal(err)
+			}
+			got, err := img.ConfigName()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != cfgName {
+				t.Errorf("ConfigName(); got %v, want %v", got, cfgName)
+			}
+			if configGets != 0 {
+				t.Errorf("fetched config %d times before asking for it", configGets)
+			}
+
+			for i := 0; i < 2; i++ {
+				if _, err := img.ConfigFile(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if configGets != 1 {
+				t.Errorf("fetched config %d times, want 1", configGets)
+			}
+		})
+	}
+}
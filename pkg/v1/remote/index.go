@@ -17,6 +17,7 @@ package remote
 import (
 	"bytes"
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/google/go-containerregistry/internal/verify"
@@ -205,6 +206,7 @@ func (r *remoteIndex) childByPlatform(platform v1.Platform) (*Descriptor, error)
 	if err != nil {
 		return nil, err
 	}
+	available := make([]string, 0, len(index.Manifests))
 	for _, childDesc := range index.Manifests {
 		// If platform is missing from child descriptor, assume it's amd64/linux.
 		p := defaultPlatform
@@ -212,11 +214,12 @@ func (r *remoteIndex) childByPlatform(platform v1.Platform) (*Descriptor, error)
 			p = *childDesc.Platform
 		}
 
-		if matchesPlatform(p, platform) {
+		if p.Satisfies(platform) {
 			return r.childDescriptor(childDesc, platform)
 		}
+		available = append(available, p.String())
 	}
-	return nil, fmt.Errorf("no child with platform %+v in index %s", platform, r.Ref)
+	return nil, fmt.Errorf("no child with platform %s in index %s (available platforms: %s)", platform, r.Ref, strings.Join(available, ", "))
 }
 
 func (r *remoteIndex) childByHash(h v1.Hash) (*Descriptor, error) {
@@ -261,49 +264,3 @@ func (r *remoteIndex) childDescriptor(child v1.Descriptor, platform v1.Platform)
 		platform:   platform,
 	}, nil
 }
-
-// matchesPlatform checks if the given platform matches the required platforms.
-// The given platform matches the required platform if
-// - architecture and OS are identical.
-// - OS version and variant are identical if provided.
-// - features and OS features of the required platform are subsets of those of the given platform.
-func matchesPlatform(given, required v1.Platform) bool {
-	// Required fields that must be identical.
-	if given.Architecture != required.Architecture || given.OS != required.OS {
-		return false
-	}
-
-	// Optional fields that may be empty, but must be identical if provided.
-	if required.OSVersion != "" && given.OSVersion != required.OSVersion {
-		return false
-	}
-	if required.Variant != "" && given.Variant != required.Variant {
-		return false
-	}
-
-	// Verify required platform's features are a subset of given platform's features.
-	if !isSubset(given.OSFeatures, required.OSFeatures) {
-		return false
-	}
-	if !isSubset(given.Features, required.Features) {
-		return false
-	}
-
-	return true
-}
-
-// isSubset checks if the required array of strings is a subset of the given lst.
-func isSubset(lst, required []string) bool {
-	set := make(map[string]bool)
-	for _, value := range lst {
-		set[value] = true
-	}
-
-	for _, value := range required {
-		if _, ok := set[value]; !ok {
-			return false
-		}
-	}
-
-	return true
-}
@@ -16,6 +16,7 @@ package remote
 
 import (
 	"io"
+	"sync"
 
 	"github.com/google/go-containerregistry/internal/redact"
 	"github.com/google/go-containerregistry/internal/verify"
@@ -29,6 +30,9 @@ import (
 type remoteLayer struct {
 	fetcher
 	digest v1.Hash
+
+	sizeLock sync.Mutex // Protects size
+	size     *int64
 }
 
 // Compressed implements partial.CompressedLayer
@@ -38,14 +42,22 @@ func (rl *remoteLayer) Compressed() (io.ReadCloser, error) {
 	return rl.fetchBlob(ctx, verify.SizeUnknown, rl.digest)
 }
 
-// Compressed implements partial.CompressedLayer
+// Size implements partial.CompressedLayer. The size is fetched with a HEAD
+// request the first time it's needed, and cached after that.
 func (rl *remoteLayer) Size() (int64, error) {
+	rl.sizeLock.Lock()
+	defer rl.sizeLock.Unlock()
+	if rl.size != nil {
+		return *rl.size, nil
+	}
 	resp, err := rl.headBlob(rl.digest)
 	if err != nil {
 		return -1, err
 	}
 	defer resp.Body.Close()
-	return resp.ContentLength, nil
+	size := resp.ContentLength
+	rl.size = &size
+	return size, nil
 }
 
 // Digest implements partial.CompressedLayer
@@ -16,13 +16,17 @@ package remote
 
 import (
 	"fmt"
+	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/google/go-containerregistry/internal/compare"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
@@ -86,6 +90,82 @@ func TestRemoteLayer(t *testing.T) {
 	}
 }
 
+func TestRemoteLayerSizeCached(t *testing.T) {
+	var heads int64
+	reg := registry.New()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead && strings.Contains(r.URL.Path, "/blobs/") {
+			atomic.AddInt64(&heads, 1)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var layers []v1.Layer
+	for i := 0; i < 2; i++ {
+		layer, err := random.Layer(1024, types.DockerLayer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		digest, err := layer.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref, err := name.NewDigest(fmt.Sprintf("%s/some/path@%s", u.Host, digest))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteLayer(ref.Context(), layer); err != nil {
+			t.Fatalf("failed to WriteLayer: %v", err)
+		}
+		got, err := Layer(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		layers = append(layers, got)
+	}
+
+	atomic.StoreInt64(&heads, 0)
+	img, err := mutate.AppendLayers(empty.Image, layers...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := img.RawManifest(); err != nil {
+			t.Fatal(err)
+		}
+		for _, l := range layers {
+			if _, err := l.Size(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if got, want := atomic.LoadInt64(&heads), int64(len(layers)); got > want {
+		t.Errorf("HEAD requests: got %d, want at most %d", got, want)
+	}
+
+	// A reconstructed layer doesn't share the cache.
+	atomic.StoreInt64(&heads, 0)
+	ml, ok := layers[0].(*MountableLayer)
+	if !ok {
+		t.Fatalf("Layer() = %T, want *MountableLayer", layers[0])
+	}
+	again, err := Layer(ml.Reference.(name.Digest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := again.Size(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := atomic.LoadInt64(&heads), int64(1); got != want {
+		t.Errorf("HEAD requests after reconstructing: got %d, want %d", got, want)
+	}
+}
+
 func TestRemoteLayerDescriptor(t *testing.T) {
 	layer, err := random.Layer(1024, types.DockerLayer)
 	if err != nil {
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/google/go-containerregistry/pkg/name"
@@ -45,69 +46,120 @@ func List(repo name.Repository, options ...Option) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
-	scopes := []string{repo.Scope(transport.PullScope)}
-	tr, err := transport.NewWithContext(o.context, repo.Registry, o.auth, o.transport, scopes)
+	client, err := tagsClient(repo, o)
 	if err != nil {
 		return nil, err
 	}
 
-	uri := &url.URL{
-		Scheme: repo.Registry.Scheme(),
-		Host:   repo.Registry.RegistryStr(),
-		Path:   fmt.Sprintf("/v2/%s/tags/list", repo.RepositoryStr()),
-	}
-
-	if o.pageSize > 0 {
-		uri.RawQuery = fmt.Sprintf("n=%d", o.pageSize)
-	}
-
-	client := http.Client{Transport: tr}
+	uri := tagsURL(repo, "", o.pageSize)
 	tagList := []string{}
-	parsed := tags{}
 
 	// get responses until there is no next page
-	for {
+	for uri != nil {
 		select {
 		case <-o.context.Done():
 			return nil, o.context.Err()
 		default:
 		}
 
-		req, err := http.NewRequestWithContext(o.context, "GET", uri.String(), nil)
+		var page []string
+		page, uri, err = listPage(o.context, client, uri)
 		if err != nil {
 			return nil, err
 		}
+		tagList = append(tagList, page...)
+	}
 
-		resp, err := client.Do(req)
-		if err != nil {
-			return nil, err
-		}
+	return tagList, nil
+}
 
-		if err := transport.CheckError(resp, http.StatusOK); err != nil {
-			return nil, err
-		}
+// ListPage calls /tags/list for the given repository, returning a single page
+// of at most n tags that sort after last. If n is not positive, the
+// registry's default page size is used. If last is empty, the first page is
+// returned.
+//
+// The returned cursor can be passed as last to fetch the next page. It is
+// empty once there are no more pages.
+func ListPage(repo name.Repository, last string, n int, options ...Option) ([]string, string, error) {
+	o, err := makeOptions(repo, options...)
+	if err != nil {
+		return nil, "", err
+	}
+	client, err := tagsClient(repo, o)
+	if err != nil {
+		return nil, "", err
+	}
 
-		if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
-			return nil, err
-		}
+	page, next, err := listPage(o.context, client, tagsURL(repo, last, n))
+	if err != nil {
+		return nil, "", err
+	}
+	if next == nil {
+		return page, "", nil
+	}
+	cursor := next.Query().Get("last")
+	if cursor == "" && len(page) > 0 {
+		// The registry's next link doesn't use the last parameter, but
+		// tags are returned in lexical order, so the last one we saw works.
+		cursor = page[len(page)-1]
+	}
+	return page, cursor, nil
+}
 
-		if err := resp.Body.Close(); err != nil {
-			return nil, err
-		}
+func tagsClient(repo name.Repository, o *options) (*http.Client, error) {
+	scopes := []string{repo.Scope(transport.PullScope)}
+	tr, err := transport.NewWithContext(o.context, repo.Registry, o.auth, o.transport, scopes, o.transportOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: tr}, nil
+}
 
-		tagList = append(tagList, parsed.Tags...)
+func tagsURL(repo name.Repository, last string, n int) *url.URL {
+	uri := &url.URL{
+		Scheme: repo.Registry.Scheme(),
+		Host:   repo.Registry.RegistryStr(),
+		Path:   fmt.Sprintf("/v2/%s/tags/list", repo.RepositoryStr()),
+	}
+	q := url.Values{}
+	if n > 0 {
+		q.Set("n", strconv.Itoa(n))
+	}
+	if last != "" {
+		q.Set("last", last)
+	}
+	uri.RawQuery = q.Encode()
+	return uri
+}
 
-		uri, err = getNextPageURL(resp)
-		if err != nil {
-			return nil, err
-		}
-		// no next page
-		if uri == nil {
-			break
-		}
+// listPage fetches the tags at uri, returning them along with the URL of the
+// next page, which is nil if this was the last page.
+func listPage(ctx context.Context, client *http.Client, uri *url.URL) ([]string, *url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", uri.String(), nil)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return tagList, nil
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := transport.CheckError(resp, http.StatusOK); err != nil {
+		return nil, nil, err
+	}
+
+	parsed := tags{}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, nil, err
+	}
+
+	next, err := getNextPageURL(resp)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parsed.Tags, next, nil
 }
 
 // getNextPageURL checks if there is a Link header in a http.Response which
@@ -25,6 +25,8 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
 )
 
 func TestList(t *testing.T) {
@@ -116,6 +118,75 @@ func TestCancelledList(t *testing.T) {
 	}
 }
 
+func TestListPage(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(u.Host + "/test/list")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a", "b", "c", "d", "e"}
+	for _, tag := range want {
+		if err := Write(repo.Tag(tag), img); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		got   []string
+		pages int
+		last  string
+	)
+	for {
+		page, next, err := ListPage(repo, last, 2)
+		if err != nil {
+			t.Fatalf("ListPage(%q): %v", last, err)
+		}
+		got = append(got, page...)
+		pages++
+		if next == "" {
+			break
+		}
+		last = next
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ListPage() wrong tags (-want +got) = %s", diff)
+	}
+	if want := 3; pages != want {
+		t.Errorf("pages; got %d, want %d", pages, want)
+	}
+
+	// Resume from a cursor.
+	page, next, err := ListPage(repo, "c", 0)
+	if err != nil {
+		t.Fatalf("ListPage: %v", err)
+	}
+	if diff := cmp.Diff([]string{"d", "e"}, page); diff != "" {
+		t.Errorf("ListPage() wrong tags (-want +got) = %s", diff)
+	}
+	if next != "" {
+		t.Errorf("next; got %q, want empty", next)
+	}
+
+	// List follows the same pages.
+	all, err := List(repo, WithPageSize(2))
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if diff := cmp.Diff(want, all); diff != "" {
+		t.Errorf("List() wrong tags (-want +got) = %s", diff)
+	}
+}
+
 func makeResp(hdr string) *http.Response {
 	return &http.Response{
 		Header: http.Header{
@@ -15,6 +15,8 @@
 package remote
 
 import (
+	"io"
+
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
@@ -39,6 +41,11 @@ func (ml *MountableLayer) Exists() (bool, error) {
 	return partial.Exists(ml.Layer)
 }
 
+// ReaderAt allows random access to the underlying layer. See partial.ReaderAt.
+func (ml *MountableLayer) ReaderAt() (io.ReaderAt, error) {
+	return partial.ReaderAt(ml.Layer)
+}
+
 // mountableImage wraps the v1.Layer references returned by the embedded v1.Image
 // in MountableLayer's so that remote.Write might attempt to mount them from their
 // source repository.
@@ -82,7 +82,7 @@ func MultiWrite(m map[name.Reference]Taggable, options ...Option) (rerr error) {
 		ls = append(ls, l)
 	}
 	scopes := scopesForUploadingImage(repo, ls)
-	tr, err := transport.NewWithContext(o.context, repo.Registry, o.auth, o.transport, scopes)
+	tr, err := transport.NewWithContext(o.context, repo.Registry, o.auth, o.transport, scopes, o.transportOptions()...)
 	if err != nil {
 		return err
 	}
@@ -91,6 +91,8 @@ func MultiWrite(m map[name.Reference]Taggable, options ...Option) (rerr error) {
 		client:    &http.Client{Transport: tr},
 		backoff:   o.retryBackoff,
 		predicate: o.retryPredicate,
+		chunkSize: o.chunkSize,
+		dryRun:    newDryRun(o.dryRun),
 	}
 
 	// Collect the total size of blobs and manifests we're about to write.
@@ -16,10 +16,15 @@ package remote
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"sync"
 	"syscall"
 	"time"
 
@@ -28,6 +33,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/logs"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
 // Option is a functional option for remote operations.
@@ -46,6 +52,20 @@ type options struct {
 	pageSize                       int
 	retryBackoff                   Backoff
 	retryPredicate                 retry.Predicate
+	mirrors                        []string
+	maxIdleConnsPerHost            int
+	maxIdleConnsCache              *transportCache
+	insecureHosts                  []string
+	chunkSize                      int64
+	rootCAs                        *x509.CertPool
+	clientCert                     *tls.Certificate
+	tlsCache                       *transportCache
+	skipVerifyHosts                []string
+	skipVerifyCache                *transportCache
+	manifestMediaType              types.MediaType
+	dryRun                         func(v1.Descriptor)
+	allowInsecureAuth              bool
+	resolvePrefix                  bool
 }
 
 var defaultPlatform = v1.Platform{
@@ -84,6 +104,7 @@ var fastBackoff = Backoff{
 
 var retryableStatusCodes = []int{
 	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
 	http.StatusInternalServerError,
 	http.StatusBadGateway,
 	http.StatusServiceUnavailable,
@@ -136,7 +157,7 @@ func makeOptions(target authn.Resource, opts ...Option) (*options, error) {
 		// than potentially fail silently when the correct auth is overridden by option misuse.
 		return nil, errors.New("provide an option for either authn.Authenticator or authn.Keychain, not both")
 	case o.keychain != nil:
-		auth, err := o.keychain.Resolve(target)
+		auth, err := authn.Resolve(o.context, o.keychain, target)
 		if err != nil {
 			return nil, err
 		}
@@ -145,9 +166,26 @@ func makeOptions(target authn.Resource, opts ...Option) (*options, error) {
 		o.auth = authn.Anonymous
 	}
 
+	if o.rootCAs != nil || o.clientCert != nil {
+		o.transport = withTLS(o.transport, o.rootCAs, o.clientCert, o.tlsCache)
+	}
+
+	if o.maxIdleConnsPerHost > 0 {
+		o.transport = withMaxIdleConnsPerHost(o.transport, o.maxIdleConnsPerHost, o.maxIdleConnsCache)
+	}
+
+	if len(o.skipVerifyHosts) != 0 {
+		o.transport = newSkipVerifyTransport(o.transport, o.skipVerifyHosts, o.skipVerifyCache)
+	}
+
 	// transport.Wrapper is a signal that consumers are opt-ing into providing their own transport without any additional wrapping.
 	// This is to allow consumers full control over the transports logic, such as providing retry logic.
 	if _, ok := o.transport.(*transport.Wrapper); !ok {
+		// Talk plain HTTP to any hosts that were configured as insecure.
+		if len(o.insecureHosts) != 0 {
+			o.transport = newInsecureTransport(o.transport, o.insecureHosts, o.allowInsecureAuth)
+		}
+
 		// Wrap the transport in something that logs requests and responses.
 		// It's expensive to generate the dumps, so skip it if we're writing
 		// to nothing.
@@ -156,7 +194,11 @@ func makeOptions(target authn.Resource, opts ...Option) (*options, error) {
 		}
 
 		// Wrap the transport in something that can retry network flakes.
-		o.transport = transport.NewRetry(o.transport, transport.WithRetryPredicate(defaultRetryPredicate), transport.WithRetryStatusCodes(retryableStatusCodes...))
+		ropts := []transport.Option{transport.WithRetryPredicate(defaultRetryPredicate), transport.WithRetryStatusCodes(retryableStatusCodes...)}
+		if o.retryBackoff.Cap > 0 {
+			ropts = append(ropts, transport.WithRetryAfterCap(o.retryBackoff.Cap))
+		}
+		o.transport = transport.NewRetry(o.transport, ropts...)
 
 		// Wrap this last to prevent transport.New from double-wrapping.
 		if o.userAgent != "" {
@@ -180,6 +222,279 @@ func WithTransport(t http.RoundTripper) Option {
 	}
 }
 
+// WithMaxIdleConnsPerHost is a functional option for setting the
+// MaxIdleConnsPerHost of the underlying *http.Transport, so that connections
+// to a registry can be reused when fetching many blobs or manifests
+// concurrently. HTTP/2 is attempted whenever the registry supports it.
+//
+// This has no effect if the transport (see WithTransport) isn't an
+// *http.Transport. The default is the http package's default of 2.
+//
+// The tuned copy of the transport is kept by the returned Option, so reuse
+// the Option across calls for them to share a connection pool.
+func WithMaxIdleConnsPerHost(n int) Option {
+	cache := &transportCache{}
+	return func(o *options) error {
+		o.maxIdleConnsPerHost = n
+		o.maxIdleConnsCache = cache
+		return nil
+	}
+}
+
+// transportCache holds the copy of an *http.Transport that an Option made, so
+// that calls reusing the Option share a connection pool. It holds a single
+// copy, which is replaced if the Option is used with different inputs, so
+// Options created per call don't accumulate transports.
+type transportCache struct {
+	mu     sync.Mutex
+	key    interface{}
+	cached *http.Transport
+}
+
+// get returns the cached transport for key, calling clone to replace it if
+// there is none yet or it was made for a different key.
+func (c *transportCache) get(key interface{}, clone func() *http.Transport) *http.Transport {
+	if c == nil {
+		return clone()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cached == nil || c.key != key {
+		c.key, c.cached = key, clone()
+	}
+	return c.cached
+}
+
+type tunedTransportKey struct {
+	t *http.Transport
+	n int
+}
+
+// withMaxIdleConnsPerHost returns a copy of t with MaxIdleConnsPerHost set to
+// n, if t is an *http.Transport. The copy is reused from cache if possible.
+func withMaxIdleConnsPerHost(t http.RoundTripper, n int, cache *transportCache) http.RoundTripper {
+	ht, ok := t.(*http.Transport)
+	if !ok {
+		logs.Warn.Printf("WithMaxIdleConnsPerHost: ignoring unexpected transport %T", t)
+		return t
+	}
+	return cache.get(tunedTransportKey{ht, n}, func() *http.Transport {
+		tuned := ht.Clone()
+		tuned.MaxIdleConnsPerHost = n
+		tuned.ForceAttemptHTTP2 = true
+		if tuned.MaxIdleConns != 0 && tuned.MaxIdleConns < n {
+			tuned.MaxIdleConns = n
+		}
+		return tuned
+	})
+}
+
+// WithRootCAs is a functional option for verifying registries' TLS
+// certificates against pool, e.g. to trust a private CA, instead of the
+// transport's root CAs (by default, the host's).
+//
+// It is applied to a copy of the transport set by WithTransport, so it
+// composes with it regardless of the order the options are given in. It has
+// no effect if that transport isn't an *http.Transport.
+func WithRootCAs(pool *x509.CertPool) Option {
+	cache := &transportCache{}
+	return func(o *options) error {
+		o.rootCAs = pool
+		o.tlsCache = cache
+		return nil
+	}
+}
+
+// WithClientCert is a functional option for presenting cert to registries
+// that require mutual TLS. If given more than once, the last one wins.
+//
+// Like WithRootCAs, it is applied to a copy of the transport set by
+// WithTransport, and has no effect if that isn't an *http.Transport. Like
+// WithMaxIdleConnsPerHost, reuse the Option to share that copy across calls.
+func WithClientCert(cert tls.Certificate) Option {
+	cache := &transportCache{}
+	return func(o *options) error {
+		o.clientCert = &cert
+		o.tlsCache = cache
+		return nil
+	}
+}
+
+type tlsTransportKey struct {
+	t    *http.Transport
+	pool *x509.CertPool
+	cert [sha256.Size]byte
+}
+
+// withTLS returns a copy of t that verifies servers against pool and presents
+// cert, if they're non-nil and t is an *http.Transport. The copy is reused
+// from cache if possible.
+func withTLS(t http.RoundTripper, pool *x509.CertPool, cert *tls.Certificate, cache *transportCache) http.RoundTripper {
+	ht, ok := t.(*http.Transport)
+	if !ok {
+		logs.Warn.Printf("WithRootCAs/WithClientCert: ignoring unexpected transport %T", t)
+		return t
+	}
+	key := tlsTransportKey{t: ht, pool: pool}
+	if cert != nil && len(cert.Certificate) != 0 {
+		// Key on the certificate itself, since callers may pass equal
+		// certificates that were loaded separately.
+		key.cert = sha256.Sum256(cert.Certificate[0])
+	}
+	return cache.get(key, func() *http.Transport {
+		configured := ht.Clone()
+		if configured.TLSClientConfig == nil {
+			configured.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		if pool != nil {
+			configured.TLSClientConfig.RootCAs = pool
+		}
+		if cert != nil {
+			configured.TLSClientConfig.Certificates = []tls.Certificate{*cert}
+		}
+		return configured
+	})
+}
+
+// WithSkipTLSVerifyHosts is a functional option for skipping TLS certificate
+// verification when talking to the given registry hosts, e.g. an internal
+// registry with a self-signed certificate. Every other host, including any a
+// listed host redirects to, is verified as usual. Hosts match like they do for
+// WithInsecureHosts.
+//
+// Unlike WithInsecureHosts, this still talks TLS. Like WithRootCAs, it is
+// applied to a copy of the transport set by WithTransport, and has no effect
+// if that isn't an *http.Transport. Like WithMaxIdleConnsPerHost, reuse the
+// Option to share that copy across calls.
+func WithSkipTLSVerifyHosts(hosts []string) Option {
+	cache := &transportCache{}
+	return func(o *options) error {
+		o.skipVerifyHosts = hosts
+		o.skipVerifyCache = cache
+		return nil
+	}
+}
+
+// skipVerifyTransport sends requests to the listed hosts through a copy of
+// verified that skips certificate verification, and all others through
+// verified itself.
+type skipVerifyTransport struct {
+	verified   http.RoundTripper
+	unverified http.RoundTripper
+	hosts      map[string]bool
+}
+
+// newSkipVerifyTransport wraps t, if it's an *http.Transport, to skip
+// verification for hosts. The unverified copy of t is reused from cache if
+// possible.
+func newSkipVerifyTransport(t http.RoundTripper, hosts []string, cache *transportCache) http.RoundTripper {
+	ht, ok := t.(*http.Transport)
+	if !ok {
+		logs.Warn.Printf("WithSkipTLSVerifyHosts: ignoring unexpected transport %T", t)
+		return t
+	}
+	unverified := cache.get(ht, func() *http.Transport {
+		c := ht.Clone()
+		if c.TLSClientConfig == nil {
+			c.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		c.TLSClientConfig.InsecureSkipVerify = true //nolint: gosec
+		return c
+	})
+	st := &skipVerifyTransport{
+		verified:   ht,
+		unverified: unverified,
+		hosts:      make(map[string]bool, len(hosts)),
+	}
+	for _, h := range hosts {
+		st.hosts[h] = true
+	}
+	return st
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *skipVerifyTransport) RoundTrip(in *http.Request) (*http.Response, error) {
+	if t.hosts[in.URL.Host] || t.hosts[in.URL.Hostname()] {
+		return t.unverified.RoundTrip(in)
+	}
+	return t.verified.RoundTrip(in)
+}
+
+// WithAllowInsecureAuth is a functional option for sending credentials over
+// plain HTTP to registries that don't support TLS, i.e. those parsed with
+// name.Insecure or treated as insecure by WithInsecureHosts. Without it,
+// credentials are only ever sent over HTTP to loopback addresses, and talking
+// to any other insecure registry that asks for them fails.
+//
+// Only use this for registries reached over a fully trusted network.
+func WithAllowInsecureAuth() Option {
+	return func(o *options) error {
+		o.allowInsecureAuth = true
+		return nil
+	}
+}
+
+// transportOptions returns the options to pass to transport.NewWithContext.
+func (o *options) transportOptions() []transport.Option {
+	if o.allowInsecureAuth {
+		return []transport.Option{transport.WithAllowInsecureAuth()}
+	}
+	return nil
+}
+
+// WithInsecureHosts is a functional option for treating the given registry
+// hosts as insecure, i.e. talking to them over plain HTTP, without having to
+// parse references with name.Insecure. Hosts match a request's host either
+// exactly (e.g. "registry.example.com:5000") or by hostname alone (e.g.
+// "registry.example.com" matches any port).
+//
+// This has no effect if WithTransport is given a *transport.Wrapper.
+func WithInsecureHosts(hosts []string) Option {
+	return func(o *options) error {
+		o.insecureHosts = hosts
+		return nil
+	}
+}
+
+// insecureTransport rewrites https requests to insecure hosts to use http.
+type insecureTransport struct {
+	inner     http.RoundTripper
+	hosts     map[string]bool
+	allowAuth bool
+}
+
+func newInsecureTransport(inner http.RoundTripper, hosts []string, allowAuth bool) http.RoundTripper {
+	t := &insecureTransport{
+		inner:     inner,
+		hosts:     make(map[string]bool, len(hosts)),
+		allowAuth: allowAuth,
+	}
+	for _, h := range hosts {
+		t.hosts[h] = true
+	}
+	return t
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *insecureTransport) RoundTrip(in *http.Request) (*http.Response, error) {
+	if in.URL.Scheme == "https" && (t.hosts[in.URL.Host] || t.hosts[in.URL.Hostname()]) {
+		out := in.Clone(in.Context())
+		out.URL.Scheme = "http"
+		if out.Header.Get("Authorization") == "" || t.allowAuth || transport.IsLoopback(out.URL.Host) {
+			return t.inner.RoundTrip(out)
+		}
+		// Don't send credentials in the clear, and fail if they were needed.
+		out.Header.Del("Authorization")
+		resp, err := t.inner.RoundTrip(out)
+		if err == nil && resp.StatusCode == http.StatusUnauthorized {
+			resp.Body.Close()
+			return nil, fmt.Errorf("refusing to send credentials to %s over plain HTTP; see WithAllowInsecureAuth", out.URL.Host)
+		}
+		return resp, err
+	}
+	return t.inner.RoundTrip(in)
+}
+
 // WithAuth is a functional option for overriding the default authenticator
 // for remote operations.
 // It is an error to use both WithAuth and WithAuthFromKeychain in the same Option set.
@@ -194,7 +509,8 @@ func WithAuth(auth authn.Authenticator) Option {
 
 // WithAuthFromKeychain is a functional option for overriding the default
 // authenticator for remote operations, using an authn.Keychain to find
-// credentials.
+// credentials. If keys implements authn.ContextKeychain, it is resolved with
+// the context from WithContext.
 // It is an error to use both WithAuth and WithAuthFromKeychain in the same Option set.
 //
 // The default authenticator is authn.Anonymous.
@@ -222,6 +538,10 @@ func WithPlatform(p v1.Platform) Option {
 // context will be set on http requests generated by subsequent calls to
 // RawConfigFile() and even methods on layers returned by Layers().
 //
+// Cancelling the context aborts any in-flight requests and uploads and stops
+// further retries, so functions like Write and List return promptly with the
+// context's error.
+//
 // The default context is context.Background().
 func WithContext(ctx context.Context) Option {
 	return func(o *options) error {
@@ -232,13 +552,14 @@ func WithContext(ctx context.Context) Option {
 
 // WithJobs is a functional option for setting the parallelism of remote
 // operations performed by a given function. Note that not all remote
-// operations support parallelism.
+// operations support parallelism. For Write, WriteIndex and MultiWrite, this
+// bounds the number of concurrent blob uploads.
 //
-// The default value is 4.
+// If jobs is not positive, the default value of 4 is used.
 func WithJobs(jobs int) Option {
 	return func(o *options) error {
 		if jobs <= 0 {
-			return errors.New("jobs must be greater than zero")
+			jobs = defaultJobs
 		}
 		o.jobs = jobs
 		return nil
@@ -246,7 +567,8 @@ func WithJobs(jobs int) Option {
 }
 
 // WithUserAgent adds the given string to the User-Agent header for any HTTP
-// requests. This header will also include "go-containerregistry/${version}".
+// requests, including those made to authenticate. This header will also
+// include "go-containerregistry/${version}".
 //
 // If you want to completely overwrite the User-Agent header, use WithTransport.
 func WithUserAgent(ua string) Option {
@@ -288,7 +610,78 @@ func WithPageSize(size int) Option {
 	}
 }
 
+// WithManifestMediaType converts images passed to Write to the manifest
+// media type mt, which must be types.DockerManifestSchema2 or
+// types.OCIManifestSchema1, before pushing them, e.g. for legacy registries
+// that reject OCI manifests. The config and layer media types in the
+// manifest are mapped as mutate.MediaType maps them; blobs are unchanged.
+//
+// Converting changes the image's digest. It is an error to convert an image
+// to Docker schema 2 if it uses OCI-only features that would be lost:
+// annotations, a subject, an artifactType or a non-image config. Images that
+// already have media type mt are written unchanged.
+//
+// Only Write converts images: WriteIndex, MultiWrite and Put write the
+// manifests they're given, including those of an index's children, as is.
+func WithManifestMediaType(mt types.MediaType) Option {
+	return func(o *options) error {
+		if mt != types.DockerManifestSchema2 && mt != types.OCIManifestSchema1 {
+			return fmt.Errorf("unsupported manifest media type %q: must be %q or %q", mt, types.DockerManifestSchema2, types.OCIManifestSchema1)
+		}
+		o.manifestMediaType = mt
+		return nil
+	}
+}
+
+// WithDryRun makes Write, WriteIndex, WriteLayer and MultiWrite check which
+// blobs already exist in the destination without writing anything: no blobs
+// are uploaded or mounted, and no manifests are put (so Put and Tag do
+// nothing). Instead, missing is called with the descriptor of each blob that
+// would have been uploaded, e.g. to estimate the size of a push. Calls to
+// missing are serialized.
+//
+// Children of an index that already exist are skipped as usual, so their
+// blobs aren't checked. Layers whose digest isn't known up front, like
+// stream.Layer, can't be checked and cause an error.
+func WithDryRun(missing func(v1.Descriptor)) Option {
+	return func(o *options) error {
+		o.dryRun = missing
+		return nil
+	}
+}
+
+// WithResolvePrefix is a functional option for letting ResolveDigestPrefix
+// resolve truncated digests, which lists the repository's tags and fetches a
+// manifest for each. Without it, ResolveDigestPrefix only accepts full
+// digests, so a truncated one can't slow down code that isn't interactive.
+func WithResolvePrefix() Option {
+	return func(o *options) error {
+		o.resolvePrefix = true
+		return nil
+	}
+}
+
+// WithChunkSize uploads blobs larger than size in chunks of that many bytes,
+// using the registry's chunked upload protocol. If a chunk fails, the upload
+// resumes from the last offset the registry acknowledged rather than starting
+// over. Each chunk is buffered in memory.
+//
+// The default value is 0, which uploads each blob in a single request.
+func WithChunkSize(size int64) Option {
+	return func(o *options) error {
+		if size < 0 {
+			return fmt.Errorf("chunk size must be non-negative, got %d", size)
+		}
+		o.chunkSize = size
+		return nil
+	}
+}
+
 // WithRetryBackoff sets the httpBackoff for retry HTTP operations.
+//
+// When a registry responds with a Retry-After header (e.g. while rate
+// limiting), requests wait as long as it asks before retrying. If backoff.Cap
+// is set, it also bounds how long any single Retry-After wait can be.
 func WithRetryBackoff(backoff Backoff) Option {
 	return func(o *options) error {
 		o.retryBackoff = backoff
@@ -303,3 +696,20 @@ func WithRetryPredicate(predicate retry.Predicate) Option {
 		return nil
 	}
 }
+
+// WithMirrors sets a list of registry hosts to fall back to, in order, when
+// fetching a manifest from the primary registry fails with a retryable error
+// or a connection failure. Only the registry host is rewritten; the repository
+// and reference are preserved.
+//
+// Credentials passed with WithAuth are only sent to the primary registry;
+// mirrors are accessed anonymously, unless WithAuthFromKeychain is used, in
+// which case credentials are resolved for each mirror separately.
+//
+// If every mirror fails, the last error is returned.
+func WithMirrors(mirrors []string) Option {
+	return func(o *options) error {
+		o.mirrors = mirrors
+		return nil
+	}
+}
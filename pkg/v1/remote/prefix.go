@@ -0,0 +1,103 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// ResolveDigestPrefix returns the digest in repo that starts with prefix, a
+// possibly truncated digest like "sha256:1a2b3c" (or just "1a2b3c"), for
+// humans who paste shortened digests. name.Digest requires a full digest,
+// so resolve the prefix first and then pass the result to e.g. Image.
+//
+// Registries can't be asked for a manifest by prefix, so this lists repo's
+// tags and issues a HEAD request for each, which is slow for repositories
+// with many tags; it's meant for interactive use, and truncated digests are
+// only resolved if WithResolvePrefix is given. Only manifests that are tagged
+// can be found, and tags that disappear while resolving are skipped. It's an
+// error if no manifest matches, or if more than one does, in which case the
+// error lists the candidates.
+func ResolveDigestPrefix(repo name.Repository, prefix string, options ...Option) (name.Digest, error) {
+	alg, hex, ok := strings.Cut(prefix, ":")
+	if !ok {
+		alg, hex = "sha256", prefix
+	}
+	hex = strings.ToLower(hex)
+	if hex == "" || strings.Trim(hex, "0123456789abcdef") != "" {
+		return name.Digest{}, fmt.Errorf("invalid digest prefix %q", prefix)
+	}
+	if h, err := v1.NewHash(alg + ":" + hex); err == nil {
+		// It's not truncated.
+		return repo.Digest(h.String()), nil
+	}
+
+	o, err := makeOptions(repo, options...)
+	if err != nil {
+		return name.Digest{}, err
+	}
+	if !o.resolvePrefix {
+		return name.Digest{}, fmt.Errorf("digest %q is truncated; resolving it requires WithResolvePrefix", prefix)
+	}
+	tags, err := List(repo, options...)
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("listing tags to resolve %q: %w", prefix, err)
+	}
+	if len(tags) == 0 {
+		return name.Digest{}, fmt.Errorf("no tags in %s to resolve %q against", repo, prefix)
+	}
+	f, err := makeFetcher(repo.Tag(tags[0]), o)
+	if err != nil {
+		return name.Digest{}, err
+	}
+
+	candidates := map[v1.Hash][]string{}
+	for _, tag := range tags {
+		desc, err := f.headManifest(repo.Tag(tag), headMediaTypes())
+		var terr *transport.Error
+		if errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound {
+			// The tag was deleted since we listed it.
+			continue
+		} else if err != nil {
+			return name.Digest{}, fmt.Errorf("resolving %q: %w", prefix, err)
+		}
+		if desc.Digest.Algorithm == alg && strings.HasPrefix(desc.Digest.Hex, hex) {
+			candidates[desc.Digest] = append(candidates[desc.Digest], tag)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return name.Digest{}, fmt.Errorf("no tagged manifest in %s matches digest prefix %q", repo, prefix)
+	case 1:
+		for h := range candidates {
+			return repo.Digest(h.String()), nil
+		}
+	}
+	matches := make([]string, 0, len(candidates))
+	for h, tags := range candidates {
+		matches = append(matches, fmt.Sprintf("%s (%s)", h, strings.Join(tags, ", ")))
+	}
+	sort.Strings(matches)
+	return name.Digest{}, fmt.Errorf("digest prefix %q is ambiguous in %s, candidates: %s", prefix, repo, strings.Join(matches, "; "))
+}
@@ -0,0 +1,155 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+func TestResolveDigestPrefix(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(u.Host + "/test/prefix")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// With 17 images, at least two share their first hex character.
+	byFirst := map[byte][]v1.Hash{}
+	var ambiguous string
+	var want v1.Hash
+	for i := 0; i < 17; i++ {
+		img, err := random.Image(256, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Write(repo.Tag(fmt.Sprintf("tag%d", i)), img); err != nil {
+			t.Fatal(err)
+		}
+		h, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			want = h
+			// Tag the first image twice; it's still a single candidate.
+			if err := Write(repo.Tag("latest"), img); err != nil {
+				t.Fatal(err)
+			}
+		}
+		byFirst[h.Hex[0]] = append(byFirst[h.Hex[0]], h)
+		if len(byFirst[h.Hex[0]]) > 1 {
+			ambiguous = h.Hex[:1]
+		}
+	}
+
+	for _, prefix := range []string{want.String()[:23], strings.ToUpper(want.Hex[:16]), want.String()} {
+		got, err := ResolveDigestPrefix(repo, prefix, WithResolvePrefix())
+		if err != nil {
+			t.Fatalf("ResolveDigestPrefix(%q): %v", prefix, err)
+		}
+		if got.DigestStr() != want.String() {
+			t.Errorf("ResolveDigestPrefix(%q) = %s, want %s", prefix, got.DigestStr(), want)
+		}
+	}
+
+	// Truncated digests are only resolved if asked to; full ones always are.
+	if _, err := ResolveDigestPrefix(repo, want.Hex[:16]); err == nil {
+		t.Errorf("ResolveDigestPrefix(%q) without WithResolvePrefix: expected error", want.Hex[:16])
+	}
+	if got, err := ResolveDigestPrefix(repo, want.String()); err != nil {
+		t.Errorf("ResolveDigestPrefix(%q): %v", want, err)
+	} else if got.DigestStr() != want.String() {
+		t.Errorf("ResolveDigestPrefix(%q) = %s, want %s", want, got.DigestStr(), want)
+	}
+
+	_, err = ResolveDigestPrefix(repo, ambiguous, WithResolvePrefix())
+	if err == nil {
+		t.Fatalf("ResolveDigestPrefix(%q): expected ambiguity error", ambiguous)
+	}
+	for _, h := range byFirst[ambiguous[0]] {
+		if !strings.Contains(err.Error(), h.String()) {
+			t.Errorf("ambiguity error %q does not list candidate %s", err, h)
+		}
+	}
+
+	// Flip the last character of a long prefix so that nothing matches.
+	last := "0"
+	if want.Hex[39] == '0' {
+		last = "1"
+	}
+	for _, prefix := range []string{"sha256:", "sha256:xyz", want.Hex[:39] + last} {
+		if _, err := ResolveDigestPrefix(repo, prefix, WithResolvePrefix()); err == nil {
+			t.Errorf("ResolveDigestPrefix(%q): expected error", prefix)
+		}
+	}
+}
+
+func TestResolveDigestPrefixSkipsMissingTags(t *testing.T) {
+	reg := registry.New()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Pretend "gone" was deleted after the tags were listed.
+		if r.Method == http.MethodHead && strings.HasSuffix(r.URL.Path, "/manifests/gone") {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(u.Host + "/test/prefix")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want v1.Hash
+	for _, tag := range []string{"gone", "kept"} {
+		img, err := random.Image(256, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Write(repo.Tag(tag), img); err != nil {
+			t.Fatal(err)
+		}
+		if want, err = img.Digest(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := ResolveDigestPrefix(repo, want.Hex[:12], WithResolvePrefix())
+	if err != nil {
+		t.Fatalf("ResolveDigestPrefix: %v", err)
+	}
+	if got.DigestStr() != want.String() {
+		t.Errorf("ResolveDigestPrefix = %s, want %s", got.DigestStr(), want)
+	}
+}
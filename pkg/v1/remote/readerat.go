@@ -0,0 +1,99 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/google/go-containerregistry/internal/redact"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// ReaderAt implements partial.withReaderAt by issuing a range request for each
+// read. See partial.ReaderAt.
+//
+// Note that reads through the returned io.ReaderAt are not verified against
+// the layer's digest.
+func (rl *remoteImageLayer) ReaderAt() (io.ReaderAt, error) {
+	size, err := rl.Size()
+	if err != nil {
+		return nil, err
+	}
+	return &blobReaderAt{
+		fetcher: &rl.ri.fetcher,
+		digest:  rl.digest,
+		size:    size,
+	}, nil
+}
+
+// blobReaderAt implements io.ReaderAt for a blob using HTTP range requests.
+type blobReaderAt struct {
+	*fetcher
+	digest v1.Hash
+	size   int64
+}
+
+// ReadAt implements io.ReaderAt
+func (b *blobReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off >= b.size {
+		return 0, io.EOF
+	}
+	end := off + int64(len(p))
+	if end > b.size {
+		end = b.size
+	}
+	if end == off {
+		return 0, nil
+	}
+
+	u := b.url("blobs", b.digest.String())
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, end-1))
+
+	// We don't want to log binary layers -- this can break terminals.
+	ctx := redact.NewContext(b.context, "omitting binary blobs from logs")
+	resp, err := b.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, redact.Error(err)
+	}
+	defer resp.Body.Close()
+
+	if err := transport.CheckError(resp, http.StatusPartialContent, http.StatusOK); err != nil {
+		return 0, err
+	}
+
+	// The registry ignored our Range header and sent the whole blob, so
+	// fall back to reading up to the offset.
+	if resp.StatusCode == http.StatusOK {
+		if _, err := io.CopyN(io.Discard, resp.Body, off); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := io.ReadFull(resp.Body, p[:end-off])
+	if err == nil && end-off < int64(len(p)) {
+		err = io.EOF
+	} else if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
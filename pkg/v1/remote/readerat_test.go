@@ -0,0 +1,134 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestLayerReaderAt(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for i := 0; i < 10; i++ {
+		contents := strings.Repeat(fmt.Sprint(i), 100*i)
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     fmt.Sprintf("file%d", i),
+			Mode:     0644,
+			Size:     int64(len(contents)),
+			Typeflag: tar.TypeReg,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	img, err := mutate.AppendLayers(empty.Image, static.NewLayer(buf.Bytes(), types.DockerUncompressedLayer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		ranges bool
+	}{{
+		name:   "range requests",
+		ranges: true,
+	}, {
+		name:   "no range requests",
+		ranges: false,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := registry.New()
+			partials := 0
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !tc.ranges || r.Method != http.MethodGet || r.Header.Get("Range") == "" {
+					reg.ServeHTTP(w, r)
+					return
+				}
+				rec := httptest.NewRecorder()
+				reg.ServeHTTP(rec, r)
+				partials++
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(rec.Body.Bytes()))
+			}))
+			defer s.Close()
+			u, err := url.Parse(s.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ref := mustNewTag(t, fmt.Sprintf("%s/test/readerat:latest", u.Host))
+			if err := Write(ref, img); err != nil {
+				t.Fatal(err)
+			}
+			rimg, err := Image(ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ls, err := rimg.Layers()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ra, err := partial.ReaderAt(ls[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := ra.(*blobReaderAt); !ok {
+				t.Fatalf("ReaderAt; got %T, want *blobReaderAt", ra)
+			}
+
+			toc, err := partial.TOC(ls[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := len(toc) - 1; i >= 0; i-- {
+				r, err := partial.OpenEntry(ls[0], toc[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				b, err := io.ReadAll(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got, want := string(b), strings.Repeat(fmt.Sprint(i), 100*i); got != want {
+					t.Errorf("OpenEntry(%s); got %q, want %q", toc[i].Header.Name, got, want)
+				}
+			}
+			if tc.ranges && partials == 0 {
+				t.Error("expected range requests")
+			}
+		})
+	}
+}
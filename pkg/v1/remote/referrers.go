@@ -0,0 +1,117 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Referrers returns an index of the manifests that refer to the given digest
+// via their subject field, using the OCI Referrers API.
+//
+// If the registry doesn't support the Referrers API, this falls back to the
+// referrers tag schema, i.e. the index tagged as "<alg>-<hex>". If neither is
+// found, an empty index is returned, since the subject may simply have no
+// referrers yet.
+//
+// When a manifest with a subject (see mutate.Subject) is pushed to a registry
+// that doesn't acknowledge it with an OCI-Subject header, it is added to the
+// subject's fallback tag, so that it is listed either way.
+func Referrers(d name.Digest, options ...Option) (v1.ImageIndex, error) {
+	o, err := makeOptions(d.Context(), options...)
+	if err != nil {
+		return nil, err
+	}
+	f, err := makeFetcher(d, o)
+	if err != nil {
+		return nil, err
+	}
+	return f.fetchReferrers(d)
+}
+
+// fallbackTag returns the tag used by the referrers tag schema for d.
+func fallbackTag(d name.Digest) name.Tag {
+	// Don't validate the digest, so that algorithms v1.Hash doesn't support
+	// are mapped the same way.
+	alg, hex, _ := strings.Cut(d.DigestStr(), ":")
+	return d.Context().Tag(v1.Hash{Algorithm: alg, Hex: hex}.TagName())
+}
+
+func (f *fetcher) fetchReferrers(d name.Digest) (v1.ImageIndex, error) {
+	u := f.url("referrers", d.DigestStr())
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", string(types.OCIImageIndex))
+
+	resp, err := f.Client.Do(req.WithContext(f.context))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := transport.CheckError(resp, http.StatusOK, http.StatusNotFound, http.StatusBadRequest); err != nil {
+		return nil, err
+	}
+
+	var b []byte
+	if resp.StatusCode == http.StatusOK {
+		b, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// The registry doesn't support the Referrers API, so try the tag schema.
+		b, _, err = f.fetchManifest(fallbackTag(d), []types.MediaType{types.OCIImageIndex})
+		var terr *transport.Error
+		if errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound {
+			// No referrers have been pushed yet.
+			b, err = json.Marshal(&v1.IndexManifest{
+				SchemaVersion: 2,
+				MediaType:     types.OCIImageIndex,
+				Manifests:     []v1.Descriptor{},
+			})
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	h, size, err := v1.SHA256(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	return &remoteIndex{
+		fetcher:   *f,
+		manifest:  b,
+		mediaType: types.OCIImageIndex,
+		descriptor: &v1.Descriptor{
+			Digest:    h,
+			Size:      size,
+			MediaType: types.OCIImageIndex,
+		},
+	}, nil
+}
@@ -0,0 +1,217 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestReferrers(t *testing.T) {
+	referrer := v1.Descriptor{
+		MediaType:    types.OCIManifestSchema1,
+		Size:         123,
+		Digest:       v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("a", 64)},
+		ArtifactType: "application/vnd.example.sbom.v1+json",
+		Annotations:  map[string]string{"org.example": "sbom"},
+	}
+
+	for _, tc := range []struct {
+		desc string
+		// If set, the registry implements the Referrers API.
+		api bool
+		// If set, the fallback tag is pushed.
+		tag  bool
+		want int
+	}{{
+		desc: "referrers API",
+		api:  true,
+		want: 1,
+	}, {
+		desc: "fallback tag",
+		tag:  true,
+		want: 1,
+	}, {
+		desc: "no referrers",
+		want: 0,
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			reg := registry.New()
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.api && strings.Contains(r.URL.Path, "/referrers/") {
+					w.Header().Set("Content-Type", string(types.OCIImageIndex))
+					json.NewEncoder(w).Encode(v1.IndexManifest{
+						SchemaVersion: 2,
+						MediaType:     types.OCIImageIndex,
+						Manifests:     []v1.Descriptor{referrer},
+					})
+					return
+				}
+				reg.ServeHTTP(w, r)
+			}))
+			defer s.Close()
+			u, err := url.Parse(s.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			img, err := random.Image(1024, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h, err := img.Digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, err := name.NewDigest(u.Host + "/foo/bar@" + h.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Write(d, img); err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.tag {
+				idx := mutate.IndexMediaType(mutate.AppendManifests(empty.Index, mutate.IndexAddendum{
+					Add:        img,
+					Descriptor: referrer,
+				}), types.OCIImageIndex)
+				if err := WriteIndex(fallbackTag(d), idx); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			idx, err := Referrers(d)
+			if err != nil {
+				t.Fatalf("Referrers() = %v", err)
+			}
+			m, err := idx.IndexManifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(m.Manifests); got != tc.want {
+				t.Fatalf("len(Manifests); got %d, want %d", got, tc.want)
+			}
+			if tc.want == 0 {
+				return
+			}
+			got := m.Manifests[0]
+			if got.ArtifactType != referrer.ArtifactType {
+				t.Errorf("ArtifactType; got %q, want %q", got.ArtifactType, referrer.ArtifactType)
+			}
+			if got.Annotations["org.example"] != "sbom" {
+				t.Errorf("Annotations; got %v, want %v", got.Annotations, referrer.Annotations)
+			}
+		})
+	}
+}
+
+func TestWriteReferrer(t *testing.T) {
+	for _, api := range []bool{false, true} {
+		t.Run(fmt.Sprintf("api=%t", api), func(t *testing.T) {
+			reg := registry.New()
+			var tagged bool
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/manifests/sha256-") {
+					tagged = true
+				}
+				if api && r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/manifests/") {
+					// Pretend to support the Referrers API.
+					w.Header().Set("OCI-Subject", "sha256:whatever")
+				}
+				reg.ServeHTTP(w, r)
+			}))
+			defer s.Close()
+			u, err := url.Parse(s.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			subject, err := random.Image(1024, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sd, err := partial.Descriptor(subject)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, err := name.NewDigest(u.Host + "/foo/bar@" + sd.Digest.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Write(d, subject); err != nil {
+				t.Fatal(err)
+			}
+
+			img, err := random.Image(1024, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			img = mutate.Annotations(mutate.Subject(img, *sd), map[string]string{"org.example": "sbom"}).(v1.Image)
+			rd, err := img.Digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Pushing twice doesn't duplicate the referrer.
+			for i := 0; i < 2; i++ {
+				if err := Write(d.Context().Digest(rd.String()), img); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if tagged == api {
+				t.Fatalf("fallback tag pushed: %t, want %t", tagged, !api)
+			}
+			if api {
+				return
+			}
+			idx, err := Referrers(d)
+			if err != nil {
+				t.Fatalf("Referrers() = %v", err)
+			}
+			m, err := idx.IndexManifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(m.Manifests); got != 1 {
+				t.Fatalf("len(Manifests); got %d, want 1", got)
+			}
+			got := m.Manifests[0]
+			if got.Digest != rd {
+				t.Errorf("Digest; got %s, want %s", got.Digest, rd)
+			}
+			if got.ArtifactType != string(types.OCIConfigJSON) {
+				t.Errorf("ArtifactType; got %q, want %q", got.ArtifactType, types.OCIConfigJSON)
+			}
+			if got.Annotations["org.example"] != "sbom" {
+				t.Errorf("Annotations; got %v", got.Annotations)
+			}
+		})
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 
 	authchallenge "github.com/docker/distribution/registry/client/auth/challenge"
 	"github.com/google/go-containerregistry/internal/redact"
@@ -48,6 +49,10 @@ type bearerTransport struct {
 	scopes  []string
 	// Scheme we should use, determined by ping response.
 	scheme string
+
+	// Guards basic, bearer and scopes, which are updated by refreshes that
+	// may race with requests from other goroutines.
+	mx sync.RWMutex
 }
 
 var _ http.RoundTripper = (*bearerTransport)(nil)
@@ -67,57 +72,93 @@ func stringSet(ss []string) map[string]struct{} {
 
 // RoundTrip implements http.RoundTripper
 func (bt *bearerTransport) RoundTrip(in *http.Request) (*http.Response, error) {
-	sendRequest := func() (*http.Response, error) {
+	sendRequest := func() (*http.Response, string, error) {
+		bt.mx.RLock()
+		token := bt.bearer.RegistryToken
+		bt.mx.RUnlock()
+
 		// http.Client handles redirects at a layer above the http.RoundTripper
 		// abstraction, so to avoid forwarding Authorization headers to places
 		// we are redirected, only set it when the authorization header matches
 		// the registry with which we are interacting.
 		// In case of redirect http.Client can use an empty Host, check URL too.
 		if matchesHost(bt.registry, in, bt.scheme) {
-			hdr := fmt.Sprintf("Bearer %s", bt.bearer.RegistryToken)
+			hdr := fmt.Sprintf("Bearer %s", token)
 			in.Header.Set("Authorization", hdr)
 		}
-		return bt.inner.RoundTrip(in)
+		res, err := bt.inner.RoundTrip(in)
+		return res, token, err
 	}
 
-	res, err := sendRequest()
+	res, sent, err := sendRequest()
 	if err != nil {
 		return nil, err
 	}
 
 	// If we hit a WWW-Authenticate challenge, it might be due to expired tokens or insufficient scope.
 	if challenges := authchallenge.ResponseChallenges(res); len(challenges) != 0 {
-		// close out old response, since we will not return it.
-		res.Body.Close()
+		if err := bt.refreshForChallenges(in.Context(), challenges, sent); err != nil {
+			res.Body.Close()
+			return nil, err
+		}
 
-		newScopes := []string{}
-		for _, wac := range challenges {
-			// TODO(jonjohnsonjr): Should we also update "realm" or "service"?
-			if want, ok := wac.Parameters["scope"]; ok {
-				// Add any scopes that we don't already request.
-				got := stringSet(bt.scopes)
-				if _, ok := got[want]; !ok {
-					newScopes = append(newScopes, want)
-				}
+		// If the body has already been consumed and we can't get it back,
+		// return the challenge as-is. The token has been refreshed, so
+		// the caller can retry the whole request.
+		if in.Body != nil && in.Body != http.NoBody {
+			if in.GetBody == nil {
+				return res, nil
+			}
+			body, err := in.GetBody()
+			if err != nil {
+				res.Body.Close()
+				return nil, err
 			}
+			in.Body = body
 		}
 
-		// Some registries seem to only look at the first scope parameter during a token exchange.
-		// If a request fails because it's missing a scope, we should put those at the beginning,
-		// otherwise the registry might just ignore it :/
-		newScopes = append(newScopes, bt.scopes...)
-		bt.scopes = newScopes
+		// close out old response, since we will not return it.
+		res.Body.Close()
+
+		// Retry the request with the new token.
+		res, _, err = sendRequest()
+		return res, err
+	}
 
-		// TODO(jonjohnsonjr): Teach transport.Error about "error" and "error_description" from challenge.
+	return res, err
+}
 
-		// Retry the request to attempt to get a valid token.
-		if err = bt.refresh(in.Context()); err != nil {
-			return nil, err
+// refreshForChallenges adds any scopes requested by challenges and then
+// refreshes the bearer token, unless another request already replaced the
+// token we sent and no new scopes are needed.
+func (bt *bearerTransport) refreshForChallenges(ctx context.Context, challenges []authchallenge.Challenge, sent string) error {
+	bt.mx.Lock()
+	defer bt.mx.Unlock()
+
+	newScopes := []string{}
+	for _, wac := range challenges {
+		// TODO(jonjohnsonjr): Should we also update "realm" or "service"?
+		if want, ok := wac.Parameters["scope"]; ok {
+			// Add any scopes that we don't already request.
+			got := stringSet(bt.scopes)
+			if _, ok := got[want]; !ok {
+				newScopes = append(newScopes, want)
+			}
 		}
-		return sendRequest()
+	}
+	if len(newScopes) == 0 && bt.bearer.RegistryToken != sent {
+		return nil
 	}
 
-	return res, err
+	// Some registries seem to only look at the first scope parameter during a token exchange.
+	// If a request fails because it's missing a scope, we should put those at the beginning,
+	// otherwise the registry might just ignore it :/
+	bt.scopes = append(newScopes, bt.scopes...)
+
+	// TODO(jonjohnsonjr): Teach transport.Error about "error" and "error_description" from challenge.
+
+	// Get a new token, asking the Authenticator for fresh credentials.
+	return bt.refresh(ctx)
 }
 
 // It's unclear which authentication flow to use based purely on the protocol,
@@ -17,6 +17,7 @@ package transport
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -214,6 +215,93 @@ func TestBearerTransportTokenRefresh(t *testing.T) {
 	}
 }
 
+func TestBearerTransportReplaysBody(t *testing.T) {
+	initialToken := "foo"
+	refreshedToken := "bar"
+	payload := "some blob contents"
+
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hdr := r.Header.Get("Authorization")
+			if strings.HasPrefix(hdr, "Basic ") {
+				w.Write([]byte(fmt.Sprintf(`{"token": %q}`, refreshedToken)))
+				return
+			}
+			b, err := io.ReadAll(r.Body)
+			if err != nil {
+				t.Errorf("ReadAll: %v", err)
+			}
+			if hdr == "Bearer "+refreshedToken {
+				if got, want := string(b), payload; got != want {
+					t.Errorf("body; got %q, want %q", got, want)
+				}
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			// The token has "expired".
+			w.Header().Set("WWW-Authenticate", `Bearer realm="foo"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry, err := name.NewRegistry(u.Host, name.WeakValidation)
+	if err != nil {
+		t.Fatalf("Unexpected error during NewRegistry: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		rewind   bool
+		wantCode int
+	}{{
+		name:     "rewindable",
+		rewind:   true,
+		wantCode: http.StatusAccepted,
+	}, {
+		name:     "not rewindable",
+		rewind:   false,
+		wantCode: http.StatusUnauthorized,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			transport := &bearerTransport{
+				inner:    http.DefaultTransport,
+				bearer:   authn.AuthConfig{RegistryToken: initialToken},
+				basic:    &authn.Basic{Username: "foo", Password: "bar"},
+				registry: registry,
+				realm:    server.URL,
+				scheme:   "http",
+			}
+			client := http.Client{Transport: transport}
+
+			var body io.Reader = strings.NewReader(payload)
+			if !tc.rewind {
+				// Hide the concrete type so http.NewRequest can't set GetBody.
+				body = io.MultiReader(body)
+			}
+			req, err := http.NewRequest(http.MethodPatch, fmt.Sprintf("http://%s/v2/foo/bar/blobs/uploads/123", u.Host), body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("client.Do: %v", err)
+			}
+			res.Body.Close()
+			if got, want := res.StatusCode, tc.wantCode; got != want {
+				t.Errorf("StatusCode; got %v, want %v", got, want)
+			}
+			// Either way, the next request should use the new token.
+			if got, want := transport.bearer.RegistryToken, refreshedToken; got != want {
+				t.Errorf("RegistryToken; got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
 func TestBearerTransportOauthRefresh(t *testing.T) {
 	initialToken := "foo"
 	accessToken := "bar"
@@ -16,6 +16,7 @@ package transport
 
 import (
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/google/go-containerregistry/internal/retry"
@@ -29,6 +30,10 @@ var defaultBackoff = retry.Backoff{
 	Steps:    3,
 }
 
+// defaultRetryAfterCap is the longest we'll wait to honor a Retry-After header
+// unless overridden by WithRetryAfterCap.
+var defaultRetryAfterCap = time.Minute
+
 var _ http.RoundTripper = (*retryTransport)(nil)
 
 // retryTransport wraps a RoundTripper and retries temporary network errors.
@@ -37,15 +42,19 @@ type retryTransport struct {
 	backoff   retry.Backoff
 	predicate retry.Predicate
 	codes     []int
+	afterCap  time.Duration
 }
 
-// Option is a functional option for retryTransport.
+// Option is a functional option for retryTransport, or for NewWithContext.
 type Option func(*options)
 
 type options struct {
 	backoff   retry.Backoff
 	predicate retry.Predicate
 	codes     []int
+	afterCap  time.Duration
+
+	allowInsecureAuth bool
 }
 
 // Backoff is an alias of retry.Backoff to expose this configuration option to consumers of this lib
@@ -72,11 +81,21 @@ func WithRetryStatusCodes(codes ...int) Option {
 	}
 }
 
+// WithRetryAfterCap sets the longest the transport will wait before retrying
+// a response that carries a Retry-After header. The default is one minute;
+// a non-positive value removes the cap.
+func WithRetryAfterCap(d time.Duration) Option {
+	return func(o *options) {
+		o.afterCap = d
+	}
+}
+
 // NewRetry returns a transport that retries errors.
 func NewRetry(inner http.RoundTripper, opts ...Option) http.RoundTripper {
 	o := &options{
 		backoff:   defaultBackoff,
 		predicate: retry.IsTemporary,
+		afterCap:  defaultRetryAfterCap,
 	}
 
 	for _, opt := range opts {
@@ -88,11 +107,24 @@ func NewRetry(inner http.RoundTripper, opts ...Option) http.RoundTripper {
 		backoff:   o.backoff,
 		predicate: o.predicate,
 		codes:     o.codes,
+		afterCap:  o.afterCap,
 	}
 }
 
 func (t *retryTransport) RoundTrip(in *http.Request) (out *http.Response, err error) {
+	// If the registry told us how long to wait via Retry-After, wait that long
+	// (in addition to the backoff) before the next attempt.
+	var delay time.Duration
 	roundtrip := func() error {
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-in.Context().Done():
+				out, err = nil, in.Context().Err()
+				return err
+			}
+			delay = 0
+		}
 		out, err = t.inner.RoundTrip(in)
 		if !retry.Ever(in.Context()) {
 			return nil
@@ -100,12 +132,38 @@ func (t *retryTransport) RoundTrip(in *http.Request) (out *http.Response, err er
 		if out != nil {
 			for _, code := range t.codes {
 				if out.StatusCode == code {
+					delay = retryAfter(out.Header, t.afterCap)
 					return CheckError(out)
 				}
 			}
 		}
 		return err
 	}
-	retry.Retry(roundtrip, t.predicate, t.backoff)
+	if rerr := retry.RetryWithContext(in.Context(), roundtrip, t.predicate, t.backoff); out == nil && err == nil {
+		// The context was done before we made a single attempt.
+		return nil, rerr
+	}
 	return
 }
+
+// retryAfter parses the Retry-After header, which may be either a number of
+// seconds or an HTTP-date, and returns how long to wait, capped at limit if it's positive.
+func retryAfter(h http.Header, limit time.Duration) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	var d time.Duration
+	if secs, err := strconv.Atoi(v); err == nil {
+		d = time.Duration(secs) * time.Second
+	} else if when, err := http.ParseTime(v); err == nil {
+		d = time.Until(when)
+	}
+	if d < 0 {
+		return 0
+	}
+	if limit > 0 && d > limit {
+		return limit
+	}
+	return d
+}
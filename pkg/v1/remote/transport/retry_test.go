@@ -175,3 +175,77 @@ func TestTimeoutContext(t *testing.T) {
 		t.Fatalf("deadline was not recognized by transport")
 	}
 }
+
+func TestRetryAfter(t *testing.T) {
+	for _, test := range []struct {
+		header string
+		limit  time.Duration
+		want   time.Duration
+	}{{
+		header: "",
+		want:   0,
+	}, {
+		header: "3",
+		want:   3 * time.Second,
+	}, {
+		header: "120",
+		limit:  time.Minute,
+		want:   time.Minute,
+	}, {
+		header: "-1",
+		want:   0,
+	}, {
+		header: "garbage",
+		want:   0,
+	}, {
+		header: time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat),
+		want:   0,
+	}, {
+		header: time.Now().Add(time.Hour).UTC().Format(http.TimeFormat),
+		limit:  time.Second,
+		want:   time.Second,
+	}} {
+		h := http.Header{}
+		if test.header != "" {
+			h.Set("Retry-After", test.header)
+		}
+		if got := retryAfter(h, test.limit); got != test.want {
+			t.Errorf("retryAfter(%q, %v); got %v, want %v", test.header, test.limit, got, test.want)
+		}
+	}
+}
+
+func TestRetryTransportHonorsRetryAfter(t *testing.T) {
+	tooMany := resp(http.StatusTooManyRequests)
+	tooMany.Header = http.Header{"Retry-After": []string{"10"}}
+	mt := mockTransport{
+		resps: []*http.Response{tooMany, resp(http.StatusOK)},
+	}
+
+	limit := 50 * time.Millisecond
+	tr := NewRetry(&mt,
+		WithRetryBackoff(retry.Backoff{Steps: 3}),
+		WithRetryPredicate(retry.IsTemporary),
+		WithRetryStatusCodes(http.StatusTooManyRequests),
+		WithRetryAfterCap(limit),
+	)
+
+	req, err := http.NewRequest("GET", "example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	out, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < limit {
+		t.Errorf("RoundTrip() returned after %v, want at least %v", elapsed, limit)
+	}
+	if out.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode; got %d, want %d", out.StatusCode, http.StatusOK)
+	}
+	if mt.count != 2 {
+		t.Errorf("wrong count, wanted %d, got %d", 2, mt.count)
+	}
+}
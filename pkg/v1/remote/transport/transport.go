@@ -17,7 +17,9 @@ package transport
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
@@ -38,7 +40,16 @@ func New(reg name.Registry, auth authn.Authenticator, t http.RoundTripper, scope
 // In case the RoundTripper is already of the type Wrapper it assumes
 // authentication was already done prior to this call, so it just returns
 // the provided RoundTripper without further action
-func NewWithContext(ctx context.Context, reg name.Registry, auth authn.Authenticator, t http.RoundTripper, scopes []string) (http.RoundTripper, error) {
+//
+// Credentials are never sent over plain HTTP to anything but a loopback
+// address unless WithAllowInsecureAuth is passed: if an insecure registry
+// asks for them, this returns an error instead.
+func NewWithContext(ctx context.Context, reg name.Registry, auth authn.Authenticator, t http.RoundTripper, scopes []string, opts ...Option) (http.RoundTripper, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
 	// When the transport provided is of the type Wrapper this function assumes that the caller already
 	// executed the necessary login and check.
 	switch t.(type) {
@@ -76,8 +87,19 @@ func NewWithContext(ctx context.Context, reg name.Registry, auth authn.Authentic
 		inner:    t,
 	}
 
+	// Whether we'd be sending credentials to an insecure registry without
+	// having been allowed to.
+	insecure := auth != authn.Anonymous && !o.allowInsecureAuth && pr.scheme == "http" && !IsLoopback(reg.RegistryStr())
 	switch pr.challenge.Canonical() {
 	case anonymous, basic:
+		if insecure {
+			if pr.challenge.Canonical() == anonymous {
+				// The registry doesn't need them, so just don't send them.
+				auth = authn.Anonymous
+			} else {
+				return nil, insecureAuthError(reg.RegistryStr())
+			}
+		}
 		return &Wrapper{&basicTransport{inner: t, auth: auth, target: reg.RegistryStr()}}, nil
 	case bearer:
 		// We require the realm, which tells us where to send our Basic auth to turn it into Bearer auth.
@@ -85,6 +107,10 @@ func NewWithContext(ctx context.Context, reg name.Registry, auth authn.Authentic
 		if !ok {
 			return nil, fmt.Errorf("malformed www-authenticate, missing realm: %v", pr.parameters)
 		}
+		if u, err := url.Parse(realm); err == nil && insecure && u.Scheme == "http" && !IsLoopback(u.Host) {
+			// Our Basic auth would be sent to the realm in the clear.
+			return nil, insecureAuthError(u.Host)
+		}
 		service := pr.parameters["service"]
 		bt := &bearerTransport{
 			inner:    t,
@@ -104,6 +130,32 @@ func NewWithContext(ctx context.Context, reg name.Registry, auth authn.Authentic
 	}
 }
 
+// WithAllowInsecureAuth permits NewWithContext to send credentials over plain
+// HTTP, to registries that don't support TLS. Only use this for registries
+// reached over a fully trusted network.
+func WithAllowInsecureAuth() Option {
+	return func(o *options) {
+		o.allowInsecureAuth = true
+	}
+}
+
+func insecureAuthError(host string) error {
+	return fmt.Errorf("refusing to send credentials to %s over plain HTTP; see remote.WithAllowInsecureAuth", host)
+}
+
+// IsLoopback reports whether host (which may have a port) is a loopback
+// address, which it's safe to send credentials to without TLS.
+func IsLoopback(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 // Wrapper results in *not* wrapping supplied transport with additional logic such as retries, useragent and debug logging
 // Consumers are opt-ing into providing their own transport without any additional wrapping.
 type Wrapper struct {
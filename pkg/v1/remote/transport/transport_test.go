@@ -184,6 +184,41 @@ func TestTransportSelectionBearer(t *testing.T) {
 	}
 }
 
+func TestTransportSelectionInsecureAuth(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/token" {
+				w.Write([]byte(`{"token": "dfskdjhfkhsjdhfkjhsdf"}`))
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="http://auth.example.com/token"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		}))
+	defer server.Close()
+	tprt := &http.Transport{
+		Proxy: func(req *http.Request) (*url.URL, error) {
+			return url.Parse(server.URL)
+		},
+	}
+	reg, err := name.NewRegistry("registry.example.com", name.Insecure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	basic := &authn.Basic{Username: "foo", Password: "bar"}
+	scopes := []string{testReference.Scope(PullScope)}
+
+	if _, err := NewWithContext(context.Background(), reg, basic, tprt, scopes); err == nil || !strings.Contains(err.Error(), "plain HTTP") {
+		t.Errorf("NewWithContext() = %v, want plain HTTP error", err)
+	}
+	// Anonymous requests have no credentials to leak.
+	if _, err := NewWithContext(context.Background(), reg, authn.Anonymous, tprt, scopes); err != nil {
+		t.Errorf("NewWithContext(Anonymous) = %v", err)
+	}
+	if _, err := NewWithContext(context.Background(), reg, basic, tprt, scopes, WithAllowInsecureAuth()); err != nil {
+		t.Errorf("NewWithContext(WithAllowInsecureAuth) = %v", err)
+	}
+}
+
 func TestTransportSelectionBearerMissingRealm(t *testing.T) {
 	server := httptest.NewServer(
 		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
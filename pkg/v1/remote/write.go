@@ -17,13 +17,16 @@ package remote
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 
+	"github.com/google/go-containerregistry/internal/convert"
 	"github.com/google/go-containerregistry/internal/redact"
 	"github.com/google/go-containerregistry/internal/retry"
 	"github.com/google/go-containerregistry/pkg/logs"
@@ -48,6 +51,13 @@ func Write(ref name.Reference, img v1.Image, options ...Option) (rerr error) {
 		return err
 	}
 
+	if o.manifestMediaType != "" {
+		img, err = convertManifest(img, o.manifestMediaType)
+		if err != nil {
+			return err
+		}
+	}
+
 	var p *progress
 	if o.updates != nil {
 		p = &progress{updates: o.updates}
@@ -62,13 +72,85 @@ func Write(ref name.Reference, img v1.Image, options ...Option) (rerr error) {
 	return writeImage(o.context, ref, img, o, p)
 }
 
+// convertManifest converts img to the manifest media type mt for
+// WithManifestMediaType.
+func convertManifest(img v1.Image, mt types.MediaType) (v1.Image, error) {
+	got, err := img.MediaType()
+	if err != nil {
+		return nil, err
+	}
+	if got == mt {
+		return img, nil
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	if mt == types.DockerManifestSchema2 {
+		var lost []string
+		if len(m.Annotations) != 0 {
+			lost = append(lost, "annotations")
+		}
+		if m.Subject != nil {
+			lost = append(lost, "subject")
+		}
+		if m.ArtifactType != "" {
+			lost = append(lost, "artifactType")
+		}
+		if cmt := m.Config.MediaType; cmt != types.OCIConfigJSON && cmt != types.DockerConfigJSON {
+			lost = append(lost, fmt.Sprintf("config media type %q", cmt))
+		}
+		if len(lost) != 0 {
+			return nil, fmt.Errorf("cannot convert %s image to %s: it has OCI-only %s", got, mt, strings.Join(lost, ", "))
+		}
+	}
+	m = m.DeepCopy()
+	if _, err := convert.MediaTypes(m, mt); err != nil {
+		return nil, fmt.Errorf("converting %s image to %s: %w", got, mt, err)
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return &convertedImage{Image: img, manifest: b, mediaType: mt}, nil
+}
+
+// convertedImage is an image whose manifest has had its media types
+// rewritten by convertManifest. Its blobs are unchanged.
+type convertedImage struct {
+	v1.Image
+	manifest  []byte
+	mediaType types.MediaType
+}
+
+func (i *convertedImage) MediaType() (types.MediaType, error) {
+	return i.mediaType, nil
+}
+
+func (i *convertedImage) RawManifest() ([]byte, error) {
+	return i.manifest, nil
+}
+
+func (i *convertedImage) Manifest() (*v1.Manifest, error) {
+	return v1.ParseManifest(bytes.NewReader(i.manifest))
+}
+
+func (i *convertedImage) Digest() (v1.Hash, error) {
+	h, _, err := v1.SHA256(bytes.NewReader(i.manifest))
+	return h, err
+}
+
+func (i *convertedImage) Size() (int64, error) {
+	return int64(len(i.manifest)), nil
+}
+
 func writeImage(ctx context.Context, ref name.Reference, img v1.Image, o *options, progress *progress) error {
 	ls, err := img.Layers()
 	if err != nil {
 		return err
 	}
 	scopes := scopesForUploadingImage(ref.Context(), ls)
-	tr, err := transport.NewWithContext(o.context, ref.Context().Registry, o.auth, o.transport, scopes)
+	tr, err := transport.NewWithContext(o.context, ref.Context().Registry, o.auth, o.transport, scopes, o.transportOptions()...)
 	if err != nil {
 		return err
 	}
@@ -78,6 +160,8 @@ func writeImage(ctx context.Context, ref name.Reference, img v1.Image, o *option
 		progress:  progress,
 		backoff:   o.retryBackoff,
 		predicate: o.retryPredicate,
+		chunkSize: o.chunkSize,
+		dryRun:    newDryRun(o.dryRun),
 	}
 
 	// Upload individual blobs and collect any errors.
@@ -95,6 +179,11 @@ func writeImage(ctx context.Context, ref name.Reference, img v1.Image, o *option
 		})
 	}
 
+	// We may not be able to read the ConfigLayer yet, possibly because of
+	// streaming layers, since the layer DiffIDs haven't been calculated yet.
+	// If we can, upload it alongside the layers so that it counts against jobs.
+	cl, clErr := partial.ConfigLayer(img)
+
 	// Upload individual layers in goroutines and collect any errors.
 	// If we can dedupe by the layer digest, try to do so. If we can't determine
 	// the digest for whatever reason, we can't dedupe and might re-upload.
@@ -130,17 +219,23 @@ func writeImage(ctx context.Context, ref name.Reference, img v1.Image, o *option
 				return gctx.Err()
 			}
 		}
+
+		if clErr == nil {
+			select {
+			case blobChan <- cl:
+			case <-gctx.Done():
+				return gctx.Err()
+			}
+		}
 		return nil
 	})
 
-	if l, err := partial.ConfigLayer(img); err != nil {
-		// We can't read the ConfigLayer, possibly because of streaming layers,
-		// since the layer DiffIDs haven't been calculated yet. Attempt to wait
-		// for the other layers to be uploaded, then try the config again.
-		if err := g.Wait(); err != nil {
-			return err
-		}
+	// Wait for the layers (and config, if we could read it).
+	if err := g.Wait(); err != nil {
+		return err
+	}
 
+	if clErr != nil {
 		// Now that all the layers are uploaded, try to upload the config file blob.
 		l, err := partial.ConfigLayer(img)
 		if err != nil {
@@ -149,16 +244,6 @@ func writeImage(ctx context.Context, ref name.Reference, img v1.Image, o *option
 		if err := w.uploadOne(ctx, l); err != nil {
 			return err
 		}
-	} else {
-		// We *can* read the ConfigLayer, so upload it concurrently with the layers.
-		g.Go(func() error {
-			return w.uploadOne(gctx, l)
-		})
-
-		// Wait for the layers + config.
-		if err := g.Wait(); err != nil {
-			return err
-		}
 	}
 
 	// With all of the constituent elements uploaded, upload the manifest
@@ -174,6 +259,51 @@ type writer struct {
 	progress  *progress
 	backoff   Backoff
 	predicate retry.Predicate
+
+	// If positive, blobs larger than this are uploaded in chunks of this size.
+	chunkSize int64
+
+	// If set, nothing is written; see WithDryRun.
+	dryRun *dryRun
+}
+
+// dryRun reports the blobs that a writer would have uploaded.
+type dryRun struct {
+	mu      sync.Mutex
+	missing func(v1.Descriptor)
+}
+
+func newDryRun(missing func(v1.Descriptor)) *dryRun {
+	if missing == nil {
+		return nil
+	}
+	return &dryRun{missing: missing}
+}
+
+// reportMissing calls w.dryRun.missing with l's descriptor if l isn't in
+// w's repository.
+func (w *writer) reportMissing(ctx context.Context, l v1.Layer) error {
+	h, err := l.Digest()
+	if err != nil {
+		return fmt.Errorf("dry run: cannot check layer without a known digest: %w", err)
+	}
+	existing, err := w.checkExistingBlob(ctx, h)
+	if err != nil {
+		return err
+	}
+	if existing {
+		logs.Progress.Printf("existing blob: %v", h)
+		return nil
+	}
+	desc, err := partial.Descriptor(l)
+	if err != nil {
+		return err
+	}
+	logs.Progress.Printf("would push blob: %v", h)
+	w.dryRun.mu.Lock()
+	defer w.dryRun.mu.Unlock()
+	w.dryRun.missing(*desc)
+	return nil
 }
 
 // url returns a url.Url for the specified path in the context of this remote image reference.
@@ -360,6 +490,208 @@ func (w *writer) streamBlob(ctx context.Context, layer v1.Layer, streamLocation
 	return w.nextLocation(resp)
 }
 
+// chunked returns whether l should be uploaded with streamBlobChunked.
+func (w *writer) chunked(l v1.Layer) bool {
+	if w.chunkSize <= 0 {
+		return false
+	}
+	size, err := l.Size()
+	if err != nil {
+		// We don't know the size of streaming layers until they've been
+		// consumed, so chunk them just in case they're large.
+		return true
+	}
+	return size > w.chunkSize
+}
+
+// streamBlobChunked streams the contents of the blob to the specified location
+// in chunks of w.chunkSize bytes, each sent as a PATCH with a Content-Range.
+// On success, this returns the location header indicating how to commit this
+// layer.
+func (w *writer) streamBlobChunked(ctx context.Context, layer v1.Layer, location string) (commitLocation string, rerr error) {
+	var acked int64
+	defer func() {
+		if rerr != nil {
+			// The whole upload will be retried, so don't count it twice.
+			w.incrProgress(-acked)
+		}
+	}()
+
+	blob, err := layer.Compressed()
+	if err != nil {
+		return "", err
+	}
+	defer blob.Close()
+
+	buf := make([]byte, w.chunkSize)
+	for {
+		n, err := io.ReadFull(blob, buf)
+		if n > 0 {
+			location, err = w.uploadChunk(ctx, location, buf[:n], acked)
+			if err != nil {
+				return "", err
+			}
+			acked += int64(n)
+			w.incrProgress(int64(n))
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return location, nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+}
+
+// uploadChunk sends chunk, which starts at offset within the blob, to location.
+// If that fails, it asks the registry how much of the blob it has received and
+// resends only the rest of the chunk.
+func (w *writer) uploadChunk(ctx context.Context, location string, chunk []byte, offset int64) (string, error) {
+	var (
+		sent   int64
+		resync bool
+		next   string
+
+		// If the registry rejected the last range with a 416 that says what
+		// it has received, rejected holds that, so it needn't be asked.
+		rejected *int64
+	)
+	tryUpload := func() error {
+		if resync {
+			var received int64
+			if rejected != nil {
+				received, rejected = *rejected, nil
+			} else {
+				loc, r, err := w.uploadStatus(ctx, location)
+				if err != nil {
+					return err
+				}
+				location, received = loc, r
+			}
+			if received < offset || received > offset+int64(len(chunk)) {
+				return fmt.Errorf("cannot resume upload: registry has %d bytes, chunk covers %d-%d", received, offset, offset+int64(len(chunk))-1)
+			}
+			sent = received - offset
+			if sent == int64(len(chunk)) {
+				// The registry got the whole chunk before we failed.
+				next = location
+				return nil
+			}
+		}
+		resync = true
+
+		rest := chunk[sent:]
+		req, err := http.NewRequest(http.MethodPatch, location, bytes.NewReader(rest))
+		if err != nil {
+			return err
+		}
+		start := offset + sent
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Header.Set("Content-Range", fmt.Sprintf("%d-%d", start, start+int64(len(rest))-1))
+		req.ContentLength = int64(len(rest))
+
+		resp, err := w.client.Do(req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if err := transport.CheckError(resp, http.StatusNoContent, http.StatusAccepted, http.StatusCreated); err != nil {
+			if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+				if received, ok := rejectedRange(resp, start); ok {
+					rejected = &received
+				}
+				if loc, err := w.nextLocation(resp); err == nil {
+					location = loc
+				}
+			}
+			return err
+		}
+		next, err = w.nextLocation(resp)
+		return err
+	}
+
+	// A registry may reject a chunk whose range doesn't match what it has
+	// received, so we can resync after that too.
+	predicate := func(err error) bool {
+		var terr *transport.Error
+		if errors.As(err, &terr) && terr.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+			return true
+		}
+		return w.predicate != nil && w.predicate(err)
+	}
+	if err := retry.RetryWithContext(ctx, tryUpload, predicate, w.backoff); err != nil {
+		return "", err
+	}
+	return next, nil
+}
+
+// rejectedRange returns how many bytes a registry that rejected a chunk
+// starting at start with a 416 says it has received, if it says, so that the
+// retry resyncs from that rather than resending the same range.
+func rejectedRange(resp *http.Response, start int64) (int64, bool) {
+	r := resp.Header.Get("Range")
+	if r == "" {
+		return 0, false
+	}
+	received, err := uploadRange(resp)
+	if err != nil {
+		return 0, false
+	}
+	if received == start && r == "0-0" {
+		// We sent from the start, so "0-0" can only mean the registry
+		// already has the first byte.
+		received = 1
+	}
+	return received, true
+}
+
+// uploadStatus asks the registry how many bytes of the upload at location it
+// has received, returning the location to continue the upload at.
+func (w *writer) uploadStatus(ctx context.Context, location string) (string, int64, error) {
+	req, err := http.NewRequest(http.MethodGet, location, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	resp, err := w.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	if err := transport.CheckError(resp, http.StatusNoContent); err != nil {
+		return "", 0, err
+	}
+	loc, err := w.nextLocation(resp)
+	if err != nil {
+		return "", 0, err
+	}
+	received, err := uploadRange(resp)
+	if err != nil {
+		return "", 0, err
+	}
+	return loc, received, nil
+}
+
+// uploadRange returns how many bytes of an upload the registry says it has
+// received in resp's Range header, "0-<offset of the last byte received>".
+// Some registries omit the header when nothing has been received. "0-0" is
+// ambiguous between zero and one bytes received; it's taken to mean zero,
+// since resending a byte is better than skipping one.
+func uploadRange(resp *http.Response) (int64, error) {
+	r := resp.Header.Get("Range")
+	if r == "" {
+		return 0, nil
+	}
+	var start, end int64
+	if _, err := fmt.Sscanf(r, "%d-%d", &start, &end); err != nil {
+		return 0, fmt.Errorf("parsing upload Range %q: %w", r, err)
+	}
+	if end == 0 {
+		return 0, nil
+	}
+	return end + 1, nil
+}
+
 // commitBlob commits this blob by sending a PUT to the location returned from
 // streaming the blob.
 func (w *writer) commitBlob(ctx context.Context, location, digest string) error {
@@ -396,6 +728,11 @@ func (w *writer) incrProgress(written int64) {
 
 // uploadOne performs a complete upload of a single layer.
 func (w *writer) uploadOne(ctx context.Context, l v1.Layer) error {
+	if w.dryRun != nil {
+		return retry.RetryWithContext(ctx, func() error {
+			return w.reportMissing(retry.Never(ctx), l)
+		}, w.predicate, w.backoff)
+	}
 	tryUpload := func() error {
 		ctx := retry.Never(ctx)
 		var from, mount, origin string
@@ -418,7 +755,7 @@ func (w *writer) uploadOne(ctx context.Context, l v1.Layer) error {
 
 			mount = h.String()
 		}
-		if ml, ok := l.(*MountableLayer); ok {
+		if ml, ok := mountableLayer(l); ok {
 			from = ml.Reference.Context().RepositoryStr()
 			origin = ml.Reference.Context().RegistryStr()
 		}
@@ -451,7 +788,11 @@ func (w *writer) uploadOne(ctx context.Context, l v1.Layer) error {
 			ctx = redact.NewContext(ctx, "omitting binary blobs from logs")
 		}
 
-		location, err = w.streamBlob(ctx, l, location)
+		if w.chunked(l) {
+			location, err = w.streamBlobChunked(ctx, l, location)
+		} else {
+			location, err = w.streamBlob(ctx, l, location)
+		}
 		if err != nil {
 			return err
 		}
@@ -469,7 +810,7 @@ func (w *writer) uploadOne(ctx context.Context, l v1.Layer) error {
 		return nil
 	}
 
-	return retry.Retry(tryUpload, w.predicate, w.backoff)
+	return retry.RetryWithContext(ctx, tryUpload, w.predicate, w.backoff)
 }
 
 type withLayer interface {
@@ -579,9 +920,19 @@ func unpackTaggable(t Taggable) ([]byte, *v1.Descriptor, error) {
 
 // commitManifest does a PUT of the image's manifest.
 func (w *writer) commitManifest(ctx context.Context, t Taggable, ref name.Reference) error {
+	if w.dryRun != nil {
+		logs.Progress.Printf("would push manifest: %v", ref)
+		return nil
+	}
+	var (
+		raw          []byte
+		desc         *v1.Descriptor
+		subjectAcked bool
+	)
 	tryUpload := func() error {
 		ctx := retry.Never(ctx)
-		raw, desc, err := unpackTaggable(t)
+		var err error
+		raw, desc, err = unpackTaggable(t)
 		if err != nil {
 			return err
 		}
@@ -605,13 +956,109 @@ func (w *writer) commitManifest(ctx context.Context, t Taggable, ref name.Refere
 			return err
 		}
 
+		// Registries that support the Referrers API acknowledge a manifest's
+		// subject with this header.
+		subjectAcked = resp.Header.Get("OCI-Subject") != ""
+
 		// The image was successfully pushed!
 		logs.Progress.Printf("%v: digest: %v size: %d", ref, desc.Digest, desc.Size)
 		w.incrProgress(int64(len(raw)))
 		return nil
 	}
 
-	return retry.Retry(tryUpload, w.predicate, w.backoff)
+	if err := retry.RetryWithContext(ctx, tryUpload, w.predicate, w.backoff); err != nil {
+		return err
+	}
+	if subjectAcked {
+		return nil
+	}
+	return w.commitReferrersTag(ctx, raw, desc)
+}
+
+// commitReferrersTag adds the manifest raw, described by desc, to the index
+// tagged for its subject by the referrers tag schema (see Referrers), if it
+// has a subject. This is how clients record referrers on registries that
+// don't support the Referrers API.
+func (w *writer) commitReferrersTag(ctx context.Context, raw []byte, desc *v1.Descriptor) error {
+	var m struct {
+		Subject      *v1.Descriptor    `json:"subject,omitempty"`
+		ArtifactType string            `json:"artifactType,omitempty"`
+		Config       v1.Descriptor     `json:"config"`
+		Annotations  map[string]string `json:"annotations,omitempty"`
+	}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return err
+	}
+	if m.Subject == nil {
+		return nil
+	}
+
+	referrer := *desc
+	referrer.ArtifactType = m.ArtifactType
+	if referrer.ArtifactType == "" {
+		referrer.ArtifactType = string(m.Config.MediaType)
+	}
+	referrer.Annotations = m.Annotations
+
+	tag := w.repo.Tag(m.Subject.Digest.TagName())
+	f := fetcher{Ref: tag, Client: w.client, context: ctx}
+	idx := v1.IndexManifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIImageIndex,
+		Manifests:     []v1.Descriptor{},
+	}
+	b, _, err := f.fetchManifest(tag, []types.MediaType{types.OCIImageIndex})
+	var terr *transport.Error
+	if err == nil {
+		if err := json.Unmarshal(b, &idx); err != nil {
+			return err
+		}
+	} else if !errors.As(err, &terr) || terr.StatusCode != http.StatusNotFound {
+		return err
+	}
+	for _, d := range idx.Manifests {
+		if d.Digest == referrer.Digest {
+			return nil
+		}
+	}
+	idx.Manifests = append(idx.Manifests, referrer)
+
+	b, err = json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	h, sz, err := v1.SHA256(bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	// Don't count the tag's index towards the progress of the write.
+	tw := *w
+	tw.progress = nil
+	return tw.commitManifest(ctx, &Descriptor{
+		Manifest: b,
+		Descriptor: v1.Descriptor{
+			MediaType: types.OCIImageIndex,
+			Size:      sz,
+			Digest:    h,
+		},
+	}, tag)
+}
+
+// mountableLayer returns the MountableLayer that l is or wraps, if any. Layers
+// that only override metadata (e.g. the media types rewritten by mutate when
+// converting an image to OCI) expose the layer they wrap via Unwrap, since
+// the blob, and so where it can be mounted from, is unchanged.
+func mountableLayer(l v1.Layer) (*MountableLayer, bool) {
+	for {
+		switch t := l.(type) {
+		case *MountableLayer:
+			return t, true
+		case interface{ Unwrap() v1.Layer }:
+			l = t.Unwrap()
+		default:
+			return nil, false
+		}
+	}
 }
 
 func scopesForUploadingImage(repo name.Repository, layers []v1.Layer) []string {
@@ -619,7 +1066,7 @@ func scopesForUploadingImage(repo name.Repository, layers []v1.Layer) []string {
 	scopeSet := map[string]struct{}{}
 
 	for _, l := range layers {
-		if ml, ok := l.(*MountableLayer); ok {
+		if ml, ok := mountableLayer(l); ok {
 			// we will add push scope for ref.Context() after the loop.
 			// for now we ask pull scope for references of the same registry
 			if ml.Reference.Context().String() != repo.String() && ml.Reference.Context().Registry.String() == repo.Registry.String() {
@@ -649,7 +1096,7 @@ func WriteIndex(ref name.Reference, ii v1.ImageIndex, options ...Option) (rerr e
 	}
 
 	scopes := []string{ref.Scope(transport.PushScope)}
-	tr, err := transport.NewWithContext(o.context, ref.Context().Registry, o.auth, o.transport, scopes)
+	tr, err := transport.NewWithContext(o.context, ref.Context().Registry, o.auth, o.transport, scopes, o.transportOptions()...)
 	if err != nil {
 		return err
 	}
@@ -658,6 +1105,8 @@ func WriteIndex(ref name.Reference, ii v1.ImageIndex, options ...Option) (rerr e
 		client:    &http.Client{Transport: tr},
 		backoff:   o.retryBackoff,
 		predicate: o.retryPredicate,
+		chunkSize: o.chunkSize,
+		dryRun:    newDryRun(o.dryRun),
 	}
 
 	if o.updates != nil {
@@ -784,14 +1233,20 @@ func countIndex(idx v1.ImageIndex, allowNondistributableArtifacts bool) (int64,
 	return total, nil
 }
 
-// WriteLayer uploads the provided Layer to the specified repo.
+// WriteLayer uploads the provided Layer to the specified repo, returning once
+// the blob has been committed. This is useful for pushing blobs ahead of (and
+// in parallel with) the manifests that reference them.
+//
+// If the blob already exists in repo, this does nothing beyond the existence
+// check. If layer is a MountableLayer (e.g. from remote.Layer or remote.Image),
+// it will first attempt to mount the blob from its source repository.
 func WriteLayer(repo name.Repository, layer v1.Layer, options ...Option) (rerr error) {
 	o, err := makeOptions(repo, options...)
 	if err != nil {
 		return err
 	}
 	scopes := scopesForUploadingImage(repo, []v1.Layer{layer})
-	tr, err := transport.NewWithContext(o.context, repo.Registry, o.auth, o.transport, scopes)
+	tr, err := transport.NewWithContext(o.context, repo.Registry, o.auth, o.transport, scopes, o.transportOptions()...)
 	if err != nil {
 		return err
 	}
@@ -800,6 +1255,8 @@ func WriteLayer(repo name.Repository, layer v1.Layer, options ...Option) (rerr e
 		client:    &http.Client{Transport: tr},
 		backoff:   o.retryBackoff,
 		predicate: o.retryPredicate,
+		chunkSize: o.chunkSize,
+		dryRun:    newDryRun(o.dryRun),
 	}
 
 	if o.updates != nil {
@@ -861,7 +1318,7 @@ func Put(ref name.Reference, t Taggable, options ...Option) error {
 	// * Allow callers to pass in a transport.Transport, typecheck
 	//   it to allow them to reuse the transport across multiple calls.
 	// * WithTag option to do multiple manifest PUTs in commitManifest.
-	tr, err := transport.NewWithContext(o.context, ref.Context().Registry, o.auth, o.transport, scopes)
+	tr, err := transport.NewWithContext(o.context, ref.Context().Registry, o.auth, o.transport, scopes, o.transportOptions()...)
 	if err != nil {
 		return err
 	}
@@ -870,6 +1327,8 @@ func Put(ref name.Reference, t Taggable, options ...Option) error {
 		client:    &http.Client{Transport: tr},
 		backoff:   o.retryBackoff,
 		predicate: o.retryPredicate,
+		chunkSize: o.chunkSize,
+		dryRun:    newDryRun(o.dryRun),
 	}
 
 	return w.commitManifest(o.context, t, ref)
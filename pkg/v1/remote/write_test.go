@@ -19,19 +19,25 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"path"
 	"regexp"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/registry"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -1641,3 +1647,904 @@ func BenchmarkWrite(b *testing.B) {
 		}
 	}
 }
+
+func TestWriteJobs(t *testing.T) {
+	for _, jobs := range []int{1, 2} {
+		t.Run(fmt.Sprintf("jobs=%d", jobs), func(t *testing.T) {
+			var inflight, max int32
+			reg := registry.New()
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPatch {
+					n := atomic.AddInt32(&inflight, 1)
+					defer atomic.AddInt32(&inflight, -1)
+					for {
+						m := atomic.LoadInt32(&max)
+						if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+							break
+						}
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+				reg.ServeHTTP(w, r)
+			}))
+			defer s.Close()
+			u, err := url.Parse(s.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			img, err := random.Image(1024, 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			idx := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: img})
+
+			tag := mustNewTag(t, u.Host+"/foo/bar:latest")
+			if err := WriteIndex(tag, idx, WithJobs(jobs)); err != nil {
+				t.Fatal(err)
+			}
+			if got := atomic.LoadInt32(&max); got > int32(jobs) {
+				t.Errorf("max concurrent uploads = %d, want <= %d", got, jobs)
+			}
+		})
+	}
+}
+
+func TestWithJobsDefault(t *testing.T) {
+	for _, jobs := range []int{0, -1} {
+		o, err := makeOptions(name.MustParseReference("example.com/foo").Context(), WithJobs(jobs))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o.jobs != defaultJobs {
+			t.Errorf("WithJobs(%d); got %d, want %d", jobs, o.jobs, defaultJobs)
+		}
+	}
+}
+
+// artifactImage overrides the manifest of a v1.Image with arbitrary bytes.
+type artifactImage struct {
+	v1.Image
+	raw []byte
+}
+
+func (i *artifactImage) RawManifest() ([]byte, error) {
+	return i.raw, nil
+}
+
+func (i *artifactImage) Manifest() (*v1.Manifest, error) {
+	return v1.ParseManifest(bytes.NewReader(i.raw))
+}
+
+func (i *artifactImage) Digest() (v1.Hash, error) {
+	h, _, err := v1.SHA256(bytes.NewReader(i.raw))
+	return h, err
+}
+
+func (i *artifactImage) Size() (int64, error) {
+	return int64(len(i.raw)), nil
+}
+
+func TestWriteArtifact(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subjectDesc, err := partial.Descriptor(subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base = mutate.MediaType(base, types.OCIManifestSchema1)
+	m, err := base.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m = m.DeepCopy()
+	m.Config.MediaType = "application/vnd.example.config.v1+json"
+	m.ArtifactType = "application/vnd.example.sbom.v1+json"
+	m.Subject = subjectDesc
+	m.Annotations = map[string]string{"org.example": "sbom"}
+
+	// Use unusual formatting to make sure the bytes are pushed as-is.
+	b, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := &artifactImage{Image: base, raw: b}
+	want, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag := mustNewTag(t, u.Host+"/foo/artifact:latest")
+	if err := Write(tag, img); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+
+	desc, err := Get(tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Digest != want {
+		t.Errorf("Digest; got %v, want %v", desc.Digest, want)
+	}
+	if !bytes.Equal(desc.Manifest, b) {
+		t.Errorf("manifest bytes were not preserved; got %s, want %s", desc.Manifest, b)
+	}
+
+	got, err := v1.ParseManifest(bytes.NewReader(desc.Manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ArtifactType != m.ArtifactType {
+		t.Errorf("ArtifactType; got %q, want %q", got.ArtifactType, m.ArtifactType)
+	}
+	if got.Subject == nil || got.Subject.Digest != subjectDesc.Digest {
+		t.Errorf("Subject; got %v, want %v", got.Subject, subjectDesc)
+	}
+}
+
+func TestWriteTokenExpiry(t *testing.T) {
+	reg := registry.New()
+
+	var (
+		mu      sync.Mutex
+		tokens  int
+		valid   string
+		uses    int
+		expired int
+	)
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if r.URL.Path == "/token" {
+			tokens++
+			valid = fmt.Sprintf("token-%d", tokens)
+			uses = 0
+			mu.Unlock()
+			w.Write([]byte(fmt.Sprintf(`{"token": %q}`, valid)))
+			return
+		}
+		ok := valid != "" && r.Header.Get("Authorization") == "Bearer "+valid
+		if ok {
+			// Each token is only good for a few requests.
+			if uses++; uses > 3 {
+				valid = ""
+				expired++
+				ok = false
+			}
+		}
+		mu.Unlock()
+
+		if !ok {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, s.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := mustNewTag(t, u.Host+"/test/expiry")
+
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(tag, img, WithAuth(&authn.Basic{Username: "foo", Password: "bar"}), WithJobs(1)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if expired == 0 {
+		t.Errorf("token never expired")
+	}
+
+	got, err := Image(tag, WithAuth(&authn.Basic{Username: "foo", Password: "bar"}))
+	if err != nil {
+		t.Fatalf("Image: %v", err)
+	}
+	if err := validate.Image(got); err != nil {
+		t.Errorf("validate.Image: %v", err)
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	reg := registry.New()
+
+	var (
+		mu  sync.Mutex
+		uas = map[string]string{}
+	)
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		uas[r.Method+" "+r.URL.Path] = r.Header.Get("User-Agent")
+		mu.Unlock()
+
+		if r.URL.Path == "/token" {
+			w.Write([]byte(`{"token": "secret"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, s.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := mustNewTag(t, u.Host+"/test/ua")
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := []Option{
+		WithAuth(&authn.Basic{Username: "foo", Password: "bar"}),
+		WithUserAgent("tool/1.0"),
+	}
+	if err := Write(tag, img, opts...); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	got, err := Image(tag, opts...)
+	if err != nil {
+		t.Fatalf("Image: %v", err)
+	}
+	if err := validate.Image(got); err != nil {
+		t.Errorf("validate.Image: %v", err)
+	}
+
+	for _, want := range []string{"GET /v2/", "GET /token", "PUT /v2/test/ua/manifests/latest"} {
+		if _, ok := uas[want]; !ok {
+			t.Errorf("no %s request", want)
+		}
+	}
+	for req, ua := range uas {
+		if want := "tool/1.0 go-containerregistry"; !strings.HasPrefix(ua, want) {
+			t.Errorf("%s: User-Agent; got %q, want prefix %q", req, ua, want)
+		}
+	}
+}
+
+func TestWriteChunkedResync(t *testing.T) {
+	const chunkSize = 1024
+	for _, tc := range []struct {
+		name string
+		// How much of the first chunk the registry gets before failing.
+		received int64
+		// Whether upload status responses omit the Range header.
+		noRange bool
+	}{{
+		name:    "missing Range means nothing received",
+		noRange: true,
+	}, {
+		name:     "416 after resending the first byte",
+		received: 1,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := registry.New()
+			var (
+				mu     sync.Mutex
+				failed bool
+				ranges []string
+				posts  int
+			)
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				upload := strings.Contains(r.URL.Path, "/blobs/uploads/") && !strings.HasSuffix(r.URL.Path, "/uploads/")
+				cr := r.Header.Get("Content-Range")
+				mu.Lock()
+				fail := r.Method == http.MethodPatch && cr != "" && !failed
+				failed = failed || fail
+				if cr != "" {
+					ranges = append(ranges, cr)
+				}
+				if r.Method == http.MethodPost {
+					posts++
+				}
+				mu.Unlock()
+
+				if fail {
+					r.Body = io.NopCloser(io.LimitReader(r.Body, tc.received))
+					r.ContentLength = tc.received
+					reg.ServeHTTP(httptest.NewRecorder(), r)
+					if tc.noRange {
+						// Make the client ask for the upload status.
+						w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+						return
+					}
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				rec := httptest.NewRecorder()
+				reg.ServeHTTP(rec, r)
+				for k, v := range rec.Header() {
+					w.Header()[k] = v
+				}
+				switch {
+				case upload && r.Method == http.MethodGet && tc.noRange:
+					w.Header().Del("Range")
+				case rec.Code == http.StatusRequestedRangeNotSatisfiable:
+					// Say what we have, as the distribution spec asks.
+					w.Header().Set("Location", r.URL.Path)
+					w.Header().Set("Range", fmt.Sprintf("0-%d", tc.received-1))
+				}
+				w.WriteHeader(rec.Code)
+				io.Copy(w, rec.Body)
+			}))
+			defer s.Close()
+			u, err := url.Parse(s.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			layer, err := random.Layer(2*chunkSize, types.OCIUncompressedLayer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			img, err := mutate.AppendLayers(empty.Image, layer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tag := mustNewTag(t, u.Host+"/test/resync")
+			if err := Write(tag, img, WithChunkSize(chunkSize), WithRetryBackoff(Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3})); err != nil {
+				t.Fatalf("Write: %v (ranges %v)", err, ranges)
+			}
+			got, err := Image(tag)
+			if err != nil {
+				t.Fatalf("Image: %v", err)
+			}
+			if err := validate.Image(got); err != nil {
+				t.Errorf("validate.Image: %v", err)
+			}
+			// One upload for the layer and one for the config: the layer's
+			// upload was resumed rather than restarted.
+			if posts != 2 {
+				t.Errorf("upload POST requests; got %d, want 2 (ranges %v)", posts, ranges)
+			}
+		})
+	}
+}
+
+func TestWriteChunked(t *testing.T) {
+	const chunkSize = 1024
+	reg := registry.New()
+
+	var (
+		mu         sync.Mutex
+		ranges     []string
+		monolithic int
+		failed     bool
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch {
+			reg.ServeHTTP(w, r)
+			return
+		}
+		cr := r.Header.Get("Content-Range")
+		mu.Lock()
+		if cr == "" {
+			monolithic++
+		} else {
+			ranges = append(ranges, cr)
+		}
+		fail := cr != "" && !failed && len(ranges) == 2
+		if fail {
+			failed = true
+		}
+		mu.Unlock()
+
+		if fail {
+			// Simulate the connection dropping halfway through the chunk:
+			// the registry gets half of it, the client gets an error.
+			half := r.ContentLength / 2
+			r.Body = io.NopCloser(io.LimitReader(r.Body, half))
+			r.ContentLength = half
+			reg.ServeHTTP(httptest.NewRecorder(), r)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := mustNewTag(t, u.Host+"/test/chunked")
+	opts := []Option{
+		WithChunkSize(chunkSize),
+		WithRetryBackoff(Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3}),
+	}
+
+	layer, err := random.Layer(4*chunkSize, types.OCIUncompressedLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := layer.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Write(tag, img, opts...); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	got, err := Image(tag)
+	if err != nil {
+		t.Fatalf("Image: %v", err)
+	}
+	if err := validate.Image(got); err != nil {
+		t.Errorf("validate.Image: %v", err)
+	}
+
+	// One PATCH per chunk, plus one to finish the chunk that failed.
+	chunks := int((size + chunkSize - 1) / chunkSize)
+	if got, want := len(ranges), chunks+1; got != want {
+		t.Fatalf("chunk PATCH requests; got %d, want %d (%v)", got, want, ranges)
+	}
+	// The retry resumes halfway through the second chunk.
+	if got, want := ranges[2], fmt.Sprintf("%d-%d", chunkSize+chunkSize/2, 2*chunkSize-1); got != want {
+		t.Errorf("resumed Content-Range; got %q, want %q", got, want)
+	}
+	// The config blob is smaller than a chunk.
+	if got, want := monolithic, 1; got != want {
+		t.Errorf("monolithic PATCH requests; got %d, want %d", got, want)
+	}
+
+	// A chunk size of 0 keeps the single-request upload.
+	ranges, monolithic = nil, 0
+	img, err = random.Image(4*chunkSize, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(mustNewTag(t, u.Host+"/test/monolithic"), img, WithChunkSize(0)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if len(ranges) != 0 {
+		t.Errorf("chunk PATCH requests; got %v, want none", ranges)
+	}
+	// The config blob and the layer.
+	if got, want := monolithic, 2; got != want {
+		t.Errorf("monolithic PATCH requests; got %d, want %d", got, want)
+	}
+
+	// Streaming layers are chunked, since their size isn't known up front.
+	ranges = nil
+	sl := stream.NewLayer(io.NopCloser(strings.NewReader(strings.Repeat("streaming ", chunkSize))))
+	img, err = mutate.AppendLayers(empty.Image, sl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(mustNewTag(t, u.Host+"/test/stream"), img, opts...); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if len(ranges) == 0 {
+		t.Error("streaming layer was not chunked")
+	}
+
+	if _, err := makeOptions(tag.Context(), WithChunkSize(-1)); err == nil {
+		t.Error("WithChunkSize(-1): expected error")
+	}
+}
+
+func TestWriteLayerStandalone(t *testing.T) {
+	reg := registry.New()
+	var mu sync.Mutex
+	var heads, posts []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		switch r.Method {
+		case http.MethodHead:
+			heads = append(heads, r.URL.Path)
+		case http.MethodPost:
+			posts = append(posts, r.URL.RequestURI())
+		}
+		mu.Unlock()
+		// The in-memory registry shares blobs across repositories; pretend
+		// it doesn't, so that test/dst has to mount.
+		if r.Method == http.MethodHead && strings.HasPrefix(r.URL.Path, "/v2/test/dst/blobs/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := l.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := name.NewRepository(u.Host + "/test/src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteLayer(src, l); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Layer(src.Digest(h.String())); err != nil {
+		t.Fatalf("Layer() = %v", err)
+	}
+
+	// Writing it again is just an existence check.
+	heads, posts = nil, nil
+	if err := WriteLayer(src, l); err != nil {
+		t.Fatal(err)
+	}
+	if len(heads) != 1 || len(posts) != 0 {
+		t.Errorf("rewrite: got HEADs %v and POSTs %v, want a single HEAD", heads, posts)
+	}
+
+	// A MountableLayer is mounted from its source repository.
+	dst, err := name.NewRepository(u.Host + "/test/dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ml := &MountableLayer{Layer: l, Reference: src.Digest(h.String())}
+	heads, posts = nil, nil
+	if err := WriteLayer(dst, ml); err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 {
+		t.Fatalf("mount: got POSTs %v, want one", posts)
+	}
+	if want := "from=test%2Fsrc"; !strings.Contains(posts[0], want) {
+		t.Errorf("mount: got POST %s, want %s", posts[0], want)
+	}
+}
+
+func TestWriteMountsConvertedLayers(t *testing.T) {
+	reg := registry.New()
+	var mu sync.Mutex
+	var posts []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if r.Method == http.MethodPost {
+			posts = append(posts, r.URL.RequestURI())
+		}
+		mu.Unlock()
+		// As above, make test/dst mount rather than find the shared blobs.
+		if r.Method == http.MethodHead && strings.HasPrefix(r.URL.Path, "/v2/test/dst/blobs/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := name.ParseReference(u.Host + "/test/src:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := name.ParseReference(u.Host + "/test/dst:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(src, img); err != nil {
+		t.Fatal(err)
+	}
+	pulled, err := Image(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Annotating the Docker image converts its layers' media types to OCI.
+	annotated := mutate.Annotations(pulled, map[string]string{"foo": "bar"}).(v1.Image)
+	posts = nil
+	if err := Write(dst, annotated); err != nil {
+		t.Fatal(err)
+	}
+
+	layers, err := annotated.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range layers {
+		h, err := l.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		mounted := false
+		for _, p := range posts {
+			if strings.Contains(p, "mount="+url.QueryEscape(h.String())) && strings.Contains(p, "from=test%2Fsrc") {
+				mounted = true
+			}
+		}
+		if !mounted {
+			t.Errorf("layer %s wasn't mounted from test/src: got POSTs %v", h, posts)
+		}
+	}
+}
+
+func TestBasicAuthInsecureRegistry(t *testing.T) {
+	reg := registry.New()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "foo" || pass != "bar" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Credentials can be sent to loopback addresses over HTTP.
+	ref, err := name.ParseReference(u.Host+"/test/insecure", name.Insecure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := WithAuth(&authn.Basic{Username: "foo", Password: "bar"})
+	if err := Write(ref, img, auth); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	got, err := Image(ref, auth)
+	if err != nil {
+		t.Fatalf("Image: %v", err)
+	}
+	if err := validate.Image(got); err != nil {
+		t.Errorf("validate.Image: %v", err)
+	}
+
+	if _, err := Image(ref); err == nil {
+		t.Error("Image() without credentials should fail")
+	}
+
+	// Anything else needs WithAllowInsecureAuth. Resolve a non-loopback name
+	// to the test server.
+	tr := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, u.Host)
+		},
+	}
+	remoteRef, err := name.ParseReference("registry.example.com:"+u.Port()+"/test/insecure", name.Insecure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Image(remoteRef, auth, WithTransport(tr)); err == nil || !strings.Contains(err.Error(), "plain HTTP") {
+		t.Errorf("Image() without WithAllowInsecureAuth = %v, want plain HTTP error", err)
+	}
+	got, err = Image(remoteRef, auth, WithTransport(tr), WithAllowInsecureAuth())
+	if err != nil {
+		t.Fatalf("Image() with WithAllowInsecureAuth = %v", err)
+	}
+	if err := validate.Image(got); err != nil {
+		t.Errorf("validate.Image: %v", err)
+	}
+
+	// The same goes for hosts made insecure by WithInsecureHosts.
+	secureRef, err := name.ParseReference("registry.example.com:" + u.Port() + "/test/insecure")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := WithInsecureHosts([]string{"registry.example.com"})
+	if _, err := Image(secureRef, auth, WithTransport(tr), hosts); err == nil || !strings.Contains(err.Error(), "plain HTTP") {
+		t.Errorf("Image() with WithInsecureHosts = %v, want plain HTTP error", err)
+	}
+	if _, err := Image(secureRef, auth, WithTransport(tr), hosts, WithAllowInsecureAuth()); err != nil {
+		t.Errorf("Image() with WithInsecureHosts and WithAllowInsecureAuth = %v", err)
+	}
+}
+
+func TestInsecureRegistryDropsUnneededAuth(t *testing.T) {
+	var mu sync.Mutex
+	var authorized []string
+	reg := registry.New()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h := r.Header.Get("Authorization"); h != "" {
+			mu.Lock()
+			authorized = append(authorized, r.URL.Path)
+			mu.Unlock()
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, u.Host)
+		},
+	}
+	ref, err := name.ParseReference("registry.example.com:"+u.Port()+"/test/anonymous", name.Insecure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The registry doesn't ask for credentials, so they're just not sent.
+	auth := WithAuth(&authn.Basic{Username: "foo", Password: "bar"})
+	if err := Write(ref, img, auth, WithTransport(tr)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if len(authorized) != 0 {
+		t.Errorf("sent credentials over plain HTTP to %v", authorized)
+	}
+}
+
+func TestWriteWithManifestMediaType(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// random.Image is Docker schema 2; make an OCI image to push.
+	base, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oci := mutate.MediaType(base, types.OCIManifestSchema1)
+
+	for _, tc := range []struct {
+		img       v1.Image
+		mt        types.MediaType
+		wantLayer types.MediaType
+	}{
+		{oci, types.DockerManifestSchema2, types.DockerLayer},
+		{base, types.OCIManifestSchema1, types.OCILayer},
+	} {
+		tag := mustNewTag(t, fmt.Sprintf("%s/test/convert:%s", u.Host, strings.ReplaceAll(path.Base(string(tc.mt)), "+", "-")))
+		if err := Write(tag, tc.img, WithManifestMediaType(tc.mt)); err != nil {
+			t.Fatalf("Write(%s): %v", tc.mt, err)
+		}
+		got, err := Image(tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := validate.Image(got); err != nil {
+			t.Errorf("validate.Image: %v", err)
+		}
+		m, err := got.Manifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.MediaType != tc.mt {
+			t.Errorf("MediaType: got %s, want %s", m.MediaType, tc.mt)
+		}
+		for _, l := range m.Layers {
+			if l.MediaType != tc.wantLayer {
+				t.Errorf("layer MediaType: got %s, want %s", l.MediaType, tc.wantLayer)
+			}
+		}
+	}
+
+	// OCI-only features can't be converted to Docker.
+	tag := mustNewTag(t, u.Host+"/test/convert:annotated")
+	annotated := mutate.Annotations(base, map[string]string{"foo": "bar"}).(v1.Image)
+	if err := Write(tag, annotated, WithManifestMediaType(types.DockerManifestSchema2)); err == nil || !strings.Contains(err.Error(), "annotations") {
+		t.Errorf("Write(annotated): got %v, want error about annotations", err)
+	}
+
+	if err := Write(tag, base, WithManifestMediaType(types.OCIImageIndex)); err == nil {
+		t.Error("WithManifestMediaType(index): expected error")
+	}
+}
+
+func TestWriteDryRun(t *testing.T) {
+	var writes int64
+	reg := registry.New()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			atomic.AddInt64(&writes, 1)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(mustNewTag(t, u.Host+"/test/dryrun:base"), base); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := mutate.AppendLayers(base, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	atomic.StoreInt64(&writes, 0)
+	var missing []v1.Descriptor
+	tag := mustNewTag(t, u.Host+"/test/dryrun:new")
+	if err := Write(tag, img, WithDryRun(func(desc v1.Descriptor) {
+		missing = append(missing, desc)
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt64(&writes); got != 0 {
+		t.Errorf("dry run made %d write requests", got)
+	}
+
+	// Only the new layer and config are missing.
+	want := map[v1.Hash]int64{
+		m.Layers[2].Digest: m.Layers[2].Size,
+		m.Config.Digest:    m.Config.Size,
+	}
+	got := map[v1.Hash]int64{}
+	for _, desc := range missing {
+		got[desc.Digest] = desc.Size
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("missing: (-want +got) %s", diff)
+	}
+
+	if _, err := Head(tag); err == nil {
+		t.Errorf("dry run wrote %s", tag)
+	}
+
+	sl := stream.NewLayer(io.NopCloser(strings.NewReader("streamed")))
+	simg, err := mutate.AppendLayers(base, sl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(tag, simg, WithDryRun(func(v1.Descriptor) {})); err == nil {
+		t.Error("dry run of a stream.Layer: expected error")
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"hash"
 	"io"
 	"os"
@@ -50,6 +51,7 @@ type Layer struct {
 	digest, diffID *v1.Hash
 	size           int64
 	mediaType      types.MediaType
+	expected       *v1.Hash
 }
 
 var _ v1.Layer = (*Layer)(nil)
@@ -71,6 +73,15 @@ func WithMediaType(mt types.MediaType) LayerOption {
 	}
 }
 
+// WithExpectedDigest is a functional option that makes the layer check its
+// computed Digest against h once the stream has been fully consumed. If they
+// don't match, the final Read of Compressed returns an error instead of io.EOF.
+func WithExpectedDigest(h v1.Hash) LayerOption {
+	return func(l *Layer) {
+		l.expected = &h
+	}
+}
+
 // NewLayer creates a Layer from an io.ReadCloser.
 func NewLayer(rc io.ReadCloser, opts ...LayerOption) *Layer {
 	layer := &Layer{
@@ -158,6 +169,18 @@ func (l *Layer) finalize(uncompressed, compressed hash.Hash, size int64) error {
 	return nil
 }
 
+// verify checks the digest of the compressed stream against the expected
+// digest, if any.
+func (l *Layer) verify(compressed hash.Hash) error {
+	if l.expected == nil {
+		return nil
+	}
+	if got := hex.EncodeToString(compressed.Sum(nil)); got != l.expected.Hex {
+		return fmt.Errorf("stream layer digest mismatch: got sha256:%s, want %s", got, l.expected)
+	}
+	return nil
+}
+
 type compressedReader struct {
 	pr     io.Reader
 	closer func() error
@@ -248,6 +271,13 @@ func newCompressedReader(l *Layer) (*compressedReader, error) {
 			return
 		}
 
+		// Fail the final read if the digest doesn't match what was expected.
+		if err := l.verify(zh); err != nil {
+			close(doneDigesting)
+			pw.CloseWithError(err)
+			return
+		}
+
 		// Notify closer that digests are done being written.
 		close(doneDigesting)
 
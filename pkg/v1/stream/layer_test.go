@@ -222,6 +222,51 @@ func TestConsumed(t *testing.T) {
 	}
 }
 
+func TestExpectedDigest(t *testing.T) {
+	// Compute the digest of the stream once.
+	l := NewLayer(io.NopCloser(strings.NewReader("hello")))
+	rc, err := l.Compressed()
+	if err != nil {
+		t.Fatalf("Compressed: %v", err)
+	}
+	if _, err := io.Copy(io.Discard, rc); err != nil {
+		t.Fatalf("Error reading contents: %v", err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	want, err := l.Digest()
+	if err != nil {
+		t.Fatalf("Digest: %v", err)
+	}
+
+	bad, err := v1.NewHash("sha256:0000000000000000000000000000000000000000000000000000000000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		expected v1.Hash
+		wantErr  bool
+	}{{
+		expected: want,
+	}, {
+		expected: bad,
+		wantErr:  true,
+	}} {
+		l := NewLayer(io.NopCloser(strings.NewReader("hello")), WithExpectedDigest(tc.expected))
+		rc, err := l.Compressed()
+		if err != nil {
+			t.Fatalf("Compressed: %v", err)
+		}
+		_, err = io.Copy(io.Discard, rc)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("reading with expected digest %s; got err %v, wantErr %t", tc.expected, err, tc.wantErr)
+		}
+		rc.Close()
+	}
+}
+
 func TestCloseTextStreamBeforeConsume(t *testing.T) {
 	// Create stream layer from tar pipe
 	l := NewLayer(io.NopCloser(strings.NewReader("hello")))
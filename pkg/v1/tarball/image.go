@@ -223,6 +223,10 @@ func extractFileFromTar(opener Opener, filePath string) (io.ReadCloser, error) {
 	if err != nil {
 		return nil, err
 	}
+	if eo, ok := f.(entryOpener); ok {
+		f.Close()
+		return eo.openEntry(filePath)
+	}
 	close := true
 	defer func() {
 		if close {
@@ -15,8 +15,11 @@
 package tarball
 
 import (
+	"errors"
 	"io"
+	"os"
 	"testing"
+	"testing/iotest"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -137,3 +140,117 @@ func TestLoadManifest(t *testing.T) {
 		t.Fatalf("get nothing")
 	}
 }
+
+func TestImageFromReader(t *testing.T) {
+	for _, tc := range []struct {
+		path string
+		tag  string
+	}{{
+		path: "testdata/test_image_1.tar",
+	}, {
+		path: "testdata/test_bundle.tar",
+		tag:  "test_image_1",
+	}} {
+		t.Run(tc.path, func(t *testing.T) {
+			var tag *name.Tag
+			if tc.tag != "" {
+				tg, err := name.NewTag(tc.tag, name.WeakValidation)
+				if err != nil {
+					t.Fatalf("Error creating tag: %v", err)
+				}
+				tag = &tg
+			}
+
+			want, err := ImageFromPath(tc.path, tag)
+			if err != nil {
+				t.Fatalf("ImageFromPath() = %v", err)
+			}
+
+			f, err := os.Open(tc.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			// Hide f's Seek and ReadAt methods.
+			got, closer, err := ImageFromReader(struct{ io.Reader }{f}, tag)
+			if err != nil {
+				t.Fatalf("ImageFromReader() = %v", err)
+			}
+			defer closer.Close()
+
+			if err := validate.Image(got); err != nil {
+				t.Errorf("Validate() = %v", err)
+			}
+
+			wantDigest, err := want.Digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotDigest, err := got.Digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotDigest != wantDigest {
+				t.Errorf("Digest() = %s, want %s", gotDigest, wantDigest)
+			}
+		})
+	}
+}
+
+func TestImageFromReaderError(t *testing.T) {
+	f, err := os.Open("testdata/test_image_1.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	want := errors.New("broken pipe")
+	r := io.MultiReader(io.LimitReader(f, 1024), iotest.ErrReader(want))
+	if _, _, err := ImageFromReader(r, nil); !errors.Is(err, want) {
+		t.Errorf("ImageFromReader() = %v, want %v", err, want)
+	}
+}
+
+func TestImageFromReaderClose(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	f, err := os.Open("testdata/test_image_1.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	img, closer, err := ImageFromReader(f, nil)
+	if err != nil {
+		t.Fatalf("ImageFromReader() = %v", err)
+	}
+	if err := validate.Image(img); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spilled, err := os.ReadDir(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spilled) != 1 {
+		t.Errorf("got %d spill files, want 1", len(spilled))
+	}
+
+	if err := closer.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if spilled, err := os.ReadDir(tmp); err != nil {
+		t.Fatal(err)
+	} else if len(spilled) != 0 {
+		t.Errorf("Close() left %d spill files", len(spilled))
+	}
+	if _, err := layers[0].Compressed(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Compressed() after Close() = %v, want %v", err, os.ErrClosed)
+	}
+}
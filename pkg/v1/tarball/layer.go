@@ -27,6 +27,7 @@ import (
 	comp "github.com/google/go-containerregistry/internal/compression"
 	gestargz "github.com/google/go-containerregistry/internal/estargz"
 	ggzip "github.com/google/go-containerregistry/internal/gzip"
+	"github.com/google/go-containerregistry/internal/verify"
 	"github.com/google/go-containerregistry/internal/zstd"
 	"github.com/google/go-containerregistry/pkg/compression"
 	"github.com/google/go-containerregistry/pkg/logs"
@@ -45,6 +46,7 @@ type layer struct {
 	annotations        map[string]string
 	estgzopts          []estargz.Option
 	mediaType          types.MediaType
+	verify             bool
 }
 
 // Descriptor implements partial.withDescriptor.
@@ -116,7 +118,9 @@ func WithCompression(comp compression.Compression) LayerOption {
 }
 
 // WithCompressionLevel is a functional option for overriding the default
-// compression level used for compressing uncompressed tarballs.
+// compression level used for compressing uncompressed tarballs. For gzip, this
+// is passed to gzip.NewWriterLevel, and LayerFromOpener returns an error if it
+// is out of range.
 func WithCompressionLevel(level int) LayerOption {
 	return func(l *layer) {
 		l.compressionLevel = level
@@ -157,6 +161,14 @@ func WithCompressedCaching(l *layer) {
 	}
 }
 
+// WithVerify is a functional option that makes the layer verify that its
+// contents match its Digest and DiffID as they are read. This is only
+// meaningful for PrecomputedLayer, where the hashes are provided by the caller
+// rather than computed from the contents.
+func WithVerify(l *layer) {
+	l.verify = true
+}
+
 // WithEstargzOptions is a functional option that allow the caller to pass
 // through estargz.Options to the underlying compression layer.  This is
 // only meaningful when estargz is enabled.
@@ -213,10 +225,93 @@ func LayerFromFile(path string, opts ...LayerOption) (v1.Layer, error) {
 	return LayerFromOpener(opener, opts...)
 }
 
+// PrecomputedLayer returns a v1.Layer given the path to an already-compressed
+// (gzip or zstd) layer blob, along with its digest, diffID and media type.
+//
+// Unlike LayerFromFile, the provided hashes are trusted and the file is not
+// read to compute them; Compressed() serves the file as-is. To check the
+// contents against the hashes as they're read, pass WithVerify.
+func PrecomputedLayer(path string, digest, diffID v1.Hash, mediaType types.MediaType, opts ...LayerOption) (v1.Layer, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	opener := func() (io.ReadCloser, error) {
+		return os.Open(path)
+	}
+	cp, err := comp.GetCompression(opener)
+	if err != nil {
+		return nil, err
+	}
+
+	layer := &layer{
+		digest:           digest,
+		diffID:           diffID,
+		size:             fi.Size(),
+		compression:      cp,
+		annotations:      make(map[string]string, 1),
+		mediaType:        mediaType,
+		compressedopener: opener,
+	}
+
+	switch cp {
+	case compression.GZip:
+		layer.uncompressedopener = func() (io.ReadCloser, error) {
+			urc, err := layer.compressedopener()
+			if err != nil {
+				return nil, err
+			}
+			return ggzip.UnzipReadCloser(urc)
+		}
+	case compression.ZStd:
+		layer.uncompressedopener = func() (io.ReadCloser, error) {
+			urc, err := layer.compressedopener()
+			if err != nil {
+				return nil, err
+			}
+			return zstd.UnzipReadCloser(urc)
+		}
+	default:
+		return nil, fmt.Errorf("precomputed layer %s is not compressed", path)
+	}
+
+	for _, opt := range opts {
+		opt(layer)
+	}
+
+	if layer.verify {
+		compressed, uncompressed := layer.compressedopener, layer.uncompressedopener
+		layer.compressedopener = func() (io.ReadCloser, error) {
+			rc, err := compressed()
+			if err != nil {
+				return nil, err
+			}
+			return verify.ReadCloser(rc, layer.size, layer.digest)
+		}
+		layer.uncompressedopener = func() (io.ReadCloser, error) {
+			rc, err := uncompressed()
+			if err != nil {
+				return nil, err
+			}
+			return verify.ReadCloser(rc, verify.SizeUnknown, layer.diffID)
+		}
+	}
+
+	return layer, nil
+}
+
 // LayerFromOpener returns a v1.Layer given an Opener function.
 // The Opener may return either an uncompressed tarball (common),
 // or a compressed tarball (uncommon).
 //
+// Compressed tarballs are detected by sniffing the Opener's first bytes, so no
+// option is needed for them: the layer's Compressed returns the Opener's bytes
+// verbatim (and its digest and size are computed over them), and only
+// Uncompressed decompresses them. Compression options like
+// WithCompressionLevel only apply to uncompressed tarballs. Zstd tarballs
+// default to the types.OCILayerZStd media type, and everything else to
+// types.DockerLayer, unless WithMediaType is passed.
+//
 // When using this in conjunction with something like remote.Write
 // the uncompressed path may end up gzipping things multiple times:
 //  1. Compute the layer SHA256
@@ -252,6 +347,8 @@ func LayerFromOpener(opener Opener, opts ...LayerOption) (v1.Layer, error) {
 			return ggzip.UnzipReadCloser(urc)
 		}
 	case compression.ZStd:
+		layer.compression = compression.ZStd
+		layer.mediaType = types.OCILayerZStd
 		layer.compressedopener = opener
 		layer.uncompressedopener = func() (io.ReadCloser, error) {
 			urc, err := opener()
@@ -280,6 +377,10 @@ func LayerFromOpener(opener Opener, opts ...LayerOption) (v1.Layer, error) {
 		opt(layer)
 	}
 
+	if layer.compression == compression.GZip && (layer.compressionLevel < gzip.HuffmanOnly || layer.compressionLevel > gzip.BestCompression) {
+		return nil, fmt.Errorf("invalid gzip compression level %d: must be between %d and %d", layer.compressionLevel, gzip.HuffmanOnly, gzip.BestCompression)
+	}
+
 	// Warn if media type does not match compression
 	var mediaTypeMismatch = false
 	switch layer.compression {
@@ -287,10 +388,13 @@ func LayerFromOpener(opener Opener, opts ...LayerOption) (v1.Layer, error) {
 		mediaTypeMismatch =
 			layer.mediaType != types.OCILayer &&
 				layer.mediaType != types.OCIRestrictedLayer &&
-				layer.mediaType != types.DockerLayer
+				layer.mediaType != types.DockerLayer &&
+				layer.mediaType != types.DockerForeignLayer
 
 	case compression.ZStd:
-		mediaTypeMismatch = layer.mediaType != types.OCILayerZStd
+		mediaTypeMismatch =
+			layer.mediaType != types.OCILayerZStd &&
+				layer.mediaType != types.OCIRestrictedLayerZStd
 	}
 
 	if mediaTypeMismatch {
@@ -337,6 +441,29 @@ func computeDigest(opener Opener) (v1.Hash, int64, error) {
 	return v1.SHA256(rc)
 }
 
+// DiffIDFromCompressed returns the diffID of the gzip-compressed layer read
+// from rc, i.e. the sha256 digest of its uncompressed contents. The contents
+// are decompressed and hashed as they're read, without being stored. This
+// can be used to check a compressed blob against a config's diff_ids.
+//
+// rc is always closed. An error is returned if rc isn't valid gzip,
+// including if it's truncated or its checksum doesn't match.
+func DiffIDFromCompressed(rc io.ReadCloser) (v1.Hash, error) {
+	urc, err := ggzip.UnzipReadCloser(rc)
+	if err != nil {
+		rc.Close()
+		return v1.Hash{}, fmt.Errorf("decompressing layer: %w", err)
+	}
+	diffID, _, err := v1.SHA256(urc)
+	if cerr := urc.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return v1.Hash{}, fmt.Errorf("decompressing layer: %w", err)
+	}
+	return diffID, nil
+}
+
 func computeDiffID(opener Opener) (v1.Hash, error) {
 	rc, err := opener()
 	if err != nil {
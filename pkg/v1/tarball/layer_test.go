@@ -23,6 +23,7 @@ import (
 
 	"github.com/containerd/stargz-snapshotter/estargz"
 	"github.com/google/go-containerregistry/internal/compare"
+	"github.com/google/go-containerregistry/internal/zstd"
 	"github.com/google/go-containerregistry/pkg/compression"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/types"
@@ -98,6 +99,12 @@ func TestLayerFromFile(t *testing.T) {
 	if defaultDigest.String() == zstdDigest1.String() {
 		t.Errorf("expected digests to differ: %s", defaultDigest.String())
 	}
+
+	for _, level := range []int{gzip.HuffmanOnly - 1, gzip.BestCompression + 1} {
+		if _, err := LayerFromFile("testdata/content.tar", WithCompressionLevel(level)); err == nil {
+			t.Errorf("LayerFromFile() with gzip level %d; expected error", level)
+		}
+	}
 }
 
 func TestLayerFromFileEstargz(t *testing.T) {
@@ -263,6 +270,113 @@ func TestLayerFromOpenerReader(t *testing.T) {
 	}
 }
 
+func TestLayerFromOpenerCompressed(t *testing.T) {
+	setupFixtures(t)
+	defer teardownFixtures(t)
+
+	gzBytes, err := os.ReadFile("gzip_content.tgz")
+	if err != nil {
+		t.Fatalf("Unable to read tar file: %v", err)
+	}
+	layer, err := LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(gzBytes)), nil
+	})
+	if err != nil {
+		t.Fatalf("Unable to create layer from compressed tar file: %v", err)
+	}
+
+	rc, err := layer.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, gzBytes) {
+		t.Error("Compressed() should return the opener's bytes verbatim")
+	}
+
+	wantDigest, wantSize, err := v1.SHA256(bytes.NewReader(gzBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := layer.Digest(); err != nil {
+		t.Fatal(err)
+	} else if d != wantDigest {
+		t.Errorf("Digest() = %s, want %s", d, wantDigest)
+	}
+	if sz, err := layer.Size(); err != nil {
+		t.Fatal(err)
+	} else if sz != wantSize {
+		t.Errorf("Size() = %d, want %d", sz, wantSize)
+	}
+	if mt, err := layer.MediaType(); err != nil {
+		t.Fatal(err)
+	} else if mt != types.DockerLayer {
+		t.Errorf("MediaType() = %s, want %s", mt, types.DockerLayer)
+	}
+	if err := validate.Layer(layer); err != nil {
+		t.Errorf("validate.Layer: %v", err)
+	}
+}
+
+func TestLayerFromOpenerZstd(t *testing.T) {
+	tarBytes, err := os.ReadFile("testdata/content.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zstdBytes, err := io.ReadAll(zstd.ReadCloser(io.NopCloser(bytes.NewReader(tarBytes))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer, err := LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(zstdBytes)), nil
+	})
+	if err != nil {
+		t.Fatalf("Unable to create layer from zstd tar file: %v", err)
+	}
+
+	if mt, err := layer.MediaType(); err != nil {
+		t.Fatal(err)
+	} else if mt != types.OCILayerZStd {
+		t.Errorf("MediaType() = %s, want %s", mt, types.OCILayerZStd)
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, zstdBytes) {
+		t.Error("Compressed() should return the opener's bytes verbatim")
+	}
+	wantDiffID, _, err := v1.SHA256(bytes.NewReader(tarBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := layer.DiffID(); err != nil {
+		t.Fatal(err)
+	} else if d != wantDiffID {
+		t.Errorf("DiffID() = %s, want %s", d, wantDiffID)
+	}
+
+	// An explicit media type still wins.
+	layer, err = LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(zstdBytes)), nil
+	}, WithMediaType(types.OCIRestrictedLayerZStd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt, err := layer.MediaType(); err != nil {
+		t.Fatal(err)
+	} else if mt != types.OCIRestrictedLayerZStd {
+		t.Errorf("MediaType() = %s, want %s", mt, types.OCIRestrictedLayerZStd)
+	}
+}
+
 func TestWithMediaType(t *testing.T) {
 	setupFixtures(t)
 	defer teardownFixtures(t)
@@ -332,6 +446,72 @@ func TestLayerFromReader(t *testing.T) {
 	}
 }
 
+func TestPrecomputedLayer(t *testing.T) {
+	setupFixtures(t)
+	defer teardownFixtures(t)
+
+	want, err := LayerFromFile("gzip_content.tgz")
+	if err != nil {
+		t.Fatalf("Unable to create layer from tar file: %v", err)
+	}
+	digest, err := want.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffID, err := want.DiffID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opts := range [][]LayerOption{nil, {WithVerify}} {
+		got, err := PrecomputedLayer("gzip_content.tgz", digest, diffID, types.DockerLayer, opts...)
+		if err != nil {
+			t.Fatalf("PrecomputedLayer: %v", err)
+		}
+		if err := compare.Layers(got, want); err != nil {
+			t.Errorf("compare.Layers: %v", err)
+		}
+		if err := validate.Layer(got); err != nil {
+			t.Errorf("validate.Layer: %v", err)
+		}
+	}
+
+	// The provided hashes are trusted unless WithVerify is passed.
+	bad, err := v1.NewHash("sha256:0000000000000000000000000000000000000000000000000000000000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := PrecomputedLayer("gzip_content.tgz", bad, bad, types.DockerLayer)
+	if err != nil {
+		t.Fatalf("PrecomputedLayer: %v", err)
+	}
+	if got, err := l.Digest(); err != nil || got != bad {
+		t.Errorf("Digest(); got %v, %v, want %v", got, err, bad)
+	}
+
+	l, err = PrecomputedLayer("gzip_content.tgz", bad, bad, types.DockerLayer, WithVerify)
+	if err != nil {
+		t.Fatalf("PrecomputedLayer: %v", err)
+	}
+	for name, open := range map[string]func() (io.ReadCloser, error){
+		"Compressed":   l.Compressed,
+		"Uncompressed": l.Uncompressed,
+	} {
+		rc, err := open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.Copy(io.Discard, rc); err == nil {
+			t.Errorf("%s() with WithVerify and bad hashes; expected error", name)
+		}
+		rc.Close()
+	}
+
+	if _, err := PrecomputedLayer("testdata/content.tar", digest, diffID, types.DockerLayer); err == nil {
+		t.Error("PrecomputedLayer() of uncompressed file; expected error")
+	}
+}
+
 // Compression settings matter in order for the digest, size,
 // compressed assertions to pass
 //
@@ -379,3 +559,56 @@ func teardownFixtures(t *testing.T) {
 		t.Errorf("Error tearing down fixtures: %v", err)
 	}
 }
+
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestDiffIDFromCompressed(t *testing.T) {
+	setupFixtures(t)
+	defer teardownFixtures(t)
+
+	layer, err := LayerFromFile("gzip_content.tgz")
+	if err != nil {
+		t.Fatalf("Unable to create layer from compressed tar file: %v", err)
+	}
+	want, err := layer.DiffID()
+	if err != nil {
+		t.Fatalf("DiffID() = %v", err)
+	}
+	gzBytes, err := os.ReadFile("gzip_content.tgz")
+	if err != nil {
+		t.Fatalf("Unable to read tar file: %v", err)
+	}
+
+	rc := &closeTracker{Reader: bytes.NewReader(gzBytes)}
+	got, err := DiffIDFromCompressed(rc)
+	if err != nil {
+		t.Fatalf("DiffIDFromCompressed() = %v", err)
+	}
+	if got != want {
+		t.Errorf("DiffIDFromCompressed() = %s, want %s", got, want)
+	}
+	if !rc.closed {
+		t.Error("DiffIDFromCompressed() did not close its reader")
+	}
+
+	for name, b := range map[string][]byte{
+		"truncated":    gzBytes[:len(gzBytes)/2],
+		"uncompressed": []byte("not gzip at all"),
+	} {
+		rc := &closeTracker{Reader: bytes.NewReader(b)}
+		if _, err := DiffIDFromCompressed(rc); err == nil {
+			t.Errorf("%s: DiffIDFromCompressed() = nil, wanted error", name)
+		}
+		if !rc.closed {
+			t.Errorf("%s: DiffIDFromCompressed() did not close its reader", name)
+		}
+	}
+}
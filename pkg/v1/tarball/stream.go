@@ -0,0 +1,192 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tarball
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// maxBufferedSize bounds the size of the .json entries (the manifest and
+// config) that streamReader holds in memory.
+const maxBufferedSize = 4 << 20
+
+// ImageFromReader returns a v1.Image from a `docker save` tarball read from r,
+// e.g. a pipe, which doesn't need to be seekable or re-readable.
+//
+// r is consumed in a single forward pass, and only as far as is needed to
+// serve each request. The manifest and config are buffered in memory. Every
+// other entry (i.e. the layers) is spilled to a temporary file as it's
+// passed over, since layers are read more than once and may be accessed out
+// of order, so reading the whole image costs as much disk as the tarball.
+//
+// The returned io.Closer removes the spill file; the image must not be used
+// after it's closed.
+func ImageFromReader(r io.Reader, tag *name.Tag) (v1.Image, io.Closer, error) {
+	s := &streamReader{
+		tr:      tar.NewReader(r),
+		entries: map[string]*streamEntry{},
+	}
+	img, err := Image(s.open, tag)
+	if err != nil {
+		s.Close()
+		return nil, nil, err
+	}
+	return img, s, nil
+}
+
+// streamEntry is where streamReader has put the contents of a tar entry.
+type streamEntry struct {
+	data      []byte // set for entries buffered in memory
+	off, size int64  // otherwise, the entry's location in the spill file
+	link      string // set for links, the path of the linked entry
+}
+
+// streamReader indexes the entries of a tarball as it reads it forward.
+type streamReader struct {
+	mu        sync.Mutex
+	tr        *tar.Reader
+	entries   map[string]*streamEntry
+	spill     *os.File // created on first use
+	spillSize int64
+	err       error // sticky error from reading tr, io.EOF once it's exhausted
+}
+
+// open implements Opener. extractFileFromTar recognizes the result as an
+// entryOpener instead of scanning it as a tarball.
+func (s *streamReader) open() (io.ReadCloser, error) {
+	return streamOpener{s: s}, nil
+}
+
+// lookup reads forward until it has passed name, and returns its entry along
+// with the spill file its contents may be in.
+func (s *streamReader) lookup(name string) (*streamEntry, *os.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		if s.err != nil && s.err != io.EOF {
+			return nil, nil, s.err
+		}
+		if e, ok := s.entries[name]; ok {
+			return e, s.spill, nil
+		}
+		if s.err == io.EOF {
+			return nil, nil, fmt.Errorf("file %s not found in tar", name)
+		}
+		s.err = s.next()
+	}
+}
+
+// next indexes the next entry of the tarball, spilling it if it isn't
+// buffered in memory.
+func (s *streamReader) next() error {
+	hdr, err := s.tr.Next()
+	if err != nil {
+		return err
+	}
+	switch {
+	case hdr.Typeflag == tar.TypeSymlink || hdr.Typeflag == tar.TypeLink:
+		s.entries[hdr.Name] = &streamEntry{link: path.Join(path.Dir(hdr.Name), path.Clean(hdr.Linkname))}
+	case hdr.Typeflag != tar.TypeReg:
+		// Directories and the like can't be images' files.
+	case path.Ext(hdr.Name) == ".json" && hdr.Size <= maxBufferedSize:
+		data, err := io.ReadAll(s.tr)
+		if err != nil {
+			return err
+		}
+		s.entries[hdr.Name] = &streamEntry{data: data}
+	default:
+		if s.spill == nil {
+			f, err := os.CreateTemp("", "tarball-")
+			if err != nil {
+				return err
+			}
+			s.spill = f
+		}
+		n, err := io.Copy(s.spill, s.tr)
+		if err != nil {
+			return err
+		}
+		s.entries[hdr.Name] = &streamEntry{off: s.spillSize, size: n}
+		s.spillSize += n
+	}
+	return nil
+}
+
+// openEntry returns the contents of the entry at name, following links.
+func (s *streamReader) openEntry(name string) (io.ReadCloser, error) {
+	e, spill, err := s.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case e.link != "":
+		return s.openEntry(e.link)
+	case e.data != nil:
+		return io.NopCloser(bytes.NewReader(e.data)), nil
+	default:
+		return io.NopCloser(io.NewSectionReader(spill, e.off, e.size)), nil
+	}
+}
+
+// Close removes the spill file, if one was created.
+func (s *streamReader) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = os.ErrClosed
+	if s.spill == nil {
+		return nil
+	}
+	f := s.spill
+	s.spill = nil
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Remove(f.Name())
+}
+
+// entryOpener is implemented by the results of Openers that can open a
+// tarball's entries directly, rather than by scanning it from the start.
+type entryOpener interface {
+	openEntry(name string) (io.ReadCloser, error)
+}
+
+// streamOpener is what streamReader's Opener returns. It's only meaningful
+// as an entryOpener; reading it directly yields nothing.
+type streamOpener struct {
+	s *streamReader
+}
+
+func (o streamOpener) openEntry(name string) (io.ReadCloser, error) {
+	return o.s.openEntry(name)
+}
+
+func (streamOpener) Read([]byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (streamOpener) Close() error {
+	return nil
+}
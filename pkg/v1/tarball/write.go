@@ -98,6 +98,10 @@ func MultiRefWrite(refToImage map[name.Reference]v1.Image, w io.Writer, opts ...
 		}
 	}
 
+	if o.streaming {
+		return writeImagesToTarStreaming(refToImage, w, o)
+	}
+
 	size, mBytes, err := getSizeAndManifest(refToImage)
 	if err != nil {
 		return sendUpdateReturn(o, err)
@@ -175,16 +179,8 @@ func writeImagesToTar(refToImage map[name.Reference]v1.Image, m []byte, size int
 			if err != nil {
 				return sendProgressWriterReturn(pw, err)
 			}
-			// Munge the file name to appease ancient technology.
-			//
-			// tar assumes anything with a colon is a remote tape drive:
-			// https://www.gnu.org/software/tar/manual/html_section/tar_45.html
-			// Drop the algorithm prefix, e.g. "sha256:"
 			hex := d.Hex
-
-			// gunzip expects certain file extensions:
-			// https://www.gnu.org/software/gzip/manual/html_node/Overview.html
-			layerFiles[i] = fmt.Sprintf("%s.tar.gz", hex)
+			layerFiles[i] = layerFileName(d)
 
 			if _, ok := seenLayerDigests[hex]; ok {
 				continue
@@ -218,74 +214,226 @@ func writeImagesToTar(refToImage map[name.Reference]v1.Image, m []byte, size int
 	return nil
 }
 
-// calculateManifest calculates the manifest and optionally the size of the tar file
-func calculateManifest(refToImage map[name.Reference]v1.Image) (m Manifest, err error) {
+// writeImagesToTarStreaming writes the images to the tarball without first
+// computing the manifest.json and total size for every image. Each layer is
+// written as soon as its digest is known, and the manifest.json is assembled
+// along the way and written last.
+func writeImagesToTarStreaming(refToImage map[name.Reference]v1.Image, w io.Writer, o *writeOptions) error {
+	if w == nil {
+		return sendUpdateReturn(o, errors.New("must pass valid writer"))
+	}
 	imageToTags := dedupRefToImage(refToImage)
-
 	if len(imageToTags) == 0 {
-		return nil, errors.New("set of images is empty")
+		return sendUpdateReturn(o, errors.New("set of images is empty"))
+	}
+
+	tw := w
+	var pw *progressWriter
+
+	// The total size isn't known ahead of time, so it grows as entries are
+	// written.
+	if o.updates != nil {
+		pw = &progressWriter{
+			w:       w,
+			updates: o.updates,
+		}
+		tw = pw
 	}
+	grow := func(size int64) {
+		if pw != nil {
+			pw.size += calculateSingleFileInTarSize(size)
+		}
+	}
+
+	tf := tar.NewWriter(tw)
+	defer tf.Close()
+
+	var m Manifest
+	seenLayerDigests := make(map[v1.Hash]struct{})
 
 	for img, tags := range imageToTags {
-		cfgName, err := img.ConfigName()
+		cfgBlob, err := img.RawConfigFile()
 		if err != nil {
-			return nil, err
+			return sendProgressWriterReturn(pw, err)
+		}
+		cfgName, _, err := v1.SHA256(bytes.NewReader(cfgBlob))
+		if err != nil {
+			return sendProgressWriterReturn(pw, err)
+		}
+		grow(int64(len(cfgBlob)))
+		if err := writeTarEntry(tf, cfgName.String(), bytes.NewReader(cfgBlob), int64(len(cfgBlob))); err != nil {
+			return sendProgressWriterReturn(pw, err)
 		}
 
-		// Store foreign layer info.
-		layerSources := make(map[v1.Hash]v1.Descriptor)
-
-		// Write the layers.
+		// Write each layer we haven't seen yet as soon as its digest is
+		// known, and build this image's manifest.json entry along the way.
 		layers, err := img.Layers()
 		if err != nil {
-			return nil, err
+			return sendProgressWriterReturn(pw, err)
+		}
+		desc := Descriptor{
+			Config:       cfgName.String(),
+			RepoTags:     tags,
+			Layers:       make([]string, len(layers)),
+			LayerSources: map[v1.Hash]v1.Descriptor{},
 		}
-		layerFiles := make([]string, len(layers))
 		for i, l := range layers {
 			d, err := l.Digest()
 			if err != nil {
-				return nil, err
+				return sendProgressWriterReturn(pw, err)
 			}
-			// Munge the file name to appease ancient technology.
-			//
-			// tar assumes anything with a colon is a remote tape drive:
-			// https://www.gnu.org/software/tar/manual/html_section/tar_45.html
-			// Drop the algorithm prefix, e.g. "sha256:"
-			hex := d.Hex
+			desc.Layers[i] = layerFileName(d)
 
-			// gunzip expects certain file extensions:
-			// https://www.gnu.org/software/gzip/manual/html_node/Overview.html
-			layerFiles[i] = fmt.Sprintf("%s.tar.gz", hex)
-
-			// Add to LayerSources if it's a foreign layer.
-			desc, err := partial.BlobDescriptor(img, d)
+			blobSize, err := l.Size()
 			if err != nil {
-				return nil, err
+				return sendProgressWriterReturn(pw, err)
+			}
+			mt, err := l.MediaType()
+			if err != nil {
+				return sendProgressWriterReturn(pw, err)
 			}
-			if !desc.MediaType.IsDistributable() {
-				diffid, err := partial.BlobToDiffID(img, d)
+			if !mt.IsDistributable() {
+				// Store foreign layer info.
+				diffID, err := l.DiffID()
+				if err != nil {
+					return sendProgressWriterReturn(pw, err)
+				}
+				ld, err := partial.Descriptor(l)
 				if err != nil {
-					return nil, err
+					return sendProgressWriterReturn(pw, err)
 				}
-				layerSources[diffid] = *desc
+				desc.LayerSources[diffID] = *ld
+			}
+
+			if _, ok := seenLayerDigests[d]; ok {
+				continue
+			}
+			seenLayerDigests[d] = struct{}{}
+
+			r, err := l.Compressed()
+			if err != nil {
+				return sendProgressWriterReturn(pw, err)
+			}
+			grow(blobSize)
+			err = writeTarEntry(tf, desc.Layers[i], r, blobSize)
+			r.Close()
+			if err != nil {
+				return sendProgressWriterReturn(pw, err)
 			}
 		}
+		m = append(m, desc)
+	}
 
-		// Generate the tar descriptor and write it.
-		m = append(m, Descriptor{
-			Config:       cfgName.String(),
-			RepoTags:     tags,
-			Layers:       layerFiles,
-			LayerSources: layerSources,
-		})
+	sortManifest(m)
+	mBytes, err := json.Marshal(m)
+	if err != nil {
+		return sendProgressWriterReturn(pw, fmt.Errorf("could not marshall manifest to bytes: %w", err))
+	}
+	// Account for the manifest and the two padding blocks that indicate the
+	// end of a tar file.
+	grow(int64(len(mBytes)))
+	if pw != nil {
+		pw.size += 1024
+	}
+	if err := writeTarEntry(tf, "manifest.json", bytes.NewReader(mBytes), int64(len(mBytes))); err != nil {
+		return sendProgressWriterReturn(pw, err)
+	}
+
+	// be sure to close the tar writer so everything is flushed out before we send our EOF
+	if err := tf.Close(); err != nil {
+		return sendProgressWriterReturn(pw, err)
+	}
+	// send an EOF to indicate finished on the channel, but nil as our return error
+	_ = sendProgressWriterReturn(pw, io.EOF)
+	return nil
+}
+
+// calculateManifest calculates the manifest and optionally the size of the tar file
+func calculateManifest(refToImage map[name.Reference]v1.Image) (m Manifest, err error) {
+	imageToTags := dedupRefToImage(refToImage)
+
+	if len(imageToTags) == 0 {
+		return nil, errors.New("set of images is empty")
+	}
+
+	for img, tags := range imageToTags {
+		desc, err := manifestEntry(img, tags)
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, *desc)
 	}
-	// sort by name of the repotags so it is consistent. Alternatively, we could sort by hash of the
-	// descriptor, but that would make it hard for humans to process
+	sortManifest(m)
+
+	return m, nil
+}
+
+// sortManifest sorts by name of the repotags so it is consistent.
+// Alternatively, we could sort by hash of the descriptor, but that would make
+// it hard for humans to process.
+func sortManifest(m Manifest) {
 	sort.Slice(m, func(i, j int) bool {
 		return strings.Join(m[i].RepoTags, ",") < strings.Join(m[j].RepoTags, ",")
 	})
+}
 
-	return m, nil
+// manifestEntry computes the manifest.json Descriptor for img.
+func manifestEntry(img v1.Image, tags []string) (*Descriptor, error) {
+	cfgName, err := img.ConfigName()
+	if err != nil {
+		return nil, err
+	}
+
+	// Store foreign layer info.
+	layerSources := make(map[v1.Hash]v1.Descriptor)
+
+	// Write the layers.
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+	layerFiles := make([]string, len(layers))
+	for i, l := range layers {
+		d, err := l.Digest()
+		if err != nil {
+			return nil, err
+		}
+		layerFiles[i] = layerFileName(d)
+
+		// Add to LayerSources if it's a foreign layer.
+		desc, err := partial.BlobDescriptor(img, d)
+		if err != nil {
+			return nil, err
+		}
+		if !desc.MediaType.IsDistributable() {
+			diffid, err := partial.BlobToDiffID(img, d)
+			if err != nil {
+				return nil, err
+			}
+			layerSources[diffid] = *desc
+		}
+	}
+
+	return &Descriptor{
+		Config:       cfgName.String(),
+		RepoTags:     tags,
+		Layers:       layerFiles,
+		LayerSources: layerSources,
+	}, nil
+}
+
+// layerFileName returns the name of the file within the tarball for the layer
+// with digest d.
+func layerFileName(d v1.Hash) string {
+	// Munge the file name to appease ancient technology.
+	//
+	// tar assumes anything with a colon is a remote tape drive:
+	// https://www.gnu.org/software/tar/manual/html_section/tar_45.html
+	// Drop the algorithm prefix, e.g. "sha256:"
+	//
+	// gunzip expects certain file extensions:
+	// https://www.gnu.org/software/gzip/manual/html_node/Overview.html
+	return fmt.Sprintf("%s.tar.gz", d.Hex)
 }
 
 // CalculateSize calculates the expected complete size of the output tar file
@@ -392,7 +540,8 @@ func ComputeManifest(refToImage map[name.Reference]v1.Image) (Manifest, error) {
 // WriteOption a function option to pass to Write()
 type WriteOption func(*writeOptions) error
 type writeOptions struct {
-	updates chan<- v1.Update
+	updates   chan<- v1.Update
+	streaming bool
 }
 
 // WithProgress create a WriteOption for passing to Write() that enables
@@ -404,6 +553,20 @@ func WithProgress(updates chan<- v1.Update) WriteOption {
 	}
 }
 
+// WithStreaming create a WriteOption for passing to Write() that writes each
+// image's config and layers as they are read, rather than computing the
+// manifest.json (and therefore every layer's digest) for all images up front.
+// Layers shared by multiple images are still only written once.
+//
+// Since the size of the tarball isn't known ahead of time, progress updates
+// report a Total that grows as each entry is written.
+func WithStreaming() WriteOption {
+	return func(o *writeOptions) error {
+		o.streaming = true
+		return nil
+	}
+}
+
 // progressWriter is a writer which will send the download progress
 type progressWriter struct {
 	w              io.Writer
@@ -274,6 +274,22 @@ func TestWriteForeignLayers(t *testing.T) {
 }
 
 func TestWriteSharedLayers(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []tarball.WriteOption
+	}{{
+		name: "buffered",
+	}, {
+		name: "streaming",
+		opts: []tarball.WriteOption{tarball.WithStreaming()},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			testWriteSharedLayers(t, tc.opts...)
+		})
+	}
+}
+
+func testWriteSharedLayers(t *testing.T, opts ...tarball.WriteOption) {
 	// Make a tempfile for tarball writes.
 	fp, err := os.CreateTemp("", "")
 	if err != nil {
@@ -311,7 +327,7 @@ func TestWriteSharedLayers(t *testing.T) {
 	refToImage[tag2] = mutatedImage
 
 	// Write the images with both tags to the tarball
-	if err := tarball.MultiRefWriteToFile(fp.Name(), refToImage); err != nil {
+	if err := tarball.MultiRefWriteToFile(fp.Name(), refToImage, opts...); err != nil {
 		t.Fatalf("Unexpected error writing tarball: %v", err)
 	}
 	for ref := range refToImage {
@@ -353,6 +369,7 @@ func TestWriteSharedLayers(t *testing.T) {
 
 	const layerFileSuffix = ".tar.gz"
 	r := tar.NewReader(fp)
+	seen := map[string]bool{}
 	for {
 		hdr, err := r.Next()
 		if err != nil {
@@ -361,6 +378,10 @@ func TestWriteSharedLayers(t *testing.T) {
 			}
 			t.Fatalf("Get tar header: %v", err)
 		}
+		if seen[hdr.Name] {
+			t.Errorf("Found duplicate entry %q", hdr.Name)
+		}
+		seen[hdr.Name] = true
 		if strings.HasSuffix(hdr.Name, layerFileSuffix) {
 			hex := hdr.Name[:len(hdr.Name)-len(layerFileSuffix)]
 			if _, ok := wantDigests[hex]; ok {
@@ -500,3 +521,117 @@ func getLayersFilenames(hashes []string) []string {
 	}
 	return filenames
 }
+
+func TestWriteStreamingProgress(t *testing.T) {
+	img, err := random.Image(256, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := name.NewTag("gcr.io/foo/bar:latest", name.StrictValidation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := tarball.CalculateSize(map[name.Reference]v1.Image{tag: img})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	updates := make(chan v1.Update, 1000)
+	if err := tarball.Write(tag, img, &buf, tarball.WithStreaming(), tarball.WithProgress(updates)); err != nil {
+		t.Fatal(err)
+	}
+	close(updates)
+
+	var last v1.Update
+	for update := range updates {
+		last = update
+	}
+	if !errors.Is(last.Error, io.EOF) {
+		t.Errorf("last update error = %v, want io.EOF", last.Error)
+	}
+	if last.Total != want || last.Complete != want {
+		t.Errorf("last update = %+v, want Total = Complete = %d", last, want)
+	}
+	if got := int64(buf.Len()); got != want {
+		t.Errorf("wrote %d bytes, want %d", got, want)
+	}
+
+	tarImage, err := tarball.Image(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+	}, &tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := compare.Images(img, tarImage); err != nil {
+		t.Errorf("compare.Images: %v", err)
+	}
+}
+
+// digestErrLayer is a layer whose digest can't be computed.
+type digestErrLayer struct {
+	v1.Layer
+}
+
+func (digestErrLayer) Digest() (v1.Hash, error) {
+	return v1.Hash{}, errors.New("digest unavailable")
+}
+
+// lastLayerErrImage replaces the last layer of an image with a
+// digestErrLayer.
+type lastLayerErrImage struct {
+	v1.Image
+}
+
+func (i lastLayerErrImage) Layers() ([]v1.Layer, error) {
+	ls, err := i.Image.Layers()
+	if err != nil {
+		return nil, err
+	}
+	ls[len(ls)-1] = digestErrLayer{ls[len(ls)-1]}
+	return ls, nil
+}
+
+func TestWriteStreamingWritesLayersBeforeLaterDigests(t *testing.T) {
+	img, err := random.Image(256, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := layers[0].Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := name.NewTag("gcr.io/foo/bar:latest", name.StrictValidation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first layer is written before the second layer's digest is
+	// needed, so it's in the output even though writing fails.
+	var buf bytes.Buffer
+	if err := tarball.Write(tag, lastLayerErrImage{img}, &buf, tarball.WithStreaming()); err == nil {
+		t.Fatal("Write() = nil, wanted error")
+	}
+	tr := tar.NewReader(&buf)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, hdr.Name)
+	}
+	want := first.Hex + ".tar.gz"
+	found := false
+	for _, n := range names {
+		found = found || n == want
+	}
+	if !found {
+		t.Errorf("tarball entries = %v, want %s", names, want)
+	}
+}
@@ -27,8 +27,10 @@ const (
 	OCILayer                       MediaType = "application/vnd.oci.image.layer.v1.tar+gzip"
 	OCILayerZStd                   MediaType = "application/vnd.oci.image.layer.v1.tar+zstd"
 	OCIRestrictedLayer             MediaType = "application/vnd.oci.image.layer.nondistributable.v1.tar+gzip"
+	OCIRestrictedLayerZStd         MediaType = "application/vnd.oci.image.layer.nondistributable.v1.tar+zstd"
 	OCIUncompressedLayer           MediaType = "application/vnd.oci.image.layer.v1.tar"
 	OCIUncompressedRestrictedLayer MediaType = "application/vnd.oci.image.layer.nondistributable.v1.tar"
+	OCIEmptyJSON                   MediaType = "application/vnd.oci.empty.v1+json"
 
 	DockerManifestSchema1       MediaType = "application/vnd.docker.distribution.manifest.v1+json"
 	DockerManifestSchema1Signed MediaType = "application/vnd.docker.distribution.manifest.v1+prettyjws"
@@ -48,7 +50,7 @@ const (
 // https://github.com/opencontainers/image-spec/blob/master/layer.md#non-distributable-layers
 func (m MediaType) IsDistributable() bool {
 	switch m {
-	case DockerForeignLayer, OCIRestrictedLayer, OCIUncompressedRestrictedLayer:
+	case DockerForeignLayer, OCIRestrictedLayer, OCIRestrictedLayerZStd, OCIUncompressedRestrictedLayer:
 		return false
 	}
 	return true
@@ -71,3 +73,12 @@ func (m MediaType) IsIndex() bool {
 	}
 	return false
 }
+
+// IsSchema1 returns true if the mediaType represents a Docker schema 1 manifest.
+func (m MediaType) IsSchema1() bool {
+	switch m {
+	case DockerManifestSchema1, DockerManifestSchema1Signed:
+		return true
+	}
+	return false
+}
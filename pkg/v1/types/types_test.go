@@ -19,6 +19,7 @@ import "testing"
 func TestIsDistributable(t *testing.T) {
 	for _, mt := range []MediaType{
 		OCIRestrictedLayer,
+		OCIRestrictedLayerZStd,
 		OCIUncompressedRestrictedLayer,
 		DockerForeignLayer,
 	} {
@@ -110,3 +111,25 @@ func TestIsIndex(t *testing.T) {
 		}
 	}
 }
+
+func TestIsSchema1(t *testing.T) {
+	for _, mt := range []MediaType{
+		DockerManifestSchema1, DockerManifestSchema1Signed,
+	} {
+		if !mt.IsSchema1() {
+			t.Errorf("%s: should be schema 1", mt)
+		}
+	}
+
+	for _, mt := range []MediaType{
+		OCIImageIndex,
+		OCIManifestSchema1,
+
+		DockerManifestList,
+		DockerManifestSchema2,
+	} {
+		if mt.IsSchema1() {
+			t.Errorf("%s: should not be schema 1", mt)
+		}
+	}
+}
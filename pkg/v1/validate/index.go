@@ -27,6 +27,10 @@ import (
 )
 
 // Index validates that idx does not violate any invariants of the index format.
+//
+// This recursively validates every child image and index, including that each
+// child's digest, size and media type match its descriptor in the index.
+// Failures identify the child by its index, digest and platform (if any).
 func Index(idx v1.ImageIndex, opt ...Option) error {
 	errs := []string{}
 
@@ -63,10 +67,13 @@ func validateChildren(idx v1.ImageIndex, opt ...Option) error {
 				return err
 			}
 			if err := Index(idx, opt...); err != nil {
-				errs = append(errs, fmt.Sprintf("failed to validate index Manifests[%d](%s): %v", i, desc.Digest, err))
+				errs = append(errs, fmt.Sprintf("failed to validate index Manifests[%d](%s): %v", i, childName(desc), err))
 			}
 			if err := validateMediaType(idx, desc.MediaType); err != nil {
-				errs = append(errs, fmt.Sprintf("failed to validate index MediaType[%d](%s): %v", i, desc.Digest, err))
+				errs = append(errs, fmt.Sprintf("failed to validate index MediaType[%d](%s): %v", i, childName(desc), err))
+			}
+			if err := validateDescriptor(idx, desc); err != nil {
+				errs = append(errs, fmt.Sprintf("failed to validate index descriptor Manifests[%d](%s): %v", i, childName(desc), err))
 			}
 		case types.OCIManifestSchema1, types.DockerManifestSchema2:
 			img, err := idx.Image(desc.Digest)
@@ -74,10 +81,13 @@ func validateChildren(idx v1.ImageIndex, opt ...Option) error {
 				return err
 			}
 			if err := Image(img, opt...); err != nil {
-				errs = append(errs, fmt.Sprintf("failed to validate image Manifests[%d](%s): %v", i, desc.Digest, err))
+				errs = append(errs, fmt.Sprintf("failed to validate image Manifests[%d](%s): %v", i, childName(desc), err))
 			}
 			if err := validateMediaType(img, desc.MediaType); err != nil {
-				errs = append(errs, fmt.Sprintf("failed to validate image MediaType[%d](%s): %v", i, desc.Digest, err))
+				errs = append(errs, fmt.Sprintf("failed to validate image MediaType[%d](%s): %v", i, childName(desc), err))
+			}
+			if err := validateDescriptor(img, desc); err != nil {
+				errs = append(errs, fmt.Sprintf("failed to validate image descriptor Manifests[%d](%s): %v", i, childName(desc), err))
 			}
 		default:
 			// Workaround for #819.
@@ -107,6 +117,45 @@ func validateChildren(idx v1.ImageIndex, opt ...Option) error {
 	return nil
 }
 
+// childName identifies a child of an index in error messages.
+func childName(desc v1.Descriptor) string {
+	if desc.Platform == nil {
+		return desc.Digest.String()
+	}
+	return fmt.Sprintf("%s, %s", desc.Digest, desc.Platform)
+}
+
+type withDigestAndSize interface {
+	Digest() (v1.Hash, error)
+	Size() (int64, error)
+}
+
+// validateDescriptor checks that the fetched child's content matches the
+// descriptor that the index uses to reference it.
+func validateDescriptor(child withDigestAndSize, desc v1.Descriptor) error {
+	digest, err := child.Digest()
+	if err != nil {
+		return err
+	}
+	size, err := child.Size()
+	if err != nil {
+		return err
+	}
+
+	errs := []string{}
+	if digest != desc.Digest {
+		errs = append(errs, fmt.Sprintf("mismatched digest: Digest()=%s, Descriptor.Digest=%s", digest, desc.Digest))
+	}
+	if size != desc.Size {
+		errs = append(errs, fmt.Sprintf("mismatched size: Size()=%d, Descriptor.Size=%d", size, desc.Size))
+	}
+
+	if len(errs) != 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
 type withMediaType interface {
 	MediaType() (types.MediaType, error)
 }
@@ -0,0 +1,187 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package verify provides a v1.Image wrapper that checks content against the
+// digests in its manifest and config as it is read.
+package verify
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/google/go-containerregistry/internal/verify"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Image wraps img so that every blob read through it is checked against the
+// digests in img's manifest (and, for uncompressed layers, the diff IDs in its
+// config). Layer contents are hashed incrementally as they are read, and a
+// mismatch is returned as an error from the final Read.
+//
+// The manifest itself is returned as-is, so callers that don't trust its source
+// should check img's digest separately.
+func Image(img v1.Image) v1.Image {
+	return &image{Image: img}
+}
+
+type image struct {
+	v1.Image
+}
+
+// RawConfigFile implements v1.Image
+func (i *image) RawConfigFile() ([]byte, error) {
+	m, err := i.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	b, err := i.Image.RawConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	h, size, err := v1.SHA256(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	if h != m.Config.Digest {
+		return nil, fmt.Errorf("error verifying config; got digest %s, want %s", h, m.Config.Digest)
+	}
+	if size != m.Config.Size {
+		return nil, fmt.Errorf("error verifying config; got size %d, want %d", size, m.Config.Size)
+	}
+	return b, nil
+}
+
+// ConfigFile implements v1.Image
+func (i *image) ConfigFile() (*v1.ConfigFile, error) {
+	b, err := i.RawConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	return v1.ParseConfigFile(bytes.NewReader(b))
+}
+
+// Layers implements v1.Image
+func (i *image) Layers() ([]v1.Layer, error) {
+	m, err := i.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	cf, err := i.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	ls, err := i.Image.Layers()
+	if err != nil {
+		return nil, err
+	}
+	if len(ls) != len(m.Layers) {
+		return nil, fmt.Errorf("image has %d layers but its manifest lists %d", len(ls), len(m.Layers))
+	}
+	if len(cf.RootFS.DiffIDs) != len(m.Layers) {
+		return nil, fmt.Errorf("manifest lists %d layers but config lists %d diff IDs", len(m.Layers), len(cf.RootFS.DiffIDs))
+	}
+	for j, l := range ls {
+		ls[j] = &layer{
+			Layer:  l,
+			desc:   m.Layers[j],
+			diffID: cf.RootFS.DiffIDs[j],
+		}
+	}
+	return ls, nil
+}
+
+// LayerByDigest implements v1.Image
+func (i *image) LayerByDigest(h v1.Hash) (v1.Layer, error) {
+	m, err := i.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	l, err := i.Image.LayerByDigest(h)
+	if err != nil {
+		return nil, err
+	}
+	if h == m.Config.Digest {
+		// The config is its own diff ID.
+		return &layer{Layer: l, desc: m.Config, diffID: h}, nil
+	}
+	cf, err := i.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	for j, desc := range m.Layers {
+		if desc.Digest == h && j < len(cf.RootFS.DiffIDs) {
+			return &layer{Layer: l, desc: desc, diffID: cf.RootFS.DiffIDs[j]}, nil
+		}
+	}
+	return nil, fmt.Errorf("layer %s not found in manifest", h)
+}
+
+// LayerByDiffID implements v1.Image
+func (i *image) LayerByDiffID(h v1.Hash) (v1.Layer, error) {
+	m, err := i.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	cf, err := i.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	for j, diffID := range cf.RootFS.DiffIDs {
+		if diffID == h && j < len(m.Layers) {
+			return i.LayerByDigest(m.Layers[j].Digest)
+		}
+	}
+	return nil, fmt.Errorf("layer with diff ID %s not found in config", h)
+}
+
+// layer verifies its contents against the digests it was listed with.
+type layer struct {
+	v1.Layer
+	desc   v1.Descriptor
+	diffID v1.Hash
+}
+
+// Digest implements v1.Layer
+func (l *layer) Digest() (v1.Hash, error) {
+	return l.desc.Digest, nil
+}
+
+// DiffID implements v1.Layer
+func (l *layer) DiffID() (v1.Hash, error) {
+	return l.diffID, nil
+}
+
+// Size implements v1.Layer
+func (l *layer) Size() (int64, error) {
+	return l.desc.Size, nil
+}
+
+// Compressed implements v1.Layer
+func (l *layer) Compressed() (io.ReadCloser, error) {
+	rc, err := l.Layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	return verify.ReadCloser(rc, l.desc.Size, l.desc.Digest)
+}
+
+// Uncompressed implements v1.Layer
+func (l *layer) Uncompressed() (io.ReadCloser, error) {
+	rc, err := l.Layer.Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	return verify.ReadCloser(rc, verify.SizeUnknown, l.diffID)
+}
@@ -0,0 +1,144 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verify_test
+
+import (
+	"io"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+	"github.com/google/go-containerregistry/pkg/v1/verify"
+)
+
+// tampered serves other content in place of its layers and config, while
+// keeping the original manifest.
+type tampered struct {
+	v1.Image
+	other v1.Image
+}
+
+func (t *tampered) Layers() ([]v1.Layer, error) {
+	ls, err := t.Image.Layers()
+	if err != nil {
+		return nil, err
+	}
+	others, err := t.other.Layers()
+	if err != nil {
+		return nil, err
+	}
+	for i := range ls {
+		ls[i] = &swapped{Layer: ls[i], other: others[i]}
+	}
+	return ls, nil
+}
+
+func (t *tampered) RawConfigFile() ([]byte, error) {
+	return t.other.RawConfigFile()
+}
+
+type swapped struct {
+	v1.Layer
+	other v1.Layer
+}
+
+func (s *swapped) Compressed() (io.ReadCloser, error) {
+	return s.other.Compressed()
+}
+
+func (s *swapped) Uncompressed() (io.ReadCloser, error) {
+	return s.other.Uncompressed()
+}
+
+func TestImage(t *testing.T) {
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Image(verify.Image(img)); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+
+	got, err := verify.Image(img).RawManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := img.RawManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("RawManifest(); got %s, want %s", got, want)
+	}
+}
+
+func TestImageTampered(t *testing.T) {
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vimg := verify.Image(&tampered{Image: img, other: other})
+
+	if _, err := vimg.RawConfigFile(); err == nil {
+		t.Error("RawConfigFile() = nil, wanted error")
+	}
+	if _, err := vimg.ConfigFile(); err == nil {
+		t.Error("ConfigFile() = nil, wanted error")
+	}
+
+	if err := validate.Image(vimg); err == nil {
+		t.Error("validate.Image() = nil, wanted error")
+	}
+
+	// Keep the original config, since the tampered one is rejected before
+	// we get to the layers.
+	ls, err := verify.Image(&layersOnly{tampered: tampered{Image: img, other: other}}).Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, l := range ls {
+		for name, open := range map[string]func() (io.ReadCloser, error){
+			"Compressed":   l.Compressed,
+			"Uncompressed": l.Uncompressed,
+		} {
+			rc, err := open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := io.ReadAll(rc); err == nil {
+				t.Errorf("layer %d: reading %s: got nil, wanted error", i, name)
+			}
+			rc.Close()
+		}
+	}
+}
+
+// layersOnly only tampers with layers.
+type layersOnly struct {
+	tampered
+}
+
+func (l *layersOnly) RawConfigFile() ([]byte, error) {
+	return l.Image.RawConfigFile()
+}
+
+func (l *layersOnly) ConfigFile() (*v1.ConfigFile, error) {
+	return l.Image.ConfigFile()
+}